@@ -1,28 +1,53 @@
-// 3af-exec 最小 Node Agent（Story 8.2）：exec 前调用 3AF ExecAuth，allow 则执行命令，deny 则退出。
+// 3af-exec 最小 Node Agent（Story 8.2）：exec 前经 AuthStream（/auth/stream）鉴权，allow 则执行命令，deny 则退出。
+// review 时打印 CHEQ id 与审批状态并保持心跳等待 approval_push（--max-wait / DITING_3AF_MAX_WAIT，默认以服务端审批时限为准），
+// 等待中 Ctrl-C 或超时撤回 CHEQ。
 // 用法: 3af-exec [选项] -- <命令...>  或  3af-exec <命令...>
-// 环境: DITING_3AF_URL（默认 http://localhost:8080）, DITING_AGENT_TOKEN（L0 身份）, DITING_SUBJECT（默认 $USER）
+// 默认经 Unix 套接字（DITING_3AF_SOCKET，默认 /run/diting/3af.sock）连接，身份由服务端取自 SO_PEERCRED；
+// 指定 --url 或 DITING_3AF_URL 时改用 HTTP，套接字不存在时回退到 http://localhost:8080。
+// 启动时按 version 拉取并在本地缓存 SandboxProfile：命中 Hot Cache 的命令本地直接执行，记录待下次连通时批量补录审计；
+// Diting 不可达时按 profile 的 degradation_policy 处理（FAIL_OPEN 执行并补录，FAIL_CLOSE 或无缓存 profile 时拒绝）。
+// 环境: DITING_3AF_SOCKET, DITING_3AF_URL, DITING_AGENT_TOKEN（L0 身份）, DITING_SUBJECT（默认 $USER，经套接字时服务端不采信）,
+// DITING_3AF_CACHE_DIR（profile 与待补录记录的缓存目录，默认用户缓存目录下的 diting-3af）, DITING_3AF_MAX_WAIT（等待审批的最长时间）
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"net/http"
+
+	"diting/internal/hotcache"
 )
 
+// defaultSocketPath 3AF Unix 套接字的默认路径。
+const defaultSocketPath = "/run/diting/3af.sock"
+
 func main() {
 	args := os.Args[1:]
 	if len(args) == 0 {
-		fmt.Fprintf(os.Stderr, "用法: 3af-exec [--url URL] [--token TOKEN] [--subject SUBJECT] -- <命令...>\n")
+		fmt.Fprintf(os.Stderr, "用法: 3af-exec [--socket PATH | --url URL] [--token TOKEN] [--subject SUBJECT] [--cache-dir DIR] [--max-wait DURATION] -- <命令...>\n")
 		os.Exit(2)
 	}
 	baseURL := os.Getenv("DITING_3AF_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8080"
+	socketPath := os.Getenv("DITING_3AF_SOCKET")
+	if socketPath == "" {
+		socketPath = defaultSocketPath
+	}
+	var maxWait time.Duration
+	if v := os.Getenv("DITING_3AF_MAX_WAIT"); v != "" {
+		maxWait = parseMaxWait(v)
+	}
+	cacheDir := os.Getenv("DITING_3AF_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = defaultCacheDir()
 	}
 	token := os.Getenv("DITING_AGENT_TOKEN")
 	subject := os.Getenv("DITING_SUBJECT")
@@ -45,6 +70,13 @@ func main() {
 			}
 			baseURL = args[1]
 			args = args[2:]
+		case "--socket":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "缺少 --socket 参数值\n")
+				os.Exit(2)
+			}
+			socketPath, baseURL = args[1], ""
+			args = args[2:]
 		case "--token":
 			if len(args) < 2 {
 				fmt.Fprintf(os.Stderr, "缺少 --token 参数值\n")
@@ -59,6 +91,20 @@ func main() {
 			}
 			subject = args[1]
 			args = args[2:]
+		case "--max-wait":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "缺少 --max-wait 参数值\n")
+				os.Exit(2)
+			}
+			maxWait = parseMaxWait(args[1])
+			args = args[2:]
+		case "--cache-dir":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "缺少 --cache-dir 参数值\n")
+				os.Exit(2)
+			}
+			cacheDir = args[1]
+			args = args[2:]
 		default:
 			goto run
 		}
@@ -76,49 +122,176 @@ run:
 	hostname, _ := os.Hostname()
 	resource := "local://" + hostname
 
-	reqBody := map[string]interface{}{
-		"subject":      subject,
-		"action":       action,
-		"resource":     resource,
-		"command_line": commandLine,
+	client := http.DefaultClient
+	if baseURL == "" {
+		if _, err := os.Stat(socketPath); err == nil {
+			client = unixClient(socketPath)
+			baseURL = "http://unix"
+		} else {
+			baseURL = "http://localhost:8080"
+		}
+	}
+	c := &agentClient{http: client, baseURL: baseURL, token: token}
+	cache := &localCache{dir: cacheDir}
+	profile, err := c.syncProfile(cache, resource)
+	reachable := !errors.Is(err, errUnreachable)
+	if reachable {
+		c.flushAudit(cache, subject, resource)
+	}
+	if profile != nil && hotcache.Allows(profile.HotCacheActions, args) {
+		os.Exit(runLocal(cache, profile, "hot_cache", action, args))
+	}
+	if !reachable {
+		degrade(cache, profile, action, args, err)
+	}
+
+	st, err := c.dialStream(cache, resource)
+	if errors.Is(err, errUnreachable) {
+		degrade(cache, profile, action, args, err)
 	}
-	body, _ := json.Marshal(reqBody)
-	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/auth/exec", bytes.NewReader(body))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "3af-exec: %v\n", err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	if token != "" {
-		req.Header.Set("X-Agent-Token", token)
-	}
-	resp, err := http.DefaultClient.Do(req)
+	// 等待审批期间 Ctrl-C / SIGTERM 撤回 CHEQ；取得结果后恢复默认信号处理，由被执行的命令自行响应
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	result, err := st.authorize(ctx, cache, map[string]interface{}{
+		"subject":      subject,
+		"action":       action,
+		"resource":     resource,
+		"command_line": commandLine,
+	}, resource, maxWait)
+	cancelled := ctx.Err() != nil
+	stop()
+	st.close()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "3af-exec: 请求 3AF 失败: %v\n", err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-	var result struct {
-		Decision     string `json:"decision"`
-		PolicyRuleID string `json:"policy_rule_id,omitempty"`
-		Reason       string `json:"reason,omitempty"`
-		CheqID       string `json:"cheq_id,omitempty"`
+	if cancelled {
+		fmt.Fprintf(os.Stderr, "3af-exec: 已取消 (cheq_id=%s) %s\n", result.CheqID, result.Reason)
+		os.Exit(130)
 	}
-	_ = json.NewDecoder(resp.Body).Decode(&result)
 	if result.Decision == "allow" {
-		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				os.Exit(exitErr.ExitCode())
+		if result.AmendedCommandLine != "" {
+			// 审批人改后批准：执行改写后的命令
+			amended, err := splitCommandLine(result.AmendedCommandLine)
+			if err != nil || len(amended) == 0 {
+				fmt.Fprintf(os.Stderr, "3af-exec: 无法解析审批人改写的命令 %q: %v\n", result.AmendedCommandLine, err)
+				os.Exit(1)
 			}
-			fmt.Fprintf(os.Stderr, "3af-exec: 执行失败: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "3af-exec: 审批人已修改命令 (cheq_id=%s)，执行: %s\n", result.CheqID, result.AmendedCommandLine)
+			args = amended
 		}
-		return
+		os.Exit(runCommand(args))
 	}
 	fmt.Fprintf(os.Stderr, "3af-exec: 拒绝执行 (%s) %s\n", result.PolicyRuleID, result.Reason)
 	os.Exit(1)
 }
+
+// parseMaxWait 解析等待审批的最长时间：秒数或 time.Duration 格式（如 90、5m）；非法值退出。
+func parseMaxWait(v string) time.Duration {
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		fmt.Fprintf(os.Stderr, "无效的等待时间 %q\n", v)
+		os.Exit(2)
+	}
+	return d
+}
+
+// runCommand 执行命令并返回退出码；无法启动时返回 1。
+func runCommand(args []string) int {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "3af-exec: 执行失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runLocal 未经 Diting 实时鉴权执行命令（kind 为 hot_cache 或 fail_open），并记录待补录审计；返回退出码。
+func runLocal(cache *localCache, profile *sandboxProfile, kind, action string, args []string) int {
+	rec := &auditRecord{
+		Kind:        kind,
+		Action:      action,
+		Argv:        args,
+		CommandLine: strings.Join(args, " "),
+		ExecutedAt:  time.Now(),
+	}
+	if profile != nil {
+		rec.ProfileID, rec.ProfileVersion = profile.ProfileID, profile.Version
+	}
+	rec.ExitCode = runCommand(args)
+	if err := cache.spool(rec); err != nil {
+		fmt.Fprintf(os.Stderr, "3af-exec: 记录本地执行失败: %v\n", err)
+	}
+	return rec.ExitCode
+}
+
+// degrade 在 Diting 不可达时按 profile 的 degradation_policy 处理后退出：FAIL_OPEN 执行命令并记录待补录，否则拒绝。
+func degrade(cache *localCache, profile *sandboxProfile, action string, args []string, cause error) {
+	if profile.failOpen() {
+		fmt.Fprintf(os.Stderr, "%v；profile %s 为 FAIL_OPEN，直接执行并待补录审计\n", cause, profile.ProfileID)
+		os.Exit(runLocal(cache, profile, "fail_open", action, args))
+	}
+	fmt.Fprintf(os.Stderr, "%v；按 FAIL_CLOSE 拒绝执行\n", cause)
+	os.Exit(1)
+}
+
+// unixClient 返回经 Unix 套接字 path 发送请求的 HTTP 客户端（URL 中的主机名被忽略）。
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{DialContext: unixDialer(path)}}
+}
+
+// splitCommandLine 按空白切分命令行，支持单引号、双引号与反斜杠转义（不做变量展开等 shell 语义）。
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
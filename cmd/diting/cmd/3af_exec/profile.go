@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"diting/internal/hotcache"
+)
+
+const (
+	// profileTimeout 拉取 SandboxProfile 与上报补录的超时；超时视为 Diting 不可达。
+	profileTimeout = 3 * time.Second
+	// auditBatchSize 每次上报的补录条数。
+	auditBatchSize = 200
+)
+
+// errUnreachable Diting 不可达（连接失败、超时或 5xx）。
+var errUnreachable = errors.New("3af-exec: diting unreachable")
+
+// sandboxProfile 与服务端 /auth/sandbox-profile 返回的 JSON 对齐，仅解析本地需要的字段。
+type sandboxProfile struct {
+	ProfileID         string            `json:"profile_id"`
+	Version           string            `json:"version"`
+	HotCacheActions   []hotcache.Action `json:"hot_cache_actions,omitempty"`
+	DegradationPolicy string            `json:"degradation_policy,omitempty"` // FAIL_OPEN | FAIL_CLOSE
+}
+
+// failOpen 返回 Diting 不可达时是否放行；未取得 profile 时按 FAIL_CLOSE。
+func (p *sandboxProfile) failOpen() bool {
+	return p != nil && p.DegradationPolicy == "FAIL_OPEN"
+}
+
+// auditRecord 本地执行记录，与服务端 POST /auth/exec/audit 的 records 对齐。
+type auditRecord struct {
+	Kind           string    `json:"kind"` // hot_cache | fail_open
+	TraceID        string    `json:"trace_id,omitempty"`
+	Action         string    `json:"action"`
+	Argv           []string  `json:"argv"`
+	CommandLine    string    `json:"command_line,omitempty"`
+	ProfileID      string    `json:"profile_id,omitempty"`
+	ProfileVersion string    `json:"profile_version,omitempty"`
+	ExecutedAt     time.Time `json:"executed_at"`
+	ExitCode       int       `json:"exit_code"`
+}
+
+// localCache 本地缓存目录：profile.json 为最近一次拉取的 profile，audit.jsonl 为待上报的本地执行记录。
+type localCache struct {
+	dir string
+}
+
+// defaultCacheDir 返回默认缓存目录（用户缓存目录下的 diting-3af）。
+func defaultCacheDir() string {
+	if d, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(d, "diting-3af")
+	}
+	return filepath.Join(os.TempDir(), "diting-3af")
+}
+
+type cachedProfile struct {
+	Resource string         `json:"resource"`
+	Profile  sandboxProfile `json:"profile"`
+}
+
+// loadProfile 返回 resource 的缓存 profile；无缓存或缓存属于其他 resource 时返回 nil。
+func (c *localCache) loadProfile(resource string) *sandboxProfile {
+	data, err := os.ReadFile(filepath.Join(c.dir, "profile.json"))
+	if err != nil {
+		return nil
+	}
+	var cp cachedProfile
+	if json.Unmarshal(data, &cp) != nil || cp.Resource != resource {
+		return nil
+	}
+	return &cp.Profile
+}
+
+// saveProfile 原子写入缓存 profile。
+func (c *localCache) saveProfile(resource string, p *sandboxProfile) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(cachedProfile{Resource: resource, Profile: *p})
+	tmp := filepath.Join(c.dir, "profile.json."+strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(c.dir, "profile.json"))
+}
+
+// spool 追加一条待上报的本地执行记录。
+func (c *localCache) spool(rec *auditRecord) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(c.dir, "audit.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, _ := json.Marshal(rec)
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// takeSpool 取走全部待上报记录：先将 audit.jsonl 改名再读取，并发执行的 3af-exec 之后的记录写入新文件。
+func (c *localCache) takeSpool() ([]auditRecord, error) {
+	path := filepath.Join(c.dir, "audit.jsonl")
+	taken := path + "." + strconv.Itoa(os.Getpid())
+	if err := os.Rename(path, taken); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer os.Remove(taken)
+	f, err := os.Open(taken)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []auditRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var rec auditRecord
+		if json.Unmarshal(sc.Bytes(), &rec) == nil {
+			out = append(out, rec)
+		}
+	}
+	return out, sc.Err()
+}
+
+// agentClient 调用 Diting 执行层接口。
+type agentClient struct {
+	http    *http.Client
+	baseURL string
+	token   string
+}
+
+func (c *agentClient) newRequest(method, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.baseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Agent-Token", c.token)
+	}
+	return req, nil
+}
+
+// do 发送请求；连接失败或 5xx 返回 errUnreachable。
+func (c *agentClient) do(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	client := *c.http
+	client.Timeout = timeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnreachable, err)
+	}
+	if resp.StatusCode >= 500 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", errUnreachable, resp.Status)
+	}
+	return resp, nil
+}
+
+// syncProfile 以缓存的 version 作 If-None-Match 拉取 resource 的 profile：未变化沿用缓存，变化时更新缓存。
+// 不可达时返回缓存（可能为 nil）与 errUnreachable。
+func (c *agentClient) syncProfile(cache *localCache, resource string) (*sandboxProfile, error) {
+	cached := cache.loadProfile(resource)
+	req, err := c.newRequest(http.MethodGet, "/auth/sandbox-profile?resource="+url.QueryEscape(resource), nil)
+	if err != nil {
+		return cached, err
+	}
+	if cached != nil && cached.Version != "" {
+		req.Header.Set("If-None-Match", `"`+cached.Version+`"`)
+	}
+	resp, err := c.do(req, profileTimeout)
+	if err != nil {
+		return cached, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return cached, nil
+	case http.StatusOK:
+		var p sandboxProfile
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+			return cached, err
+		}
+		if cached == nil || cached.Version != p.Version {
+			if err := cache.saveProfile(resource, &p); err != nil {
+				fmt.Fprintf(os.Stderr, "3af-exec: 写入 profile 缓存失败: %v\n", err)
+			}
+		}
+		return &p, nil
+	}
+	return cached, fmt.Errorf("3af-exec: sandbox profile: %s", resp.Status)
+}
+
+// flushAudit 分批上报待补录的本地执行记录；上报失败（不可达）时记录放回缓存待下次上报，被拒绝（4xx）时丢弃并提示。
+func (c *agentClient) flushAudit(cache *localCache, subject, resource string) {
+	records, err := cache.takeSpool()
+	if err != nil || len(records) == 0 {
+		return
+	}
+	for len(records) > 0 {
+		n := len(records)
+		if n > auditBatchSize {
+			n = auditBatchSize
+		}
+		err := c.uploadAudit(subject, resource, records[:n])
+		if errors.Is(err, errUnreachable) {
+			for i := range records {
+				_ = cache.spool(&records[i])
+			}
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "3af-exec: 本地执行补录被拒绝，丢弃 %d 条: %v\n", n, err)
+		}
+		records = records[n:]
+	}
+}
+
+func (c *agentClient) uploadAudit(subject, resource string, records []auditRecord) error {
+	body, _ := json.Marshal(map[string]interface{}{"subject": subject, "resource": resource, "records": records})
+	req, err := c.newRequest(http.MethodPost, "/auth/exec/audit", body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, profileTimeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
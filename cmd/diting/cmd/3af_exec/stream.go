@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// heartbeatInterval 等待审批期间的心跳间隔。
+	heartbeatInterval = 15 * time.Second
+	// cancelWait Ctrl-C 撤回 CHEQ 后等待服务端确认的时间。
+	cancelWait = 3 * time.Second
+	// agentVersion 握手时上报的版本。
+	agentVersion = "3af-exec/1"
+)
+
+// execDecision 与服务端 ExecAuthResponse 对齐，仅解析本地需要的字段。
+type execDecision struct {
+	Decision           string `json:"decision"` // allow | deny | review
+	PolicyRuleID       string `json:"policy_rule_id,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	CheqID             string `json:"cheq_id,omitempty"`
+	ApprovalTimeoutSec int32  `json:"approval_timeout_sec,omitempty"`
+	AmendedCommandLine string `json:"amended_command_line,omitempty"`
+}
+
+type streamRequest struct {
+	RequestID string                 `json:"request_id"`
+	Init      map[string]string      `json:"init,omitempty"`
+	Auth      map[string]interface{} `json:"auth,omitempty"`
+	Ping      string                 `json:"ping,omitempty"`
+	Cancel    map[string]string      `json:"cancel,omitempty"`
+}
+
+type streamResponse struct {
+	RequestID     string          `json:"request_id,omitempty"`
+	Immediate     *execDecision   `json:"immediate,omitempty"`
+	ApprovalPush  *approvalPush   `json:"approval_push,omitempty"`
+	ProfileUpdate *sandboxProfile `json:"profile_update,omitempty"`
+	Pong          string          `json:"pong,omitempty"`
+}
+
+type approvalPush struct {
+	CheqID             string `json:"cheq_id"`
+	FinalDecision      string `json:"final_decision"` // allow | deny
+	Reason             string `json:"reason,omitempty"`
+	AmendedCommandLine string `json:"amended_command_line,omitempty"`
+}
+
+// authStream 为到 Diting /auth/stream 的一条 WebSocket 连接：读循环将消息投递到 msgs，连接断开时关闭 msgs。
+type authStream struct {
+	ws   *websocket.Conn
+	msgs chan streamResponse
+	err  error // msgs 关闭后可读
+}
+
+// dialStream 连接 /auth/stream 并完成握手；握手应答的 profile_update 写入缓存。连接失败返回 errUnreachable。
+func (c *agentClient) dialStream(cache *localCache, resource string) (*authStream, error) {
+	d := websocket.Dialer{HandshakeTimeout: profileTimeout, Proxy: http.ProxyFromEnvironment}
+	if t, ok := c.http.Transport.(*http.Transport); ok && t.DialContext != nil {
+		// 经 Unix 套接字时复用其拨号
+		d.NetDialContext = t.DialContext
+		d.Proxy = nil
+	}
+	u := strings.TrimSuffix(c.baseURL, "/") + "/auth/stream"
+	u = "ws" + strings.TrimPrefix(u, "http")
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("X-Agent-Token", c.token)
+	}
+	ws, resp, err := d.Dial(u, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode < 500 {
+			return nil, fmt.Errorf("3af-exec: auth stream: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("%w: %v", errUnreachable, err)
+	}
+	st := &authStream{ws: ws, msgs: make(chan streamResponse, 8)}
+	go st.readLoop()
+	hostname, _ := os.Hostname()
+	clientID := fmt.Sprintf("%s/%d", hostname, os.Getpid())
+	if err := st.send(streamRequest{RequestID: "init", Init: map[string]string{"client_id": clientID, "resource": resource, "agent_version": agentVersion}}); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("%w: %v", errUnreachable, err)
+	}
+	select {
+	case m, ok := <-st.msgs:
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", errUnreachable, st.err)
+		}
+		if m.ProfileUpdate != nil {
+			_ = cache.saveProfile(resource, m.ProfileUpdate)
+		}
+	case <-time.After(profileTimeout):
+		ws.Close()
+		return nil, fmt.Errorf("%w: auth stream handshake timeout", errUnreachable)
+	}
+	return st, nil
+}
+
+func (st *authStream) readLoop() {
+	defer close(st.msgs)
+	for {
+		var m streamResponse
+		if err := st.ws.ReadJSON(&m); err != nil {
+			st.err = err
+			return
+		}
+		st.msgs <- m
+	}
+}
+
+// send 发送一条消息；调用方在单一 goroutine 中发送。
+func (st *authStream) send(m streamRequest) error {
+	_ = st.ws.SetWriteDeadline(time.Now().Add(profileTimeout))
+	return st.ws.WriteJSON(m)
+}
+
+func (st *authStream) close() {
+	_ = st.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	st.ws.Close()
+}
+
+// authorize 经 AuthStream 发起鉴权并返回最终结果：review 时打印 CHEQ id 与审批状态，保持心跳并等待 approval_push，
+// 最长等待 maxWait（0 表示以服务端返回的审批时限为准）；超时或 ctx 取消（Ctrl-C）时撤回 CHEQ 并返回 deny。
+// 握手后连接中断返回错误。
+func (st *authStream) authorize(ctx context.Context, cache *localCache, auth map[string]interface{}, resource string, maxWait time.Duration) (*execDecision, error) {
+	if err := st.send(streamRequest{RequestID: "auth", Auth: auth}); err != nil {
+		return nil, err
+	}
+	var d *execDecision
+	for d == nil {
+		m, err := st.next(ctx, cache, resource)
+		if err != nil {
+			return nil, err
+		}
+		if m.RequestID == "auth" && m.Immediate != nil {
+			d = m.Immediate
+		}
+	}
+	if d.Decision != "review" || d.CheqID == "" {
+		return d, nil
+	}
+	if maxWait <= 0 {
+		// 比服务端审批时限多留余量，以便收到服务端的超时结果
+		maxWait = time.Duration(d.ApprovalTimeoutSec)*time.Second + 10*time.Second
+	}
+	fmt.Fprintf(os.Stderr, "3af-exec: 需人工审批 cheq_id=%s（%s），最长等待 %s，Ctrl-C 撤回\n", d.CheqID, d.Reason, maxWait)
+	start := time.Now()
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return st.withdraw(cache, resource, d, "cancelled by user")
+		case <-deadline.C:
+			fmt.Fprintf(os.Stderr, "3af-exec: 等待审批超过 %s\n", maxWait)
+			return st.withdraw(cache, resource, d, "requester wait timeout")
+		case <-heartbeat.C:
+			if err := st.send(streamRequest{RequestID: "ping", Ping: "heartbeat"}); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "3af-exec: cheq_id=%s 审批中，已等待 %s\n", d.CheqID, time.Since(start).Round(time.Second))
+		case m, ok := <-st.msgs:
+			if !ok {
+				return nil, fmt.Errorf("3af-exec: auth stream closed while waiting for approval: %v", st.err)
+			}
+			st.apply(cache, resource, m)
+			if p := m.ApprovalPush; p != nil && p.CheqID == d.CheqID {
+				fmt.Fprintf(os.Stderr, "3af-exec: cheq_id=%s 审批结果 %s %s\n", p.CheqID, p.FinalDecision, p.Reason)
+				return &execDecision{Decision: p.FinalDecision, PolicyRuleID: d.PolicyRuleID, Reason: p.Reason, CheqID: p.CheqID, AmendedCommandLine: p.AmendedCommandLine}, nil
+			}
+		}
+	}
+}
+
+// withdraw 撤回 CHEQ 并在 cancelWait 内等待其 approval_push；无论服务端是否确认均返回 deny。
+// 撤回前审批人已批准时仍以 deny 结束，命令不执行。
+func (st *authStream) withdraw(cache *localCache, resource string, review *execDecision, reason string) (*execDecision, error) {
+	cheqID := review.CheqID
+	fmt.Fprintf(os.Stderr, "3af-exec: 撤回 cheq_id=%s\n", cheqID)
+	out := &execDecision{Decision: "deny", PolicyRuleID: review.PolicyRuleID, CheqID: cheqID, Reason: reason}
+	if err := st.send(streamRequest{RequestID: "cancel", Cancel: map[string]string{"cheq_id": cheqID, "reason": reason}}); err != nil {
+		return out, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelWait)
+	defer cancel()
+	for {
+		m, err := st.next(ctx, cache, resource)
+		if err != nil {
+			return out, nil
+		}
+		if m.RequestID == "cancel" && m.Immediate != nil {
+			out.Reason = m.Immediate.Reason
+			return out, nil
+		}
+		if p := m.ApprovalPush; p != nil && p.CheqID == cheqID {
+			out.Reason = p.Reason
+			return out, nil
+		}
+	}
+}
+
+// next 返回下一条消息（已处理其中的 profile_update）。
+func (st *authStream) next(ctx context.Context, cache *localCache, resource string) (streamResponse, error) {
+	select {
+	case <-ctx.Done():
+		return streamResponse{}, ctx.Err()
+	case m, ok := <-st.msgs:
+		if !ok {
+			return streamResponse{}, fmt.Errorf("3af-exec: auth stream closed: %v", st.err)
+		}
+		st.apply(cache, resource, m)
+		return m, nil
+	}
+}
+
+// apply 处理服务端主动推送的 profile_update：更新本地缓存，后续命令按新 profile 执行 Hot Cache 与降级策略。
+func (st *authStream) apply(cache *localCache, resource string, m streamResponse) {
+	if m.ProfileUpdate != nil {
+		_ = cache.saveProfile(resource, m.ProfileUpdate)
+	}
+}
+
+// unixDialer 返回经 Unix 套接字 path 拨号的函数。
+func unixDialer(path string) func(ctx context.Context, _, _ string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+}
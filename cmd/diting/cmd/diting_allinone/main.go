@@ -13,12 +13,18 @@ import (
 	"syscall"
 	"time"
 
+	"diting/internal/agent"
+	"diting/internal/apikey"
 	"diting/internal/audit"
 	"diting/internal/chain"
 	"diting/internal/cheq"
 	"diting/internal/config"
 	"diting/internal/delivery"
 	feishudelivery "diting/internal/delivery/feishu"
+	"diting/internal/grant"
+	"diting/internal/httpsig"
+	"diting/internal/jwtauth"
+	"diting/internal/mtls"
 	"diting/internal/ownership"
 	"diting/internal/policy"
 	"diting/internal/proxy"
@@ -109,16 +115,47 @@ func main() {
 			fmt.Fprintf(os.Stderr, "[diting] 飞书未配置 app_id/app_secret，使用占位投递（不发飞书）。设置 DITING_FEISHU_APP_ID、DITING_FEISHU_APP_SECRET 后可见飞书审批流程\n")
 		}
 	}
+	// 审批策略在启动时校验，避免 quorum/roles 写错后静默降级
+	if _, err := ownership.ParseApprovalPolicy(cfg.Delivery.Feishu.ApprovalPolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "delivery.feishu.approval_policy: %v\n", err)
+		os.Exit(1)
+	}
+	for i, r := range cfg.CHEQ.ApprovalRules {
+		if _, err := ownership.ParseApprovalPolicy(r.ApprovalPolicy); err != nil {
+			fmt.Fprintf(os.Stderr, "cheq.approval_rules[%d].approval_policy: %v\n", i, err)
+			os.Exit(1)
+		}
+	}
+	workflows := make(map[string][]cheq.WorkflowStage, len(cfg.CHEQ.Workflows))
+	for typ, wf := range cfg.CHEQ.Workflows {
+		for i, st := range wf.Stages {
+			if _, err := ownership.ParseApprovalPolicy(st.ApprovalPolicy); err != nil {
+				fmt.Fprintf(os.Stderr, "cheq.workflows.%s.stages[%d].approval_policy: %v\n", typ, i, err)
+				os.Exit(1)
+			}
+			workflows[typ] = append(workflows[typ], cheq.WorkflowStage{
+				Name:           st.Name,
+				ConfirmerIDs:   append([]string(nil), st.ApprovalUserIDs...),
+				ApprovalPolicy: st.ApprovalPolicy,
+				Timeout:        time.Duration(st.TimeoutSeconds) * time.Second,
+			})
+		}
+	}
 	var ownershipResolver ownership.Resolver
 	// I-008: 支持多审批人默认列表；无 static_map 时也用 defaultIDs
 	defaultApprovalIDs := cfg.Delivery.Feishu.ApprovalUserIDs
 	ownershipResolver = ownership.NewStaticResolver(cfg.Ownership.StaticMap, defaultApprovalIDs)
 	if cfg.CHEQ.PersistencePath != "" {
-		store, err := cheq.NewJSONStore(cfg.CHEQ.PersistencePath)
+		store, err := cheq.NewLogStore(cfg.CHEQ.PersistencePath, cheq.LogStoreOptions{
+			Retention:       time.Duration(cfg.CHEQ.RetentionDays) * 24 * time.Hour,
+			CompactInterval: time.Duration(cfg.CHEQ.CompactIntervalSeconds) * time.Second,
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "cheq store: %v\n", err)
 			os.Exit(1)
 		}
+		store.Start()
+		defer store.Close()
 		cheqEngine = cheq.NewEngineImpl(store, cfg.CHEQ.TimeoutSeconds, ownershipResolver, deliveryProvider, cfg.Delivery.Feishu.ApprovalPolicy)
 	} else {
 		cheqEngine = cheq.NewStubEngine()
@@ -156,7 +193,16 @@ func main() {
 			fmt.Fprintf(os.Stderr, "[diting] 审计存证上链已启用（batch_size=%d, interval=%ds）\n", batchSize, intervalSec)
 		}
 	}
-	reviewRequiresApproval := cfg.CHEQ.PersistencePath != "" // 使用持久化 CHEQ 时轮询等待确认
+	if ce, ok := cheqEngine.(*cheq.EngineImpl); ok {
+		// 过期清扫：写 expired 审计并通知投递渠道更新卡片
+		ce.SetAuditStore(auditStore)
+		ce.SetApproverRoles(cfg.CHEQ.ApproverRoles)
+		ce.SetWorkflows(workflows)
+		ce.SetSecurityOncall(cfg.CHEQ.SecurityOncallIDs, time.Duration(cfg.CHEQ.OncallTimeoutSeconds)*time.Second)
+		ce.StartSweeper(time.Duration(cfg.CHEQ.SweepIntervalSeconds) * time.Second)
+		defer ce.Stop()
+	}
+	reviewRequiresApproval := cfg.CHEQ.PersistencePath != "" // 使用持久化 CHEQ 时订阅等待确认
 	var approvalMatcher *ownership.RuleMatcher
 	if len(cfg.CHEQ.ApprovalRules) > 0 {
 		rules := make([]struct {
@@ -165,6 +211,9 @@ func main() {
 			TimeoutSeconds  int
 			ApprovalUserIDs []string
 			ApprovalPolicy  string
+			RejectMode      string
+			Escalation      []ownership.EscalationStage
+			OnTimeout       string
 		}, len(cfg.CHEQ.ApprovalRules))
 		for i, r := range cfg.CHEQ.ApprovalRules {
 			rules[i] = struct {
@@ -173,62 +222,208 @@ func main() {
 				TimeoutSeconds  int
 				ApprovalUserIDs []string
 				ApprovalPolicy  string
+				RejectMode      string
+				Escalation      []ownership.EscalationStage
+				OnTimeout       string
 			}{
 				PathPrefix:      r.PathPrefix,
 				RiskLevel:       r.RiskLevel,
 				TimeoutSeconds:  r.TimeoutSeconds,
 				ApprovalUserIDs: append([]string(nil), r.ApprovalUserIDs...),
 				ApprovalPolicy:  r.ApprovalPolicy,
+				RejectMode:      r.RejectMode,
+				OnTimeout:       r.OnTimeout,
+			}
+			for _, st := range r.Escalation {
+				rules[i].Escalation = append(rules[i].Escalation, ownership.EscalationStage{
+					AfterSeconds:    st.AfterSeconds,
+					ApprovalUserIDs: append([]string(nil), st.ApprovalUserIDs...),
+				})
 			}
 		}
 		defTimeout := cfg.CHEQ.TimeoutSeconds
 		if defTimeout <= 0 {
 			defTimeout = 300
 		}
-		defPolicy := cfg.Delivery.Feishu.ApprovalPolicy
-		if defPolicy != "all" {
-			defPolicy = "any"
-		}
+		defPolicy := ownership.NormalizeApprovalPolicy(cfg.Delivery.Feishu.ApprovalPolicy, ownership.PolicyAny)
 		approvalMatcher = ownership.NewRuleMatcher(rules, ownership.ApprovalRuleMatch{
 			TimeoutSeconds:  defTimeout,
 			ApprovalUserIDs: append([]string(nil), cfg.Delivery.Feishu.ApprovalUserIDs...),
 			ApprovalPolicy:  defPolicy,
 		})
 	}
-	srv := proxy.NewServer(cfg, policyEngine, cheqEngine, deliveryProvider, auditStore, ownershipResolver, reviewRequiresApproval, approvalMatcher)
+	srv, err := proxy.NewServer(cfg, policyEngine, cheqEngine, deliveryProvider, auditStore, ownershipResolver, reviewRequiresApproval, approvalMatcher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "proxy: %v\n", err)
+		os.Exit(1)
+	}
+	approvalSigner := cheq.NewApprovalSigner(cfg.CHEQ.ApprovalLinkSecret, time.Duration(cfg.CHEQ.ApprovalLinkTTLSeconds)*time.Second)
+	srv.SetApproverAuth(approvalSigner, cfg.CHEQ.ApproverTokens)
+	if fp, ok := deliveryProvider.(*feishudelivery.Provider); ok && approvalSigner != nil {
+		fp.SetApprovalSigner(approvalSigner)
+	}
+	if approvalSigner == nil && len(cfg.CHEQ.ApproverTokens) == 0 {
+		fmt.Fprintf(os.Stderr, "[diting] 未配置 approval_link_secret/approver_tokens，/cheq/approve 不校验审批人身份，仅适用于本地调试\n")
+	}
+	grantStore, err := grant.NewFileStore(cfg.Grants.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "grant store: %v\n", err)
+		os.Exit(1)
+	}
+	srv.SetGrantStore(grantStore)
+	if p := cfg.Proxy; p.APIKeysPath != "" || len(p.APIKeys) > 0 || len(p.AllowedAPIKeys) > 0 {
+		keyStore, err := apikey.NewFileStore(p.APIKeysPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "api key store: %v\n", err)
+			os.Exit(1)
+		}
+		if err := srv.SetAPIKeyStore(keyStore); err != nil {
+			fmt.Fprintf(os.Stderr, "proxy.api_keys: %v\n", err)
+			os.Exit(1)
+		}
+		if len(p.AllowedAPIKeys) > 0 {
+			fmt.Fprintf(os.Stderr, "[diting] proxy.allowed_api_keys 已弃用（明文），请改用 api_keys（diting-ctl genkey 生成哈希）或 /apikeys 接口签发\n")
+		}
+	}
+	for _, name := range cfg.Proxy.L0Authenticators {
+		if name != "api_key" && name != "jwt" {
+			fmt.Fprintf(os.Stderr, "proxy.l0_authenticators: 未知认证器 %q（可选 api_key、jwt）\n", name)
+			os.Exit(1)
+		}
+	}
+	if j := cfg.Proxy.JWT; j.Enabled() {
+		source := j.JWKSFile
+		if source == "" {
+			source = j.JWKSURL
+		}
+		keySet := jwtauth.NewKeySet(source, time.Duration(j.JWKSRefreshSeconds)*time.Second)
+		if err := keySet.Refresh(context.Background()); err != nil {
+			// 本地文件须可用；URL 暂不可达时仍启动，首次校验时重试
+			if j.JWKSFile != "" {
+				fmt.Fprintf(os.Stderr, "proxy.jwt: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "[diting] proxy.jwt: 加载 JWKS 失败，将在校验时重试: %v\n", err)
+		}
+		srv.SetJWTVerifier(jwtauth.NewVerifier(keySet, jwtauth.Options{
+			Issuer:       j.Issuer,
+			Audience:     j.Audience,
+			Leeway:       time.Duration(j.LeewaySeconds) * time.Second,
+			SubjectClaim: j.SubjectClaim,
+			TeamClaim:    j.TeamClaim,
+			LabelClaims:  j.LabelClaims,
+		}))
+	}
+	if cfg.Agents.Enabled {
+		agentRegistry, err := agent.NewFileRegistry(cfg.Agents.RegistryPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "agent registry: %v\n", err)
+			os.Exit(1)
+		}
+		template, err := policy.LoadRules(cfg.Agents.PolicyTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "agents.policy_template: %v\n", err)
+			os.Exit(1)
+		}
+		srv.SetAgentRegistry(agentRegistry, template)
+		srv.SetAdminTokens(cfg.Agents.AdminTokens)
+		if len(cfg.Agents.AdminTokens) == 0 {
+			fmt.Fprintf(os.Stderr, "[diting] 未配置 agents.admin_tokens，/agents 管理接口不校验身份，仅适用于本地调试\n")
+		}
+		fmt.Fprintf(os.Stderr, "[diting] Agent 接入审批已启用，/init_permission 登记的 Agent 经 owner 批准后签发凭证（基线策略 %d 条）\n", len(template))
+	}
 	if cfg.Chain.Enabled {
 		srv.SetChainHandler(chainSrv.Handler())
 		fmt.Fprintf(os.Stderr, "[diting] 链子模块已启用，/chain/did/*、/chain/audit/*、/chain/health 可用\n")
 	}
+	if h := cfg.Proxy.HTTPSig; h.Enabled {
+		if ledger == nil {
+			fmt.Fprintf(os.Stderr, "proxy.http_signatures: 须启用 chain（以链上 DID 文档的公钥验签）\n")
+			os.Exit(1)
+		}
+		srv.SetSignatureVerifier(httpsig.NewVerifier(httpsig.NewDIDResolver(ledger), httpsig.Options{
+			MaxAge:             time.Duration(h.MaxAgeSeconds) * time.Second,
+			RequiredComponents: h.RequiredComponents,
+			RequireChallenge:   h.RequireChallenge,
+			ChallengeTTL:       time.Duration(h.ChallengeTTLSeconds) * time.Second,
+			MaxBodyBytes:       h.MaxBodyBytes,
+		}))
+		fmt.Fprintf(os.Stderr, "[diting] DID HTTP 消息签名校验已启用，验签通过的 DID 作为 Agent 身份\n")
+	}
+	if err := srv.SetSandboxProfiles(cfg.SandboxProfiles); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox_profiles: %v\n", err)
+		os.Exit(1)
+	}
+	if len(cfg.SandboxProfiles) > 0 {
+		fmt.Fprintf(os.Stderr, "[diting] 已加载 %d 条 Sandbox Profile，/auth/sandbox-profile 按 resource 匹配下发\n", len(cfg.SandboxProfiles))
+	}
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	// SIGHUP 触发热加载策略规则（Story 2.4）
-	if pe, ok := policyEngine.(*policy.EngineImpl); ok {
-		sigReload := make(chan os.Signal, 1)
-		signal.Notify(sigReload, syscall.SIGHUP)
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-sigReload:
+	// SIGHUP 触发热加载策略规则（Story 2.4）与 sandbox_profiles；profile 变化的 AuthStream 连接收到 profile_update
+	sigReload := make(chan os.Signal, 1)
+	signal.Notify(sigReload, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigReload:
+				if pe, ok := policyEngine.(*policy.EngineImpl); ok {
 					if err := pe.Reload(); err != nil {
 						log.Printf("[diting] policy reload failed: %v", err)
 					} else {
 						log.Printf("[diting] policy rules reloaded successfully")
 					}
 				}
+				reloaded, err := config.Load(*configPath)
+				if err == nil {
+					err = srv.SetSandboxProfiles(reloaded.SandboxProfiles)
+				}
+				if err != nil {
+					log.Printf("[diting] sandbox profiles reload failed: %v", err)
+				} else {
+					log.Printf("[diting] sandbox profiles reloaded (%d)", len(reloaded.SandboxProfiles))
+				}
 			}
-		}()
-		fmt.Fprintf(os.Stderr, "[diting] SIGHUP will reload policy rules\n")
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "[diting] SIGHUP will reload policy rules and sandbox profiles\n")
+	if t := cfg.Proxy.TLS; t.Enabled() {
+		reloader, err := mtls.New(mtls.Options{
+			CertFile:          t.CertFile,
+			KeyFile:           t.KeyFile,
+			ClientCAFile:      t.ClientCAFile,
+			CRLFile:           t.CRLFile,
+			RequireClientCert: t.RequireClientCert,
+			ReloadInterval:    time.Duration(t.ReloadIntervalSeconds) * time.Second,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "proxy.tls: %v\n", err)
+			os.Exit(1)
+		}
+		srv.SetTLS(reloader)
+		go reloader.Run(ctx, func(err error) {
+			log.Printf("[diting] tls reload failed, keeping previous certificates: %v", err)
+		})
+		mode := "TLS"
+		if t.ClientCAFile != "" {
+			mode = "mTLS（客户端证书映射为 Agent 身份）"
+		}
+		fmt.Fprintf(os.Stderr, "[diting] 以 %s 监听，证书文件变更后自动热加载\n", mode)
 	}
 	if cfg.Delivery.Feishu.Enabled && cfg.Delivery.Feishu.UseLongConnection {
-		feishudelivery.RunLongConnection(ctx, cfg.Delivery.Feishu, func(cheqID string, approved bool) error {
-			return cheqEngine.Submit(context.Background(), cheqID, approved, "")
+		feishudelivery.RunLongConnection(ctx, cfg.Delivery.Feishu, func(cheqID string, approved bool, operatorID string, opts *cheq.SubmitOptions) error {
+			return cheqEngine.SubmitWithOptions(context.Background(), cheqID, approved, operatorID, opts)
 		})
 		fmt.Fprintf(os.Stderr, "[diting] 飞书长连接已启动（卡片交互事件将在此处理）\n")
 	}
+	if cfg.Proxy.UnixSocketPath != "" {
+		fmt.Fprintf(os.Stderr, "[diting] Unix 套接字 %s（身份取自 SO_PEERCRED）\n", cfg.Proxy.UnixSocketPath)
+	}
+	if cfg.Proxy.GRPCListenAddr != "" {
+		fmt.Fprintf(os.Stderr, "[diting] gRPC ExecAuthService 监听 %s\n", cfg.Proxy.GRPCListenAddr)
+	}
 	if err := srv.Serve(ctx); err != nil && err != context.Canceled && err != http.ErrServerClosed {
 		fmt.Fprintf(os.Stderr, "serve: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,141 @@
+// diting-ctl 管理命令行：紧急隔离（kill switch）与解除，调用 /agents/quarantine、/agents/release；
+// genkey 离线生成 API Key 及其配置用哈希。
+// 用法:
+//
+//	diting-ctl [--url URL] [--admin-token TOKEN] quarantine (--agent ID | --selector k=v,... | --all) --reason 原因
+//	diting-ctl [--url URL] [--admin-token TOKEN] release    (--agent ID | --selector k=v,... | --all) [--reason 原因]
+//	diting-ctl genkey [--id KEY_ID] [--agent AGENT_ID]
+//
+// 环境: DITING_URL（默认 http://localhost:8080）, DITING_ADMIN_TOKEN（对应 agents.admin_tokens）
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"diting/internal/apikey"
+)
+
+const usage = `用法: diting-ctl [--url URL] [--admin-token TOKEN] <quarantine|release> (--agent ID | --selector k=v,... | --all) [--reason 原因]
+      diting-ctl genkey [--id KEY_ID] [--agent AGENT_ID]
+  quarantine  暂停 Agent：中止在途请求、撤销待审批 CHEQ、向 AuthStream 推送禁网 profile（须给出 --reason）
+  release     解除隔离，恢复为隔离前的状态
+  genkey      生成 API Key：明文交给调用方，输出的 proxy.api_keys 条目（只含哈希）写入配置
+`
+
+func main() {
+	baseURL := os.Getenv("DITING_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	adminToken := os.Getenv("DITING_ADMIN_TOKEN")
+	global := flag.NewFlagSet("diting-ctl", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	global.StringVar(&baseURL, "url", baseURL, "3AF 地址")
+	global.StringVar(&adminToken, "admin-token", adminToken, "管理接口 token（X-Admin-Token）")
+	_ = global.Parse(os.Args[1:])
+	args := global.Args()
+	if len(args) > 0 && args[0] == "genkey" {
+		genkey(args[1:])
+		return
+	}
+	if len(args) == 0 || (args[0] != "quarantine" && args[0] != "release") {
+		global.Usage()
+		os.Exit(2)
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	agentID := fs.String("agent", "", "Agent ID")
+	selector := fs.String("selector", "", "标签选择器，如 env=prod,team=infra")
+	all := fs.Bool("all", false, "全部 Agent")
+	reason := fs.String("reason", "", "原因（写入审计）")
+	_ = fs.Parse(args[1:])
+	n := 0
+	for _, set := range []bool{*agentID != "", *selector != "", *all} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		fmt.Fprintf(os.Stderr, "diting-ctl: --agent、--selector、--all 须且只能给出一个\n")
+		os.Exit(2)
+	}
+	if action == "quarantine" && *reason == "" {
+		fmt.Fprintf(os.Stderr, "diting-ctl: quarantine 须给出 --reason\n")
+		os.Exit(2)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"agent_id": *agentID,
+		"selector": *selector,
+		"all":      *all,
+		"reason":   *reason,
+	})
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/agents/"+action, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diting-ctl: %v\n", err)
+		os.Exit(1)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if adminToken != "" {
+		req.Header.Set("X-Admin-Token", adminToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diting-ctl: 请求 3AF 失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "diting-ctl: %s %s\n", resp.Status, strings.TrimSpace(string(data)))
+		os.Exit(1)
+	}
+	var result struct {
+		Agents          []string `json:"agents"`
+		CancelledCHEQs  []string `json:"cancelled_cheqs"`
+		AbortedRequests int      `json:"aborted_requests"`
+		NotifiedStreams int      `json:"notified_streams"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		fmt.Fprintf(os.Stderr, "diting-ctl: 无法解析响应: %v\n", err)
+		os.Exit(1)
+	}
+	verb := "已隔离"
+	if action == "release" {
+		verb = "已解除隔离"
+	}
+	fmt.Printf("%s %d 个 Agent: %s\n", verb, len(result.Agents), strings.Join(result.Agents, ", "))
+	if action == "quarantine" {
+		fmt.Printf("撤销待审批 CHEQ %d 个，中止在途请求 %d 个\n", len(result.CancelledCHEQs), result.AbortedRequests)
+	}
+	fmt.Printf("推送 profile_update 至 %d 条 AuthStream 连接\n", result.NotifiedStreams)
+}
+
+// genkey 离线生成一个 API Key，打印明文与 proxy.api_keys 配置条目。
+func genkey(args []string) {
+	fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	id := fs.String("id", "", "key id（不含 _；空则随机）")
+	agentID := fs.String("agent", "", "Key 映射的 Agent ID")
+	_ = fs.Parse(args)
+	token, k, err := apikey.Generate(*id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diting-ctl: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Printf("API Key（仅显示一次，交给调用方）:\n  %s\n\n", token)
+	fmt.Printf("写入 config.yaml 的 proxy.api_keys:\n  - id: %q\n", k.ID)
+	if *agentID != "" {
+		fmt.Printf("    agent_id: %q\n", *agentID)
+	}
+	fmt.Printf("    hash: %q\n", k.Salt+":"+k.Hash)
+}
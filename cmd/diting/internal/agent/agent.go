@@ -0,0 +1,229 @@
+// Package agent 提供 Agent 注册表：Agent 经 /init_permission 登记后处于 pending，owner 审批通过后转为 active 并签发 L0 凭证；
+// 管理员可经 /agents 接口增删改、暂停（suspended）或吊销（revoked）Agent，并可按 ID、标签或全部紧急隔离。
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"diting/internal/apikey"
+)
+
+// State Agent 生命周期状态。
+type State string
+
+const (
+	StatePending   State = "pending"   // 已登记，等待 owner 审批接入；除健康检查路径外一律拒绝
+	StateActive    State = "active"    // 已批准接入，持 L0 凭证访问
+	StateSuspended State = "suspended" // 暂停：请求一律拒绝，可恢复为 active
+	StateRevoked   State = "revoked"   // 吊销：请求一律拒绝，不可恢复
+)
+
+// ValidState 返回 s 是否为已定义的状态。
+func ValidState(s State) bool {
+	switch s {
+	case StatePending, StateActive, StateSuspended, StateRevoked:
+		return true
+	}
+	return false
+}
+
+// ErrRevoked 表示 Agent 已吊销，不可再变更状态或轮换凭证。
+var ErrRevoked = errors.New("agent: revoked")
+
+// ErrNotFound 表示 Agent 不存在。
+var ErrNotFound = errors.New("agent: not found")
+
+// Agent 注册表中的一条 Agent 记录。
+type Agent struct {
+	ID                  string            `json:"id"`
+	OwnerID             string            `json:"owner_id"`
+	Team                string            `json:"team,omitempty"`
+	Labels              map[string]string `json:"labels,omitempty"` // 供策略规则按标签匹配，如 env=prod
+	State               State             `json:"state"`
+	CredentialSalt      string            `json:"credential_salt,omitempty"`      // L0 凭证（X-Agent-Token）的盐（hex）；不保存明文
+	CredentialHash      string            `json:"credential_hash,omitempty"`      // hex(sha256(salt || 凭证))
+	CredentialPending   bool              `json:"credential_pending,omitempty"`   // 接入已批准、凭证待 Agent 首次查询时签发
+	CredentialDelivered bool              `json:"credential_delivered,omitempty"` // 凭证是否已经下发过（仅下发一次）
+	PickupSalt          string            `json:"pickup_salt,omitempty"`          // 领取凭证用的一次性口令的盐与哈希：登记时返回给登记方，
+	PickupHash          string            `json:"pickup_hash,omitempty"`          // GET /init_permission 须出示该口令才下发凭证
+	OnboardingCHEQID    string            `json:"onboarding_cheq_id,omitempty"`   // 进行中的接入审批；被拒绝或过期后清空，可重新申请
+	ApprovedBy          []string          `json:"approved_by,omitempty"`
+	CreatedAt           time.Time         `json:"created_at"`
+	ActivatedAt         time.Time         `json:"activated_at,omitempty"`
+	LastSeenAt          time.Time         `json:"last_seen_at,omitempty"`
+	Quarantine          *Quarantine       `json:"quarantine,omitempty"` // 紧急隔离记录；nil 表示未隔离
+}
+
+// Quarantine 紧急隔离记录：隔离时 Agent 置为 suspended，解除时恢复为 PrevState。
+type Quarantine struct {
+	Reason    string    `json:"reason"`
+	By        string    `json:"by,omitempty"`
+	At        time.Time `json:"at"`
+	PrevState State     `json:"prev_state"`
+}
+
+// Selector 紧急隔离与解除的作用范围：ID、标签（须全部匹配）或全部 Agent，三者取其一。
+type Selector struct {
+	ID     string
+	Labels map[string]string
+	All    bool
+}
+
+// ErrInvalidSelector 表示选择器为空或同时给出了多种范围。
+var ErrInvalidSelector = errors.New("agent: selector requires exactly one of id, labels or all")
+
+// Validate 校验选择器恰好给出一种范围。
+func (s Selector) Validate() error {
+	n := 0
+	if s.ID != "" {
+		n++
+	}
+	if len(s.Labels) > 0 {
+		n++
+	}
+	if s.All {
+		n++
+	}
+	if n != 1 {
+		return ErrInvalidSelector
+	}
+	return nil
+}
+
+// Match 返回 a 是否在选择器范围内。
+func (s Selector) Match(a *Agent) bool {
+	switch {
+	case s.All:
+		return true
+	case s.ID != "":
+		return a.ID == s.ID
+	case len(s.Labels) > 0:
+		for k, v := range s.Labels {
+			if a.Labels[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ParseLabelSelector 解析 "env=prod,team=infra" 形式的标签选择器；格式错误返回 ErrInvalidSelector。
+func ParseLabelSelector(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, ErrInvalidSelector
+		}
+		out[k] = strings.TrimSpace(v)
+	}
+	if len(out) == 0 {
+		return nil, ErrInvalidSelector
+	}
+	return out, nil
+}
+
+// Registry Agent 注册表。
+type Registry interface {
+	// Get 按 Agent ID 查询；不存在返回 ErrNotFound。
+	Get(ctx context.Context, id string) (*Agent, error)
+	// ByCredential 按 L0 凭证查询；不存在返回 ErrNotFound。
+	ByCredential(ctx context.Context, credential string) (*Agent, error)
+	// Put 新建或整体更新一条记录。
+	Put(ctx context.Context, a *Agent) error
+	// Delete 删除一条记录；不存在返回 ErrNotFound。
+	Delete(ctx context.Context, id string) error
+	// Touch 记录 Agent 最近一次访问时间；实现可合并写盘。
+	Touch(ctx context.Context, id string, at time.Time)
+	// List 返回全部 Agent，按登记时间升序。
+	List(ctx context.Context) ([]*Agent, error)
+}
+
+// SetCredential 为 Agent 生成新盐并记录凭证的加盐哈希；明文由调用方返回给 Agent 一次，此后不可再取回。
+func (a *Agent) SetCredential(credential string) error {
+	salt, hash, err := hashSecret(credential)
+	if err != nil {
+		return err
+	}
+	a.CredentialSalt, a.CredentialHash = salt, hash
+	return nil
+}
+
+// HasCredential 返回是否已签发凭证。
+func (a *Agent) HasCredential() bool {
+	return a.CredentialHash != ""
+}
+
+// CredentialMatches 以常量时间比对 credential 与已记录的加盐哈希；未签发凭证时返回 false。
+func (a *Agent) CredentialMatches(credential string) bool {
+	return secretMatches(a.CredentialSalt, a.CredentialHash, credential)
+}
+
+// IssuePickupToken 生成领取凭证的一次性口令并记录其哈希，返回明文（仅此一次，由登记请求返回）。
+func (a *Agent) IssuePickupToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := "dap_" + hex.EncodeToString(b)
+	salt, hash, err := hashSecret(token)
+	if err != nil {
+		return "", err
+	}
+	a.PickupSalt, a.PickupHash = salt, hash
+	return token, nil
+}
+
+// PickupMatches 以常量时间比对领取口令；未设置口令时返回 false。
+func (a *Agent) PickupMatches(token string) bool {
+	return secretMatches(a.PickupSalt, a.PickupHash, token)
+}
+
+// hashSecret 复用 apikey 的加盐哈希，返回 hex 编码的盐与哈希。
+func hashSecret(secret string) (salt, hash string, err error) {
+	var k apikey.Key
+	if err := k.SetSecret(secret); err != nil {
+		return "", "", err
+	}
+	return k.Salt, k.Hash, nil
+}
+
+func secretMatches(salt, hash, secret string) bool {
+	if secret == "" || hash == "" {
+		return false
+	}
+	k := apikey.Key{Salt: salt, Hash: hash}
+	return k.Matches(secret)
+}
+
+// IssueCredential 生成新凭证并记录其哈希，返回明文（仅此一次）。
+func (a *Agent) IssueCredential() (string, error) {
+	credential, err := NewCredential()
+	if err != nil {
+		return "", err
+	}
+	if err := a.SetCredential(credential); err != nil {
+		return "", err
+	}
+	return credential, nil
+}
+
+// NewCredential 生成一个随机 L0 凭证。
+func NewCredential() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "dak_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileRegistry_PutGetReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "agents.json")
+	r, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatalf("NewFileRegistry: %v", err)
+	}
+	if _, err := r.Get(ctx, "a1"); err != ErrNotFound {
+		t.Fatalf("Get missing: got %v, want ErrNotFound", err)
+	}
+	cred, err := NewCredential()
+	if err != nil || !strings.HasPrefix(cred, "dak_") {
+		t.Fatalf("NewCredential = %q, %v", cred, err)
+	}
+	_ = r.Put(ctx, &Agent{ID: "a1", OwnerID: "o1", State: StatePending, CreatedAt: time.Now()})
+	a2 := &Agent{ID: "a2", OwnerID: "o1", State: StateActive, CreatedAt: time.Now()}
+	if err := a2.SetCredential(cred); err != nil {
+		t.Fatalf("SetCredential: %v", err)
+	}
+	_ = r.Put(ctx, a2)
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), cred) {
+		t.Fatalf("plaintext credential persisted: %s", data)
+	}
+
+	r2, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	a, err := r2.ByCredential(ctx, cred)
+	if err != nil || a.ID != "a2" {
+		t.Fatalf("ByCredential after reopen = %+v, %v", a, err)
+	}
+	if _, err := r2.ByCredential(ctx, ""); err != ErrNotFound {
+		t.Errorf("empty credential: got %v, want ErrNotFound", err)
+	}
+	list, _ := r2.List(ctx)
+	if len(list) != 2 || list[0].ID != "a1" || list[0].State != StatePending {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestFileRegistry_MigratesPlaintextCredential(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "agents.json")
+	legacy := `[{"id":"a1","state":"active","credential":"dak_old","credential_delivered":true},` +
+		`{"id":"a2","state":"active","credential":"dak_undelivered"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatalf("NewFileRegistry: %v", err)
+	}
+	if a, err := r.ByCredential(ctx, "dak_old"); err != nil || a.ID != "a1" {
+		t.Errorf("delivered legacy credential should still verify: %+v, %v", a, err)
+	}
+	if _, err := r.ByCredential(ctx, "dak_undelivered"); err != ErrNotFound {
+		t.Errorf("undelivered legacy credential should be dropped: %v", err)
+	}
+	if a, _ := r.Get(ctx, "a2"); !a.CredentialPending {
+		t.Errorf("undelivered credential should be reissued: %+v", a)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "dak_old") || strings.Contains(string(data), "dak_undelivered") {
+		t.Errorf("plaintext credential left on disk: %s", data)
+	}
+}
+
+func TestFileRegistry_DeleteAndTouch(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "agents.json")
+	r, _ := NewFileRegistry(path)
+	_ = r.Put(ctx, &Agent{ID: "a1", State: StateActive, Labels: map[string]string{"env": "prod"}, CreatedAt: time.Now()})
+
+	seen := time.Now()
+	r.Touch(ctx, "a1", seen)
+	r.Touch(ctx, "missing", seen)
+	if a, _ := r.Get(ctx, "a1"); !a.LastSeenAt.Equal(seen) {
+		t.Errorf("LastSeenAt = %v, want %v", a.LastSeenAt, seen)
+	}
+	// Touch 紧随 Put 不落盘（节流），下一次 Put 时一并写入
+	r2, _ := NewFileRegistry(path)
+	if a, _ := r2.Get(ctx, "a1"); !a.LastSeenAt.IsZero() {
+		t.Errorf("Touch within flush interval should not hit disk: %v", a.LastSeenAt)
+	}
+	a, _ := r.Get(ctx, "a1")
+	_ = r.Put(ctx, a)
+	r2, _ = NewFileRegistry(path)
+	if a, _ := r2.Get(ctx, "a1"); !a.LastSeenAt.Equal(seen) || a.Labels["env"] != "prod" {
+		t.Errorf("after Put and reopen: %+v", a)
+	}
+
+	a, _ = r.Get(ctx, "a1")
+	a.Labels["env"] = "dev"
+	if got, _ := r.Get(ctx, "a1"); got.Labels["env"] != "prod" {
+		t.Errorf("Get should return a copy, labels = %v", got.Labels)
+	}
+	if err := r.Delete(ctx, "a1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := r.Delete(ctx, "a1"); err != ErrNotFound {
+		t.Errorf("second Delete: got %v, want ErrNotFound", err)
+	}
+	if !ValidState(StateSuspended) || ValidState("frozen") {
+		t.Errorf("ValidState mismatch")
+	}
+}
+
+func TestSelector(t *testing.T) {
+	labels, err := ParseLabelSelector("env=prod, team=infra")
+	if err != nil || len(labels) != 2 || labels["team"] != "infra" {
+		t.Fatalf("ParseLabelSelector = %v, %v", labels, err)
+	}
+	for _, bad := range []string{"", "env", "=prod", " , "} {
+		if _, err := ParseLabelSelector(bad); err != ErrInvalidSelector {
+			t.Errorf("ParseLabelSelector(%q): want ErrInvalidSelector, got %v", bad, err)
+		}
+	}
+	a := &Agent{ID: "a1", Labels: map[string]string{"env": "prod", "team": "infra", "zone": "x"}}
+	b := &Agent{ID: "b1", Labels: map[string]string{"env": "prod"}}
+	sel := Selector{Labels: labels}
+	if !sel.Match(a) || sel.Match(b) {
+		t.Errorf("label selector should match a1 only")
+	}
+	if !(Selector{All: true}).Match(b) || !(Selector{ID: "b1"}).Match(b) || (Selector{ID: "b1"}).Match(a) {
+		t.Errorf("id/all selector mismatch")
+	}
+	if (Selector{}).Validate() != ErrInvalidSelector || (Selector{ID: "a1", All: true}).Validate() != ErrInvalidSelector {
+		t.Errorf("Validate should require exactly one scope")
+	}
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// touchFlushInterval Touch 只更新内存，距上次写盘超过该间隔才整体写盘，避免每个请求重写文件。
+const touchFlushInterval = time.Minute
+
+// FileRegistry 注册表：内存索引 + 单个 JSON 文件（每次变更经临时文件 + rename 整体重写）。path 为空时仅内存。
+type FileRegistry struct {
+	path      string
+	mu        sync.RWMutex
+	agents    map[string]*Agent
+	lastFlush time.Time
+}
+
+var _ Registry = (*FileRegistry)(nil)
+
+// NewFileRegistry 打开 path 下的注册表文件（不存在则新建）；path 为空时仅内存。
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	r := &FileRegistry{path: path, agents: make(map[string]*Agent)}
+	if path == "" {
+		return r, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	var list []*fileAgent
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+	}
+	migrated := false
+	for _, fa := range list {
+		a := fa.Agent
+		if fa.Credential != "" {
+			// 旧版文件保存明文凭证：已下发的改存哈希；尚未下发的丢弃明文，待 Agent 查询时重新签发
+			if a.CredentialDelivered {
+				if err := a.SetCredential(fa.Credential); err != nil {
+					return nil, err
+				}
+			} else {
+				a.CredentialPending = true
+			}
+			migrated = true
+		}
+		r.agents[a.ID] = a
+	}
+	if migrated {
+		if err := r.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// fileAgent 读取注册表文件时兼容旧版明文 credential 字段。
+type fileAgent struct {
+	*Agent
+	Credential string `json:"credential,omitempty"`
+}
+
+func (r *FileRegistry) Get(ctx context.Context, id string) (*Agent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a := r.agents[id]
+	if a == nil {
+		return nil, ErrNotFound
+	}
+	return clone(a), nil
+}
+
+func (r *FileRegistry) ByCredential(ctx context.Context, credential string) (*Agent, error) {
+	if credential == "" {
+		return nil, ErrNotFound
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var found *Agent
+	for _, a := range r.agents {
+		if a.CredentialMatches(credential) {
+			found = a
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return clone(found), nil
+}
+
+func (r *FileRegistry) Put(ctx context.Context, a *Agent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev := r.agents[a.ID]
+	r.agents[a.ID] = clone(a)
+	if err := r.saveLocked(); err != nil {
+		if prev == nil {
+			delete(r.agents, a.ID)
+		} else {
+			r.agents[a.ID] = prev
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *FileRegistry) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev := r.agents[id]
+	if prev == nil {
+		return ErrNotFound
+	}
+	delete(r.agents, id)
+	if err := r.saveLocked(); err != nil {
+		r.agents[id] = prev
+		return err
+	}
+	return nil
+}
+
+func (r *FileRegistry) Touch(ctx context.Context, id string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a := r.agents[id]
+	if a == nil || !at.After(a.LastSeenAt) {
+		return
+	}
+	a.LastSeenAt = at
+	if at.Sub(r.lastFlush) >= touchFlushInterval {
+		_ = r.saveLocked()
+	}
+}
+
+func (r *FileRegistry) List(ctx context.Context) ([]*Agent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedLocked(), nil
+}
+
+// sortedLocked 返回按登记时间升序的副本。调用方须持有锁。
+func (r *FileRegistry) sortedLocked() []*Agent {
+	out := make([]*Agent, 0, len(r.agents))
+	for _, a := range r.agents {
+		out = append(out, clone(a))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// saveLocked 整体写盘。调用方须持有写锁。
+func (r *FileRegistry) saveLocked() error {
+	if r.path == "" {
+		return nil
+	}
+	r.lastFlush = time.Now()
+	data, err := json.MarshalIndent(r.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+func clone(a *Agent) *Agent {
+	c := *a
+	c.ApprovedBy = append([]string(nil), a.ApprovedBy...)
+	if a.Labels != nil {
+		c.Labels = make(map[string]string, len(a.Labels))
+		for k, v := range a.Labels {
+			c.Labels[k] = v
+		}
+	}
+	if a.Quarantine != nil {
+		q := *a.Quarantine
+		c.Quarantine = &q
+	}
+	return &c
+}
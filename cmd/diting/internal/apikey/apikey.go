@@ -0,0 +1,155 @@
+// Package apikey 提供 L0 API Key 存储：只保存加盐哈希，按 key id 定位后常量时间比对；
+// 每个 Key 映射到一个 Agent，可限定允许的 action/resource，带过期时间与最近使用时间。
+// 签发的 Key 形如 dk_<key id>_<secret>，明文仅在创建时返回一次。
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tokenPrefix 签发 Key 的前缀；前缀后到下一个 "_" 为 key id。
+const tokenPrefix = "dk_"
+
+var (
+	// ErrInvalid 表示 Key 不存在或不匹配。
+	ErrInvalid = errors.New("apikey: invalid key")
+	// ErrExpired 表示 Key 已过期。
+	ErrExpired = errors.New("apikey: key expired")
+	// ErrNotFound 表示 key id 不存在。
+	ErrNotFound = errors.New("apikey: not found")
+)
+
+// Key 一条 API Key 记录；不含明文。
+type Key struct {
+	ID          string    `json:"id"`
+	AgentID     string    `json:"agent_id,omitempty"`  // Key 代表的 Agent；审计与策略以其为主体，空则为 "key:<id>"
+	Salt        string    `json:"salt"`                // hex
+	Hash        string    `json:"hash"`                // hex(sha256(salt || key))
+	Actions     []string  `json:"actions,omitempty"`   // 允许的 action；空表示不限
+	Resources   []string  `json:"resources,omitempty"` // 允许的 resource，结尾 * 表示前缀匹配；空表示不限
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"` // 零值表示不过期
+	LastUsedAt  time.Time `json:"last_used_at,omitempty"`
+	Source      string    `json:"source,omitempty"` // SourceConfig 表示来自配置文件，启动时与配置对账；空表示经接口签发
+}
+
+// SourceConfig 标记由配置文件（proxy.api_keys / proxy.allowed_api_keys）写入的 Key。
+const SourceConfig = "config"
+
+// Expired 返回 Key 在 now 是否已过期。
+func (k *Key) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// Allows 返回 Key 的作用域是否允许 action 作用于 resource。
+func (k *Key) Allows(action, resource string) bool {
+	return matchAny(k.Actions, action) && matchAny(k.Resources, resource)
+}
+
+// matchAny 空列表匹配任意；"*" 匹配任意，结尾 "*" 为前缀匹配，否则精确匹配。
+func matchAny(patterns []string, v string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p == "*" || p == v || (strings.HasSuffix(p, "*") && strings.HasPrefix(v, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches 以常量时间比对 token 与 Key 的加盐哈希。
+func (k *Key) Matches(token string) bool {
+	salt, err := hex.DecodeString(k.Salt)
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(k.Hash)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hashToken(salt, token), want) == 1
+}
+
+func hashToken(salt []byte, token string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(token))
+	return h.Sum(nil)
+}
+
+// SetSecret 为 Key 生成新盐并记录 token 的哈希。
+func (k *Key) SetSecret(token string) error {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return err
+	}
+	k.Salt = hex.EncodeToString(salt)
+	k.Hash = hex.EncodeToString(hashToken(salt, token))
+	return nil
+}
+
+// Generate 签发一个新 Key：id 为空时随机生成；返回明文 token（仅此一次）与只含哈希的记录。
+func Generate(id string) (string, *Key, error) {
+	if id == "" {
+		b, err := randomBytes(6)
+		if err != nil {
+			return "", nil, err
+		}
+		id = hex.EncodeToString(b)
+	}
+	if strings.Contains(id, "_") {
+		return "", nil, errors.New("apikey: key id must not contain '_'")
+	}
+	secret, err := randomBytes(24)
+	if err != nil {
+		return "", nil, err
+	}
+	token := tokenPrefix + id + "_" + hex.EncodeToString(secret)
+	k := &Key{ID: id, CreatedAt: time.Now()}
+	if err := k.SetSecret(token); err != nil {
+		return "", nil, err
+	}
+	return token, k, nil
+}
+
+// ParseID 从 dk_<id>_<secret> 形式的 token 取出 key id；其他形式（如旧版明文配置的 Key）返回 false。
+func ParseID(token string) (string, bool) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return "", false
+	}
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), "_")
+	return id, ok && id != ""
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Store API Key 存储。
+type Store interface {
+	// Verify 校验 token：按 key id 定位（旧格式逐条比对），常量时间比较哈希；通过时记录最近使用时间。
+	// 不匹配返回 ErrInvalid，已过期返回 ErrExpired。
+	Verify(ctx context.Context, token string, now time.Time) (*Key, error)
+	// Get 按 key id 查询；不存在返回 ErrNotFound。
+	Get(ctx context.Context, id string) (*Key, error)
+	// Put 新建或整体更新一条记录。
+	Put(ctx context.Context, k *Key) error
+	// Delete 删除一条记录；不存在返回 ErrNotFound。
+	Delete(ctx context.Context, id string) error
+	// List 返回全部 Key，按创建时间升序。
+	List(ctx context.Context) ([]*Key, error)
+}
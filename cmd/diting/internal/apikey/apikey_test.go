@@ -0,0 +1,65 @@
+package apikey
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStore_VerifyAndReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	st, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	token, k, err := Generate("ci")
+	if err != nil || !strings.HasPrefix(token, "dk_ci_") {
+		t.Fatalf("Generate: %q %v", token, err)
+	}
+	k.AgentID, k.Resources = "bot", []string{"https://api.example.com/*"}
+	k.ExpiresAt = time.Now().Add(time.Hour)
+	if err := st.Put(ctx, k); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	legacy := &Key{ID: "legacy-1", CreatedAt: time.Now()}
+	_ = legacy.SetSecret("plain-secret")
+	_ = st.Put(ctx, legacy)
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), token) || strings.Contains(string(data), "plain-secret") {
+		t.Fatalf("plaintext key persisted: %s", data)
+	}
+	st2, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := st2.Verify(ctx, token, time.Now())
+	if err != nil || got.ID != "ci" || got.AgentID != "bot" || got.LastUsedAt.IsZero() {
+		t.Fatalf("Verify after reopen: %+v %v", got, err)
+	}
+	if !got.Allows("GET", "https://api.example.com/v1") || got.Allows("GET", "https://other.example.com/") {
+		t.Errorf("resource scope not applied")
+	}
+	if got, err := st2.Verify(ctx, "plain-secret", time.Now()); err != nil || got.ID != "legacy-1" {
+		t.Errorf("legacy key: %+v %v", got, err)
+	}
+	if _, err := st2.Verify(ctx, token+"x", time.Now()); err != ErrInvalid {
+		t.Errorf("tampered key: got %v, want ErrInvalid", err)
+	}
+	if _, err := st2.Verify(ctx, token, time.Now().Add(2*time.Hour)); err != ErrExpired {
+		t.Errorf("expired key: got %v, want ErrExpired", err)
+	}
+	if err := st2.Delete(ctx, "ci"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := st2.Verify(ctx, token, time.Now()); err != ErrInvalid {
+		t.Errorf("deleted key: got %v, want ErrInvalid", err)
+	}
+	if err := st2.Delete(ctx, "ci"); err != ErrNotFound {
+		t.Errorf("Delete missing: got %v, want ErrNotFound", err)
+	}
+}
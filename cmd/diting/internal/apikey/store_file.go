@@ -0,0 +1,172 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lastUsedFlushInterval Verify 只更新内存中的最近使用时间，距上次写盘超过该间隔才整体写盘。
+const lastUsedFlushInterval = time.Minute
+
+// FileStore API Key 存储：内存索引 + 单个 JSON 文件（每次变更经临时文件 + rename 整体重写，权限 0600）。path 为空时仅内存。
+type FileStore struct {
+	path      string
+	mu        sync.Mutex
+	keys      map[string]*Key
+	lastFlush time.Time
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore 打开 path 下的 Key 文件（不存在则新建）；path 为空时仅内存。
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, keys: make(map[string]*Key)}
+	if path == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	var list []*Key
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range list {
+		s.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+func (s *FileStore) Verify(ctx context.Context, token string, now time.Time) (*Key, error) {
+	if token == "" {
+		return nil, ErrInvalid
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found *Key
+	if id, ok := ParseID(token); ok && s.keys[id] != nil {
+		if k := s.keys[id]; k.Matches(token) {
+			found = k
+		}
+	} else {
+		// 旧格式 Key 无 id 可定位：逐条比对，不因命中提前结束
+		for _, k := range s.keys {
+			if k.Matches(token) && found == nil {
+				found = k
+			}
+		}
+	}
+	if found == nil {
+		return nil, ErrInvalid
+	}
+	if found.Expired(now) {
+		return nil, ErrExpired
+	}
+	if now.After(found.LastUsedAt) {
+		found.LastUsedAt = now
+		if now.Sub(s.lastFlush) >= lastUsedFlushInterval {
+			_ = s.saveLocked()
+		}
+	}
+	return clone(found), nil
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := s.keys[id]
+	if k == nil {
+		return nil, ErrNotFound
+	}
+	return clone(k), nil
+}
+
+func (s *FileStore) Put(ctx context.Context, k *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.keys[k.ID]
+	s.keys[k.ID] = clone(k)
+	if err := s.saveLocked(); err != nil {
+		if prev == nil {
+			delete(s.keys, k.ID)
+		} else {
+			s.keys[k.ID] = prev
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.keys[id]
+	if prev == nil {
+		return ErrNotFound
+	}
+	delete(s.keys, id)
+	if err := s.saveLocked(); err != nil {
+		s.keys[id] = prev
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) List(ctx context.Context) ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedLocked(), nil
+}
+
+// sortedLocked 返回按创建时间升序的副本。调用方须持有锁。
+func (s *FileStore) sortedLocked() []*Key {
+	out := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, clone(k))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// saveLocked 整体写盘。调用方须持有锁。
+func (s *FileStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	s.lastFlush = time.Now()
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func clone(k *Key) *Key {
+	c := *k
+	c.Actions = append([]string(nil), k.Actions...)
+	c.Resources = append([]string(nil), k.Resources...)
+	return &c
+}
@@ -2,12 +2,14 @@ package audit
 
 import (
 	"context"
+	"sync"
 
 	"diting/internal/models"
 )
 
 // StubStore 占位实现：Append 与 QueryByTraceID 为内存/无操作，供 Phase 2 装配。
 type StubStore struct {
+	mu        sync.Mutex
 	evidences []*models.Evidence
 }
 
@@ -16,11 +18,15 @@ func NewStubStore() *StubStore {
 }
 
 func (s *StubStore) Append(ctx context.Context, e *models.Evidence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.evidences = append(s.evidences, e)
 	return nil
 }
 
 func (s *StubStore) QueryByTraceID(ctx context.Context, traceID string) ([]*models.Evidence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	var out []*models.Evidence
 	for _, e := range s.evidences {
 		if e.TraceID == traceID {
@@ -0,0 +1,67 @@
+package cheq
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+
+	"diting/internal/models"
+)
+
+// ApprovalSigner 为审批链接签名与验签：sig = HMAC-SHA256(secret, id|confirmer|exp)，链接绑定对象、确认人与过期时间，
+// 拿到链接的人无法改 by 冒充他人，也无法在过期后使用。
+type ApprovalSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewApprovalSigner 创建签名器；secret 为空返回 nil（不启用签名链接）。ttl > 0 时链接有效期取 ttl 与对象过期时间的较早者。
+func NewApprovalSigner(secret string, ttl time.Duration) *ApprovalSigner {
+	if secret == "" {
+		return nil
+	}
+	return &ApprovalSigner{secret: []byte(secret), ttl: ttl}
+}
+
+// Sign 返回 id、confirmerID、exp 的签名（base64url）。
+func (s *ApprovalSigner) Sign(id, confirmerID string, exp time.Time) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "|" + confirmerID + "|" + strconv.FormatInt(exp.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验链接参数；exp 为 Unix 秒字符串。签名不符返回 ErrInvalidSignature，已过期返回 ErrLinkExpired。
+func (s *ApprovalSigner) Verify(id, confirmerID, exp, sig string, now time.Time) error {
+	sec, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || confirmerID == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+	want := s.Sign(id, confirmerID, time.Unix(sec, 0))
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > sec {
+		return ErrLinkExpired
+	}
+	return nil
+}
+
+// LinkQuery 返回 /cheq/approve 的查询串（不含 ?）：id、approved、by、exp、sig。
+func (s *ApprovalSigner) LinkQuery(obj *models.ConfirmationObject, confirmerID string, approved bool) string {
+	exp := obj.ExpiresAt
+	if s.ttl > 0 {
+		if t := time.Now().Add(s.ttl); t.Before(exp) {
+			exp = t
+		}
+	}
+	q := url.Values{}
+	q.Set("id", obj.ID)
+	q.Set("approved", strconv.FormatBool(approved))
+	q.Set("by", confirmerID)
+	q.Set("exp", strconv.FormatInt(exp.Unix(), 10))
+	q.Set("sig", s.Sign(obj.ID, confirmerID, exp))
+	return q.Encode()
+}
@@ -2,9 +2,14 @@ package cheq
 
 import (
 	"context"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"diting/internal/audit"
+	"diting/internal/delivery"
 	"diting/internal/models"
 )
 
@@ -143,6 +148,134 @@ func TestEngineImpl_Submit_AllPolicy(t *testing.T) {
 	}
 }
 
+func TestEngineImpl_Submit_Quorum(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-q", Resource: "/r", Action: "a", Summary: "s",
+		ConfirmerIDs: []string{"u1", "u2", "u3", "u4", "u5"}, Type: "op", ApprovalPolicy: "quorum:2",
+	})
+	if err := eng.Submit(ctx, obj.ID, true, ""); err != ErrConfirmerRequired {
+		t.Errorf("empty confirmer: want ErrConfirmerRequired, got %v", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "outsider"); err != ErrNotConfirmer {
+		t.Errorf("outsider: want ErrNotConfirmer, got %v", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "u1"); err != nil {
+		t.Fatalf("Submit u1: %v", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "u1"); err != nil {
+		t.Errorf("repeat vote should be idempotent: %v", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, false, "u1"); err != ErrAlreadyVoted {
+		t.Errorf("flip vote: want ErrAlreadyVoted, got %v", err)
+	}
+	got, _ := eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusPending || len(got.Votes) != 1 {
+		t.Fatalf("after one vote: status=%v votes=%+v", got.Status, got.Votes)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "u3"); err != nil {
+		t.Fatalf("Submit u3: %v", err)
+	}
+	got, _ = eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusApproved {
+		t.Errorf("2 of 5: want approved, got %v", got.Status)
+	}
+	if len(got.Votes) != 2 || got.Votes[1].ConfirmerID != "u3" || got.Votes[1].Decision != models.VoteApprove || got.Votes[1].At.IsZero() {
+		t.Errorf("votes = %+v", got.Votes)
+	}
+}
+
+func TestEngineImpl_Submit_RolesAndRejectMode(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	eng.SetApproverRoles(map[string][]string{"security": {"s1"}, "owner": {"o1", "o2"}})
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-roles", Resource: "/r", Action: "a", Summary: "s",
+		ConfirmerIDs: []string{"s1", "o1", "o2"}, Type: "op",
+		ApprovalPolicy: "roles:security=1,owner=1", RejectMode: "quorum",
+	})
+	_ = eng.Submit(ctx, obj.ID, true, "o1")
+	// quorum 拒绝模式：o2 拒绝后 s1 仍可补足，不终态
+	if err := eng.Submit(ctx, obj.ID, false, "o2"); err != nil {
+		t.Fatalf("Submit o2 reject: %v", err)
+	}
+	got, _ := eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusPending {
+		t.Fatalf("after o2 reject: %v", got.Status)
+	}
+	_ = eng.Submit(ctx, obj.ID, true, "s1")
+	got, _ = eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusApproved || got.Votes[2].Role != "security" {
+		t.Fatalf("after s1: status=%v votes=%+v", got.Status, got.Votes)
+	}
+
+	// 默认 veto：任一拒绝即拒绝
+	obj2, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-veto", Resource: "/r", Action: "a", Summary: "s",
+		ConfirmerIDs: []string{"u1", "u2", "u3"}, Type: "op", ApprovalPolicy: "quorum:2",
+	})
+	_ = eng.Submit(ctx, obj2.ID, false, "u2")
+	got, _ = eng.GetByID(ctx, obj2.ID)
+	if got.Status != models.ConfirmationStatusRejected {
+		t.Errorf("veto: want rejected, got %v", got.Status)
+	}
+}
+
+func TestEngineImpl_Submit_SelfApproval(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	auditStore := audit.NewStubStore()
+	eng.SetAuditStore(auditStore)
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-sod", Resource: "/r", Action: "a", Summary: "s", Type: "op",
+		ConfirmerIDs: []string{"owner-1", "u2"}, Requester: "agent-1", RequesterOwner: "owner-1",
+	})
+	if err := eng.Submit(ctx, obj.ID, true, "owner-1"); err != ErrSelfApproval {
+		t.Errorf("owner approve: want ErrSelfApproval, got %v", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "agent-1"); err != ErrSelfApproval {
+		t.Errorf("requester approve: want ErrSelfApproval, got %v", err)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-sod")
+	if len(evs) != 2 || evs[0].Decision != "approval_rejected" || evs[0].Confirmer != "owner-1" {
+		t.Errorf("rejected attempts evidence = %+v", evs)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "u2"); err != nil {
+		t.Fatalf("Submit u2: %v", err)
+	}
+}
+
+func TestApprovalSigner(t *testing.T) {
+	s := NewApprovalSigner("secret", 0)
+	obj := &models.ConfirmationObject{ID: "c1", ExpiresAt: time.Now().Add(time.Minute)}
+	q, _ := url.ParseQuery(s.LinkQuery(obj, "u1", true))
+	if q.Get("by") != "u1" || q.Get("approved") != "true" {
+		t.Fatalf("link query = %v", q)
+	}
+	if err := s.Verify("c1", "u1", q.Get("exp"), q.Get("sig"), time.Now()); err != nil {
+		t.Errorf("Verify valid link: %v", err)
+	}
+	if err := s.Verify("c1", "u2", q.Get("exp"), q.Get("sig"), time.Now()); err != ErrInvalidSignature {
+		t.Errorf("Verify forged by: want ErrInvalidSignature, got %v", err)
+	}
+	if err := s.Verify("c2", "u1", q.Get("exp"), q.Get("sig"), time.Now()); err != ErrInvalidSignature {
+		t.Errorf("Verify other id: want ErrInvalidSignature, got %v", err)
+	}
+	if err := s.Verify("c1", "u1", q.Get("exp"), q.Get("sig"), time.Now().Add(2*time.Minute)); err != ErrLinkExpired {
+		t.Errorf("Verify expired: want ErrLinkExpired, got %v", err)
+	}
+	if NewApprovalSigner("", 0) != nil {
+		t.Error("empty secret should disable signer")
+	}
+}
+
 func TestEngineImpl_Submit_Expired(t *testing.T) {
 	dir := t.TempDir()
 	store, _ := NewJSONStore(dir)
@@ -159,3 +292,725 @@ func TestEngineImpl_Submit_Expired(t *testing.T) {
 		t.Errorf("Submit expired: want ErrExpired, got %v", err)
 	}
 }
+
+func TestEngineImpl_Watch_SubmitNotifies(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-watch", Resource: "/r", Action: "a", Summary: "s",
+		ConfirmerIDs: []string{"u1"}, Type: "op",
+	})
+	ch, err := eng.Watch(ctx, obj.ID)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	first := <-ch
+	if first.Status != models.ConfirmationStatusPending {
+		t.Fatalf("first snapshot status = %v", first.Status)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "u1"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	select {
+	case got := <-ch:
+		if got.Status != models.ConfirmationStatusApproved {
+			t.Errorf("pushed status = %v", got.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no push after Submit")
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel should be closed after terminal status")
+	}
+
+	if _, err := eng.Watch(ctx, "nonexistent-id"); err != ErrNotFound {
+		t.Errorf("Watch not found: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestEngineImpl_Watch_ExpiresByTimer(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	auditStore := audit.NewStubStore()
+	eng.SetAuditStore(auditStore)
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-timer", Resource: "/r", Action: "a", Summary: "s",
+		ExpiresAt: time.Now().Add(200 * time.Millisecond), Type: "op",
+	})
+	ch, _ := eng.Watch(ctx, obj.ID)
+	<-ch
+	select {
+	case got := <-ch:
+		if got.Status != models.ConfirmationStatusExpired {
+			t.Errorf("pushed status = %v", got.Status)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no expiry push")
+	}
+	// 过期已由定时器写回 store，无需读路径触发
+	stored, _ := store.Get(ctx, obj.ID)
+	if stored.Status != models.ConfirmationStatusExpired {
+		t.Errorf("stored status = %v", stored.Status)
+	}
+	// 有订阅者等待时 expired 审计由等待方写，引擎不重复写
+	if evs, _ := auditStore.QueryByTraceID(ctx, "t-timer"); len(evs) != 0 {
+		t.Errorf("engine wrote evidence for watched object: %+v", evs)
+	}
+}
+
+// notifyRecorder 记录 NotifyStatus 调用，验证过期后会通知投递渠道。
+type notifyRecorder struct {
+	ch chan *models.ConfirmationObject
+}
+
+func (n *notifyRecorder) Deliver(ctx context.Context, in *delivery.DeliverInput) error { return nil }
+
+func (n *notifyRecorder) NotifyStatus(ctx context.Context, obj *models.ConfirmationObject) error {
+	n.ch <- obj
+	return nil
+}
+
+func TestEngineImpl_SweepExpiresOrphans(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	ctx := context.Background()
+	// 模拟重启前遗留：直接写入已过期的 pending 对象，引擎无对应定时器
+	orphan := &models.ConfirmationObject{
+		ID: "orphan-1", TraceID: "t-orphan", Status: models.ConfirmationStatusPending,
+		CreatedAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(-time.Minute),
+		Resource: "/r", Action: "a", ConfirmerIDs: []string{"u1"}, Requester: "agent-a",
+		Deliveries: []models.DeliveryRef{{Channel: "feishu", MessageID: "om_1", Card: true}},
+	}
+	_ = store.Put(ctx, orphan)
+
+	notifier := &notifyRecorder{ch: make(chan *models.ConfirmationObject, 1)}
+	eng := NewEngineImpl(store, 300, nil, notifier, "any")
+	auditStore := audit.NewStubStore()
+	eng.SetAuditStore(auditStore)
+
+	if n := eng.Sweep(ctx); n != 1 {
+		t.Fatalf("Sweep: expired %d, want 1", n)
+	}
+	got, _ := store.Get(ctx, "orphan-1")
+	if got.Status != models.ConfirmationStatusExpired {
+		t.Errorf("status = %v", got.Status)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-orphan")
+	if len(evs) != 1 || evs[0].Decision != "expired" || evs[0].CHEQStatus != "expired" || evs[0].AgentID != "agent-a" {
+		t.Errorf("expected one expired evidence, got %+v", evs)
+	}
+	select {
+	case obj := <-notifier.ch:
+		if obj.ID != "orphan-1" || obj.Status != models.ConfirmationStatusExpired || len(obj.Deliveries) != 1 {
+			t.Errorf("notified %+v", obj)
+		}
+	case <-time.After(time.Second):
+		t.Error("delivery provider not notified")
+	}
+	if n := eng.Sweep(ctx); n != 0 {
+		t.Errorf("second Sweep: expired %d, want 0", n)
+	}
+}
+
+// deliverRecorder 记录每次 Deliver 的目标确认人，验证升级时重新投递。
+type deliverRecorder struct {
+	ch chan []string
+}
+
+func (d *deliverRecorder) Deliver(ctx context.Context, in *delivery.DeliverInput) error {
+	d.ch <- in.Options.ConfirmerIDs
+	return nil
+}
+
+// sentRecorder 模拟发出一条消息并通过 OnSent 回报。
+type sentRecorder struct{}
+
+func (sentRecorder) Deliver(ctx context.Context, in *delivery.DeliverInput) error {
+	in.Options.OnSent(models.DeliveryRef{Channel: "feishu", MessageID: "om_" + in.Object.ID, Card: true})
+	return nil
+}
+
+func TestEngineImpl_PersistsDeliveries(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewJSONStore(dir)
+	eng := NewEngineImpl(store, 300, nil, sentRecorder{}, "any")
+	ctx := context.Background()
+	obj, _ := eng.Create(ctx, &CreateInput{TraceID: "t-sent", Resource: "/r", Action: "a", Summary: "s", Type: "op"})
+	eng.Stop()
+
+	// 重启后从 store 读出的对象仍带消息标识
+	reopened, _ := NewJSONStore(dir)
+	got, _ := reopened.Get(ctx, obj.ID)
+	if got == nil || len(got.Deliveries) != 1 || got.Deliveries[0].MessageID != "om_"+obj.ID {
+		t.Fatalf("deliveries = %+v", got)
+	}
+}
+
+func TestEngineImpl_EscalationRedelivers(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	rec := &deliverRecorder{ch: make(chan []string, 4)}
+	eng := NewEngineImpl(store, 300, nil, rec, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	eng.SetAuditStore(auditStore)
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-esc", Resource: "/r", Action: "a", Summary: "s",
+		ExpiresAt: time.Now().Add(5 * time.Second), ConfirmerIDs: []string{"u1"}, Type: "op",
+		Escalation: []EscalationStage{{After: 100 * time.Millisecond, ConfirmerIDs: []string{"lead"}}},
+	})
+	if got := <-rec.ch; len(got) != 1 || got[0] != "u1" {
+		t.Fatalf("initial delivery to %v", got)
+	}
+	select {
+	case got := <-rec.ch:
+		if len(got) != 1 || got[0] != "lead" {
+			t.Fatalf("escalation delivery to %v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no escalation delivery")
+	}
+	got, _ := eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusPending || got.EscalationLevel != 1 || len(got.ConfirmerIDs) != 2 {
+		t.Fatalf("after escalation: %+v", got)
+	}
+	if len(got.Transitions) != 1 || got.Transitions[0].Stage != "escalation:1" {
+		t.Errorf("transitions = %+v", got.Transitions)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-esc")
+	if len(evs) != 1 || evs[0].Decision != "escalated" || evs[0].Confirmer != "lead" {
+		t.Errorf("escalation evidence = %+v", evs)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "lead"); err != nil {
+		t.Fatalf("Submit by escalated approver: %v", err)
+	}
+}
+
+func TestEngineImpl_WorkflowStages(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	rec := &deliverRecorder{ch: make(chan []string, 4)}
+	eng := NewEngineImpl(store, 300, nil, rec, "any")
+	defer eng.Stop()
+	eng.SetWorkflows(map[string][]WorkflowStage{
+		"agent_onboarding": {
+			{Name: "owner"},
+			{Name: "security", ConfirmerIDs: []string{"sec1", "sec2"}, ApprovalPolicy: "all", Timeout: time.Hour},
+		},
+	})
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{TraceID: "t-wf", Resource: "agent://a1", Action: "onboard", Type: "agent_onboarding", ConfirmerIDs: []string{"owner1"}})
+	if got := <-rec.ch; len(got) != 1 || got[0] != "owner1" {
+		t.Fatalf("first stage delivered to %v", got)
+	}
+	if obj.CurrentStage() != "owner" || len(obj.Stages) != 2 {
+		t.Fatalf("stage = %q, stages = %+v", obj.CurrentStage(), obj.Stages)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "sec1"); err != ErrNotConfirmer {
+		t.Fatalf("second-stage approver in first stage: got %v, want ErrNotConfirmer", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "owner1"); err != nil {
+		t.Fatalf("Submit owner: %v", err)
+	}
+	select {
+	case got := <-rec.ch:
+		if len(got) != 2 || got[0] != "sec1" {
+			t.Fatalf("second stage delivered to %v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no second stage delivery")
+	}
+	got, _ := eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusPending || got.CurrentStage() != "security" || got.ApprovalPolicy != "all" {
+		t.Fatalf("after owner approval: status=%s stage=%s policy=%s", got.Status, got.CurrentStage(), got.ApprovalPolicy)
+	}
+	if time.Until(got.ExpiresAt) < 50*time.Minute {
+		t.Errorf("second stage expires at %v, want about an hour from now", got.ExpiresAt)
+	}
+	if len(got.Transitions) != 1 || got.Transitions[0].Stage != "stage:security" {
+		t.Errorf("transitions = %+v", got.Transitions)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "sec1"); err != nil {
+		t.Fatalf("Submit sec1: %v", err)
+	}
+	got, _ = eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusPending {
+		t.Fatalf("one of two security approvals should stay pending, got %s", got.Status)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "sec2"); err != nil {
+		t.Fatalf("Submit sec2: %v", err)
+	}
+	got, _ = eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusApproved || len(got.Votes) != 3 || got.Votes[0].Stage != "owner" || got.Votes[2].Stage != "security" {
+		t.Errorf("final: status=%s votes=%+v", got.Status, got.Votes)
+	}
+
+	// 其他类型不受流程影响
+	op, _ := eng.Create(ctx, &CreateInput{TraceID: "t-op", Resource: "/r", Action: "a", Type: "operation_approval", ConfirmerIDs: []string{"u1"}})
+	<-rec.ch
+	if len(op.Stages) != 0 || op.CurrentStage() != "" {
+		t.Errorf("operation_approval should be single stage, got %+v", op.Stages)
+	}
+}
+
+func TestEngineImpl_OnTimeoutAllowWithFlag(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	eng.SetAuditStore(auditStore)
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-flag", Resource: "/r", Action: "a", Summary: "s",
+		ExpiresAt: time.Now().Add(100 * time.Millisecond), Type: "op", OnTimeout: models.OnTimeoutAllowWithFlag,
+	})
+	ch, _ := eng.Watch(ctx, obj.ID)
+	var last *models.ConfirmationObject
+	for o := range ch {
+		last = o
+	}
+	if last.Status != models.ConfirmationStatusApproved || last.TimeoutAction != models.OnTimeoutAllowWithFlag {
+		t.Fatalf("final = %v action=%q", last.Status, last.TimeoutAction)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-flag")
+	if len(evs) != 1 || evs[0].PolicyRuleID != "cheq_timeout_allow" || evs[0].Decision != "approved" {
+		t.Errorf("timeout evidence = %+v", evs)
+	}
+}
+
+func TestEngineImpl_OnTimeoutEscalateOncall(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	rec := &deliverRecorder{ch: make(chan []string, 4)}
+	eng := NewEngineImpl(store, 300, nil, rec, "any")
+	defer eng.Stop()
+	eng.SetSecurityOncall([]string{"sec"}, 150*time.Millisecond)
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-oncall", Resource: "/r", Action: "a", Summary: "s",
+		ExpiresAt: time.Now().Add(100 * time.Millisecond), ConfirmerIDs: []string{"u1"}, Type: "op",
+		OnTimeout: models.OnTimeoutEscalateOncall,
+	})
+	<-rec.ch
+	select {
+	case got := <-rec.ch:
+		if len(got) != 1 || got[0] != "sec" {
+			t.Fatalf("oncall delivery to %v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no oncall delivery")
+	}
+	ch, _ := eng.Watch(ctx, obj.ID)
+	var last *models.ConfirmationObject
+	for o := range ch {
+		last = o
+	}
+	if last.Status != models.ConfirmationStatusExpired {
+		t.Fatalf("final status = %v", last.Status)
+	}
+	if len(last.Transitions) != 2 || last.Transitions[0].Stage != "oncall" || last.Transitions[1].Stage != "timeout:deny" {
+		t.Errorf("transitions = %+v", last.Transitions)
+	}
+}
+
+func TestEngineImpl_Submit_CommentAndReasonCode(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	defer eng.Stop()
+	obj, _ := eng.Create(ctx, &CreateInput{TraceID: "t1", Resource: "/admin", Action: "POST"})
+
+	if err := eng.SubmitWithOptions(ctx, obj.ID, false, "u1", &SubmitOptions{ReasonCode: "nope"}); err != ErrInvalidReasonCode {
+		t.Fatalf("unknown reason code: got %v, want ErrInvalidReasonCode", err)
+	}
+	opts := ParseSubmitOptions(url.Values{"reason_code": {models.ReasonTooBroad}, "comment": {" limit to /tmp "}}.Get)
+	if err := eng.SubmitWithOptions(ctx, obj.ID, false, "u1", opts); err != nil {
+		t.Fatalf("SubmitWithOptions: %v", err)
+	}
+	got, _ := eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusRejected || got.DecisionReasonCode != models.ReasonTooBroad || got.DecisionComment != "limit to /tmp" {
+		t.Errorf("expected rejected with too_broad and comment, got %s %q %q", got.Status, got.DecisionReasonCode, got.DecisionComment)
+	}
+	if len(got.Votes) != 1 || got.Votes[0].ReasonCode != models.ReasonTooBroad {
+		t.Errorf("vote should carry reason code, got %+v", got.Votes)
+	}
+}
+
+func TestEngineImpl_Submit_Amendment(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "all")
+	defer eng.Stop()
+	obj, _ := eng.Create(ctx, &CreateInput{TraceID: "t1", Resource: "local://h", Action: "exec:run", ConfirmerIDs: []string{"u1", "u2"}})
+
+	if err := eng.SubmitWithOptions(ctx, obj.ID, true, "u1", &SubmitOptions{BodyPatch: `{"dry_run":true}`}); err != ErrInvalidAmendment {
+		t.Fatalf("body_patch on exec: got %v, want ErrInvalidAmendment", err)
+	}
+	if err := eng.SubmitWithOptions(ctx, obj.ID, false, "u1", &SubmitOptions{AmendCommand: "ls"}); err != ErrInvalidAmendment {
+		t.Fatalf("amendment on reject: got %v, want ErrInvalidAmendment", err)
+	}
+	if err := eng.SubmitWithOptions(ctx, obj.ID, true, "u1", &SubmitOptions{AmendCommand: "rm -r /tmp/x"}); err != nil {
+		t.Fatalf("SubmitWithOptions: %v", err)
+	}
+	if err := eng.SubmitWithOptions(ctx, obj.ID, true, "u2", &SubmitOptions{AmendCommand: "rm /tmp/x"}); err != ErrAmendmentConflict {
+		t.Fatalf("different amendment: got %v, want ErrAmendmentConflict", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "u2"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	got, _ := eng.GetByID(ctx, obj.ID)
+	if got.Status != models.ConfirmationStatusApproved || got.Amendment == nil || got.Amendment.CommandLine != "rm -r /tmp/x" || got.Amendment.By != "u1" {
+		t.Errorf("expected approved with u1's amendment, got %s %+v", got.Status, got.Amendment)
+	}
+}
+
+func TestEngineImpl_CreateAttachesDuplicate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, _ := NewJSONStore(dir)
+	rec := &deliverRecorder{ch: make(chan []string, 4)}
+	eng := NewEngineImpl(store, 300, nil, rec, "any")
+	defer eng.Stop()
+
+	in := &CreateInput{TraceID: "t1", Resource: "/admin", Action: "POST", ConfirmerIDs: []string{"u1"}, Fingerprint: "fp-1"}
+	first, err := eng.Create(ctx, in)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second, err := eng.Create(ctx, &CreateInput{TraceID: "t2", Resource: "/admin", Action: "POST", ConfirmerIDs: []string{"u1"}, Fingerprint: "fp-1"})
+	if err != nil {
+		t.Fatalf("Create duplicate: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Fatalf("duplicate should attach to %s, got %s", first.ID, second.ID)
+	}
+	if len(second.Waiters) != 1 || second.Waiters[0] != "t2" {
+		t.Errorf("Waiters = %v, want [t2]", second.Waiters)
+	}
+	if n := len(rec.ch); n != 1 {
+		t.Errorf("duplicate should not be delivered again, deliveries = %d", n)
+	}
+
+	// 重启后仍能按指纹找到待确认对象
+	eng2 := NewEngineImpl(store, 300, nil, nil, "any")
+	defer eng2.Stop()
+	third, _ := eng2.Create(ctx, &CreateInput{TraceID: "t3", Fingerprint: "fp-1"})
+	if third.ID != first.ID {
+		t.Errorf("after restart duplicate should attach to %s, got %s", first.ID, third.ID)
+	}
+
+	if err := eng2.Submit(ctx, first.ID, true, "u1"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	fourth, _ := eng2.Create(ctx, &CreateInput{TraceID: "t4", Fingerprint: "fp-1"})
+	if fourth.ID == first.ID {
+		t.Error("request after decision should create a new object")
+	}
+}
+
+func TestEngineImpl_ListFilterAndPaginate(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	ctx := context.Background()
+
+	var ids []string
+	for i, in := range []*CreateInput{
+		{TraceID: "l1", Resource: "/admin/a", Action: "POST", ConfirmerIDs: []string{"u1"}, Type: "operation_approval"},
+		{TraceID: "l2", Resource: "/admin/b", Action: "POST", ConfirmerIDs: []string{"u1", "u2"}, Type: "operation_approval"},
+		{TraceID: "l3", Resource: "/api/c", Action: "GET", ConfirmerIDs: []string{"u2"}, Type: "operation_approval"},
+		{TraceID: "l4", Resource: "/admin/d", Action: "POST", ConfirmerIDs: []string{"u1"}, Type: "agent_onboarding"},
+	} {
+		obj, err := eng.Create(ctx, in)
+		if err != nil {
+			t.Fatalf("Create %d: %v", i, err)
+		}
+		ids = append(ids, obj.ID)
+		time.Sleep(2 * time.Millisecond) // 保证 CreatedAt 有序
+	}
+	_ = eng.Submit(ctx, ids[1], true, "")
+
+	res, err := eng.List(ctx, &ListFilter{Status: models.ConfirmationStatusPending, ConfirmerID: "u1", ResourcePrefix: "/admin"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(res.Items) != 2 || res.Items[0].ID != ids[0] || res.Items[1].ID != ids[3] {
+		t.Errorf("List pending u1 /admin: got %d items", len(res.Items))
+	}
+
+	res, _ = eng.List(ctx, &ListFilter{Type: "operation_approval", Limit: 2})
+	if len(res.Items) != 2 || res.NextCursor == "" {
+		t.Fatalf("first page: %d items, cursor %q", len(res.Items), res.NextCursor)
+	}
+	page2, err := eng.List(ctx, &ListFilter{Type: "operation_approval", Limit: 2, Cursor: res.NextCursor})
+	if err != nil {
+		t.Fatalf("List page 2: %v", err)
+	}
+	if len(page2.Items) != 1 || page2.Items[0].ID != ids[2] || page2.NextCursor != "" {
+		t.Errorf("second page: %+v", page2)
+	}
+
+	if _, err := eng.List(ctx, &ListFilter{Cursor: "!!"}); err != ErrInvalidCursor {
+		t.Errorf("bad cursor: want ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestLogStore_PutGetReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	s, err := NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("NewLogStore: %v", err)
+	}
+	now := time.Now()
+	o := &models.ConfirmationObject{ID: "c1", Status: models.ConfirmationStatusPending, CreatedAt: now, ExpiresAt: now.Add(time.Minute), Resource: "/a"}
+	if err := s.Put(ctx, o); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	o2 := *o
+	o2.Status = models.ConfirmationStatusApproved
+	if err := s.Put(ctx, &o2); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s.Put(ctx, &models.ConfirmationObject{ID: "c2", Status: models.ConfirmationStatusPending, CreatedAt: now.Add(time.Second)}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_ = s.Close()
+
+	s, err = NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	got, err := s.Get(ctx, "c1")
+	if err != nil || got == nil || got.Status != models.ConfirmationStatusApproved {
+		t.Fatalf("Get c1 after reopen: %+v, %v", got, err)
+	}
+	res, err := s.List(ctx, &ListFilter{Status: models.ConfirmationStatusPending})
+	if err != nil || len(res.Items) != 1 || res.Items[0].ID != "c2" {
+		t.Fatalf("List pending: %+v, %v", res, err)
+	}
+}
+
+func TestLogStore_TruncatesPartialTail(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	s, err := NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("NewLogStore: %v", err)
+	}
+	if err := s.Put(ctx, &models.ConfirmationObject{ID: "c1", Status: models.ConfirmationStatusPending}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_ = s.Close()
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"seq":2,"op":"put","obj":{"ID":"c2"`)
+	_ = f.Close()
+
+	s, err = NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got, _ := s.Get(ctx, "c2"); got != nil {
+		t.Fatal("partial record should be discarded")
+	}
+	if err := s.Put(ctx, &models.ConfirmationObject{ID: "c3", Status: models.ConfirmationStatusPending}); err != nil {
+		t.Fatalf("Put after truncate: %v", err)
+	}
+	_ = s.Close()
+	s, err = NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	for _, id := range []string{"c1", "c3"} {
+		if got, _ := s.Get(ctx, id); got == nil {
+			t.Errorf("%s missing after recovery", id)
+		}
+	}
+}
+
+func TestLogStore_SkipsCorruptMiddleRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	s, err := NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("NewLogStore: %v", err)
+	}
+	_ = s.Put(ctx, &models.ConfirmationObject{ID: "c1", Status: models.ConfirmationStatusPending})
+	_ = s.Close()
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("{garbage\n")
+	_, _ = f.WriteString(`{"seq":3,"op":"put","obj":{"ID":"c3","Status":"pending"}}` + "\n")
+	_ = f.Close()
+
+	s, err = NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	for _, id := range []string{"c1", "c3"} {
+		if got, _ := s.Get(ctx, id); got == nil {
+			t.Errorf("%s missing: records after a corrupt line should be replayed", id)
+		}
+	}
+}
+
+func TestLogStore_CompactDropsExpiredTerminal(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	s, err := NewLogStore(dir, LogStoreOptions{Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("NewLogStore: %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	_ = s.Put(ctx, &models.ConfirmationObject{ID: "old-done", Status: models.ConfirmationStatusApproved, CreatedAt: old, ExpiresAt: old})
+	_ = s.Put(ctx, &models.ConfirmationObject{ID: "old-pending", Status: models.ConfirmationStatusPending, CreatedAt: old, ExpiresAt: old})
+	_ = s.Put(ctx, &models.ConfirmationObject{ID: "new-done", Status: models.ConfirmationStatusRejected, CreatedAt: time.Now(), ExpiresAt: time.Now()})
+	// 保留期按进入终态的时间计算，而非 ExpiresAt
+	_ = s.Put(ctx, &models.ConfirmationObject{ID: "closed-old", Status: models.ConfirmationStatusApproved, CreatedAt: old, ExpiresAt: time.Now().Add(time.Hour), ClosedAt: old})
+	_ = s.Put(ctx, &models.ConfirmationObject{ID: "closed-new", Status: models.ConfirmationStatusExpired, CreatedAt: old, ExpiresAt: old, ClosedAt: time.Now()})
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, logFileName)); err != nil || fi.Size() != 0 {
+		t.Fatalf("log should be empty after compact: %v, %v", fi, err)
+	}
+	_ = s.Close()
+
+	s, err = NewLogStore(dir, LogStoreOptions{Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	for _, id := range []string{"old-done", "closed-old"} {
+		if got, _ := s.Get(ctx, id); got != nil {
+			t.Errorf("%s: terminal object past retention should be dropped", id)
+		}
+	}
+	for _, id := range []string{"old-pending", "new-done", "closed-new"} {
+		if got, _ := s.Get(ctx, id); got == nil {
+			t.Errorf("%s should be kept", id)
+		}
+	}
+}
+
+func TestLogStore_MigratesJSONStore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	js, err := NewJSONStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	for _, id := range []string{"m1", "m2"} {
+		if err := js.Put(ctx, &models.ConfirmationObject{ID: id, Status: models.ConfirmationStatusPending, Resource: "/r/" + id}); err != nil {
+			t.Fatalf("JSONStore.Put: %v", err)
+		}
+	}
+	s, err := NewLogStore(dir, LogStoreOptions{})
+	if err != nil {
+		t.Fatalf("NewLogStore: %v", err)
+	}
+	defer s.Close()
+	for _, id := range []string{"m1", "m2"} {
+		got, _ := s.Get(ctx, id)
+		if got == nil || got.Resource != "/r/"+id {
+			t.Errorf("migrated %s: %+v", id, got)
+		}
+		if _, err := os.Stat(filepath.Join(dir, id+".json")); !os.IsNotExist(err) {
+			t.Errorf("%s.json should be removed after migration", id)
+		}
+	}
+}
+
+func TestEngineImpl_Cancel(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	auditStore := audit.NewStubStore()
+	eng.SetAuditStore(auditStore)
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-cancel", Resource: "/r", Action: "a", Summary: "s",
+		ConfirmerIDs: []string{"u1"}, Type: "op", Requester: "agent-1",
+	})
+	other, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-other", Resource: "/r", Action: "a", Summary: "s",
+		ConfirmerIDs: []string{"u1"}, Type: "op", Requester: "agent-2",
+	})
+	res, _ := eng.List(ctx, &ListFilter{Status: models.ConfirmationStatusPending, Requester: "agent-1"})
+	if len(res.Items) != 1 || res.Items[0].ID != obj.ID {
+		t.Fatalf("List by requester = %+v", res.Items)
+	}
+	ch, _ := eng.Watch(ctx, obj.ID)
+	<-ch
+	if err := eng.Cancel(ctx, obj.ID, "ops", "agent quarantined"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	select {
+	case got := <-ch:
+		if got.Status != models.ConfirmationStatusCancelled || got.CancelledBy != "ops" || got.DecisionComment != "agent quarantined" {
+			t.Errorf("pushed object = %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no push after Cancel")
+	}
+	if err := eng.Cancel(ctx, obj.ID, "ops", "again"); err != ErrAlreadyProcessed {
+		t.Errorf("second Cancel: want ErrAlreadyProcessed, got %v", err)
+	}
+	if err := eng.Submit(ctx, obj.ID, true, "u1"); err != ErrAlreadyProcessed {
+		t.Errorf("Submit after Cancel: want ErrAlreadyProcessed, got %v", err)
+	}
+	if err := eng.Cancel(ctx, "nonexistent-id", "ops", "x"); err != ErrNotFound {
+		t.Errorf("Cancel not found: want ErrNotFound, got %v", err)
+	}
+	if got, _ := eng.GetByID(ctx, other.ID); got.Status != models.ConfirmationStatusPending {
+		t.Errorf("other requester's object should stay pending, got %v", got.Status)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-cancel")
+	if len(evs) != 1 || evs[0].Decision != "cancelled" || evs[0].PolicyRuleID != "cheq_cancel" || evs[0].Confirmer != "ops" {
+		t.Errorf("cancel audit = %+v", evs)
+	}
+}
+
+func TestEngineImpl_Withdraw(t *testing.T) {
+	store, _ := NewJSONStore(t.TempDir())
+	eng := NewEngineImpl(store, 300, nil, nil, "any")
+	auditStore := audit.NewStubStore()
+	eng.SetAuditStore(auditStore)
+	ctx := context.Background()
+
+	obj, _ := eng.Create(ctx, &CreateInput{
+		TraceID: "t-withdraw", Resource: "/r", Action: "exec:run", Summary: "s",
+		ConfirmerIDs: []string{"u1"}, Type: "op", Requester: "agent-1",
+	})
+	if err := eng.Withdraw(ctx, obj.ID, "agent-2", "ctrl-c"); err != ErrNotRequester {
+		t.Errorf("Withdraw by other requester: want ErrNotRequester, got %v", err)
+	}
+	if err := eng.Withdraw(ctx, obj.ID, "", "ctrl-c"); err != ErrNotRequester {
+		t.Errorf("Withdraw without requester: want ErrNotRequester, got %v", err)
+	}
+	if err := eng.Withdraw(ctx, obj.ID, "agent-1", "ctrl-c"); err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+	if got, _ := eng.GetByID(ctx, obj.ID); got.Status != models.ConfirmationStatusCancelled || got.CancelledBy != "agent-1" {
+		t.Errorf("withdrawn object = %+v", got)
+	}
+	if err := eng.Withdraw(ctx, obj.ID, "agent-1", "again"); err != ErrAlreadyProcessed {
+		t.Errorf("second Withdraw: want ErrAlreadyProcessed, got %v", err)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-withdraw")
+	if len(evs) != 1 || evs[0].PolicyRuleID != "cheq_withdraw" || evs[0].Confirmer != "agent-1" {
+		t.Errorf("withdraw audit = %+v", evs)
+	}
+}
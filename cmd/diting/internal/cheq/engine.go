@@ -7,14 +7,28 @@ import (
 )
 
 // Engine CHEQ 统一确认引擎接口。
-// Create 创建待确认对象；GetByID 查询状态；Submit 幂等提交确认结果。
-// 超时语义：由实现侧在 GetByID 或后台任务中将过期对象置为 expired。
+// Create 创建待确认对象；GetByID 查询状态；Submit 幂等提交确认结果；Watch 订阅状态变化；List 查询待审队列。
+// 超时语义：由实现侧按 ExpiresAt 定时将过期对象置为 expired，并通知 Watch 订阅者。
 type Engine interface {
 	// Create 创建 ConfirmationObject，返回带 ID 的对象；后续可投递并等待 Submit。
+	// in.Fingerprint 非空且已有同指纹的待确认对象时不新建、不投递，将 in.TraceID 记入其 Waiters 并返回该对象。
 	Create(ctx context.Context, in *CreateInput) (*models.ConfirmationObject, error)
 	// GetByID 根据 id 查询当前状态；若已过期则 Status 为 expired。
 	GetByID(ctx context.Context, id string) (*models.ConfirmationObject, error)
 	// Submit 幂等提交确认结果；已处理或已过期返回 ErrAlreadyProcessed/ErrExpired。
 	// confirmerID 用于 I-008「全部通过」时记录谁批准；为空时按「任一通过」处理。
 	Submit(ctx context.Context, id string, approved bool, confirmerID string) error
+	// SubmitWithOptions 同 Submit，opts 携带审批人的附加选择（如限时授权范围）；opts 为 nil 等价于 Submit。
+	SubmitWithOptions(ctx context.Context, id string, approved bool, confirmerID string, opts *SubmitOptions) error
+	// Cancel 撤销未终态对象（如 Agent 被隔离或发起方中止），置为 cancelled 并通知订阅者；by 与 reason 记入对象与审计。
+	// 已终态返回 ErrAlreadyProcessed，id 不存在返回 ErrNotFound。
+	Cancel(ctx context.Context, id, by, reason string) error
+	// Withdraw 由发起方撤回自己的未终态对象（如 3af-exec 用户 Ctrl-C），效果同 Cancel，by 记为 requester；
+	// requester 与对象的 Requester 不一致时返回 ErrNotRequester。
+	Withdraw(ctx context.Context, id, requester, reason string) error
+	// Watch 订阅 id 的状态变化：先推送当前快照，之后每次变更推送最新对象；终态或 ctx 取消后关闭 channel。
+	// 订阅者只读对象，慢消费者只会看到最新一次状态；id 不存在返回 ErrNotFound。
+	Watch(ctx context.Context, id string) (<-chan *models.ConfirmationObject, error)
+	// List 按状态、确认人、资源前缀、类型、创建时间过滤并游标分页，供审批队列与看板使用；filter 为 nil 表示不过滤。
+	List(ctx context.Context, filter *ListFilter) (*ListResult, error)
 }
@@ -2,10 +2,15 @@ package cheq
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"sync"
+	"strings"
 	"time"
 
+	"diting/internal/audit"
 	"diting/internal/delivery"
 	"diting/internal/models"
 	"diting/internal/ownership"
@@ -13,28 +18,46 @@ import (
 )
 
 // EngineImpl 持久化 CHEQ：Create 时解析确认人并投递，GetByID/Submit 读写 store。
+// 状态变更在 mu 下串行并通知 Watch 订阅者；过期由按 ExpiresAt 设置的定时器驱动。
 type EngineImpl struct {
-	store         *JSONStore
-	timeout       time.Duration
-	resolve       ownership.Resolver
-	delivery      delivery.Provider
-	approvalPolicy string // "any" 或 "all"（I-008）
+	store          Store
+	timeout        time.Duration
+	resolve        ownership.Resolver
+	delivery       delivery.Provider
+	approvalPolicy string // 默认审批策略：any / all / quorum:N / roles:...（I-008）
+
+	mu     sync.Mutex
+	hub    *watchHub
+	timers map[string]*time.Timer // id -> 过期定时器，仅非终态对象持有
+	audit  audit.Store            // 可选：过期、升级与超时动作写审计
+
+	oncallIDs     []string      // 安全值班确认人，on_timeout=escalate_oncall 时投递
+	oncallTimeout time.Duration // 转值班后再等待的时长
+	roles         map[string]string // 确认人 -> 角色，roles 策略计票使用
+	workflows     map[string][]WorkflowStage // ConfirmationObject.Type -> 多阶段审批流程
+
+	pending       map[string]string // 请求指纹 -> 待确认对象 id，重复请求挂接用
+	pendingLoaded bool              // 是否已从 store 载入重启前的待确认对象指纹
+
+	sweepDone chan struct{}
+	sweepWG   sync.WaitGroup
 }
 
-// NewEngineImpl 创建带持久化与投递的 CHEQ 引擎。approvalPolicy 为 "any"（任一通过）或 "all"（全部通过），空则按 "any"。
-func NewEngineImpl(store *JSONStore, timeoutSeconds int, resolve ownership.Resolver, deliver delivery.Provider, approvalPolicy string) *EngineImpl {
+// NewEngineImpl 创建带持久化与投递的 CHEQ 引擎。approvalPolicy 为默认审批策略（any、all、quorum:N、roles:role=N,...），空或无法解析则按 "any"。
+func NewEngineImpl(store Store, timeoutSeconds int, resolve ownership.Resolver, deliver delivery.Provider, approvalPolicy string) *EngineImpl {
 	if timeoutSeconds <= 0 {
 		timeoutSeconds = 300
 	}
-	if approvalPolicy != "all" {
-		approvalPolicy = "any"
-	}
+	approvalPolicy = ownership.NormalizeApprovalPolicy(approvalPolicy, ownership.PolicyAny)
 	return &EngineImpl{
 		store:          store,
 		timeout:        time.Duration(timeoutSeconds) * time.Second,
 		resolve:        resolve,
 		delivery:       deliver,
 		approvalPolicy: approvalPolicy,
+		hub:            newWatchHub(),
+		timers:         make(map[string]*time.Timer),
+		pending:        make(map[string]string),
 	}
 }
 
@@ -43,29 +66,52 @@ func (e *EngineImpl) Create(ctx context.Context, in *CreateInput) (*models.Confi
 	if in == nil {
 		return nil, fmt.Errorf("cheq: nil create input")
 	}
+	if in.Fingerprint != "" {
+		obj, err := e.attach(ctx, in)
+		if err != nil || obj != nil {
+			return obj, err
+		}
+	}
 	id := uuid.New().String()
+	createdAt := time.Now()
 	expiresAt := in.ExpiresAt
 	if expiresAt.IsZero() {
-		expiresAt = time.Now().Add(e.timeout)
+		expiresAt = createdAt.Add(e.timeout)
 	}
 	confirmerIDs := in.ConfirmerIDs
-	if e.resolve != nil {
+	if e.resolve != nil && !(in.KeepConfirmers && len(confirmerIDs) > 0) {
 		ids, _ := e.resolve.Resolve(ctx, in.Resource, in.Action)
 		if len(ids) > 0 {
 			confirmerIDs = ids
 		}
 	}
 	policy := e.approvalPolicy
-	if in.ApprovalPolicy == "all" {
-		policy = "all"
-	} else if in.ApprovalPolicy != "" {
-		policy = "any"
+	if in.ApprovalPolicy != "" {
+		policy = ownership.NormalizeApprovalPolicy(in.ApprovalPolicy, e.approvalPolicy)
+	}
+	stages := e.stagesFor(in.Type, confirmerIDs, policy, expiresAt.Sub(createdAt))
+	if len(stages) > 0 {
+		confirmerIDs, policy = stages[0].ConfirmerIDs, stages[0].ApprovalPolicy
+		expiresAt = createdAt.Add(stages[0].Timeout)
+	}
+	var plan []models.EscalationStage
+	for _, st := range in.Escalation {
+		at := createdAt.Add(st.After)
+		if len(st.ConfirmerIDs) == 0 || !at.Before(expiresAt) {
+			continue
+		}
+		plan = append(plan, models.EscalationStage{At: at, ConfirmerIDs: append([]string(nil), st.ConfirmerIDs...)})
+	}
+	sort.SliceStable(plan, func(i, j int) bool { return plan[i].At.Before(plan[j].At) })
+	onTimeout := in.OnTimeout
+	if onTimeout != models.OnTimeoutAllowWithFlag && onTimeout != models.OnTimeoutEscalateOncall {
+		onTimeout = models.OnTimeoutDeny
 	}
 	obj := &models.ConfirmationObject{
 		ID:              id,
 		TraceID:         in.TraceID,
 		Status:          models.ConfirmationStatusPending,
-		CreatedAt:       time.Now(),
+		CreatedAt:       createdAt,
 		ExpiresAt:       expiresAt,
 		Resource:        in.Resource,
 		Action:          in.Action,
@@ -73,12 +119,27 @@ func (e *EngineImpl) Create(ctx context.Context, in *CreateInput) (*models.Confi
 		ConfirmerIDs:    confirmerIDs,
 		Type:            in.Type,
 		ApprovalPolicy:  policy,
+		RejectMode:      ownership.NormalizeRejectMode(in.RejectMode),
+		Requester:       in.Requester,
+		RequesterOwner:  in.RequesterOwner,
+		SessionID:       in.SessionID,
+		Fingerprint:     in.Fingerprint,
+		EscalationPlan:  plan,
+		OnTimeout:       onTimeout,
+		Stages:          stages,
 	}
+	e.mu.Lock()
 	if err := e.store.Put(ctx, obj); err != nil {
+		e.mu.Unlock()
 		return nil, err
 	}
+	e.armLocked(obj)
+	if obj.Fingerprint != "" {
+		e.pending[obj.Fingerprint] = obj.ID
+	}
+	e.mu.Unlock()
 	if e.delivery != nil {
-		opts := &delivery.DeliverOptions{ConfirmerIDs: confirmerIDs, Summary: in.Summary, ChannelType: "feishu"}
+		opts := &delivery.DeliverOptions{ConfirmerIDs: confirmerIDs, Summary: stagePrefix(obj) + in.Summary, ChannelType: "feishu", OnSent: e.deliverySink(obj.ID)}
 		if err := e.delivery.Deliver(ctx, &delivery.DeliverInput{Object: obj, Options: opts}); err != nil {
 			fmt.Fprintf(os.Stderr, "[diting] [cheq] 飞书投递失败（请求仍待确认，可凭终端中的链接批准）: %v\n", err)
 		}
@@ -86,21 +147,123 @@ func (e *EngineImpl) Create(ctx context.Context, in *CreateInput) (*models.Confi
 	return obj, nil
 }
 
-// GetByID 从 store 读取；若已过期则更新为 expired 并写回。
+// stagesFor 按 typ 查找多阶段流程并展开为对象上的阶段快照：未配置审批人、策略或时限的阶段沿用请求的值。
+// 未配置流程时返回 nil。
+func (e *EngineImpl) stagesFor(typ string, confirmerIDs []string, policy string, timeout time.Duration) []models.WorkflowStage {
+	e.mu.Lock()
+	wf := e.workflows[typ]
+	e.mu.Unlock()
+	if len(wf) == 0 {
+		return nil
+	}
+	stages := make([]models.WorkflowStage, len(wf))
+	for i, st := range wf {
+		stages[i] = models.WorkflowStage{Name: st.Name, ConfirmerIDs: st.ConfirmerIDs, ApprovalPolicy: policy, Timeout: st.Timeout}
+		if stages[i].Name == "" {
+			stages[i].Name = fmt.Sprintf("stage%d", i+1)
+		}
+		if len(st.ConfirmerIDs) == 0 {
+			stages[i].ConfirmerIDs = confirmerIDs
+		}
+		stages[i].ConfirmerIDs = append([]string(nil), stages[i].ConfirmerIDs...)
+		if st.ApprovalPolicy != "" {
+			stages[i].ApprovalPolicy = ownership.NormalizeApprovalPolicy(st.ApprovalPolicy, policy)
+		}
+		if st.Timeout <= 0 {
+			stages[i].Timeout = timeout
+		}
+	}
+	return stages
+}
+
+// stagePrefix 返回投递摘要的阶段前缀，如「【第 2/3 步：security】」；单阶段对象为空。
+func stagePrefix(obj *models.ConfirmationObject) string {
+	if len(obj.Stages) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("【第 %d/%d 步：%s】", obj.StageIndex+1, len(obj.Stages), obj.CurrentStage())
+}
+
+// attach 查找与 in.Fingerprint 相同的待确认对象，找到则将 in.TraceID 记入 Waiters 并返回；无则返回 nil, nil。
+func (e *EngineImpl) attach(ctx context.Context, in *CreateInput) (*models.ConfirmationObject, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.pendingLoaded {
+		e.loadPendingLocked(ctx)
+	}
+	id := e.pending[in.Fingerprint]
+	if id == "" {
+		return nil, nil
+	}
+	obj, err := e.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil || obj.IsTerminal() || !time.Now().Before(obj.ExpiresAt) {
+		delete(e.pending, in.Fingerprint)
+		return nil, nil
+	}
+	if in.TraceID != "" && in.TraceID != obj.TraceID && !containsID(obj.Waiters, in.TraceID) {
+		obj.Waiters = append(obj.Waiters, in.TraceID)
+		if err := e.commitLocked(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "[diting] [cheq] 重复请求已合并到待确认对象 id=%s trace_id=%s\n", obj.ID, in.TraceID)
+	return obj, nil
+}
+
+// loadPendingLocked 载入 store 中待确认对象的指纹（重启后首次去重时调用）。调用方须持有 mu。
+func (e *EngineImpl) loadPendingLocked(ctx context.Context) {
+	f := &ListFilter{Status: models.ConfirmationStatusPending, Limit: maxListLimit}
+	for {
+		res, err := e.store.List(ctx, f)
+		if err != nil {
+			return
+		}
+		for _, obj := range res.Items {
+			if obj.Fingerprint != "" {
+				e.pending[obj.Fingerprint] = obj.ID
+			}
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		f.Cursor = res.NextCursor
+	}
+	e.pendingLoaded = true
+}
+
+// GetByID 从 store 读取，不在读路径上改写状态；未终态对象若尚无过期定时器（如重启后）则补设。
 func (e *EngineImpl) GetByID(ctx context.Context, id string) (*models.ConfirmationObject, error) {
 	obj, err := e.store.Get(ctx, id)
 	if err != nil || obj == nil {
 		return nil, err
 	}
-	if !obj.IsTerminal() && time.Now().After(obj.ExpiresAt) {
-		obj.Status = models.ConfirmationStatusExpired
-		_ = e.store.Put(ctx, obj)
+	if !obj.IsTerminal() {
+		e.mu.Lock()
+		e.armLocked(obj)
+		e.mu.Unlock()
 	}
 	return obj, nil
 }
 
-// Submit 幂等提交；已终态或过期返回对应错误。confirmerID 用于「全部通过」时记录谁批准。
+// Submit 记录 confirmerID 的一票并按审批策略判定终态；已终态或过期返回对应错误。
+// confirmerID 非空时须在 ConfirmerIDs 中（ConfirmerIDs 为空时不限制）；all/quorum/roles 策略下 confirmerID 不可为空。
+// 同一确认人重复投相同票为幂等，投相反票返回 ErrAlreadyVoted。发起方或其 owner 提交返回 ErrSelfApproval。
+// 因身份不符被拒的提交会写一条 approval_rejected 审计。
 func (e *EngineImpl) Submit(ctx context.Context, id string, approved bool, confirmerID string) error {
+	return e.SubmitWithOptions(ctx, id, approved, confirmerID, nil)
+}
+
+// SubmitWithOptions 实现 Engine.SubmitWithOptions：opts 中的授权选择（仅批准票有效）、备注与原因码记录在本票上；
+// 本票促成终态时备注与原因码同时写入对象，反馈给发起方。原因码未定义返回 ErrInvalidReasonCode。
+func (e *EngineImpl) SubmitWithOptions(ctx context.Context, id string, approved bool, confirmerID string, opts *SubmitOptions) error {
+	if opts != nil && !models.ValidReasonCode(opts.ReasonCode) {
+		return ErrInvalidReasonCode
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	obj, err := e.store.Get(ctx, id)
 	if err != nil {
 		return err
@@ -108,44 +271,614 @@ func (e *EngineImpl) Submit(ctx context.Context, id string, approved bool, confi
 	if obj == nil {
 		return ErrNotFound
 	}
+	if obj.Status == models.ConfirmationStatusExpired {
+		return ErrExpired
+	}
 	if obj.IsTerminal() {
 		return ErrAlreadyProcessed
 	}
 	if time.Now().After(obj.ExpiresAt) {
-		obj.Status = models.ConfirmationStatusExpired
-		_ = e.store.Put(ctx, obj)
-		return ErrExpired
+		// 定时器尚未触发：先执行超时动作；转值班后对象仍待确认，本次提交继续处理
+		_ = e.timeoutLocked(ctx, obj)
+		if obj.Status == models.ConfirmationStatusExpired {
+			return ErrExpired
+		}
+		if obj.IsTerminal() {
+			return ErrAlreadyProcessed
+		}
+	}
+	policy := e.policyOf(obj)
+	if confirmerID == "" {
+		if policy.MultiVote() {
+			e.appendAudit(ctx, obj, "approval_rejected", "cheq_approver_auth", ErrConfirmerRequired.Error(), nil)
+			return ErrConfirmerRequired
+		}
+	} else if confirmerID == obj.Requester || confirmerID == obj.RequesterOwner {
+		e.appendAudit(ctx, obj, "approval_rejected", "cheq_separation_of_duty", ErrSelfApproval.Error(), []string{confirmerID})
+		return ErrSelfApproval
+	} else if len(obj.ConfirmerIDs) > 0 && !containsID(obj.ConfirmerIDs, confirmerID) {
+		e.appendAudit(ctx, obj, "approval_rejected", "cheq_approver_auth", ErrNotConfirmer.Error(), []string{confirmerID})
+		return ErrNotConfirmer
+	}
+	decision := models.VoteReject
+	if approved {
+		decision = models.VoteApprove
+	}
+	amendment, err := amendmentOf(obj, approved, confirmerID, opts)
+	if err != nil {
+		return err
+	}
+	stage := obj.CurrentStage()
+	if confirmerID != "" {
+		for _, v := range obj.Votes {
+			if v.ConfirmerID != confirmerID || v.Stage != stage {
+				continue
+			}
+			if v.Decision == decision {
+				return nil
+			}
+			return ErrAlreadyVoted
+		}
+	}
+	vote := newVote(confirmerID, decision, e.roleOf(confirmerID), opts)
+	vote.Stage = stage
+	obj.Votes = append(obj.Votes, vote)
+	if amendment != nil {
+		obj.Amendment = amendment
 	}
-	if !approved {
+	if approved && confirmerID != "" {
+		obj.ApprovedBy = append(obj.ApprovedBy, confirmerID)
+	}
+	approvers, rejecters := tallyVotes(obj.Votes, stage)
+	switch {
+	case approved && policy.Satisfied(approvers, obj.ConfirmerIDs, e.roleOf) && obj.StageIndex+1 < len(obj.Stages):
+		return e.nextStageLocked(ctx, obj)
+	case approved && policy.Satisfied(approvers, obj.ConfirmerIDs, e.roleOf):
+		obj.Status = models.ConfirmationStatusApproved
+	case !approved && (obj.RejectMode != ownership.RejectQuorum || !e.stillSatisfiable(policy, obj, approvers, rejecters)):
 		obj.Status = models.ConfirmationStatusRejected
-		return e.store.Put(ctx, obj)
-	}
-	// approved == true
-	policy := obj.ApprovalPolicy
-	if policy == "" {
-		policy = e.approvalPolicy
-	}
-	if policy == "all" {
-		if obj.ApprovedBy == nil {
-			obj.ApprovedBy = []string{}
-		}
-		already := false
-		for _, x := range obj.ApprovedBy {
-			if x == confirmerID {
-				already = true
-				break
+	}
+	if obj.IsTerminal() {
+		obj.DecisionComment, obj.DecisionReasonCode = vote.Comment, vote.ReasonCode
+	}
+	// 未达策略时仅写回投票记录，不设终态
+	return e.commitLocked(ctx, obj)
+}
+
+// Cancel 实现 Engine.Cancel：撤销定时器、写 cancelled 审计并通知投递渠道更新卡片。
+func (e *EngineImpl) Cancel(ctx context.Context, id, by, reason string) error {
+	return e.cancel(ctx, id, by, reason, false)
+}
+
+// Withdraw 实现 Engine.Withdraw。
+func (e *EngineImpl) Withdraw(ctx context.Context, id, requester, reason string) error {
+	return e.cancel(ctx, id, requester, reason, true)
+}
+
+// cancel 置为 cancelled；onlyRequester 为 true 时 by 须为对象的发起方。
+func (e *EngineImpl) cancel(ctx context.Context, id, by, reason string, onlyRequester bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	obj, err := e.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		return ErrNotFound
+	}
+	if onlyRequester && (by == "" || obj.Requester != by) {
+		return ErrNotRequester
+	}
+	if obj.IsTerminal() {
+		return ErrAlreadyProcessed
+	}
+	obj.Status = models.ConfirmationStatusCancelled
+	obj.CancelledBy, obj.DecisionComment, obj.DecisionReasonCode = by, reason, ""
+	if err := e.commitLocked(ctx, obj); err != nil {
+		return err
+	}
+	ruleID := "cheq_cancel"
+	if onlyRequester {
+		ruleID = "cheq_withdraw"
+	}
+	e.appendAudit(ctx, obj, string(models.ConfirmationStatusCancelled), ruleID, "confirmation cancelled: "+reason, []string{by})
+	return nil
+}
+
+// amendmentOf 校验 opts 中的改写并返回待写入对象的改写；无改写或与已有改写相同时返回 nil。
+func amendmentOf(obj *models.ConfirmationObject, approved bool, confirmerID string, opts *SubmitOptions) (*models.Amendment, error) {
+	if opts == nil || (opts.AmendCommand == "" && opts.BodyPatch == "") {
+		return nil, nil
+	}
+	isExec := strings.HasPrefix(obj.Action, "exec:")
+	if !approved || (opts.AmendCommand != "" && !isExec) || (opts.BodyPatch != "" && isExec) {
+		return nil, ErrInvalidAmendment
+	}
+	if opts.BodyPatch != "" {
+		var patch map[string]interface{}
+		if err := json.Unmarshal([]byte(opts.BodyPatch), &patch); err != nil {
+			return nil, ErrInvalidAmendment
+		}
+	}
+	if a := obj.Amendment; a != nil {
+		if a.CommandLine == opts.AmendCommand && a.BodyPatch == opts.BodyPatch {
+			return nil, nil
+		}
+		return nil, ErrAmendmentConflict
+	}
+	return &models.Amendment{CommandLine: opts.AmendCommand, BodyPatch: opts.BodyPatch, By: confirmerID, At: time.Now()}, nil
+}
+
+// newVote 构建一张投票，附带 opts 中的授权选择（仅批准票）、备注与原因码。
+func newVote(confirmerID, decision, role string, opts *SubmitOptions) models.Vote {
+	v := models.Vote{ConfirmerID: confirmerID, Decision: decision, Role: role, At: time.Now()}
+	if opts == nil {
+		return v
+	}
+	if decision == models.VoteApprove {
+		v.GrantScope, v.GrantMinutes = opts.GrantScope, opts.GrantMinutes
+	}
+	v.Comment = opts.Comment
+	if len(v.Comment) > MaxCommentLen {
+		v.Comment = strings.ToValidUTF8(v.Comment[:MaxCommentLen], "")
+	}
+	v.ReasonCode = opts.ReasonCode
+	return v
+}
+
+// policyOf 返回对象的审批策略；对象未记录或无法解析时用引擎默认，默认也无法解析时按 all（从严）。
+func (e *EngineImpl) policyOf(obj *models.ConfirmationObject) ownership.ApprovalPolicy {
+	s := obj.ApprovalPolicy
+	if s == "" {
+		s = e.approvalPolicy
+	}
+	p, err := ownership.ParseApprovalPolicy(s)
+	if err != nil {
+		return ownership.ApprovalPolicy{Kind: ownership.PolicyAll}
+	}
+	return p
+}
+
+// stillSatisfiable 判断已批准者加上尚未投票的确认人是否仍可能满足策略；ConfirmerIDs 为空时不限投票人，视为仍可能。
+func (e *EngineImpl) stillSatisfiable(policy ownership.ApprovalPolicy, obj *models.ConfirmationObject, approvers, rejecters []string) bool {
+	if len(obj.ConfirmerIDs) == 0 {
+		return true
+	}
+	potential := append([]string(nil), approvers...)
+	for _, c := range obj.ConfirmerIDs {
+		if !containsID(approvers, c) && !containsID(rejecters, c) {
+			potential = append(potential, c)
+		}
+	}
+	return policy.Satisfied(potential, obj.ConfirmerIDs, e.roleOf)
+}
+
+// roleOf 返回确认人所属角色；未配置时为空。
+func (e *EngineImpl) roleOf(confirmerID string) string {
+	return e.roles[confirmerID]
+}
+
+// tallyVotes 按 stage 阶段的投票记录分出批准者与拒绝者。
+func tallyVotes(votes []models.Vote, stage string) (approvers, rejecters []string) {
+	for _, v := range votes {
+		if v.Stage != stage {
+			continue
+		}
+		if v.Decision == models.VoteApprove {
+			approvers = append(approvers, v.ConfirmerID)
+		} else {
+			rejecters = append(rejecters, v.ConfirmerID)
+		}
+	}
+	return approvers, rejecters
+}
+
+func containsID(ids []string, id string) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch 实现 Engine.Watch；在 mu 下读取快照并注册，保证与 Submit/过期的通知顺序一致。
+func (e *EngineImpl) Watch(ctx context.Context, id string) (<-chan *models.ConfirmationObject, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	obj, err := e.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrNotFound
+	}
+	e.armLocked(obj)
+	return e.hub.watch(ctx, obj), nil
+}
+
+// List 实现 Engine.List，直接查询 store。
+func (e *EngineImpl) List(ctx context.Context, filter *ListFilter) (*ListResult, error) {
+	return e.store.List(ctx, filter)
+}
+
+// commitLocked 持久化 obj 并通知订阅者；进入终态时记录 ClosedAt 并撤销过期定时器。调用方须持有 mu。
+func (e *EngineImpl) commitLocked(ctx context.Context, obj *models.ConfirmationObject) error {
+	if obj.IsTerminal() && obj.ClosedAt.IsZero() {
+		obj.ClosedAt = time.Now()
+	}
+	if err := e.store.Put(ctx, obj); err != nil {
+		return err
+	}
+	if obj.IsTerminal() {
+		if t := e.timers[obj.ID]; t != nil {
+			t.Stop()
+			delete(e.timers, obj.ID)
+		}
+		if obj.Fingerprint != "" && e.pending[obj.Fingerprint] == obj.ID {
+			delete(e.pending, obj.Fingerprint)
+		}
+	}
+	e.hub.publish(obj)
+	if obj.IsTerminal() {
+		e.notifyStatus(obj)
+	}
+	return nil
+}
+
+// notifyStatus 异步通知投递渠道更新卡片/消息（Provider 实现 delivery.StatusNotifier 时）。
+func (e *EngineImpl) notifyStatus(obj *models.ConfirmationObject) {
+	n, ok := e.delivery.(delivery.StatusNotifier)
+	if !ok {
+		return
+	}
+	go func() {
+		if err := n.NotifyStatus(context.Background(), obj); err != nil {
+			fmt.Fprintf(os.Stderr, "[diting] [cheq] 更新投递状态失败 id=%s: %v\n", obj.ID, err)
+		}
+	}()
+}
+
+// expireLocked 将未终态对象置为 expired 并通知订阅者。有订阅者（等待中的请求）时由其写 expired 审计；
+// 无人等待（如重启后遗留的对象）时由引擎写，保证每个 trace 恰好一条。调用方须持有 mu。
+func (e *EngineImpl) expireLocked(ctx context.Context, obj *models.ConfirmationObject) error {
+	obj.Status = models.ConfirmationStatusExpired
+	waited := e.hub.watched(obj.ID)
+	if err := e.commitLocked(ctx, obj); err != nil {
+		return err
+	}
+	if !waited {
+		e.appendAudit(ctx, obj, string(models.ConfirmationStatusExpired), "cheq_expiry", "confirmation expired without decision", obj.ConfirmerIDs)
+	}
+	return nil
+}
+
+// appendAudit 写一条引擎侧审计（过期、升级、超时动作）；未设置审计存储时忽略。
+func (e *EngineImpl) appendAudit(ctx context.Context, obj *models.ConfirmationObject, decision, ruleID, reason string, confirmerIDs []string) {
+	if e.audit == nil {
+		return
+	}
+	_ = e.audit.Append(ctx, &models.Evidence{
+		TraceID:        obj.TraceID,
+		AgentID:        obj.Requester,
+		PolicyRuleID:   ruleID,
+		DecisionReason: reason,
+		Decision:       decision,
+		CHEQStatus:     string(obj.Status),
+		Confirmer:      strings.Join(confirmerIDs, ","),
+		Timestamp:      time.Now(),
+		Resource:       obj.Resource,
+		Action:         obj.Action,
+	})
+}
+
+// nextDeadline 返回对象下一个需要处理的时刻：未触发的升级阶段或最终超时。
+func nextDeadline(obj *models.ConfirmationObject) time.Time {
+	if obj.EscalationLevel < len(obj.EscalationPlan) {
+		if at := obj.EscalationPlan[obj.EscalationLevel].At; at.Before(obj.ExpiresAt) {
+			return at
+		}
+	}
+	return obj.ExpiresAt
+}
+
+// advanceLocked 处理已到期的升级阶段或最终超时，返回是否有状态变化。调用方须持有 mu。
+func (e *EngineImpl) advanceLocked(ctx context.Context, obj *models.ConfirmationObject, now time.Time) (bool, error) {
+	if obj.IsTerminal() || now.Before(nextDeadline(obj)) {
+		return false, nil
+	}
+	if now.Before(obj.ExpiresAt) {
+		return true, e.escalateLocked(ctx, obj, now)
+	}
+	return true, e.timeoutLocked(ctx, obj)
+}
+
+// escalateLocked 触发下一个升级阶段：阶段审批人并入 ConfirmerIDs、记录流转、写审计并重新投递。调用方须持有 mu。
+func (e *EngineImpl) escalateLocked(ctx context.Context, obj *models.ConfirmationObject, now time.Time) error {
+	stage := obj.EscalationPlan[obj.EscalationLevel]
+	obj.EscalationLevel++
+	added := mergeConfirmers(obj, stage.ConfirmerIDs)
+	reason := fmt.Sprintf("no decision after %s, escalated to level %d", stage.At.Sub(obj.CreatedAt).Round(time.Second), obj.EscalationLevel)
+	obj.Transitions = append(obj.Transitions, models.StageTransition{
+		Stage:        fmt.Sprintf("escalation:%d", obj.EscalationLevel),
+		At:           now,
+		ConfirmerIDs: stage.ConfirmerIDs,
+		Reason:       reason,
+	})
+	if err := e.commitLocked(ctx, obj); err != nil {
+		return err
+	}
+	e.appendAudit(ctx, obj, "escalated", "cheq_escalation", reason, stage.ConfirmerIDs)
+	e.redeliver(obj, added, "【升级】")
+	return nil
+}
+
+// timeoutLocked 到达 ExpiresAt 仍无决定时执行 OnTimeout：allow_with_flag 放行并标记；escalate_oncall 转值班并顺延
+// ExpiresAt（仅一次，未配置值班人时按 deny）；其余置为 expired。调用方须持有 mu。
+func (e *EngineImpl) timeoutLocked(ctx context.Context, obj *models.ConfirmationObject) error {
+	now := time.Now()
+	switch obj.OnTimeout {
+	case models.OnTimeoutAllowWithFlag:
+		reason := "confirmation timed out, allowed with flag"
+		obj.Status = models.ConfirmationStatusApproved
+		obj.TimeoutAction = models.OnTimeoutAllowWithFlag
+		obj.Transitions = append(obj.Transitions, models.StageTransition{Stage: "timeout:" + models.OnTimeoutAllowWithFlag, At: now, Reason: reason})
+		if err := e.commitLocked(ctx, obj); err != nil {
+			return err
+		}
+		e.appendAudit(ctx, obj, string(models.ConfirmationStatusApproved), "cheq_timeout_allow", reason, obj.ConfirmerIDs)
+		return nil
+	case models.OnTimeoutEscalateOncall:
+		if len(e.oncallIDs) > 0 {
+			added := mergeConfirmers(obj, e.oncallIDs)
+			reason := "confirmation timed out, escalated to security on-call"
+			obj.TimeoutAction = models.OnTimeoutEscalateOncall
+			obj.OnTimeout = models.OnTimeoutDeny // 值班仍未处理则拒绝
+			obj.ExpiresAt = now.Add(e.oncallTimeout)
+			obj.Transitions = append(obj.Transitions, models.StageTransition{Stage: "oncall", At: now, ConfirmerIDs: e.oncallIDs, Reason: reason})
+			if err := e.commitLocked(ctx, obj); err != nil {
+				return err
+			}
+			e.appendAudit(ctx, obj, "escalated", "cheq_escalation", reason, e.oncallIDs)
+			e.armLocked(obj)
+			e.redeliver(obj, added, "【安全值班】")
+			return nil
+		}
+	}
+	if obj.OnTimeout != "" {
+		obj.TimeoutAction = models.OnTimeoutDeny
+		obj.Transitions = append(obj.Transitions, models.StageTransition{Stage: "timeout:" + models.OnTimeoutDeny, At: now, Reason: "confirmation expired without decision"})
+	}
+	return e.expireLocked(ctx, obj)
+}
+
+// nextStageLocked 当前阶段已达成策略：进入下一阶段，换成该阶段的审批人、策略与时限，未触发的升级阶段不再执行；
+// 记录流转、写审计并向新阶段审批人投递。调用方须持有 mu。
+func (e *EngineImpl) nextStageLocked(ctx context.Context, obj *models.ConfirmationObject) error {
+	now := time.Now()
+	prev := obj.CurrentStage()
+	obj.StageIndex++
+	next := obj.Stages[obj.StageIndex]
+	obj.ConfirmerIDs = append([]string(nil), next.ConfirmerIDs...)
+	obj.ApprovalPolicy = next.ApprovalPolicy
+	obj.ExpiresAt = now.Add(next.Timeout)
+	obj.EscalationLevel = len(obj.EscalationPlan)
+	reason := fmt.Sprintf("stage %s approved, moved to stage %s", prev, next.Name)
+	obj.Transitions = append(obj.Transitions, models.StageTransition{
+		Stage:        "stage:" + next.Name,
+		At:           now,
+		ConfirmerIDs: next.ConfirmerIDs,
+		Reason:       reason,
+	})
+	if t := e.timers[obj.ID]; t != nil {
+		t.Stop()
+		delete(e.timers, obj.ID)
+	}
+	if err := e.commitLocked(ctx, obj); err != nil {
+		return err
+	}
+	e.armLocked(obj)
+	e.appendAudit(ctx, obj, "stage_advanced", "cheq_workflow", reason, next.ConfirmerIDs)
+	e.redeliver(obj, next.ConfirmerIDs, stagePrefix(obj))
+	return nil
+}
+
+// mergeConfirmers 将 ids 中尚未在 ConfirmerIDs 的确认人追加进去，返回新增的部分。
+func mergeConfirmers(obj *models.ConfirmationObject, ids []string) []string {
+	var added []string
+	for _, id := range ids {
+		if !containsID(obj.ConfirmerIDs, id) {
+			obj.ConfirmerIDs = append(obj.ConfirmerIDs, id)
+			added = append(added, id)
+		}
+	}
+	return added
+}
+
+// redeliver 异步向新增确认人重新投递；ids 为空时不投递。
+func (e *EngineImpl) redeliver(obj *models.ConfirmationObject, ids []string, prefix string) {
+	if e.delivery == nil || len(ids) == 0 {
+		return
+	}
+	in := &delivery.DeliverInput{Object: obj, Options: &delivery.DeliverOptions{ConfirmerIDs: ids, Summary: prefix + obj.Summary, ChannelType: "feishu", OnSent: e.deliverySink(obj.ID)}}
+	go func() {
+		if err := e.delivery.Deliver(context.Background(), in); err != nil {
+			fmt.Fprintf(os.Stderr, "[diting] [cheq] 升级投递失败 id=%s: %v\n", obj.ID, err)
+		}
+	}()
+}
+
+// deliverySink 返回投递回调：每条已发出的消息追加到对象的 Deliveries 并落盘，重启后终态通知仍能找到它。
+// 投递期间对象已进入终态（如被快速审批）时，立即为该条消息补发状态更新。
+func (e *EngineImpl) deliverySink(id string) func(models.DeliveryRef) {
+	return func(ref models.DeliveryRef) {
+		ctx := context.Background()
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		obj, err := e.store.Get(ctx, id)
+		if err != nil || obj == nil {
+			return
+		}
+		obj.Deliveries = append(obj.Deliveries, ref)
+		if err := e.store.Put(ctx, obj); err != nil {
+			fmt.Fprintf(os.Stderr, "[diting] [cheq] 记录投递消息失败 id=%s: %v\n", id, err)
+		}
+		if obj.IsTerminal() {
+			late := *obj
+			late.Deliveries = []models.DeliveryRef{ref}
+			e.notifyStatus(&late)
+		}
+	}
+}
+
+// armLocked 为未终态对象按下一个升级阶段或 ExpiresAt 设置定时器（已有则跳过）。调用方须持有 mu。
+func (e *EngineImpl) armLocked(obj *models.ConfirmationObject) {
+	if obj.IsTerminal() || e.timers[obj.ID] != nil {
+		return
+	}
+	id := obj.ID
+	e.timers[id] = time.AfterFunc(time.Until(nextDeadline(obj)), func() { e.onTimer(id) })
+}
+
+// onTimer 定时器到期回调：重新读取对象，处理到期的升级阶段或最终超时；仍未终态则按下一个时刻重新设定时器。
+func (e *EngineImpl) onTimer(id string) {
+	ctx := context.Background()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.timers, id)
+	obj, err := e.store.Get(ctx, id)
+	if err != nil || obj == nil || obj.IsTerminal() {
+		return
+	}
+	if _, err := e.advanceLocked(ctx, obj, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "[diting] [cheq] 超时处理写回失败 id=%s: %v\n", id, err)
+	}
+	e.armLocked(obj)
+}
+
+// SetAuditStore 设置审计存储；非 nil 时过期、升级与超时动作各追加一条 Evidence。
+func (e *EngineImpl) SetAuditStore(a audit.Store) {
+	e.mu.Lock()
+	e.audit = a
+	e.mu.Unlock()
+}
+
+// SetSecurityOncall 设置安全值班确认人与转值班后的等待时长（<=0 时用引擎默认超时），供 on_timeout=escalate_oncall 使用。
+func (e *EngineImpl) SetSecurityOncall(ids []string, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = e.timeout
+	}
+	e.mu.Lock()
+	e.oncallIDs = append([]string(nil), ids...)
+	e.oncallTimeout = timeout
+	e.mu.Unlock()
+}
+
+// SetWorkflows 设置按 ConfirmationObject.Type 的多阶段审批流程；之后创建的该类型对象依次经过各阶段审批。
+// 阶段为空的类型按单阶段处理。
+func (e *EngineImpl) SetWorkflows(workflows map[string][]WorkflowStage) {
+	m := make(map[string][]WorkflowStage, len(workflows))
+	for typ, stages := range workflows {
+		if len(stages) > 0 {
+			m[typ] = append([]WorkflowStage(nil), stages...)
+		}
+	}
+	e.mu.Lock()
+	e.workflows = m
+	e.mu.Unlock()
+}
+
+// SetApproverRoles 设置角色 -> 确认人映射，供 roles:... 策略计票；同一确认人出现在多个角色时取角色名字典序最小者。
+func (e *EngineImpl) SetApproverRoles(roles map[string][]string) {
+	names := make([]string, 0, len(roles))
+	for role := range roles {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+	m := make(map[string]string)
+	for _, role := range names {
+		for _, id := range roles[role] {
+			if _, ok := m[id]; !ok {
+				m[id] = role
+			}
+		}
+	}
+	e.mu.Lock()
+	e.roles = m
+	e.mu.Unlock()
+}
+
+// StartSweeper 启动后台清扫：每 interval 扫描 store，处理已到期但无定时器（如进程重启后）的升级阶段与超时。
+// interval <= 0 时用 30 秒；关闭时调用 Stop。
+func (e *EngineImpl) StartSweeper(interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	e.sweepDone = make(chan struct{})
+	e.sweepWG.Add(1)
+	go func() {
+		defer e.sweepWG.Done()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		e.Sweep(context.Background())
+		for {
+			select {
+			case <-e.sweepDone:
+				return
+			case <-tick.C:
+				e.Sweep(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop 停止清扫器并撤销全部过期定时器。
+func (e *EngineImpl) Stop() {
+	if e.sweepDone != nil {
+		close(e.sweepDone)
+		e.sweepWG.Wait()
+		e.sweepDone = nil
+	}
+	e.mu.Lock()
+	for id, t := range e.timers {
+		t.Stop()
+		delete(e.timers, id)
+	}
+	e.mu.Unlock()
+}
+
+// Sweep 扫描一次 store，处理到期的升级阶段与超时动作，返回本次处理的对象数量。
+func (e *EngineImpl) Sweep(ctx context.Context) int {
+	now := time.Now()
+	var overdue []string
+	filter := &ListFilter{Status: models.ConfirmationStatusPending, CreatedBefore: now, Limit: maxListLimit}
+	for {
+		res, err := e.store.List(ctx, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[diting] [cheq] 过期清扫失败: %v\n", err)
+			break
+		}
+		for _, obj := range res.Items {
+			if !now.Before(nextDeadline(obj)) {
+				overdue = append(overdue, obj.ID)
 			}
 		}
-		if !already && confirmerID != "" {
-			obj.ApprovedBy = append(obj.ApprovedBy, confirmerID)
+		if res.NextCursor == "" {
+			break
 		}
-		if len(obj.ApprovedBy) >= len(obj.ConfirmerIDs) {
-			obj.Status = models.ConfirmationStatusApproved
+		filter.Cursor = res.NextCursor
+	}
+	n := 0
+	for _, id := range overdue {
+		e.mu.Lock()
+		obj, err := e.store.Get(ctx, id)
+		if err == nil && obj != nil {
+			if changed, err := e.advanceLocked(ctx, obj, time.Now()); changed && err == nil {
+				n++
+			}
+			e.armLocked(obj)
 		}
-		// 未达全部时仅写回 ApprovedBy，不设终态
-		return e.store.Put(ctx, obj)
+		e.mu.Unlock()
 	}
-	// any: 任一通过即放行
-	obj.Status = models.ConfirmationStatusApproved
-	return e.store.Put(ctx, obj)
+	return n
 }
@@ -0,0 +1,112 @@
+package cheq
+
+import (
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"diting/internal/models"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// Match 返回 obj 是否满足过滤条件（不含游标与分页）。
+func (f *ListFilter) Match(obj *models.ConfirmationObject) bool {
+	if obj == nil {
+		return false
+	}
+	if f.Status != "" && obj.Status != f.Status {
+		return false
+	}
+	if f.Type != "" && obj.Type != f.Type {
+		return false
+	}
+	if f.Requester != "" && obj.Requester != f.Requester {
+		return false
+	}
+	if f.ResourcePrefix != "" && !strings.HasPrefix(obj.Resource, f.ResourcePrefix) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && obj.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !obj.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if f.ConfirmerID != "" {
+		found := false
+		for _, c := range obj.ConfirmerIDs {
+			if c == f.ConfirmerID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// paginate 对已过滤的对象按 (CreatedAt, ID) 升序排序，从游标之后取 Limit 条；各 Store/Engine 实现共用。
+func paginate(objs []*models.ConfirmationObject, f *ListFilter) (*ListResult, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	sort.Slice(objs, func(i, j int) bool { return lessCreated(objs[i], objs[j]) })
+	start := 0
+	if f.Cursor != "" {
+		after, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		start = sort.Search(len(objs), func(i int) bool { return lessCreated(after, objs[i]) })
+	}
+	end := start + limit
+	if end > len(objs) {
+		end = len(objs)
+	}
+	out := &ListResult{Items: objs[start:end]}
+	if end < len(objs) && end > start {
+		out.NextCursor = encodeCursor(objs[end-1])
+	}
+	return out, nil
+}
+
+func lessCreated(a, b *models.ConfirmationObject) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// 游标为「CreatedAt 纳秒:ID」的 base64url 编码，对调用方不透明。
+func encodeCursor(obj *models.ConfirmationObject) string {
+	raw := strconv.FormatInt(obj.CreatedAt.UnixNano(), 10) + ":" + obj.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(c string) (*models.ConfirmationObject, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	ns, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &models.ConfirmationObject{ID: id, CreatedAt: time.Unix(0, ns)}, nil
+}
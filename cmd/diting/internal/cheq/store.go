@@ -0,0 +1,23 @@
+package cheq
+
+import (
+	"context"
+
+	"diting/internal/models"
+)
+
+// Store CHEQ 对象持久化接口；EngineImpl 依赖此接口，实现见 JSONStore（每对象一文件）与 LogStore（追加日志 + 内存索引）。
+type Store interface {
+	// Put 写入对象；同 id 覆盖。
+	Put(ctx context.Context, obj *models.ConfirmationObject) error
+	// Get 读取对象副本；不存在返回 nil, nil。
+	Get(ctx context.Context, id string) (*models.ConfirmationObject, error)
+	// List 按 filter 过滤并游标分页。
+	List(ctx context.Context, filter *ListFilter) (*ListResult, error)
+}
+
+// 编译期保证两种实现均满足 Store。
+var (
+	_ Store = (*JSONStore)(nil)
+	_ Store = (*LogStore)(nil)
+)
@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"diting/internal/models"
 )
 
 // JSONStore 将每个 ConfirmationObject 存为单独 JSON 文件：<dir>/<id>.json。
+// 无索引与清理，仅适合小规模或测试；生产使用 LogStore（会在启动时迁移本格式的目录）。
 type JSONStore struct {
 	dir string
 	mu  sync.Mutex
@@ -29,7 +31,7 @@ func NewJSONStore(dir string) (*JSONStore, error) {
 
 func (s *JSONStore) path(id string) string { return filepath.Join(s.dir, id+".json") }
 
-// Put 写入对象；同 id 覆盖。
+// Put 写入对象；同 id 覆盖。经临时文件 + rename 原子替换，崩溃不会留下截断的 JSON。
 func (s *JSONStore) Put(ctx context.Context, obj *models.ConfirmationObject) error {
 	if obj == nil {
 		return nil
@@ -40,7 +42,7 @@ func (s *JSONStore) Put(ctx context.Context, obj *models.ConfirmationObject) err
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.path(obj.ID), data, 0644)
+	return writeFileAtomic(s.path(obj.ID), data)
 }
 
 // Get 读取对象；不存在返回 nil, nil。
@@ -60,3 +62,47 @@ func (s *JSONStore) Get(ctx context.Context, id string) (*models.ConfirmationObj
 	}
 	return &obj, nil
 }
+
+// List 扫描目录并按 filter 过滤、分页（线性扫描，适合 MVP 规模）。
+func (s *JSONStore) List(ctx context.Context, filter *ListFilter) (*ListResult, error) {
+	if filter == nil {
+		filter = &ListFilter{}
+	}
+	var matched []*models.ConfirmationObject
+	err := s.scan(ctx, func(obj *models.ConfirmationObject) bool {
+		if filter.Match(obj) {
+			matched = append(matched, obj)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paginate(matched, filter)
+}
+
+// scan 遍历目录下全部对象，fn 返回 false 时提前结束；无法解析的文件跳过。
+func (s *JSONStore) scan(ctx context.Context, fn func(obj *models.ConfirmationObject) bool) error {
+	s.mu.Lock()
+	entries, err := os.ReadDir(s.dir)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, ent := range entries {
+		if ent.IsDir() || !strings.HasSuffix(ent.Name(), ".json") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		obj, err := s.Get(ctx, strings.TrimSuffix(ent.Name(), ".json"))
+		if err != nil || obj == nil {
+			continue
+		}
+		if !fn(obj) {
+			return nil
+		}
+	}
+	return nil
+}
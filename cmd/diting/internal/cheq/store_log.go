@@ -0,0 +1,510 @@
+package cheq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"diting/internal/models"
+)
+
+const (
+	logFileName      = "cheq.log"
+	snapshotFileName = "cheq.snapshot"
+	snapshotVersion  = 1
+)
+
+// LogStoreOptions LogStore 的压缩与保留策略；零值字段使用默认值。
+type LogStoreOptions struct {
+	Retention        time.Duration // 终态对象自进入终态起的保留时长，超出后在压缩时丢弃；默认 30 天，<0 表示永久保留
+	CompactInterval  time.Duration // 后台定时压缩间隔；默认 5 分钟
+	CompactThreshold int           // 日志累计记录数达到该值时提前压缩；默认 10000
+}
+
+// LogStore 嵌入式 CHEQ 存储：追加写日志（每次 Put 后 fsync）+ 内存索引（按 id、按状态），
+// 定期将全量对象写快照（tmp + fsync + rename）并截断日志；终态对象超过保留期在压缩时清理。
+// 打开时加载快照并重放日志，中间损坏的记录被跳过，尾部残缺记录（崩溃时半写）会被截掉；目录中遗留的 <id>.json 会被迁移。
+type LogStore struct {
+	dir  string
+	opts LogStoreOptions
+
+	mu       sync.RWMutex
+	log      *os.File
+	seq      uint64                                            // 最近一条日志记录序号
+	logCount int                                               // 快照之后的日志记录数
+	byID     map[string]*logEntry                              // id -> 最新对象
+	byStatus map[models.ConfirmationStatus]map[string]struct{} // 状态索引，供 List 按状态过滤
+
+	compactCh chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// logEntry 索引项：raw 为对象 JSON，Get/List 从 raw 解码返回副本；obj 仅用于过滤，不对外暴露。
+type logEntry struct {
+	obj *models.ConfirmationObject
+	raw json.RawMessage
+}
+
+// logRecord 日志行格式。
+type logRecord struct {
+	Seq uint64          `json:"seq"`
+	Op  string          `json:"op"` // put
+	Obj json.RawMessage `json:"obj"`
+}
+
+// snapshotHeader 快照首行：记录快照覆盖到的日志序号，重放时跳过 seq 不大于它的记录。
+type snapshotHeader struct {
+	Version int       `json:"version"`
+	Seq     uint64    `json:"seq"`
+	At      time.Time `json:"at"`
+}
+
+// NewLogStore 打开（不存在则创建）dir 下的日志存储，加载快照、重放日志并迁移遗留 JSON 文件；调用 Start 启动后台压缩，关闭时调用 Close。
+func NewLogStore(dir string, opts LogStoreOptions) (*LogStore, error) {
+	if dir == "" {
+		return nil, os.ErrInvalid
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if opts.Retention == 0 {
+		opts.Retention = 30 * 24 * time.Hour
+	}
+	if opts.CompactInterval <= 0 {
+		opts.CompactInterval = 5 * time.Minute
+	}
+	if opts.CompactThreshold <= 0 {
+		opts.CompactThreshold = 10000
+	}
+	s := &LogStore{
+		dir:       dir,
+		opts:      opts,
+		byID:      make(map[string]*logEntry),
+		byStatus:  make(map[models.ConfirmationStatus]map[string]struct{}),
+		compactCh: make(chan struct{}, 1),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.path(logFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.log = f
+	if err := s.migrateJSONFiles(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *LogStore) path(name string) string { return filepath.Join(s.dir, name) }
+
+// Put 追加一条日志并 fsync，成功后更新内存索引。
+func (s *LogStore) Put(ctx context.Context, obj *models.ConfirmationObject) error {
+	if obj == nil {
+		return nil
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return os.ErrClosed
+	}
+	rec := logRecord{Seq: s.seq + 1, Op: "put", Obj: raw}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := s.log.Write(line); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	s.seq = rec.Seq
+	s.logCount++
+	if err := s.indexLocked(raw); err != nil {
+		return err
+	}
+	if s.logCount >= s.opts.CompactThreshold {
+		select {
+		case s.compactCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Get 返回对象副本；不存在返回 nil, nil。
+func (s *LogStore) Get(ctx context.Context, id string) (*models.ConfirmationObject, error) {
+	s.mu.RLock()
+	ent := s.byID[id]
+	s.mu.RUnlock()
+	if ent == nil {
+		return nil, nil
+	}
+	return decodeObject(ent.raw)
+}
+
+// List 按状态索引缩小候选集后过滤、分页，返回对象副本。
+func (s *LogStore) List(ctx context.Context, filter *ListFilter) (*ListResult, error) {
+	if filter == nil {
+		filter = &ListFilter{}
+	}
+	s.mu.RLock()
+	var matched []*models.ConfirmationObject
+	raws := make(map[*models.ConfirmationObject]json.RawMessage)
+	add := func(ent *logEntry) {
+		if filter.Match(ent.obj) {
+			matched = append(matched, ent.obj)
+			raws[ent.obj] = ent.raw
+		}
+	}
+	if filter.Status != "" {
+		for id := range s.byStatus[filter.Status] {
+			add(s.byID[id])
+		}
+	} else {
+		for _, ent := range s.byID {
+			add(ent)
+		}
+	}
+	s.mu.RUnlock()
+	res, err := paginate(matched, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range res.Items {
+		cp, err := decodeObject(raws[o])
+		if err != nil {
+			return nil, err
+		}
+		res.Items[i] = cp
+	}
+	return res, nil
+}
+
+// Start 启动后台压缩：每 CompactInterval 或日志记录数达到 CompactThreshold 时执行 Compact。
+func (s *LogStore) Start() {
+	s.done = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		tick := time.NewTicker(s.opts.CompactInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-tick.C:
+			case <-s.compactCh:
+			}
+			if err := s.Compact(); err != nil {
+				fmt.Fprintf(os.Stderr, "[diting] [cheq] 存储压缩失败: %v\n", err)
+			}
+		}
+	}()
+}
+
+// Close 停止后台压缩并关闭日志文件。
+func (s *LogStore) Close() error {
+	if s.done != nil {
+		close(s.done)
+		s.wg.Wait()
+		s.done = nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// Compact 将当前全量对象（剔除超过保留期的终态对象）写入新快照并截断日志。
+// 顺序：写 snapshot.tmp → fsync → rename → fsync 目录 → 截断日志；任一步崩溃后重启都能由快照 + 日志恢复一致状态。
+func (s *LogStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return os.ErrClosed
+	}
+	cutoff := time.Time{}
+	if s.opts.Retention > 0 {
+		cutoff = time.Now().Add(-s.opts.Retention)
+	}
+	var buf bytes.Buffer
+	hdr, _ := json.Marshal(snapshotHeader{Version: snapshotVersion, Seq: s.seq, At: time.Now()})
+	buf.Write(hdr)
+	buf.WriteByte('\n')
+	var dropped []string
+	for id, ent := range s.byID {
+		if !cutoff.IsZero() && ent.obj.IsTerminal() && closedAt(ent.obj).Before(cutoff) {
+			dropped = append(dropped, id)
+			continue
+		}
+		buf.Write(ent.raw)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(s.path(snapshotFileName), buf.Bytes()); err != nil {
+		return err
+	}
+	for _, id := range dropped {
+		s.unindexLocked(id)
+	}
+	// 快照已落盘，日志中的记录均已被覆盖：以空文件替换日志
+	if err := writeFileAtomic(s.path(logFileName), nil); err != nil {
+		return err
+	}
+	_ = s.log.Close()
+	f, err := os.OpenFile(s.path(logFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		s.log = nil
+		return err
+	}
+	s.log = f
+	s.logCount = 0
+	return nil
+}
+
+func (s *LogStore) loadSnapshot() error {
+	f, err := os.Open(s.path(snapshotFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	first := true
+	for {
+		line, err := r.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			if first {
+				var hdr snapshotHeader
+				if jerr := json.Unmarshal(line, &hdr); jerr != nil || hdr.Version != snapshotVersion {
+					return fmt.Errorf("cheq snapshot: invalid header")
+				}
+				s.seq = hdr.Seq
+				first = false
+			} else if ierr := s.indexLocked(append(json.RawMessage(nil), line...)); ierr != nil {
+				return fmt.Errorf("cheq snapshot: %w", ierr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// replayLog 重放快照之后的日志。中间无法解析的记录记录日志后跳过，不影响其后的记录；
+// 仅当最后一行缺少换行（崩溃时半写）时将其截掉。
+func (s *LogStore) replayLog() error {
+	f, err := os.OpenFile(s.path(logFileName), os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var good int64
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) > 0 {
+			break // 无换行结尾的最后一行为半写记录
+		}
+		if len(line) > 0 {
+			if rerr := s.replayRecord(line); rerr != nil {
+				fmt.Fprintf(os.Stderr, "[diting] [cheq] 跳过日志第 %d 行无法解析的记录: %v\n", n, rerr)
+			}
+			good += int64(len(line))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "[diting] [cheq] 日志尾部存在残缺记录，已截断至 %d 字节\n", good)
+	if err := f.Truncate(good); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// replayRecord 解析一行日志并更新索引；seq 不大于快照序号的记录已包含在快照中，直接忽略。
+func (s *LogStore) replayRecord(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	var rec logRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return err
+	}
+	if rec.Op != "put" {
+		return fmt.Errorf("unknown op %q", rec.Op)
+	}
+	if rec.Seq <= s.seq {
+		return nil
+	}
+	if err := s.indexLocked(rec.Obj); err != nil {
+		return err
+	}
+	s.seq = rec.Seq
+	s.logCount++
+	return nil
+}
+
+// migrateJSONFiles 将 JSONStore 遗留的 <id>.json 导入，压缩落盘后删除原文件。
+func (s *LogStore) migrateJSONFiles() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var migrated []string
+	for _, ent := range entries {
+		name := ent.Name()
+		if ent.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile(s.path(name))
+		if err != nil {
+			return err
+		}
+		obj, err := decodeObject(data)
+		if err != nil || obj.ID == "" {
+			fmt.Fprintf(os.Stderr, "[diting] [cheq] 迁移跳过无法解析的文件 %s\n", name)
+			continue
+		}
+		s.mu.Lock()
+		_, exists := s.byID[obj.ID]
+		s.mu.Unlock()
+		if !exists {
+			if err := s.Put(context.Background(), obj); err != nil {
+				return err
+			}
+		}
+		migrated = append(migrated, name)
+	}
+	if len(migrated) == 0 {
+		return nil
+	}
+	if err := s.Compact(); err != nil {
+		return err
+	}
+	for _, name := range migrated {
+		_ = os.Remove(s.path(name))
+	}
+	fmt.Fprintf(os.Stderr, "[diting] [cheq] 已迁移 %d 个 JSON 文件至日志存储\n", len(migrated))
+	return nil
+}
+
+// indexLocked 解析 raw 并更新 byID 与状态索引。调用方须持有写锁（或处于初始化阶段）。
+func (s *LogStore) indexLocked(raw json.RawMessage) error {
+	obj, err := decodeObject(raw)
+	if err != nil {
+		return err
+	}
+	if old := s.byID[obj.ID]; old != nil {
+		delete(s.byStatus[old.obj.Status], obj.ID)
+	}
+	s.byID[obj.ID] = &logEntry{obj: obj, raw: raw}
+	if s.byStatus[obj.Status] == nil {
+		s.byStatus[obj.Status] = make(map[string]struct{})
+	}
+	s.byStatus[obj.Status][obj.ID] = struct{}{}
+	return nil
+}
+
+func (s *LogStore) unindexLocked(id string) {
+	if old := s.byID[id]; old != nil {
+		delete(s.byStatus[old.obj.Status], id)
+		delete(s.byID, id)
+	}
+}
+
+// closedAt 返回对象进入终态的时间，保留期从此刻起算。早期对象未记录 ClosedAt 时取最后一票或最后一次阶段流转的时间，
+// 均无则退回 ExpiresAt。
+func closedAt(obj *models.ConfirmationObject) time.Time {
+	if !obj.ClosedAt.IsZero() {
+		return obj.ClosedAt
+	}
+	var at time.Time
+	for _, v := range obj.Votes {
+		if v.At.After(at) {
+			at = v.At
+		}
+	}
+	for _, tr := range obj.Transitions {
+		if tr.At.After(at) {
+			at = tr.At
+		}
+	}
+	if at.IsZero() {
+		return obj.ExpiresAt
+	}
+	return at
+}
+
+func decodeObject(raw []byte) (*models.ConfirmationObject, error) {
+	var obj models.ConfirmationObject
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// writeFileAtomic 写临时文件、fsync 后 rename 覆盖 path，并 fsync 所在目录，保证崩溃后要么旧内容要么新内容。
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if d, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -13,10 +13,11 @@ import (
 type StubEngine struct {
 	mu   sync.RWMutex
 	objs map[string]*models.ConfirmationObject
+	hub  *watchHub
 }
 
 func NewStubEngine() *StubEngine {
-	return &StubEngine{objs: make(map[string]*models.ConfirmationObject)}
+	return &StubEngine{objs: make(map[string]*models.ConfirmationObject), hub: newWatchHub()}
 }
 
 func (s *StubEngine) Create(ctx context.Context, in *CreateInput) (*models.ConfirmationObject, error) {
@@ -33,6 +34,8 @@ func (s *StubEngine) Create(ctx context.Context, in *CreateInput) (*models.Confi
 		ConfirmerIDs:    in.ConfirmerIDs,
 		Type:            in.Type,
 		ApprovalPolicy:  in.ApprovalPolicy,
+		Requester:       in.Requester,
+		SessionID:       in.SessionID,
 	}
 	s.mu.Lock()
 	s.objs[id] = obj
@@ -54,6 +57,11 @@ func (s *StubEngine) GetByID(ctx context.Context, id string) (*models.Confirmati
 }
 
 func (s *StubEngine) Submit(ctx context.Context, id string, approved bool, confirmerID string) error {
+	return s.SubmitWithOptions(ctx, id, approved, confirmerID, nil)
+}
+
+// SubmitWithOptions 占位实现：记录一票后直接终态（不计票）。
+func (s *StubEngine) SubmitWithOptions(ctx context.Context, id string, approved bool, confirmerID string, opts *SubmitOptions) error {
 	s.mu.Lock()
 	obj := s.objs[id]
 	if obj == nil {
@@ -66,14 +74,87 @@ func (s *StubEngine) Submit(ctx context.Context, id string, approved bool, confi
 	}
 	if time.Now().After(obj.ExpiresAt) {
 		obj.Status = models.ConfirmationStatusExpired
+		s.hub.publish(obj)
 		s.mu.Unlock()
 		return ErrExpired
 	}
+	if opts != nil && !models.ValidReasonCode(opts.ReasonCode) {
+		s.mu.Unlock()
+		return ErrInvalidReasonCode
+	}
+	amendment, err := amendmentOf(obj, approved, confirmerID, opts)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if amendment != nil {
+		obj.Amendment = amendment
+	}
+	vote := newVote(confirmerID, models.VoteReject, "", opts)
+	obj.Status = models.ConfirmationStatusRejected
 	if approved {
+		vote = newVote(confirmerID, models.VoteApprove, "", opts)
 		obj.Status = models.ConfirmationStatusApproved
-	} else {
-		obj.Status = models.ConfirmationStatusRejected
 	}
+	obj.Votes = append(obj.Votes, vote)
+	obj.DecisionComment, obj.DecisionReasonCode = vote.Comment, vote.ReasonCode
+	s.hub.publish(obj)
 	s.mu.Unlock()
 	return nil
 }
+
+// Cancel 占位实现：未终态对象置为 cancelled。
+func (s *StubEngine) Cancel(ctx context.Context, id, by, reason string) error {
+	return s.cancel(id, by, reason, false)
+}
+
+// Withdraw 占位实现：同 Cancel，要求 requester 为对象的发起方。
+func (s *StubEngine) Withdraw(ctx context.Context, id, requester, reason string) error {
+	return s.cancel(id, requester, reason, true)
+}
+
+func (s *StubEngine) cancel(id, by, reason string, onlyRequester bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj := s.objs[id]
+	if obj == nil {
+		return ErrNotFound
+	}
+	if onlyRequester && (by == "" || obj.Requester != by) {
+		return ErrNotRequester
+	}
+	if obj.IsTerminal() {
+		return ErrAlreadyProcessed
+	}
+	obj.Status = models.ConfirmationStatusCancelled
+	obj.CancelledBy, obj.DecisionComment = by, reason
+	s.hub.publish(obj)
+	return nil
+}
+
+// Watch 推送当前快照与后续 Submit 结果；占位实现不设过期定时器。
+func (s *StubEngine) Watch(ctx context.Context, id string) (<-chan *models.ConfirmationObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj := s.objs[id]
+	if obj == nil {
+		return nil, ErrNotFound
+	}
+	return s.hub.watch(ctx, obj), nil
+}
+
+// List 在内存对象上过滤与分页。
+func (s *StubEngine) List(ctx context.Context, filter *ListFilter) (*ListResult, error) {
+	if filter == nil {
+		filter = &ListFilter{}
+	}
+	s.mu.RLock()
+	var matched []*models.ConfirmationObject
+	for _, obj := range s.objs {
+		if filter.Match(obj) {
+			matched = append(matched, obj)
+		}
+	}
+	s.mu.RUnlock()
+	return paginate(matched, filter)
+}
@@ -3,7 +3,11 @@ package cheq
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 	"time"
+
+	"diting/internal/models"
 )
 
 // CreateInput 创建 ConfirmationObject 的入参。
@@ -14,8 +18,88 @@ type CreateInput struct {
 	Summary       string
 	ExpiresAt     time.Time
 	ConfirmerIDs  []string
+	KeepConfirmers bool // 为 true 时 ConfirmerIDs 不被归属解析覆盖（如 Agent 接入须由其 owner 审批）
 	Type          string
-	ApprovalPolicy string // I-009：本请求的审批策略（any/all）；空则用引擎默认
+	ApprovalPolicy string // I-009：本请求的审批策略（any/all/quorum:N/roles:...）；空或无法解析则用引擎默认
+	RejectMode    string // veto（默认）或 quorum
+	Requester      string // 发起请求的主体（Agent 身份）；不可审批本请求
+	RequesterOwner string // 发起主体的 owner；同样不可审批本请求
+	SessionID      string // 发起请求的会话；供「本会话内授权」使用
+	Fingerprint    string // 请求指纹；非空时若已有同指纹的待确认对象，则挂接到该对象而不新建
+	Escalation    []EscalationStage // 升级阶段，按 After 升序；After 不早于 ExpiresAt 的阶段被忽略
+	OnTimeout     string            // 最终超时动作，见 models.OnTimeout*；空或未知值按 deny
+}
+
+// EscalationStage 升级阶段入参：创建后 After 仍无决定则投递给 ConfirmerIDs。
+type EscalationStage struct {
+	After        time.Duration
+	ConfirmerIDs []string
+}
+
+// WorkflowStage 多阶段审批流程的一个阶段入参（见 EngineImpl.SetWorkflows）。
+type WorkflowStage struct {
+	Name           string
+	ConfirmerIDs   []string      // 本阶段审批人；空则沿用请求的确认人（如 Agent owner）
+	ApprovalPolicy string        // 本阶段审批策略；空则沿用请求的策略
+	Timeout        time.Duration // 本阶段时限，自进入本阶段起计；<=0 则与请求的初始时限相同
+}
+
+// SubmitOptions 审批人提交时的附加选择，随投票记录在对象上。
+type SubmitOptions struct {
+	GrantScope   string // 限时授权范围：once（默认）/ minutes / session，见 grant.Scope*；仅批准票有效
+	GrantMinutes int    // GrantScope 为 minutes（或 session 的上限）时的分钟数
+	Comment      string // 审批人备注，超过 MaxCommentLen 字节截断
+	ReasonCode   string // 原因码，须为 models.Reason* 之一，否则 ErrInvalidReasonCode
+
+	// 改后批准（仅批准票）：exec 请求给 AmendCommand，HTTP 请求给 BodyPatch（JSON 对象，按 merge patch 应用）
+	AmendCommand string
+	BodyPatch    string
+}
+
+// MaxCommentLen 审批人备注的最大字节数。
+const MaxCommentLen = 1000
+
+// ParseSubmitOptions 从审批链接查询参数或卡片按钮值中解析附加选择：grant、grant_minutes、comment、reason_code、
+// amend_command、body_patch。
+// get 按键取值（如 url.Values.Get）；均未给出时返回 nil。
+func ParseSubmitOptions(get func(key string) string) *SubmitOptions {
+	o := &SubmitOptions{
+		GrantScope: get("grant"),
+		Comment:    strings.TrimSpace(get("comment")),
+		ReasonCode: strings.TrimSpace(get("reason_code")),
+
+		AmendCommand: strings.TrimSpace(get("amend_command")),
+		BodyPatch:    strings.TrimSpace(get("body_patch")),
+	}
+	if o.GrantScope == "once" {
+		o.GrantScope = ""
+	}
+	if o.GrantScope != "" {
+		o.GrantMinutes, _ = strconv.Atoi(get("grant_minutes"))
+	}
+	if o.GrantScope == "" && o.Comment == "" && o.ReasonCode == "" && o.AmendCommand == "" && o.BodyPatch == "" {
+		return nil
+	}
+	return o
+}
+
+// ListFilter List 的查询条件；零值字段表示不过滤。结果按 CreatedAt 升序（先到先审），游标分页。
+type ListFilter struct {
+	Status         models.ConfirmationStatus // 状态精确匹配，如 pending
+	ConfirmerID    string                    // ConfirmerIDs 中包含该确认人
+	ResourcePrefix string                    // Resource 前缀匹配
+	Type           string                    // Type 精确匹配
+	Requester      string                    // Requester 精确匹配（发起请求的 Agent）
+	CreatedAfter   time.Time                 // CreatedAt >= CreatedAfter
+	CreatedBefore  time.Time                 // CreatedAt < CreatedBefore
+	Cursor         string                    // 上一页返回的 NextCursor；空表示第一页
+	Limit          int                       // 每页条数；<=0 用默认 50，上限 500
+}
+
+// ListResult List 的分页结果；NextCursor 为空表示没有更多。
+type ListResult struct {
+	Items      []*models.ConfirmationObject
+	NextCursor string
 }
 
 // ErrAlreadyProcessed 表示该 ConfirmationObject 已处理（幂等提交时返回）。
@@ -26,3 +110,36 @@ var ErrNotFound = errors.New("cheq: confirmation object not found")
 
 // ErrExpired 表示已过期。
 var ErrExpired = errors.New("cheq: confirmation object expired")
+
+// ErrNotConfirmer 表示提交者不在该对象的确认人列表中。
+var ErrNotConfirmer = errors.New("cheq: submitter is not a confirmer of this object")
+
+// ErrConfirmerRequired 表示该对象的审批策略需要计票，提交时必须给出确认人标识。
+var ErrConfirmerRequired = errors.New("cheq: confirmer id required by approval policy")
+
+// ErrAlreadyVoted 表示该确认人已对此对象投过相反的票。
+var ErrAlreadyVoted = errors.New("cheq: confirmer already voted")
+
+// ErrSelfApproval 表示提交者为请求发起方或其 owner（职责分离）。
+var ErrSelfApproval = errors.New("cheq: requester cannot approve its own request")
+
+// ErrInvalidSignature 表示审批链接签名缺失或不匹配。
+var ErrInvalidSignature = errors.New("cheq: invalid approval link signature")
+
+// ErrLinkExpired 表示审批链接已过期。
+var ErrLinkExpired = errors.New("cheq: approval link expired")
+
+// ErrInvalidReasonCode 表示提交的原因码未定义。
+var ErrInvalidReasonCode = errors.New("cheq: invalid reason code")
+
+// ErrInvalidAmendment 表示改写与请求类型不符（exec 只能改命令行，HTTP 只能改请求体）、body_patch 不是 JSON 对象，或随拒绝票提交。
+var ErrInvalidAmendment = errors.New("cheq: invalid amendment")
+
+// ErrAmendmentConflict 表示对象已有另一份不同的改写。
+var ErrAmendmentConflict = errors.New("cheq: conflicting amendment")
+
+// ErrNotRequester 表示撤回者不是该对象的发起方。
+var ErrNotRequester = errors.New("cheq: withdrawer is not the requester of this object")
+
+// ErrInvalidCursor 表示 List 的游标无法解析。
+var ErrInvalidCursor = errors.New("cheq: invalid list cursor")
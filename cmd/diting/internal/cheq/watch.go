@@ -0,0 +1,96 @@
+package cheq
+
+import (
+	"context"
+	"sync"
+
+	"diting/internal/models"
+)
+
+// watchHub 按 id 管理 Watch 订阅者；publish 向订阅者推送最新快照，终态时关闭 channel。
+// 调用方需保证 watch 与 publish 在同一把锁下串行，避免旧快照覆盖新状态。
+type watchHub struct {
+	mu   sync.Mutex
+	subs map[string]map[*watcher]struct{}
+}
+
+type watcher struct {
+	ch   chan *models.ConfirmationObject
+	done chan struct{}
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{subs: make(map[string]map[*watcher]struct{})}
+}
+
+// watch 注册订阅者并立即推送 snapshot；snapshot 已终态时直接关闭。ctx 取消后自动退订。
+func (h *watchHub) watch(ctx context.Context, snapshot *models.ConfirmationObject) <-chan *models.ConfirmationObject {
+	w := &watcher{ch: make(chan *models.ConfirmationObject, 1), done: make(chan struct{})}
+	id := snapshot.ID
+	h.mu.Lock()
+	w.ch <- snapshot
+	if snapshot.IsTerminal() {
+		close(w.ch)
+		close(w.done)
+		h.mu.Unlock()
+		return w.ch
+	}
+	if h.subs[id] == nil {
+		h.subs[id] = make(map[*watcher]struct{})
+	}
+	h.subs[id][w] = struct{}{}
+	h.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.unsubscribe(id, w)
+		case <-w.done:
+		}
+	}()
+	return w.ch
+}
+
+func (h *watchHub) unsubscribe(id string, w *watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[id][w]; !ok {
+		return
+	}
+	delete(h.subs[id], w)
+	if len(h.subs[id]) == 0 {
+		delete(h.subs, id)
+	}
+	close(w.ch)
+	close(w.done)
+}
+
+// watched 返回 id 当前是否有订阅者。
+func (h *watchHub) watched(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[id]) > 0
+}
+
+// publish 向 obj.ID 的全部订阅者推送 obj；缓冲中未读的旧快照被替换，不阻塞发布方。
+func (h *watchHub) publish(obj *models.ConfirmationObject) {
+	if obj == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs := h.subs[obj.ID]
+	for w := range subs {
+		select {
+		case <-w.ch:
+		default:
+		}
+		w.ch <- obj
+		if obj.IsTerminal() {
+			close(w.ch)
+			close(w.done)
+		}
+	}
+	if obj.IsTerminal() {
+		delete(h.subs, obj.ID)
+	}
+}
@@ -10,6 +10,9 @@ type Config struct {
 	Audit    AuditConfig   `yaml:"audit"`
 	Ownership OwnershipConfig `yaml:"ownership"`
 	Chain    ChainConfig   `yaml:"chain,omitempty"` // 私有链与 DID/存证（I-017）
+	Grants   GrantsConfig  `yaml:"grants,omitempty"` // 审批产生的限时授权
+	Agents   AgentsConfig  `yaml:"agents,omitempty"` // Agent 注册表与接入审批（/init_permission）
+	SandboxProfiles []SandboxProfileConfig `yaml:"sandbox_profiles,omitempty"` // 按 resource 下发的 Sandbox Profile；先匹配先生效，均未命中用内置默认
 	// 以下供 main_feishu / main 等入口使用（YAML 可选段）
 	LLM  *LLMConfig  `yaml:"llm,omitempty"`
 	Risk *RiskConfig `yaml:"risk,omitempty"`
@@ -25,6 +28,57 @@ type ChainConfig struct {
 	AuditBatchIntervalSec  int    `yaml:"audit_batch_interval_sec"`   // 定时提交间隔（秒）；0 表示默认 30
 }
 
+// GrantsConfig 限时授权：审批人批准时可选「N 分钟内」或「本会话内」对同一主体 + 操作 + 资源免审。
+type GrantsConfig struct {
+	Path       string `yaml:"path"`        // 授权持久化文件；空则仅内存
+	MaxMinutes int    `yaml:"max_minutes"` // 单条授权最长时长（分钟），会话授权同样受限；0 表示默认 480
+}
+
+// AgentsConfig Agent 注册表与接入：/init_permission 登记 Agent 并由 owner 审批，批准后签发 L0 凭证并挂载基线策略；
+// 管理员经 /agents 增删改 Agent（团队、标签、状态 pending/active/suspended/revoked）。
+type AgentsConfig struct {
+	Enabled                  bool   `yaml:"enabled"`                    // 为 false 时 /init_permission 仅返回 ok（兼容旧行为）
+	RegistryPath             string `yaml:"registry_path"`              // 注册表文件；空则仅内存
+	PolicyTemplate           string `yaml:"policy_template"`            // 基线策略模板（规则文件格式），按 Agent 实例化：subject 置为 agent_id，{agent_id} 被替换
+	HealthPath               string `yaml:"health_path"`                // 待审批 Agent 仍可访问的健康检查路径；空表示 /healthz
+	OnboardingTimeoutSeconds int    `yaml:"onboarding_timeout_seconds"` // 接入审批时限（秒）；0 表示默认 86400
+	AdminTokens              map[string]string `yaml:"admin_tokens,omitempty"` // 管理员 -> token，调用 /agents 等管理接口时放在 X-Admin-Token；空表示不校验（仅限本地调试）
+}
+
+// SandboxProfileConfig 一条按 resource 匹配的 Sandbox Profile（/auth/sandbox-profile、GetSandboxProfile 下发给 3af-exec）。
+// resource 为 "*"（任意）、结尾 "*" 的前缀（如 docker://project-a/*）或精确值（如 local://host-01）。
+type SandboxProfileConfig struct {
+	ID                string                   `yaml:"id"`                           // profile_id，写入 exec 审计
+	Resource          string                   `yaml:"resource"`                     // resource 匹配模式
+	Boundary          SandboxBoundaryConfig    `yaml:"boundary"`
+	HotCacheActions   []HotCacheActionConfig   `yaml:"hot_cache_actions,omitempty"`  // 本地直接放行的命令
+	SudoHotCache      []SudoHotCacheConfig     `yaml:"sudo_hot_cache,omitempty"`     // Sudo 预授权
+	DegradationPolicy string                   `yaml:"degradation_policy,omitempty"` // Diting 不可达时：FAIL_OPEN 或 FAIL_CLOSE；空表示 FAIL_CLOSE
+}
+
+// SandboxBoundaryConfig 沙箱边界。
+type SandboxBoundaryConfig struct {
+	NetworkEnabled  bool     `yaml:"network_enabled"`
+	FsWritablePaths []string `yaml:"fs_writable_paths,omitempty"`
+	SyscallPreset   string   `yaml:"syscall_preset,omitempty"` // 空表示 default
+	MaxMemoryMB     int64    `yaml:"max_memory_mb"`            // 0 表示不限制
+	ReadonlyRoot    bool     `yaml:"readonly_root"`
+}
+
+// HotCacheActionConfig 本地快速放行规则：executable 精确匹配，argv_allowlist 非空时参数须逐个在列表内。
+type HotCacheActionConfig struct {
+	Executable    string   `yaml:"executable"`
+	ArgvAllowlist []string `yaml:"argv_allowlist,omitempty"`
+	RunAsSudo     bool     `yaml:"run_as_sudo"`
+}
+
+// SudoHotCacheConfig Sudo 预授权条目。
+type SudoHotCacheConfig struct {
+	CommandPattern string `yaml:"command_pattern"`
+	RunAsUser      string `yaml:"run_as_user,omitempty"`
+	Description    string `yaml:"description,omitempty"`
+}
+
 // LLMConfig 大模型配置（main_feishu 等用）。
 type LLMConfig struct {
 	Provider    string  `yaml:"provider"`
@@ -46,8 +100,76 @@ type RiskConfig struct {
 // ProxyConfig 代理监听与上游；L0 身份校验（MVP API Key）。
 type ProxyConfig struct {
 	ListenAddr     string   `yaml:"listen_addr"`      // 如 :8080
+	GRPCListenAddr string   `yaml:"grpc_listen_addr"` // gRPC ExecAuthService 监听地址，如 :9090；空表示不启动
+	UnixSocketPath string   `yaml:"unix_socket_path"` // 同机 Agent 的 Unix 套接字（身份取自 SO_PEERCRED）；空表示不监听
+	UnixSocketMode string   `yaml:"unix_socket_mode"` // 套接字文件权限（八进制）；空表示 0660
 	Upstream       string   `yaml:"upstream"`         // 上游 base URL
-	AllowedAPIKeys []string `yaml:"allowed_api_keys"` // 允许的 L0 API Key 列表；空表示不强制 L0 校验
+	AllowedAPIKeys []string `yaml:"allowed_api_keys"` // 已弃用：明文 L0 API Key，启动时转为加盐哈希（key id 为 legacy-<明文哈希前缀>，不映射 Agent）
+	APIKeysPath    string         `yaml:"api_keys_path"` // API Key 存储文件（只存加盐哈希与最近使用时间）；空则仅内存
+	APIKeys        []APIKeyConfig `yaml:"api_keys"`      // 静态配置的 API Key（只含哈希，由 diting-ctl genkey 生成）
+	JWT            JWTConfig      `yaml:"jwt"`           // JWT Bearer Token（工作负载身份 / OIDC）校验；配置 jwks_file 或 jwks_url 即启用
+	TLS            TLSConfig      `yaml:"tls"`           // TLS / mTLS 监听；配置 cert_file 与 key_file 即以 HTTPS 监听
+	HTTPSig        HTTPSigConfig  `yaml:"http_signatures"` // DID HTTP 消息签名校验；须启用 chain（以链上 DID 文档的公钥验签）
+	// L0Authenticators L0 认证器顺序（api_key、jwt），按序尝试，token 不属于某认证器的格式时交给下一个；空表示 [api_key, jwt]
+	L0Authenticators []string `yaml:"l0_authenticators"`
+	// 配置了 api_keys_path、api_keys、allowed_api_keys 或 jwt 任一项即强制 L0 校验
+}
+
+// TLSConfig 监听的 TLS / mTLS：配置 client_ca_file 后校验客户端证书，并将证书身份映射为 L0 的 Agent 身份。
+// 证书、CA 与 CRL 文件变更后自动热加载，新连接使用新配置。
+type TLSConfig struct {
+	CertFile              string   `yaml:"cert_file"`
+	KeyFile               string   `yaml:"key_file"`
+	ClientCAFile          string   `yaml:"client_ca_file"`          // 客户端证书 CA；空表示仅 TLS、不校验客户端证书
+	CRLFile               string   `yaml:"crl_file"`                // 客户端证书吊销列表（PEM/DER）；空表示不检查
+	RequireClientCert     bool     `yaml:"require_client_cert"`     // true 时握手即拒绝未出示证书的连接；否则证书可选（出示须有效）
+	ClientIdentity        []string `yaml:"client_identity"`         // 证书映射为 Agent 身份的来源顺序：spiffe、san_uri、san_dns、san_email、cn；空表示 [spiffe, san_uri, san_dns, cn]
+	SPIFFETrustDomain     string   `yaml:"spiffe_trust_domain"`     // 非空时只接受该信任域的 SPIFFE ID
+	ReloadIntervalSeconds int      `yaml:"reload_interval_seconds"` // 检查证书文件变更的间隔；0 表示默认 30
+}
+
+// Enabled 是否配置了 TLS 监听。
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// HTTPSigConfig HTTP 消息签名（RFC 9421 风格）校验：Signature-Input 的 keyid 为链上 DID，以其公钥验签，吊销的 DID 拒绝；
+// 签名须带 created 与 nonce，created 超出时间窗或 nonce 重复使用时拒绝（防重放）。
+type HTTPSigConfig struct {
+	Enabled             bool     `yaml:"enabled"`
+	MaxAgeSeconds       int      `yaml:"max_age_seconds"`       // created 距今的最长时间；0 表示默认 300
+	RequireChallenge    bool     `yaml:"require_challenge"`     // true 时 nonce 须由 POST /auth/challenge 签发
+	ChallengeTTLSeconds int      `yaml:"challenge_ttl_seconds"` // challenge nonce 有效期；0 表示默认 60
+	RequiredComponents  []string `yaml:"required_components"`   // 签名须覆盖的组件；空表示 [@method, @target-uri]，带 body 时另须 content-digest
+	MaxBodyBytes        int64    `yaml:"max_body_bytes"`        // 校验 content-digest 时读取 body 的上限；0 表示默认 10 MiB
+}
+
+// JWTConfig JWT Bearer Token 校验：按 JWKS 验签，校验 iss/aud/exp/nbf，并将 claim 映射为 Agent 主体、团队与标签。
+type JWTConfig struct {
+	JWKSFile           string            `yaml:"jwks_file"`            // 本地 JWKS 文件
+	JWKSURL            string            `yaml:"jwks_url"`             // JWKS URL（与 jwks_file 二选一，jwks_file 优先）
+	JWKSRefreshSeconds int               `yaml:"jwks_refresh_seconds"` // JWKS 缓存刷新间隔；0 表示默认 300，遇到未知 kid 时另行重新加载
+	Issuer             string            `yaml:"issuer"`               // 期望的 iss；空表示不校验
+	Audience           []string          `yaml:"audience"`             // 可接受的 aud；空表示不校验
+	LeewaySeconds      int               `yaml:"leeway_seconds"`       // exp/nbf 时钟偏差；0 表示默认 60
+	SubjectClaim       string            `yaml:"subject_claim"`        // 映射为 Agent ID 的 claim；空表示 sub，支持 a.b 嵌套路径
+	TeamClaim          string            `yaml:"team_claim"`           // 映射为团队的 claim；空表示不映射
+	LabelClaims        map[string]string `yaml:"label_claims"`         // 标签名 -> claim
+}
+
+// Enabled 是否配置了 JWT 校验。
+func (c JWTConfig) Enabled() bool {
+	return c.JWKSFile != "" || c.JWKSURL != ""
+}
+
+// APIKeyConfig 静态配置的一条 API Key；hash 为 "<salt hex>:<sha256 hex>"，明文不入配置。
+type APIKeyConfig struct {
+	ID        string   `yaml:"id"`
+	AgentID   string   `yaml:"agent_id"`  // Key 代表的 Agent；审计与策略以其为主体
+	Hash      string   `yaml:"hash"`
+	Actions   []string `yaml:"actions"`   // 允许的 action；空表示不限
+	Resources []string `yaml:"resources"` // 允许的 resource，结尾 * 为前缀匹配；空表示不限
+	ExpiresAt string   `yaml:"expires_at"` // RFC3339；空表示不过期
 }
 
 // PolicyConfig 策略引擎配置（规则路径、热加载等）。
@@ -60,7 +182,32 @@ type CHEQConfig struct {
 	TimeoutSeconds              int             `yaml:"timeout_seconds"`
 	ReminderSecondsBeforeTimeout int             `yaml:"reminder_seconds_before_timeout"` // 超时前多少秒发飞书提醒；0 表示默认 60
 	PersistencePath             string          `yaml:"persistence_path"`
+	SweepIntervalSeconds        int             `yaml:"sweep_interval_seconds"` // 后台过期清扫间隔（秒）；0 表示默认 30
+	RetentionDays               int             `yaml:"retention_days"`         // 终态对象保留天数，压缩时清理；0 表示默认 30，<0 表示永久保留
+	CompactIntervalSeconds      int             `yaml:"compact_interval_seconds"` // 存储快照压缩间隔（秒）；0 表示默认 300
 	ApprovalRules               []ApprovalRule  `yaml:"approval_rules,omitempty"` // I-009：按 path/risk_level 匹配不同超时与审批人；先匹配先生效
+	SecurityOncallIDs           []string        `yaml:"security_oncall_ids,omitempty"` // 安全值班确认人，规则 on_timeout=escalate_oncall 时投递
+	OncallTimeoutSeconds        int             `yaml:"oncall_timeout_seconds"`        // 转值班后再等待的秒数；0 表示用 timeout_seconds
+	ApproverRoles               map[string][]string `yaml:"approver_roles,omitempty"` // 角色 -> 确认人列表，供 roles:... 策略按角色计票
+	// 审批人认证：配置任一项后 /cheq/approve 须带签名链接或审批人 token
+	ApprovalLinkSecret     string            `yaml:"approval_link_secret"`          // 审批链接 HMAC 密钥；实际从 DITING_CHEQ_APPROVAL_LINK_SECRET 覆盖
+	ApprovalLinkTTLSeconds int               `yaml:"approval_link_ttl_seconds"`     // 链接有效期（秒）；0 表示与请求过期时间一致
+	ApproverTokens         map[string]string `yaml:"approver_tokens,omitempty"`     // 确认人 -> API token，直接调用 /cheq/approve 时放在 X-Approver-Token
+	RequesterOwners        map[string]string `yaml:"requester_owners,omitempty"`    // Agent 身份 -> owner 确认人；owner 不可审批该 Agent 的请求
+	Workflows              map[string]WorkflowConfig `yaml:"workflows,omitempty"` // ConfirmationObject.Type（agent_onboarding/service_access/operation_approval）-> 多阶段审批流程
+}
+
+// WorkflowConfig 多阶段审批流程：各阶段依次审批，当前阶段达成策略后投递给下一阶段，最后一阶段达成才放行；任一阶段拒绝即拒绝。
+type WorkflowConfig struct {
+	Stages []WorkflowStage `yaml:"stages"`
+}
+
+// WorkflowStage 审批流程的一个阶段。
+type WorkflowStage struct {
+	Name            string   `yaml:"name"`                        // 阶段名，如 owner、security；空则为 stageN
+	ApprovalUserIDs []string `yaml:"approval_user_ids,omitempty"` // 本阶段审批人；空则沿用请求的审批人（如 Agent owner 或匹配规则的审批人）
+	ApprovalPolicy  string   `yaml:"approval_policy,omitempty"`   // any、all、quorum:N 或 roles:role=N,...；空则沿用请求的策略
+	TimeoutSeconds  int      `yaml:"timeout_seconds"`             // 本阶段时限（秒），自进入本阶段起计；0 表示与请求的超时相同
 }
 
 // ApprovalRule I-009：单条审批规则，按 path 前缀或 risk_level 匹配，覆盖超时与审批人。
@@ -69,7 +216,16 @@ type ApprovalRule struct {
 	RiskLevel        string   `yaml:"risk_level,omitempty"`    // 风险等级精确匹配（如 high、medium、low）；可选
 	TimeoutSeconds   int      `yaml:"timeout_seconds"`         // 本规则超时秒数；0 表示用全局 CHEQ.timeout_seconds
 	ApprovalUserIDs  []string `yaml:"approval_user_ids,omitempty"` // 本规则审批人列表；空表示用 delivery.feishu 默认
-	ApprovalPolicy   string   `yaml:"approval_policy,omitempty"`   // any 或 all；空表示用 delivery.feishu 默认
+	ApprovalPolicy   string   `yaml:"approval_policy,omitempty"`   // any、all、quorum:N 或 roles:role=N,...；空表示用 delivery.feishu 默认
+	RejectMode       string   `yaml:"reject_mode,omitempty"`       // veto（默认，任一拒绝即拒绝）或 quorum（剩余票数已无法满足策略时才拒绝）
+	Escalation       []EscalationStage `yaml:"escalation,omitempty"` // 升级阶段：创建后 after_seconds 仍无决定则投递给下一组审批人
+	OnTimeout        string   `yaml:"on_timeout,omitempty"`        // 最终超时动作：deny（默认）、allow_with_flag、escalate_oncall
+}
+
+// EscalationStage 审批升级阶段；after_seconds 自请求创建起计，需小于规则超时。
+type EscalationStage struct {
+	AfterSeconds    int      `yaml:"after_seconds"`
+	ApprovalUserIDs []string `yaml:"approval_user_ids"`
 }
 
 // DeliveryConfig 投递配置；敏感项从 env 覆盖（DITING_FEISHU_APP_SECRET 等）。
@@ -84,7 +240,7 @@ type FeishuConfig struct {
 	Enabled                bool     `yaml:"enabled"`
 	ApprovalUserID         string   `yaml:"approval_user_id"`           // 单审批人（兼容）；与 ApprovalUserIDs 二选一
 	ApprovalUserIDs        []string `yaml:"approval_user_ids"`          // 多审批人列表（I-008）；空时用 approval_user_id 转成单元素
-	ApprovalPolicy         string   `yaml:"approval_policy"`            // any（任一通过）或 all（全部通过）；默认 any
+	ApprovalPolicy         string   `yaml:"approval_policy"`            // any（任一通过）、all（全部通过）、quorum:N 或 roles:role=N,...；默认 any
 	ReceiveIDType          string   `yaml:"receive_id_type"`           // open_id（默认）或 user_id，避免 open_id cross app
 	ApprovalTimeoutMinutes int    `yaml:"approval_timeout_minutes"` // 审批超时（分钟）
 	UseMessageReply        bool   `yaml:"use_message_reply"`
@@ -97,6 +253,9 @@ type FeishuConfig struct {
 	// 飞书发送失败时的重试与退避（P1）
 	RetryMaxAttempts         int `yaml:"retry_max_attempts"`          // 最大重试次数；0 表示默认 3
 	RetryInitialBackoffSeconds int `yaml:"retry_initial_backoff_seconds"` // 首次退避秒数，之后指数增加；0 表示默认 1
+	// HTTP 卡片回调（/feishu/card）的来源校验，二选一；实际从 env 覆盖
+	EncryptKey        string `yaml:"encrypt_key"`        // 飞书后台 Encrypt Key：校验 X-Lark-Signature
+	VerificationToken string `yaml:"verification_token"` // 飞书后台 Verification Token：校验回调中的 token
 }
 
 // AuditConfig 审计写入路径与脱敏配置。
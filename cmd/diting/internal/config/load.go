@@ -107,7 +107,7 @@ func Load(path string) (*Config, error) {
 	if len(c.Delivery.Feishu.ApprovalUserIDs) == 0 && c.Delivery.Feishu.ApprovalUserID != "" {
 		c.Delivery.Feishu.ApprovalUserIDs = []string{c.Delivery.Feishu.ApprovalUserID}
 	}
-	if c.Delivery.Feishu.ApprovalPolicy != "all" {
+	if c.Delivery.Feishu.ApprovalPolicy == "" {
 		c.Delivery.Feishu.ApprovalPolicy = "any"
 	}
 	return &c, nil
@@ -130,6 +130,12 @@ func applyEnvOverrides(c *Config) {
 	if v := os.Getenv("DITING_FEISHU_CHAT_ID"); v != "" {
 		c.Delivery.Feishu.ChatID = v
 	}
+	if v := os.Getenv("DITING_FEISHU_ENCRYPT_KEY"); v != "" {
+		c.Delivery.Feishu.EncryptKey = v
+	}
+	if v := os.Getenv("DITING_FEISHU_VERIFICATION_TOKEN"); v != "" {
+		c.Delivery.Feishu.VerificationToken = v
+	}
 	if v := os.Getenv("DITING_FEISHU_RECEIVE_ID_TYPE"); v != "" {
 		c.Delivery.Feishu.ReceiveIDType = v
 	}
@@ -150,6 +156,9 @@ func applyEnvOverrides(c *Config) {
 			c.CHEQ.TimeoutSeconds = n
 		}
 	}
+	if v := os.Getenv("DITING_CHEQ_APPROVAL_LINK_SECRET"); v != "" {
+		c.CHEQ.ApprovalLinkSecret = v
+	}
 	if v := os.Getenv("DITING_CHEQ_REMINDER_SECONDS_BEFORE_TIMEOUT"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			c.CHEQ.ReminderSecondsBeforeTimeout = n
@@ -0,0 +1,74 @@
+// 卡片回调（HTTP 方式）的来源校验：配置 encrypt_key 时校验请求签名，否则配置 verification_token 时校验回调中的 token。
+
+package feishu
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diting/internal/config"
+)
+
+// callbackMaxSkew 签名时间戳与本地时间的最大偏差，超出视为重放。
+const callbackMaxSkew = 5 * time.Minute
+
+var (
+	// ErrCallbackUnverifiable 未配置 encrypt_key 与 verification_token，无法校验回调来源。
+	ErrCallbackUnverifiable = errors.New("feishu: callback verification not configured")
+	// ErrCallbackSignature 签名缺失、过期或不匹配。
+	ErrCallbackSignature = errors.New("feishu: invalid callback signature")
+	// ErrCallbackToken verification token 缺失或不匹配。
+	ErrCallbackToken = errors.New("feishu: invalid callback verification token")
+)
+
+// CanVerifyCallback 返回是否配置了回调校验所需的 encrypt_key 或 verification_token。
+func CanVerifyCallback(cfg config.FeishuConfig) bool {
+	return cfg.EncryptKey != "" || cfg.VerificationToken != ""
+}
+
+// VerifyCardCallback 校验卡片回调来自飞书：配置 encrypt_key 时要求 X-Lark-Signature 等于
+// sha256(timestamp + nonce + encrypt_key + body) 且时间戳在 callbackMaxSkew 内；否则要求回调 token
+// （旧版卡片为顶层 token，2.0 为 header.token）与 verification_token 一致。二者均未配置返回 ErrCallbackUnverifiable。
+func VerifyCardCallback(cfg config.FeishuConfig, header http.Header, body []byte, now time.Time) error {
+	if cfg.EncryptKey != "" {
+		ts := header.Get("X-Lark-Request-Timestamp")
+		nonce := header.Get("X-Lark-Request-Nonce")
+		sig := header.Get("X-Lark-Signature")
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil || sig == "" {
+			return ErrCallbackSignature
+		}
+		if d := now.Sub(time.Unix(sec, 0)); d > callbackMaxSkew || d < -callbackMaxSkew {
+			return ErrCallbackSignature
+		}
+		sum := sha256.Sum256([]byte(ts + nonce + cfg.EncryptKey + string(body)))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(sig)) != 1 {
+			return ErrCallbackSignature
+		}
+		return nil
+	}
+	if cfg.VerificationToken == "" {
+		return ErrCallbackUnverifiable
+	}
+	var cb struct {
+		Token  string `json:"token"`
+		Header struct {
+			Token string `json:"token"`
+		} `json:"header"`
+	}
+	_ = json.Unmarshal(body, &cb)
+	tok := cb.Token
+	if tok == "" {
+		tok = cb.Header.Token
+	}
+	if tok == "" || subtle.ConstantTimeCompare([]byte(tok), []byte(cfg.VerificationToken)) != 1 {
+		return ErrCallbackToken
+	}
+	return nil
+}
@@ -13,8 +13,10 @@ import (
 	"sync"
 	"time"
 
+	"diting/internal/cheq"
 	"diting/internal/config"
 	"diting/internal/delivery"
+	"diting/internal/models"
 )
 
 const (
@@ -29,6 +31,11 @@ type Provider struct {
 	mu      sync.RWMutex
 	token   string
 	expiry  time.Time
+
+	sentMu sync.Mutex
+	sent   map[string][]models.DeliveryRef // cheq_id -> 已发送消息；仅调用方未提供 OnSent 时使用，不跨重启
+
+	signer *cheq.ApprovalSigner // 非 nil 时审批链接按接收人签名
 }
 
 // NewProvider 根据飞书配置创建；app_secret 应从环境变量读取（config.Load 已做 env 覆盖）。
@@ -36,9 +43,15 @@ func NewProvider(cfg config.FeishuConfig) *Provider {
 	return &Provider{
 		cfg:    cfg,
 		client: &http.Client{Timeout: 15 * time.Second},
+		sent:   make(map[string][]models.DeliveryRef),
 	}
 }
 
+// SetApprovalSigner 设置审批链接签名器；设置后发给个人的链接带 by/exp/sig，仅该接收人可用。
+func (p *Provider) SetApprovalSigner(s *cheq.ApprovalSigner) {
+	p.signer = s
+}
+
 // Deliver 将待确认对象以文本消息发送到飞书（优先 approval_user_id，否则 chat_id）。
 func (p *Provider) Deliver(ctx context.Context, in *delivery.DeliverInput) error {
 	if in == nil || in.Object == nil {
@@ -98,7 +111,6 @@ func (p *Provider) Deliver(ctx context.Context, in *delivery.DeliverInput) error
 	if initialBackoff <= 0 {
 		initialBackoff = 1
 	}
-	policyAll := p.cfg.ApprovalPolicy == "all"
 	var lastErr error
 	for _, rid := range receiveIDs {
 		if rid == "" {
@@ -110,21 +122,26 @@ func (p *Provider) Deliver(ctx context.Context, in *delivery.DeliverInput) error
 		} else if rid == p.cfg.ChatID {
 			idType = "chat_id"
 		}
-		approveURL := fmt.Sprintf("%s/cheq/approve?id=%s&approved=true", baseURL, in.Object.ID)
-		rejectURL := fmt.Sprintf("%s/cheq/approve?id=%s&approved=false", baseURL, in.Object.ID)
-		if policyAll {
-			approveURL += "&by=" + url.QueryEscape(rid)
-			rejectURL += "&by=" + url.QueryEscape(rid)
+		links := p.approvalLinks(baseURL, in.Object, rid, idType)
+		var linkText strings.Builder
+		for _, l := range links {
+			label := ""
+			if len(links) > 1 {
+				label = "（" + l.Confirmer + "）"
+			}
+			fmt.Fprintf(&linkText, "\n批准%s: %s\n批准 1 小时%s: %s\n拒绝%s: %s", label, l.Approve, label, l.Approve+"&grant=minutes&grant_minutes=60", label, l.Reject)
 		}
-		body := fmt.Sprintf("待确认请求\nTraceID: %s\nID: %s\n摘要: %s\n\n批准: %s\n拒绝: %s",
-			in.Object.TraceID, in.Object.ID, summary, approveURL, rejectURL)
+		body := fmt.Sprintf("待确认请求\nTraceID: %s\nID: %s\n摘要: %s\n%s\n（拒绝时可在链接后追加 &reason_code=too_broad&comment=说明，原因会反馈给 Agent）",
+			in.Object.TraceID, in.Object.ID, summary, linkText.String())
+		var msgID string
 		for attempt := 0; attempt < maxAttempts; attempt++ {
 			if p.cfg.UseCardDelivery {
-				lastErr = p.sendCard(ctx, token, idType, rid, in.Object.TraceID, in.Object.ID, summary, approveURL, rejectURL)
+				msgID, lastErr = p.sendCard(ctx, token, idType, rid, in.Object.TraceID, in.Object.ID, summary, links, in.Object.SessionID != "")
 			} else {
-				lastErr = p.sendMessage(ctx, token, idType, rid, body)
+				msgID, lastErr = p.sendMessage(ctx, token, idType, rid, body)
 			}
 			if lastErr == nil {
+				p.recordSent(in, models.DeliveryRef{Channel: "feishu", MessageID: msgID, Card: p.cfg.UseCardDelivery})
 				break
 			}
 			if attempt < maxAttempts-1 {
@@ -132,18 +149,109 @@ func (p *Provider) Deliver(ctx context.Context, in *delivery.DeliverInput) error
 			}
 		}
 		if lastErr != nil && strings.Contains(lastErr.Error(), "open_id cross app") && p.cfg.ChatID != "" {
-			lastErr = p.sendMessage(ctx, token, "chat_id", p.cfg.ChatID, body)
+			msgID, lastErr = p.sendMessage(ctx, token, "chat_id", p.cfg.ChatID, body)
+			if lastErr == nil {
+				p.recordSent(in, models.DeliveryRef{Channel: "feishu", MessageID: msgID})
+			}
 		}
 	}
 	return lastErr
 }
 
+// approvalLink 一组审批链接；Confirmer 为链接绑定的确认人（未绑定为空）。
+type approvalLink struct {
+	Confirmer string
+	Approve   string
+	Reject    string
+}
+
+// approvalLinks 返回投递给 rid 的审批链接：发给个人时绑定该接收人（计票策略据此记录确认人）；发到群聊时启用签名则按对象的
+// 确认人逐人生成（无确认人名单时绑定群 id），未启用签名时为不绑定确认人的一组链接。启用签名时链接均带 by/exp/sig。
+func (p *Provider) approvalLinks(baseURL string, obj *models.ConfirmationObject, rid, idType string) []approvalLink {
+	confirmers := []string{rid}
+	if idType == "chat_id" {
+		if p.signer == nil {
+			return []approvalLink{{
+				Approve: fmt.Sprintf("%s/cheq/approve?id=%s&approved=true", baseURL, obj.ID),
+				Reject:  fmt.Sprintf("%s/cheq/approve?id=%s&approved=false", baseURL, obj.ID),
+			}}
+		}
+		if len(obj.ConfirmerIDs) > 0 {
+			confirmers = obj.ConfirmerIDs
+		}
+	}
+	links := make([]approvalLink, 0, len(confirmers))
+	for _, c := range confirmers {
+		l := approvalLink{Confirmer: c}
+		if p.signer != nil {
+			l.Approve = baseURL + "/cheq/approve?" + p.signer.LinkQuery(obj, c, true)
+			l.Reject = baseURL + "/cheq/approve?" + p.signer.LinkQuery(obj, c, false)
+		} else {
+			l.Approve = fmt.Sprintf("%s/cheq/approve?id=%s&approved=true&by=%s", baseURL, obj.ID, url.QueryEscape(c))
+			l.Reject = fmt.Sprintf("%s/cheq/approve?id=%s&approved=false&by=%s", baseURL, obj.ID, url.QueryEscape(c))
+		}
+		links = append(links, l)
+	}
+	return links
+}
+
+// rejectReasons 卡片上带原因码的拒绝按钮。
+var rejectReasons = []struct{ code, label string }{
+	{models.ReasonTooBroad, "范围过大"},
+	{models.ReasonInsufficientContext, "信息不足"},
+	{models.ReasonPolicyViolation, "违反策略"},
+}
+
 // sendCard 发送交互卡片（批准/拒绝按钮），按钮 value 为 {"request_id":"<cheq_id>","action":"approve"|"reject"}，供长连接或 HTTP 回调解析。
-func (p *Provider) sendCard(ctx context.Context, token, receiveIDType, receiveID, traceID, cheqID, summary, approveURL, rejectURL string) error {
-	bodyMD := fmt.Sprintf("**待确认请求**\n\nTraceID: `%s`\nID: `%s`\n摘要: %s\n\n可点击下方按钮审批，或使用链接：\n[批准](%s) | [拒绝](%s)",
-		traceID, cheqID, summary, approveURL, rejectURL)
+// 另有「批准 1 小时」按钮（value 带 grant=minutes、grant_minutes）；sessionGrant 为 true（请求来自会话）时再加「本会话批准」（grant=session）。
+func (p *Provider) sendCard(ctx context.Context, token, receiveIDType, receiveID, traceID, cheqID, summary string, links []approvalLink, sessionGrant bool) (string, error) {
+	bodyMD := fmt.Sprintf("**待确认请求**\n\nTraceID: `%s`\nID: `%s`\n摘要: %s\n\n可点击下方按钮审批，或使用链接：",
+		traceID, cheqID, summary)
+	for _, l := range links {
+		bodyMD += fmt.Sprintf("\n[批准](%s) | [拒绝](%s)", l.Approve, l.Reject)
+		if len(links) > 1 {
+			bodyMD += "（" + l.Confirmer + "）"
+		}
+	}
 	approveVal := map[string]string{"request_id": cheqID, "action": "approve"}
 	rejectVal := map[string]string{"request_id": cheqID, "action": "reject"}
+	actions := []interface{}{
+		map[string]interface{}{
+			"tag":  "button",
+			"text": map[string]interface{}{"tag": "plain_text", "content": "批准"},
+			"type": "primary",
+			"value": approveVal,
+		},
+		map[string]interface{}{
+			"tag":  "button",
+			"text": map[string]interface{}{"tag": "plain_text", "content": "批准 1 小时"},
+			"type": "default",
+			"value": map[string]string{"request_id": cheqID, "action": "approve", "grant": "minutes", "grant_minutes": "60"},
+		},
+	}
+	if sessionGrant {
+		actions = append(actions, map[string]interface{}{
+			"tag":  "button",
+			"text": map[string]interface{}{"tag": "plain_text", "content": "本会话批准"},
+			"type": "default",
+			"value": map[string]string{"request_id": cheqID, "action": "approve", "grant": "session"},
+		})
+	}
+	actions = append(actions, map[string]interface{}{
+		"tag":  "button",
+		"text": map[string]interface{}{"tag": "plain_text", "content": "拒绝"},
+		"type": "danger",
+		"value": rejectVal,
+	})
+	// 带原因码的拒绝，原因随结果反馈给 Agent
+	for _, r := range rejectReasons {
+		actions = append(actions, map[string]interface{}{
+			"tag":  "button",
+			"text": map[string]interface{}{"tag": "plain_text", "content": "拒绝：" + r.label},
+			"type": "default",
+			"value": map[string]string{"request_id": cheqID, "action": "reject", "reason_code": r.code},
+		})
+	}
 	// 不使用回调 URL。卡片点击只通过长连接（card.action.trigger）回传，不填 request_url。
 	configCard := map[string]interface{}{"wide_screen_mode": true}
 	card := map[string]interface{}{
@@ -163,21 +271,8 @@ func (p *Provider) sendCard(ctx context.Context, token, receiveIDType, receiveID
 				},
 			},
 			map[string]interface{}{
-				"tag": "action",
-				"actions": []interface{}{
-					map[string]interface{}{
-						"tag":  "button",
-						"text": map[string]interface{}{"tag": "plain_text", "content": "批准"},
-						"type": "primary",
-						"value": approveVal,
-					},
-					map[string]interface{}{
-						"tag":  "button",
-						"text": map[string]interface{}{"tag": "plain_text", "content": "拒绝"},
-						"type": "default",
-						"value": rejectVal,
-					},
-				},
+				"tag":     "action",
+				"actions": actions,
 			},
 		},
 	}
@@ -189,33 +284,10 @@ func (p *Provider) sendCard(ctx context.Context, token, receiveIDType, receiveID
 	}
 	payload, _ := json.Marshal(reqBody)
 	url := messageAPI + "?receive_id_type=" + receiveIDType
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("feishu message api HTTP %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-	var result struct {
-		Code int    `json:"code"`
-		Msg  string `json:"msg"`
-	}
-	_ = json.Unmarshal(bodyBytes, &result)
-	if result.Code != 0 {
-		return fmt.Errorf("feishu API code=%d msg=%s", result.Code, result.Msg)
-	}
-	return nil
+	return p.callMessageAPI(ctx, token, http.MethodPost, url, payload)
 }
 
-func (p *Provider) sendMessage(ctx context.Context, token, receiveIDType, receiveID, body string) error {
+func (p *Provider) sendMessage(ctx context.Context, token, receiveIDType, receiveID, body string) (string, error) {
 	// 飞书要求 content 为 JSON 字符串，即对 {"text":"..."} 再序列化一次
 	contentJSON, _ := json.Marshal(map[string]string{"text": body})
 	reqBody := map[string]interface{}{
@@ -225,30 +297,123 @@ func (p *Provider) sendMessage(ctx context.Context, token, receiveIDType, receiv
 	}
 	payload, _ := json.Marshal(reqBody)
 	url := messageAPI + "?receive_id_type=" + receiveIDType
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	return p.callMessageAPI(ctx, token, http.MethodPost, url, payload)
+}
+
+// callMessageAPI 调用飞书消息接口（发送/更新/回复），返回 data.message_id。
+func (p *Provider) callMessageAPI(ctx context.Context, token, method, url string, payload []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
 	if err != nil {
-		return err
+		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token)
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 	bodyBytes, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("feishu message api HTTP %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", fmt.Errorf("feishu message api HTTP %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 	var result struct {
 		Code int    `json:"code"`
 		Msg  string `json:"msg"`
+		Data struct {
+			MessageID string `json:"message_id"`
+		} `json:"data"`
 	}
 	_ = json.Unmarshal(bodyBytes, &result)
 	if result.Code != 0 {
-		return fmt.Errorf("feishu API code=%d msg=%s", result.Code, result.Msg)
+		return "", fmt.Errorf("feishu API code=%d msg=%s", result.Code, result.Msg)
+	}
+	return result.Data.MessageID, nil
+}
+
+// recordSent 记录已发出的消息：调用方提供 OnSent 时交由其持久化到对象，否则记入进程内 sent。
+func (p *Provider) recordSent(in *delivery.DeliverInput, ref models.DeliveryRef) {
+	if ref.MessageID == "" {
+		return
+	}
+	if in.Options != nil && in.Options.OnSent != nil {
+		in.Options.OnSent(ref)
+		return
+	}
+	p.sentMu.Lock()
+	p.sent[in.Object.ID] = append(p.sent[in.Object.ID], ref)
+	p.sentMu.Unlock()
+}
+
+// NotifyStatus 实现 delivery.StatusNotifier：对象终态后将已发卡片更新为结果卡片，文本消息则回复一条状态说明。
+// 待更新的消息取自对象上持久化的 Deliveries 与进程内 sent（按 MessageID 去重）。
+func (p *Provider) NotifyStatus(ctx context.Context, obj *models.ConfirmationObject) error {
+	if obj == nil {
+		return nil
+	}
+	var msgs []models.DeliveryRef
+	seen := make(map[string]bool)
+	for _, m := range obj.Deliveries {
+		if m.Channel == "feishu" && m.MessageID != "" && !seen[m.MessageID] {
+			seen[m.MessageID] = true
+			msgs = append(msgs, m)
+		}
+	}
+	p.sentMu.Lock()
+	for _, m := range p.sent[obj.ID] {
+		if !seen[m.MessageID] {
+			seen[m.MessageID] = true
+			msgs = append(msgs, m)
+		}
+	}
+	delete(p.sent, obj.ID)
+	p.sentMu.Unlock()
+	if len(msgs) == 0 {
+		return nil
+	}
+	token, err := p.getToken(ctx)
+	if err != nil {
+		return fmt.Errorf("feishu token: %w", err)
+	}
+	status := statusText(obj.Status)
+	if obj.DecisionReasonCode != "" {
+		status += "（" + obj.DecisionReasonCode + "）"
+	}
+	if obj.DecisionComment != "" {
+		status += "：" + obj.DecisionComment
+	}
+	var lastErr error
+	for _, m := range msgs {
+		if m.Card {
+			content, _ := json.Marshal(resultCardContent(status, obj.ID))
+			payload, _ := json.Marshal(map[string]string{"content": string(content)})
+			_, err = p.callMessageAPI(ctx, token, http.MethodPatch, messageAPI+"/"+m.MessageID, payload)
+		} else {
+			contentJSON, _ := json.Marshal(map[string]string{"text": fmt.Sprintf("该请求%s\nID: %s", status, obj.ID)})
+			payload, _ := json.Marshal(map[string]string{"msg_type": "text", "content": string(contentJSON)})
+			_, err = p.callMessageAPI(ctx, token, http.MethodPost, messageAPI+"/"+m.MessageID+"/reply", payload)
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// statusText 返回终态的中文展示文案。
+func statusText(s models.ConfirmationStatus) string {
+	switch s {
+	case models.ConfirmationStatusApproved:
+		return "已批准"
+	case models.ConfirmationStatusRejected:
+		return "已拒绝"
+	case models.ConfirmationStatusExpired:
+		return "已过期"
+	case models.ConfirmationStatusCancelled:
+		return "已撤销"
+	default:
+		return string(s)
 	}
-	return nil
 }
 
 func (p *Provider) getToken(ctx context.Context) (string, error) {
@@ -299,5 +464,8 @@ func (p *Provider) getToken(ctx context.Context) (string, error) {
 	return p.token, nil
 }
 
-// 编译期保证 *Provider 实现 delivery.Provider。
-var _ delivery.Provider = (*Provider)(nil)
+// 编译期保证 *Provider 实现 delivery.Provider 与 delivery.StatusNotifier。
+var (
+	_ delivery.Provider       = (*Provider)(nil)
+	_ delivery.StatusNotifier = (*Provider)(nil)
+)
@@ -0,0 +1,87 @@
+package feishu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"diting/internal/cheq"
+	"diting/internal/config"
+	"diting/internal/models"
+)
+
+func TestVerifyCardCallback(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"action":{"value":{"request_id":"c1","action":"approve"}}}`)
+	signed := func(key string, at time.Time) http.Header {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		sum := sha256.Sum256([]byte(ts + "n1" + key + string(body)))
+		h := http.Header{}
+		h.Set("X-Lark-Request-Timestamp", ts)
+		h.Set("X-Lark-Request-Nonce", "n1")
+		h.Set("X-Lark-Signature", hex.EncodeToString(sum[:]))
+		return h
+	}
+	cfg := config.FeishuConfig{EncryptKey: "ek"}
+	if err := VerifyCardCallback(cfg, signed("ek", now), body, now); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	for name, h := range map[string]http.Header{
+		"wrong key": signed("other", now),
+		"stale":     signed("ek", now.Add(-10*time.Minute)),
+		"unsigned":  {},
+	} {
+		if err := VerifyCardCallback(cfg, h, body, now); !errors.Is(err, ErrCallbackSignature) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+
+	cfg = config.FeishuConfig{VerificationToken: "vt"}
+	if err := VerifyCardCallback(cfg, http.Header{}, []byte(`{"header":{"token":"vt"}}`), now); err != nil {
+		t.Errorf("schema 2.0 token: %v", err)
+	}
+	if err := VerifyCardCallback(cfg, http.Header{}, []byte(`{"token":"x"}`), now); !errors.Is(err, ErrCallbackToken) {
+		t.Errorf("wrong token: err = %v", err)
+	}
+	if err := VerifyCardCallback(config.FeishuConfig{}, http.Header{}, body, now); !errors.Is(err, ErrCallbackUnverifiable) {
+		t.Errorf("unconfigured: err = %v", err)
+	}
+}
+
+func TestApprovalLinksSignedForChat(t *testing.T) {
+	signer := cheq.NewApprovalSigner("secret", 0)
+	p := NewProvider(config.FeishuConfig{ChatID: "oc_1"})
+	p.SetApprovalSigner(signer)
+	obj := &models.ConfirmationObject{ID: "c1", ConfirmerIDs: []string{"u1", "u2"}, ExpiresAt: time.Now().Add(time.Minute)}
+	verify := func(link, by string) {
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if q.Get("by") != by || signer.Verify("c1", by, q.Get("exp"), q.Get("sig"), time.Now()) != nil {
+			t.Errorf("link %s not signed for %s", link, by)
+		}
+	}
+
+	links := p.approvalLinks("https://gw", obj, "oc_1", "chat_id")
+	if len(links) != 2 {
+		t.Fatalf("chat links = %+v", links)
+	}
+	for i, by := range []string{"u1", "u2"} {
+		verify(links[i].Approve, by)
+		verify(links[i].Reject, by)
+	}
+	// 无确认人名单时绑定群 id
+	obj.ConfirmerIDs = nil
+	if links = p.approvalLinks("https://gw", obj, "oc_1", "chat_id"); len(links) != 1 {
+		t.Fatalf("chat links without confirmers = %+v", links)
+	}
+	verify(links[0].Approve, "oc_1")
+	verify(p.approvalLinks("https://gw", obj, "ou_9", "open_id")[0].Reject, "ou_9")
+}
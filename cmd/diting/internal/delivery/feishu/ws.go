@@ -16,15 +16,17 @@ import (
 )
 
 // RunLongConnection 在后台建立飞书长连接，接收 EVENT_CALLBACK；若为卡片交互（action.value.request_id + action），则调用 onCardAction。
+// operatorID 为点击人标识（receive_id_type 为 user_id 时取 user_id，否则 open_id），供计票与确认人校验；
+// opts 为按钮携带的授权选择（「批准 1 小时」「本会话批准」）或拒绝原因码，普通批准/拒绝为 nil。
 // 需在飞书开放平台选择「使用长连接接收事件」并订阅相应事件。ctx 取消时退出。
-func RunLongConnection(ctx context.Context, cfg config.FeishuConfig, onCardAction func(cheqID string, approved bool) error) {
+func RunLongConnection(ctx context.Context, cfg config.FeishuConfig, onCardAction func(cheqID string, approved bool, operatorID string, opts *cheq.SubmitOptions) error) {
 	if !cfg.Enabled || cfg.AppID == "" || cfg.AppSecret == "" {
 		return
 	}
 	go runWSLoop(ctx, cfg, onCardAction)
 }
 
-func runWSLoop(ctx context.Context, cfg config.FeishuConfig, onCardAction func(cheqID string, approved bool) error) {
+func runWSLoop(ctx context.Context, cfg config.FeishuConfig, onCardAction func(cheqID string, approved bool, operatorID string, opts *cheq.SubmitOptions) error) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -36,7 +38,7 @@ func runWSLoop(ctx context.Context, cfg config.FeishuConfig, onCardAction func(c
 				if event == nil || event.Event == nil || event.Event.Action == nil {
 					return &callback.CardActionTriggerResponse{}, nil
 				}
-				return handleWSCardAction(event.Event.Action.Value, onCardAction), nil
+				return handleWSCardAction(event.Event.Action.Value, operatorID(event.Event.Operator, cfg.ReceiveIDType), onCardAction), nil
 			})
 		client := larkws.NewClient(cfg.AppID, cfg.AppSecret, larkws.WithEventHandler(eventHandler))
 		fmt.Fprintf(os.Stderr, "[diting] 飞书长连接已建立，等待卡片交互事件...\n")
@@ -58,7 +60,7 @@ func runWSLoop(ctx context.Context, cfg config.FeishuConfig, onCardAction func(c
 }
 
 // handleWSCardAction 处理 SDK 事件里的卡片点击（event_type=card.action.trigger），不走 HTTP 回调。
-func handleWSCardAction(value map[string]interface{}, onCardAction func(cheqID string, approved bool) error) *callback.CardActionTriggerResponse {
+func handleWSCardAction(value map[string]interface{}, operator string, onCardAction func(cheqID string, approved bool, operatorID string, opts *cheq.SubmitOptions) error) *callback.CardActionTriggerResponse {
 	if value == nil {
 		return &callback.CardActionTriggerResponse{
 			Toast: &callback.Toast{Type: "info", Content: "忽略"},
@@ -72,7 +74,11 @@ func handleWSCardAction(value map[string]interface{}, onCardAction func(cheqID s
 		}
 	}
 	approved := actionStr == "approve"
-	if err := onCardAction(requestID, approved); err != nil {
+	opts := cheq.ParseSubmitOptions(func(k string) string {
+		v, _ := value[k].(string)
+		return v
+	})
+	if err := onCardAction(requestID, approved, operator, opts); err != nil {
 		fmt.Fprintf(os.Stderr, "[diting] 飞书卡片审批 Submit: %v\n", err)
 		if err == cheq.ErrNotFound {
 			return &callback.CardActionTriggerResponse{
@@ -86,6 +92,16 @@ func handleWSCardAction(value map[string]interface{}, onCardAction func(cheqID s
 				Card:  buildResultCard("已过期", requestID),
 			}
 		}
+		if err == cheq.ErrNotConfirmer || err == cheq.ErrConfirmerRequired {
+			return &callback.CardActionTriggerResponse{
+				Toast: &callback.Toast{Type: "warning", Content: "您不是该请求的审批人"},
+			}
+		}
+		if err == cheq.ErrAlreadyVoted {
+			return &callback.CardActionTriggerResponse{
+				Toast: &callback.Toast{Type: "warning", Content: "您已投过票"},
+			}
+		}
 		if err == cheq.ErrAlreadyProcessed {
 			return &callback.CardActionTriggerResponse{
 				Toast: &callback.Toast{Type: "warning", Content: "该请求已处理"},
@@ -107,8 +123,27 @@ func handleWSCardAction(value map[string]interface{}, onCardAction func(cheqID s
 	}
 }
 
+// operatorID 取卡片点击人标识，与审批人配置的 receive_id_type 一致。
+func operatorID(op *callback.Operator, receiveIDType string) string {
+	if op == nil {
+		return ""
+	}
+	if receiveIDType == "user_id" && op.UserID != nil && *op.UserID != "" {
+		return *op.UserID
+	}
+	return op.OpenID
+}
+
 func buildResultCard(status, requestID string) *callback.Card {
-	card := map[string]interface{}{
+	return &callback.Card{
+		Type: "raw",
+		Data: resultCardContent(status, requestID),
+	}
+}
+
+// resultCardContent 审批结果卡片内容；长连接回调与 NotifyStatus 更新卡片共用。
+func resultCardContent(status, requestID string) map[string]interface{} {
+	return map[string]interface{}{
 		"config": map[string]interface{}{
 			"wide_screen_mode": true,
 		},
@@ -128,8 +163,4 @@ func buildResultCard(status, requestID string) *callback.Card {
 			},
 		},
 	}
-	return &callback.Card{
-		Type: "raw",
-		Data: card,
-	}
 }
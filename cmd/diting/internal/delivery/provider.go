@@ -2,6 +2,8 @@ package delivery
 
 import (
 	"context"
+
+	"diting/internal/models"
 )
 
 // Provider 投递接口：将待确认请求投递到 IM/CLI 等。
@@ -10,3 +12,9 @@ type Provider interface {
 	// Deliver 投递待确认请求；Object 与 Options 由调用方组装。
 	Deliver(ctx context.Context, in *DeliverInput) error
 }
+
+// StatusNotifier 可选接口：对象进入终态（批准/拒绝/过期）后更新已投递的 IM 卡片或消息，避免确认人看到失效的待办。
+// CHEQ 引擎在状态落盘后异步调用；未实现该接口的 Provider 不做更新。
+type StatusNotifier interface {
+	NotifyStatus(ctx context.Context, obj *models.ConfirmationObject) error
+}
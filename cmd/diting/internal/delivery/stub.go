@@ -2,6 +2,8 @@ package delivery
 
 import (
 	"context"
+
+	"diting/internal/models"
 )
 
 // StubProvider 占位实现：Deliver 无操作，供 Phase 2 装配。
@@ -10,3 +12,7 @@ type StubProvider struct{}
 func (StubProvider) Deliver(ctx context.Context, in *DeliverInput) error {
 	return nil
 }
+
+func (StubProvider) NotifyStatus(ctx context.Context, obj *models.ConfirmationObject) error {
+	return nil
+}
@@ -9,6 +9,9 @@ type DeliverOptions struct {
 	ConfirmerIDs []string
 	Summary      string
 	ChannelType  string // 如 feishu / cli
+
+	// OnSent 非 nil 时，Provider 每发出一条消息回调一次，由调用方将消息标识持久化到对象上（见 ConfirmationObject.Deliveries）。
+	OnSent func(ref models.DeliveryRef)
 }
 
 // DeliverInput 供 Deliver 使用的入参，包含待确认对象与选项。
@@ -0,0 +1,88 @@
+// Package grant 提供限时授权（standing grant）：审批人批准时可选择「在 N 分钟内 / 本会话内」对同一主体 + 操作 + 资源免审，
+// 流水线在创建 CHEQ 前先查授权，命中则直接放行并审计为 approved_by_grant。
+package grant
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"diting/internal/models"
+)
+
+// 授权范围（审批人批准时选择）。
+const (
+	ScopeOnce    = "once"    // 仅本次，不产生授权
+	ScopeMinutes = "minutes" // 同一主体 + 操作 + 资源在 N 分钟内免审
+	ScopeSession = "session" // 同一会话内免审，会话结束（或达到上限时长）失效
+)
+
+// ErrNotFound 表示授权不存在。
+var ErrNotFound = errors.New("grant: not found")
+
+// Grant 一条限时授权；由某个已批准的 CHEQ 产生。
+type Grant struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	Action    string    `json:"action"`
+	Resource  string    `json:"resource"`
+	SessionID string    `json:"session_id,omitempty"` // Scope 为 session 时须匹配
+	Scope     string    `json:"scope"`
+	CHEQID    string    `json:"cheq_id"`              // 产生该授权的 CHEQ
+	GrantedBy []string  `json:"granted_by,omitempty"` // 批准的确认人
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at,omitempty"`
+	RevokedBy string    `json:"revoked_by,omitempty"`
+}
+
+// Active 返回授权在 now 是否有效（未撤销且未过期）。
+func (g *Grant) Active(now time.Time) bool {
+	return g.RevokedAt.IsZero() && now.Before(g.ExpiresAt)
+}
+
+// Store 授权存储。
+type Store interface {
+	// Create 保存授权；同一 CHEQID 已有授权时返回已有的（多个等待方重复创建为幂等）。
+	Create(ctx context.Context, g *Grant) (*Grant, error)
+	// Match 返回与主体、操作、资源（及会话）匹配的有效授权；无则 nil, nil。
+	Match(ctx context.Context, subject, action, resource, sessionID string, now time.Time) (*Grant, error)
+	// Revoke 撤销授权；不存在返回 ErrNotFound，已撤销为幂等。
+	Revoke(ctx context.Context, id, by string) error
+	// RevokeSession 撤销某会话下的全部授权，返回撤销数量。
+	RevokeSession(ctx context.Context, sessionID, by string) int
+	// List 返回授权列表，按创建时间升序；activeOnly 为 true 时仅返回有效授权。
+	List(ctx context.Context, activeOnly bool) ([]*Grant, error)
+}
+
+// FromApproval 由已批准的 CHEQ 推导授权范围：须每一张批准票都选择了 minutes 或 session；
+// 任一票为 session 则按 session，时长取各票最小值。返回 ok=false 表示不产生授权。
+func FromApproval(obj *models.ConfirmationObject) (scope string, minutes int, ok bool) {
+	if obj == nil || obj.Status != models.ConfirmationStatusApproved {
+		return "", 0, false
+	}
+	n := 0
+	for _, v := range obj.Votes {
+		if v.Decision != models.VoteApprove {
+			continue
+		}
+		n++
+		switch v.GrantScope {
+		case ScopeMinutes:
+			if scope == "" {
+				scope = ScopeMinutes
+			}
+		case ScopeSession:
+			scope = ScopeSession
+		default:
+			return "", 0, false
+		}
+		if minutes == 0 || (v.GrantMinutes > 0 && v.GrantMinutes < minutes) {
+			minutes = v.GrantMinutes
+		}
+	}
+	if n == 0 {
+		return "", 0, false
+	}
+	return scope, minutes, true
+}
@@ -0,0 +1,106 @@
+package grant
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"diting/internal/models"
+)
+
+func TestFileStore_MatchRevokeReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "grants.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	now := time.Now()
+	g := &Grant{ID: "g1", Subject: "agent1", Action: "POST", Resource: "/admin", Scope: ScopeMinutes, CHEQID: "c1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if _, err := s.Create(ctx, g); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// 同一 CHEQ 重复创建返回已有授权
+	dup, _ := s.Create(ctx, &Grant{ID: "g2", CHEQID: "c1"})
+	if dup.ID != "g1" {
+		t.Errorf("duplicate create should return g1, got %s", dup.ID)
+	}
+	if m, _ := s.Match(ctx, "agent1", "POST", "/admin", "", now); m == nil || m.ID != "g1" {
+		t.Fatalf("expected match g1, got %+v", m)
+	}
+	if m, _ := s.Match(ctx, "agent2", "POST", "/admin", "", now); m != nil {
+		t.Errorf("other subject should not match, got %+v", m)
+	}
+	if m, _ := s.Match(ctx, "agent1", "POST", "/admin", "", now.Add(2*time.Hour)); m != nil {
+		t.Errorf("expired grant should not match, got %+v", m)
+	}
+
+	s2, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if m, _ := s2.Match(ctx, "agent1", "POST", "/admin", "", now); m == nil {
+		t.Fatal("grant should survive reopen")
+	}
+	if err := s2.Revoke(ctx, "g1", "sec_1"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if m, _ := s2.Match(ctx, "agent1", "POST", "/admin", "", now); m != nil {
+		t.Errorf("revoked grant should not match, got %+v", m)
+	}
+	if err := s2.Revoke(ctx, "missing", "sec_1"); err != ErrNotFound {
+		t.Errorf("Revoke missing = %v, want ErrNotFound", err)
+	}
+	all, _ := s2.List(ctx, false)
+	if len(all) != 1 || all[0].RevokedBy != "sec_1" {
+		t.Errorf("expected revoked grant in list, got %+v", all)
+	}
+}
+
+func TestFileStore_SessionScope(t *testing.T) {
+	ctx := context.Background()
+	s, _ := NewFileStore("")
+	now := time.Now()
+	_, _ = s.Create(ctx, &Grant{ID: "g1", Subject: "agent1", Action: "exec:rm", Resource: "/tmp", Scope: ScopeSession, SessionID: "sess-1", CHEQID: "c1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if m, _ := s.Match(ctx, "agent1", "exec:rm", "/tmp", "sess-2", now); m != nil {
+		t.Errorf("other session should not match, got %+v", m)
+	}
+	if m, _ := s.Match(ctx, "agent1", "exec:rm", "/tmp", "sess-1", now); m == nil {
+		t.Fatal("same session should match")
+	}
+	if n := s.RevokeSession(ctx, "sess-1", "session_closed"); n != 1 {
+		t.Errorf("RevokeSession = %d, want 1", n)
+	}
+	if m, _ := s.Match(ctx, "agent1", "exec:rm", "/tmp", "sess-1", now); m != nil {
+		t.Errorf("closed session grant should not match, got %+v", m)
+	}
+}
+
+func TestFromApproval(t *testing.T) {
+	obj := &models.ConfirmationObject{Status: models.ConfirmationStatusApproved}
+	if _, _, ok := FromApproval(obj); ok {
+		t.Error("no votes should not produce a grant")
+	}
+	obj.Votes = []models.Vote{
+		{ConfirmerID: "a", Decision: models.VoteApprove, GrantScope: ScopeMinutes, GrantMinutes: 60},
+		{ConfirmerID: "b", Decision: models.VoteApprove, GrantScope: ScopeMinutes, GrantMinutes: 15},
+	}
+	if scope, minutes, ok := FromApproval(obj); !ok || scope != ScopeMinutes || minutes != 15 {
+		t.Errorf("FromApproval = %s %d %v, want minutes 15 true", scope, minutes, ok)
+	}
+	obj.Votes[1].GrantScope = ScopeSession
+	if scope, _, ok := FromApproval(obj); !ok || scope != ScopeSession {
+		t.Errorf("FromApproval scope = %s %v, want session", scope, ok)
+	}
+	// 任一审批人只批准本次则不产生授权
+	obj.Votes[0].GrantScope = ""
+	if _, _, ok := FromApproval(obj); ok {
+		t.Error("a once vote should not produce a grant")
+	}
+	obj.Votes[0].GrantScope = ScopeMinutes
+	obj.Status = models.ConfirmationStatusRejected
+	if _, _, ok := FromApproval(obj); ok {
+		t.Error("rejected object should not produce a grant")
+	}
+}
@@ -0,0 +1,176 @@
+package grant
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// pruneAfter 过期或撤销超过该时长的授权在下次写盘时清理。
+const pruneAfter = 7 * 24 * time.Hour
+
+// FileStore 授权存储：内存索引 + 单个 JSON 文件（每次变更经临时文件 + rename 整体重写）。path 为空时仅内存。
+// 授权数量通常很小（每次审批最多一条），整体重写足够。
+type FileStore struct {
+	path   string
+	mu     sync.RWMutex
+	grants map[string]*Grant
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore 打开 path 下的授权文件（不存在则新建）；path 为空时仅内存。
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, grants: make(map[string]*Grant)}
+	if path == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	var list []*Grant
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range list {
+		s.grants[g.ID] = g
+	}
+	return s, nil
+}
+
+func (s *FileStore) Create(ctx context.Context, g *Grant) (*Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g.CHEQID != "" {
+		for _, x := range s.grants {
+			if x.CHEQID == g.CHEQID {
+				c := *x
+				return &c, nil
+			}
+		}
+	}
+	c := *g
+	s.grants[g.ID] = &c
+	if err := s.saveLocked(); err != nil {
+		delete(s.grants, g.ID)
+		return nil, err
+	}
+	return g, nil
+}
+
+func (s *FileStore) Match(ctx context.Context, subject, action, resource, sessionID string, now time.Time) (*Grant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var best *Grant
+	for _, g := range s.grants {
+		if !g.Active(now) || g.Subject != subject || g.Action != action || g.Resource != resource {
+			continue
+		}
+		if g.Scope == ScopeSession && (sessionID == "" || g.SessionID != sessionID) {
+			continue
+		}
+		if best == nil || g.ExpiresAt.After(best.ExpiresAt) {
+			best = g
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	c := *best
+	return &c, nil
+}
+
+func (s *FileStore) Revoke(ctx context.Context, id, by string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.grants[id]
+	if g == nil {
+		return ErrNotFound
+	}
+	if !g.RevokedAt.IsZero() {
+		return nil
+	}
+	g.RevokedAt = time.Now()
+	g.RevokedBy = by
+	return s.saveLocked()
+}
+
+func (s *FileStore) RevokeSession(ctx context.Context, sessionID, by string) int {
+	if sessionID == "" {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, g := range s.grants {
+		if g.Scope == ScopeSession && g.SessionID == sessionID && g.Active(now) {
+			g.RevokedAt = now
+			g.RevokedBy = by
+			n++
+		}
+	}
+	if n > 0 {
+		_ = s.saveLocked()
+	}
+	return n
+}
+
+func (s *FileStore) List(ctx context.Context, activeOnly bool) ([]*Grant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	out := make([]*Grant, 0, len(s.grants))
+	for _, g := range s.grants {
+		if activeOnly && !g.Active(now) {
+			continue
+		}
+		c := *g
+		out = append(out, &c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// saveLocked 清理早已失效的授权并整体写盘。调用方须持有写锁。
+func (s *FileStore) saveLocked() error {
+	cutoff := time.Now().Add(-pruneAfter)
+	list := make([]*Grant, 0, len(s.grants))
+	for id, g := range s.grants {
+		end := g.ExpiresAt
+		if !g.RevokedAt.IsZero() && g.RevokedAt.Before(end) {
+			end = g.RevokedAt
+		}
+		if end.Before(cutoff) {
+			delete(s.grants, id)
+			continue
+		}
+		list = append(list, g)
+	}
+	if s.path == "" {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,46 @@
+// Package hotcache 为 SandboxProfile 中的 Hot Cache 本地放行规则；3af-exec 本地放行与服务端补录复核使用同一匹配逻辑。
+package hotcache
+
+// Action 一条本地快速放行规则。
+type Action struct {
+	Executable    string   `json:"executable,omitempty"`
+	ArgvAllowlist []string `json:"argv_allowlist,omitempty"`
+	RunAsSudo     bool     `json:"run_as_sudo,omitempty"`
+}
+
+// Allows 返回 argv 是否命中 actions：argv[0] 与 executable 精确相等（sudo 命令去掉 sudo 后比较，且仅匹配 run_as_sudo 条目），
+// argv_allowlist 非空时其余参数须逐个在列表内。
+func Allows(actions []Action, argv []string) bool {
+	sudo := len(argv) > 0 && argv[0] == "sudo"
+	if sudo {
+		argv = argv[1:]
+	}
+	if len(argv) == 0 {
+		return false
+	}
+	for _, a := range actions {
+		if a.RunAsSudo != sudo || a.Executable != argv[0] {
+			continue
+		}
+		if allArgsAllowed(a.ArgvAllowlist, argv[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func allArgsAllowed(allowlist, args []string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	allowed := make(map[string]bool, len(allowlist))
+	for _, a := range allowlist {
+		allowed[a] = true
+	}
+	for _, a := range args {
+		if !allowed[a] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package hotcache
+
+import "testing"
+
+func TestAllows(t *testing.T) {
+	actions := []Action{
+		{Executable: "ls"},
+		{Executable: "git", ArgvAllowlist: []string{"status", "log"}},
+		{Executable: "systemctl", ArgvAllowlist: []string{"restart", "nginx"}, RunAsSudo: true},
+	}
+	cases := []struct {
+		argv []string
+		want bool
+	}{
+		{[]string{"ls", "-la", "/tmp"}, true},
+		{[]string{"git", "status"}, true},
+		{[]string{"git", "push"}, false},
+		{[]string{"sudo", "ls"}, false},
+		{[]string{"systemctl", "restart", "nginx"}, false},
+		{[]string{"sudo", "systemctl", "restart", "nginx"}, true},
+		{[]string{"sudo"}, false},
+		{nil, false},
+		{[]string{"/bin/ls"}, false},
+	}
+	for _, c := range cases {
+		if got := Allows(actions, c.argv); got != c.want {
+			t.Errorf("Allows(%q) = %v, want %v", c.argv, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package httpsig
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"diting/internal/jwtauth"
+	"diting/pkg/chain"
+)
+
+// DIDGetter 按 DID 读取链上 DID 文档；chain.Ledger 与 chain.Backend 均满足。
+type DIDGetter interface {
+	GetDID(ctx context.Context, did string) (*chain.DIDDocument, error)
+}
+
+// DIDResolver 以链上 DID 文档的公钥作为验签公钥：keyid 即 DID，文档须为 active。
+type DIDResolver struct {
+	ledger DIDGetter
+}
+
+// NewDIDResolver 创建基于 ledger 的 DID 公钥解析器。
+func NewDIDResolver(ledger DIDGetter) *DIDResolver {
+	return &DIDResolver{ledger: ledger}
+}
+
+// ResolveKey 实现 KeyResolver。DID 不存在返回包装 ErrInvalid 的错误；已吊销或未激活返回包装 ErrRevoked 的错误。
+func (d *DIDResolver) ResolveKey(ctx context.Context, did string) (crypto.PublicKey, error) {
+	doc, err := d.ledger.GetDID(ctx, did)
+	if errors.Is(err, chain.ErrNotFound) || (err == nil && doc == nil) {
+		return nil, fmt.Errorf("%w: unknown did %q", ErrInvalid, did)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("httpsig: resolve did: %w", err)
+	}
+	if doc.Status != chain.DIDStatusActive {
+		return nil, fmt.Errorf("%w: %s is %s", ErrRevoked, did, doc.Status)
+	}
+	pub, err := ParsePublicKey(doc.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: did %q: %v", ErrInvalid, did, err)
+	}
+	return pub, nil
+}
+
+// ParsePublicKey 解析 DID 文档中的公钥材料：PEM（PKIX "PUBLIC KEY"）或 JWK（JSON）。
+func ParsePublicKey(material string) (crypto.PublicKey, error) {
+	material = strings.TrimSpace(material)
+	if strings.HasPrefix(material, "{") {
+		return jwtauth.ParseJWK([]byte(material))
+	}
+	block, _ := pem.Decode([]byte(material))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("public key must be PEM (PUBLIC KEY) or JWK")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
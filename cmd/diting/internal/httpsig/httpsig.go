@@ -0,0 +1,432 @@
+// Package httpsig 提供 L0 的 HTTP 消息签名校验（RFC 9421 风格）：Agent 以 DID 文档中的公钥对请求签名，
+// Signature-Input 的 keyid 为 DID；校验覆盖的组件、created/expires 时间窗与一次性 nonce（防重放），
+// 请求带 body 时须覆盖 content-digest（RFC 9530）并与 body 一致。支持 ed25519、ecdsa-p256-sha256、
+// rsa-pss-sha512、rsa-v1_5-sha256。
+package httpsig
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalid 表示签名格式错误、覆盖组件不足或验签失败。
+	ErrInvalid = errors.New("httpsig: invalid signature")
+	// ErrExpired 表示签名超出 created/expires 时间窗。
+	ErrExpired = errors.New("httpsig: signature expired")
+	// ErrReplay 表示 nonce 已使用过或不是服务端签发的有效 challenge。
+	ErrReplay = errors.New("httpsig: nonce replayed or unknown")
+	// ErrRevoked 表示签名 DID 已吊销或不处于 active 状态。
+	ErrRevoked = errors.New("httpsig: did not active")
+)
+
+const (
+	// defaultMaxAge created 距今的默认最长时间。
+	defaultMaxAge = 5 * time.Minute
+	// clockSkew 允许 created 超前服务端时钟的偏差。
+	clockSkew = 30 * time.Second
+	// defaultMaxBodyBytes 校验 content-digest 时读取 body 的默认上限。
+	defaultMaxBodyBytes = 10 << 20
+)
+
+// defaultComponents 签名至少须覆盖的组件。
+var defaultComponents = []string{"@method", "@target-uri"}
+
+// KeyResolver 按 keyid 解析验签公钥。
+type KeyResolver interface {
+	// ResolveKey 返回 keyid 的公钥；keyid 不可用于签名（如 DID 已吊销）时返回包装 ErrRevoked 的错误。
+	ResolveKey(ctx context.Context, keyID string) (crypto.PublicKey, error)
+}
+
+// Options 校验参数。
+type Options struct {
+	// MaxAge 签名 created 距今的最长时间，同时为 nonce 的保留时长；0 表示默认 5 分钟。
+	MaxAge time.Duration
+	// RequiredComponents 签名须覆盖的组件；空表示 @method、@target-uri。请求带 body 时另须覆盖 content-digest。
+	RequiredComponents []string
+	// RequireChallenge 为 true 时 nonce 须为 Challenge 签发且未使用；否则任意未使用过的 nonce 均可。
+	RequireChallenge bool
+	// ChallengeTTL challenge nonce 的有效期；0 表示默认 1 分钟。
+	ChallengeTTL time.Duration
+	// MaxBodyBytes 读取 body 计算摘要的上限；0 表示默认 10 MiB。
+	MaxBodyBytes int64
+}
+
+// Result 为校验通过的签名信息。
+type Result struct {
+	KeyID   string // 签名的 DID
+	Label   string
+	Created time.Time
+	Nonce   string
+}
+
+// Verifier 消息签名校验器。
+type Verifier struct {
+	keys   KeyResolver
+	opts   Options
+	nonces *nonceCache
+}
+
+// NewVerifier 创建校验器。
+func NewVerifier(keys KeyResolver, opts Options) *Verifier {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultMaxAge
+	}
+	if len(opts.RequiredComponents) == 0 {
+		opts.RequiredComponents = defaultComponents
+	}
+	if opts.ChallengeTTL <= 0 {
+		opts.ChallengeTTL = time.Minute
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	return &Verifier{keys: keys, opts: opts, nonces: newNonceCache()}
+}
+
+// Signed 返回请求是否带消息签名。
+func Signed(r *http.Request) bool {
+	return r.Header.Get("Signature-Input") != ""
+}
+
+// Challenge 为 keyID 签发一次性 nonce，客户端以其作为签名参数 nonce；keyID 须可解析出公钥。
+func (v *Verifier) Challenge(ctx context.Context, keyID string, now time.Time) (string, time.Time, error) {
+	if _, err := v.keys.ResolveKey(ctx, keyID); err != nil {
+		return "", time.Time{}, err
+	}
+	return v.nonces.issue(keyID, now, now.Add(v.opts.ChallengeTTL))
+}
+
+// Verify 校验 r 的消息签名。请求带 body 时读取并还原 r.Body。
+func (v *Verifier) Verify(ctx context.Context, r *http.Request, now time.Time) (*Result, error) {
+	label, params, rawParams, err := parseSignatureInput(r.Header.Get("Signature-Input"))
+	if err != nil {
+		return nil, err
+	}
+	sig, err := parseSignature(r.Header.Get("Signature"), label)
+	if err != nil {
+		return nil, err
+	}
+	keyID, nonce := params.str("keyid"), params.str("nonce")
+	if keyID == "" || nonce == "" {
+		return nil, fmt.Errorf("%w: keyid and nonce required", ErrInvalid)
+	}
+	createdUnix, ok := params.int("created")
+	if !ok {
+		return nil, fmt.Errorf("%w: created required", ErrInvalid)
+	}
+	created := time.Unix(createdUnix, 0)
+	if now.Sub(created) > v.opts.MaxAge || created.Sub(now) > clockSkew {
+		return nil, ErrExpired
+	}
+	if exp, ok := params.int("expires"); ok && !now.Before(time.Unix(exp, 0)) {
+		return nil, ErrExpired
+	}
+
+	covered := make(map[string]bool, len(params.components))
+	for _, c := range params.components {
+		covered[c] = true
+	}
+	for _, c := range v.opts.RequiredComponents {
+		if !covered[c] {
+			return nil, fmt.Errorf("%w: %s not covered", ErrInvalid, c)
+		}
+	}
+	body, err := v.readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0 && !covered["content-digest"] {
+		return nil, fmt.Errorf("%w: content-digest must be covered for requests with a body", ErrInvalid)
+	}
+	if covered["content-digest"] {
+		if err := checkDigest(r.Header.Get("Content-Digest"), body); err != nil {
+			return nil, err
+		}
+	}
+
+	base, err := signatureBase(r, params.components, rawParams)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := v.keys.ResolveKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(params.str("alg"), pub, []byte(base), sig); err != nil {
+		return nil, err
+	}
+	// 验签通过后再消耗 nonce，伪造签名无法占用他人的 nonce
+	if err := v.nonces.use(keyID, nonce, now, now.Add(v.opts.MaxAge+clockSkew), v.opts.RequireChallenge); err != nil {
+		return nil, err
+	}
+	return &Result{KeyID: keyID, Label: label, Created: created, Nonce: nonce}, nil
+}
+
+func (v *Verifier) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, v.opts.MaxBodyBytes+1))
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: read body: %v", ErrInvalid, err)
+	}
+	if int64(len(body)) > v.opts.MaxBodyBytes {
+		return nil, fmt.Errorf("%w: body too large to verify", ErrInvalid)
+	}
+	return body, nil
+}
+
+// sigParams 为 Signature-Input 中一个签名的覆盖组件与参数。
+type sigParams struct {
+	components []string
+	values     map[string]string
+}
+
+func (p *sigParams) str(k string) string { return p.values[k] }
+
+func (p *sigParams) int(k string) (int64, bool) {
+	v, ok := p.values[k]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	return n, err == nil
+}
+
+// parseSignatureInput 解析 Signature-Input 的第一个签名：label=("c1" "c2");created=1;keyid="did:..."。
+// 返回 label、参数与 label 之后的原文（即 @signature-params 的值）。
+func parseSignatureInput(h string) (string, *sigParams, string, error) {
+	bad := func(why string) (string, *sigParams, string, error) {
+		return "", nil, "", fmt.Errorf("%w: Signature-Input %s", ErrInvalid, why)
+	}
+	label, rest, ok := strings.Cut(strings.TrimSpace(h), "=")
+	if !ok || label == "" || !strings.HasPrefix(rest, "(") {
+		return bad("malformed")
+	}
+	end := strings.IndexByte(rest, ')')
+	if end < 0 {
+		return bad("malformed component list")
+	}
+	raw := rest
+	// 只取第一个签名：参数部分遇到引号外的逗号即结束
+	inQuote := false
+	for i := end; i < len(rest); i++ {
+		switch rest[i] {
+		case '"':
+			inQuote = !inQuote
+		case ',':
+			if !inQuote {
+				raw = rest[:i]
+				i = len(rest)
+			}
+		}
+	}
+	p := &sigParams{values: make(map[string]string)}
+	for _, c := range strings.Fields(rest[1:end]) {
+		if len(c) < 2 || c[0] != '"' || c[len(c)-1] != '"' {
+			return bad("component must be a quoted string")
+		}
+		p.components = append(p.components, strings.ToLower(c[1:len(c)-1]))
+	}
+	for _, kv := range strings.Split(raw[end+1:], ";") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(kv, "=")
+		p.values[k] = strings.Trim(v, `"`)
+	}
+	return label, p, raw, nil
+}
+
+// parseSignature 从 Signature 头取出 label 对应的签名：label=:base64:。
+func parseSignature(h, label string) ([]byte, error) {
+	for _, member := range strings.Split(h, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || k != label {
+			continue
+		}
+		if len(v) < 2 || v[0] != ':' || v[len(v)-1] != ':' {
+			break
+		}
+		sig, err := base64.StdEncoding.DecodeString(v[1 : len(v)-1])
+		if err != nil {
+			break
+		}
+		return sig, nil
+	}
+	return nil, fmt.Errorf("%w: Signature for %q missing or malformed", ErrInvalid, label)
+}
+
+// signatureBase 按 RFC 9421 §2.5 构造签名基：每个覆盖组件一行 "name": value，最后一行为 "@signature-params"。
+func signatureBase(r *http.Request, components []string, rawParams string) (string, error) {
+	var b strings.Builder
+	for _, c := range components {
+		v, err := componentValue(r, c)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%q: %s\n", c, v)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", rawParams)
+	return b.String(), nil
+}
+
+// TargetURI 返回服务端视角的 @target-uri：代理形式（绝对 URI）的请求取原 URI，否则为 scheme://host + 请求路径与查询。
+func TargetURI(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return r.URL.String()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func componentValue(r *http.Request, c string) (string, error) {
+	switch c {
+	case "@method":
+		return strings.ToUpper(r.Method), nil
+	case "@target-uri":
+		return TargetURI(r), nil
+	case "@authority":
+		host := r.Host
+		if r.URL.IsAbs() {
+			host = r.URL.Host
+		}
+		return strings.ToLower(host), nil
+	case "@scheme":
+		if r.URL.IsAbs() {
+			return r.URL.Scheme, nil
+		}
+		if r.TLS != nil {
+			return "https", nil
+		}
+		return "http", nil
+	case "@path":
+		if p := r.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	}
+	if strings.HasPrefix(c, "@") {
+		return "", fmt.Errorf("%w: unsupported component %s", ErrInvalid, c)
+	}
+	vals := r.Header.Values(c)
+	if len(vals) == 0 {
+		return "", fmt.Errorf("%w: covered header %s missing", ErrInvalid, c)
+	}
+	for i := range vals {
+		vals[i] = strings.TrimSpace(vals[i])
+	}
+	return strings.Join(vals, ", "), nil
+}
+
+// ContentDigest 返回 body 的 Content-Digest 头值（sha-256）。
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// checkDigest 校验 Content-Digest（sha-256 或 sha-512）与 body 一致。
+func checkDigest(h string, body []byte) error {
+	for _, member := range strings.Split(h, ",") {
+		alg, v, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || len(v) < 2 || v[0] != ':' || v[len(v)-1] != ':' {
+			continue
+		}
+		want, err := base64.StdEncoding.DecodeString(v[1 : len(v)-1])
+		if err != nil {
+			continue
+		}
+		var got []byte
+		switch alg {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			got = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			got = s[:]
+		default:
+			continue
+		}
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			return fmt.Errorf("%w: content-digest does not match body", ErrInvalid)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: content-digest missing or unsupported", ErrInvalid)
+}
+
+// verify 按 alg 验签；alg 为空时按公钥类型推断（Ed25519 → ed25519，P-256 → ecdsa-p256-sha256，RSA → rsa-pss-sha512）。
+func verify(alg string, pub crypto.PublicKey, base, sig []byte) error {
+	bad := fmt.Errorf("%w: signature verification failed", ErrInvalid)
+	if alg == "" {
+		switch k := pub.(type) {
+		case ed25519.PublicKey:
+			alg = "ed25519"
+		case *ecdsa.PublicKey:
+			if k.Curve == elliptic.P256() {
+				alg = "ecdsa-p256-sha256"
+			}
+		case *rsa.PublicKey:
+			alg = "rsa-pss-sha512"
+		}
+	}
+	switch alg {
+	case "ed25519":
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, base, sig) {
+			return bad
+		}
+	case "ecdsa-p256-sha256":
+		k, ok := pub.(*ecdsa.PublicKey)
+		if !ok || k.Curve != elliptic.P256() || len(sig) != 64 {
+			return bad
+		}
+		digest := sha256.Sum256(base)
+		if !ecdsa.Verify(k, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return bad
+		}
+	case "rsa-pss-sha512":
+		k, ok := pub.(*rsa.PublicKey)
+		digest := sha512.Sum512(base)
+		if !ok || rsa.VerifyPSS(k, crypto.SHA512, digest[:], sig, nil) != nil {
+			return bad
+		}
+	case "rsa-v1_5-sha256":
+		k, ok := pub.(*rsa.PublicKey)
+		digest := sha256.Sum256(base)
+		if !ok || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return bad
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalid, alg)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package httpsig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diting/pkg/chain"
+)
+
+// signRequest 以 sign 对 r 签名：覆盖 components，参数 created/nonce/keyid。
+func signRequest(t *testing.T, r *http.Request, components []string, keyID, nonce string, created time.Time, sign func([]byte) []byte) {
+	t.Helper()
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		quoted[i] = `"` + c + `"`
+	}
+	params := fmt.Sprintf(`(%s);created=%d;keyid="%s";nonce="%s"`, strings.Join(quoted, " "), created.Unix(), keyID, nonce)
+	base, err := signatureBase(r, components, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Signature-Input", "sig1="+params)
+	r.Header.Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(sign([]byte(base)))+":")
+}
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	ledger := chain.NewLocalStore()
+	_ = ledger.PutDID(ctx, &chain.DIDDocument{ID: "did:ziwei:local:a1", PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), Status: chain.DIDStatusActive})
+	_ = ledger.PutDID(ctx, &chain.DIDDocument{ID: "did:ziwei:local:gone", PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), Status: chain.DIDStatusRevoked})
+	v := NewVerifier(NewDIDResolver(ledger), Options{})
+	edSign := func(b []byte) []byte { return ed25519.Sign(priv, b) }
+
+	newReq := func(body string) *http.Request {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest("GET", "http://diting.local/data?x=1", nil)
+		} else {
+			r = httptest.NewRequest("POST", "http://diting.local/data", strings.NewReader(body))
+			r.Header.Set("Content-Digest", ContentDigest([]byte(body)))
+		}
+		return r
+	}
+
+	r := newReq("")
+	signRequest(t, r, []string{"@method", "@target-uri"}, "did:ziwei:local:a1", "n1", now, edSign)
+	res, err := v.Verify(ctx, r, now)
+	if err != nil || res.KeyID != "did:ziwei:local:a1" {
+		t.Fatalf("valid signature: %v %+v", err, res)
+	}
+	// 同一 nonce 再次使用
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrReplay) {
+		t.Errorf("replay: %v", err)
+	}
+
+	// 篡改覆盖的组件
+	r = newReq("")
+	signRequest(t, r, []string{"@method", "@target-uri"}, "did:ziwei:local:a1", "n2", now, edSign)
+	r.URL.RawQuery = "x=2"
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("tampered: %v", err)
+	}
+
+	// created 超出时间窗
+	r = newReq("")
+	signRequest(t, r, []string{"@method", "@target-uri"}, "did:ziwei:local:a1", "n3", now.Add(-10*time.Minute), edSign)
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrExpired) {
+		t.Errorf("stale created: %v", err)
+	}
+
+	// 未覆盖必需组件
+	r = newReq("")
+	signRequest(t, r, []string{"@method"}, "did:ziwei:local:a1", "n4", now, edSign)
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("missing component: %v", err)
+	}
+
+	// 带 body：须覆盖 content-digest，且摘要与 body 一致；校验后 body 可再读
+	r = newReq(`{"a":1}`)
+	signRequest(t, r, []string{"@method", "@target-uri"}, "did:ziwei:local:a1", "n5", now, edSign)
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("body without content-digest: %v", err)
+	}
+	r = newReq(`{"a":1}`)
+	signRequest(t, r, []string{"@method", "@target-uri", "content-digest"}, "did:ziwei:local:a1", "n6", now, edSign)
+	if _, err := v.Verify(ctx, r, now); err != nil {
+		t.Errorf("signed body: %v", err)
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != `{"a":1}` {
+		t.Errorf("body not restored: %q", b)
+	}
+	r = newReq(`{"a":1}`)
+	signRequest(t, r, []string{"@method", "@target-uri", "content-digest"}, "did:ziwei:local:a1", "n7", now, edSign)
+	r.Body = io.NopCloser(bytes.NewReader([]byte(`{"a":2}`)))
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("body mismatch: %v", err)
+	}
+
+	// 吊销的 DID
+	r = newReq("")
+	signRequest(t, r, []string{"@method", "@target-uri"}, "did:ziwei:local:gone", "n8", now, edSign)
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrRevoked) {
+		t.Errorf("revoked did: %v", err)
+	}
+
+	// ECDSA P-256，公钥为 JWK
+	ek, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwk := fmt.Sprintf(`{"kty":"EC","crv":"P-256","x":"%s","y":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(ek.X.FillBytes(make([]byte, 32))), base64.RawURLEncoding.EncodeToString(ek.Y.FillBytes(make([]byte, 32))))
+	_ = ledger.PutDID(ctx, &chain.DIDDocument{ID: "did:ziwei:local:ec", PublicKey: jwk, Status: chain.DIDStatusActive})
+	r = newReq("")
+	signRequest(t, r, []string{"@method", "@target-uri", "@authority"}, "did:ziwei:local:ec", "n9", now, func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		er, es, _ := ecdsa.Sign(rand.Reader, ek, sum[:])
+		return append(er.FillBytes(make([]byte, 32)), es.FillBytes(make([]byte, 32))...)
+	})
+	if _, err := v.Verify(ctx, r, now); err != nil {
+		t.Errorf("ecdsa jwk: %v", err)
+	}
+}
+
+func TestVerifier_Challenge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	jwk := `{"kty":"OKP","crv":"Ed25519","x":"` + base64.RawURLEncoding.EncodeToString(pub) + `"}`
+	ledger := chain.NewLocalStore()
+	_ = ledger.PutDID(ctx, &chain.DIDDocument{ID: "did:ziwei:local:a1", PublicKey: jwk, Status: chain.DIDStatusActive})
+	_ = ledger.PutDID(ctx, &chain.DIDDocument{ID: "did:ziwei:local:a2", PublicKey: jwk, Status: chain.DIDStatusActive})
+	v := NewVerifier(NewDIDResolver(ledger), Options{RequireChallenge: true})
+	edSign := func(b []byte) []byte { return ed25519.Sign(priv, b) }
+	components := []string{"@method", "@target-uri"}
+
+	r := httptest.NewRequest("GET", "http://diting.local/x", nil)
+	signRequest(t, r, components, "did:ziwei:local:a1", "self-chosen", now, edSign)
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrReplay) {
+		t.Errorf("nonce not issued: %v", err)
+	}
+	nonce, _, err := v.Challenge(ctx, "did:ziwei:local:a1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 签发给其他 DID 的 challenge 不可用
+	r = httptest.NewRequest("GET", "http://diting.local/x", nil)
+	signRequest(t, r, components, "did:ziwei:local:a2", nonce, now, edSign)
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrReplay) {
+		t.Errorf("challenge bound to other did: %v", err)
+	}
+	r = httptest.NewRequest("GET", "http://diting.local/x", nil)
+	signRequest(t, r, components, "did:ziwei:local:a1", nonce, now, edSign)
+	if _, err := v.Verify(ctx, r, now); err != nil {
+		t.Errorf("issued challenge: %v", err)
+	}
+	if _, err := v.Verify(ctx, r, now); !errors.Is(err, ErrReplay) {
+		t.Errorf("challenge reused: %v", err)
+	}
+	if _, _, err := v.Challenge(ctx, "did:ziwei:local:unknown", now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("challenge for unknown did: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package httpsig
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// nonceCache 记录已使用的 nonce（直至签名时间窗结束）与服务端签发且未使用的 challenge。
+type nonceCache struct {
+	mu         sync.Mutex
+	used       map[string]time.Time // keyid + nonce -> 过期时间
+	challenges map[string]challenge // nonce -> 签发信息
+	sweptAt    time.Time
+}
+
+type challenge struct {
+	keyID     string
+	expiresAt time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{used: make(map[string]time.Time), challenges: make(map[string]challenge)}
+}
+
+// issue 为 keyID 签发随机 challenge nonce。
+func (c *nonceCache) issue(keyID string, now, expiresAt time.Time) (string, time.Time, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("httpsig: generate nonce: %w", err)
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweepLocked(now)
+	c.challenges[nonce] = challenge{keyID: keyID, expiresAt: expiresAt}
+	return nonce, expiresAt, nil
+}
+
+// use 消耗 nonce：已用过返回 ErrReplay；requireChallenge 时 nonce 还须是签发给 keyID 且未过期的 challenge。
+// retain 为记录已用 nonce 的截止时间，应覆盖签名可被接受的整个时间窗。
+func (c *nonceCache) use(keyID, nonce string, now, retain time.Time, requireChallenge bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweepLocked(now)
+	k := keyID + "\x00" + nonce
+	if _, ok := c.used[k]; ok {
+		return ErrReplay
+	}
+	if ch, ok := c.challenges[nonce]; ok {
+		if ch.keyID != keyID || !now.Before(ch.expiresAt) {
+			return ErrReplay
+		}
+		delete(c.challenges, nonce)
+	} else if requireChallenge {
+		return ErrReplay
+	}
+	c.used[k] = retain
+	return nil
+}
+
+// sweepLocked 每分钟至多一次清理过期条目。调用方须持有锁。
+func (c *nonceCache) sweepLocked(now time.Time) {
+	if now.Sub(c.sweptAt) < time.Minute {
+		return
+	}
+	c.sweptAt = now
+	for k, exp := range c.used {
+		if !now.Before(exp) {
+			delete(c.used, k)
+		}
+	}
+	for k, ch := range c.challenges {
+		if !now.Before(ch.expiresAt) {
+			delete(c.challenges, k)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRefreshInterval JWKS 缓存的默认刷新间隔。
+	defaultRefreshInterval = 5 * time.Minute
+	// minRefetchInterval 遇到未知 kid 时重新拉取的最小间隔，防止伪造 kid 的请求反复触发拉取。
+	minRefetchInterval = 10 * time.Second
+	// maxJWKSBytes JWKS 文档大小上限。
+	maxJWKSBytes = 1 << 20
+)
+
+// jwk 为 JWKS 中的一把公钥（RFC 7517），仅解析验签所需字段。
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet JWKS 公钥集：从本地文件或 HTTP(S) URL 加载并缓存，超过刷新间隔或遇到未知 kid 时重新加载；
+// 重新加载失败时沿用上次成功加载的公钥。
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // kid -> 公钥
+	algs      map[string]string           // kid -> JWK 声明的 alg（可空）
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+// NewKeySet 创建公钥集；source 为文件路径或 http(s):// URL，refresh 为 0 表示默认 5 分钟。首次使用时加载，
+// 启动时可调用 Refresh 提前加载并检查配置。
+func NewKeySet(source string, refresh time.Duration) *KeySet {
+	if refresh <= 0 {
+		refresh = defaultRefreshInterval
+	}
+	return &KeySet{source: source, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Refresh 立即重新加载公钥集。
+func (s *KeySet) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(ctx, time.Now())
+}
+
+// key 返回 kid 对应的公钥；kid 为空且公钥集只有一把公钥时返回该公钥。
+func (s *KeySet) key(ctx context.Context, kid string, now time.Time) (crypto.PublicKey, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 加载失败后至少间隔 minRefetchInterval 再试，避免每个请求都拉取
+	canLoad := now.Sub(s.triedAt) >= minRefetchInterval
+	if canLoad && (s.keys == nil || now.Sub(s.fetchedAt) >= s.refresh) {
+		_ = s.loadLocked(ctx, now)
+		canLoad = false
+	}
+	k, alg := s.lookupLocked(kid)
+	if k == nil && canLoad {
+		// 密钥轮换：未知 kid 时立即重新拉取一次
+		_ = s.loadLocked(ctx, now)
+		k, alg = s.lookupLocked(kid)
+	}
+	if k == nil {
+		return nil, "", fmt.Errorf("%w: unknown key id %q", ErrInvalid, kid)
+	}
+	return k, alg, nil
+}
+
+// lookupLocked 调用方须持有锁。
+func (s *KeySet) lookupLocked(kid string) (crypto.PublicKey, string) {
+	if kid == "" && len(s.keys) == 1 {
+		for id, k := range s.keys {
+			return k, s.algs[id]
+		}
+	}
+	return s.keys[kid], s.algs[kid]
+}
+
+// loadLocked 加载并解析 JWKS；成功时整体替换缓存。调用方须持有锁。
+func (s *KeySet) loadLocked(ctx context.Context, now time.Time) error {
+	s.triedAt = now
+	data, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("jwtauth: parse jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	algs := make(map[string]string, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("jwtauth: jwks key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = pub
+		algs[k.Kid] = k.Alg
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("jwtauth: jwks %s has no signing keys", s.source)
+	}
+	s.keys, s.algs, s.fetchedAt = keys, algs, now
+	return nil
+}
+
+func (s *KeySet) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(s.source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwtauth: fetch jwks: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+}
+
+// ParseJWK 解析单个 JWK（JSON）为公钥，供 DID 文档等其他以 JWK 表示公钥的场景复用。
+func ParseJWK(data []byte) (crypto.PublicKey, error) {
+	var k jwk
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("jwtauth: parse jwk: %w", err)
+	}
+	return k.publicKey()
+}
+
+// publicKey 将 JWK 转为公钥；支持 RSA、EC（P-256/P-384/P-521）与 OKP（Ed25519）。
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || n.BitLen() < 2048 {
+			return nil, fmt.Errorf("unsupported rsa key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("ec point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
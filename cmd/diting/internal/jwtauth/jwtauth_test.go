@@ -0,0 +1,176 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+func rsaJWK(kid string, k *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "alg": "RS256", "n": b64.EncodeToString(k.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(k.E)).Bytes())}
+}
+
+func ecJWK(kid string, k *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64.EncodeToString(k.X.FillBytes(make([]byte, 32))), "y": b64.EncodeToString(k.Y.FillBytes(make([]byte, 32)))}
+}
+
+func jwks(keys ...map[string]string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	return b
+}
+
+// sign 以 alg 签发 token；key 为 nil 时签名为空（alg=none 等）。
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	input := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	}
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	_ = os.WriteFile(path, jwks(rsaJWK("rsa1", rsaKey), ecJWK("ec1", ecKey), map[string]string{"kty": "OKP", "kid": "ed1", "crv": "Ed25519", "x": b64.EncodeToString(edPub)}), 0644)
+	v := NewVerifier(NewKeySet(path, 0), Options{
+		Issuer:      "https://idp.example.com",
+		Audience:    []string{"diting"},
+		TeamClaim:   "team",
+		LabelClaims: map[string]string{"env": "env", "ns": "kubernetes.io.namespace"},
+	})
+	ctx := context.Background()
+	now := time.Now()
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": "https://idp.example.com", "aud": []string{"other", "diting"}, "sub": "agent-1",
+			"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(-time.Minute).Unix(),
+			"team": "infra", "env": "prod", "kubernetes.io": map[string]string{"namespace": "ci"},
+		}
+		for k, val := range extra {
+			c[k] = val
+		}
+		return c
+	}
+
+	id, err := v.Verify(ctx, sign(t, "RS256", "rsa1", rsaKey, claims(nil)), now)
+	if err != nil || id.Subject != "agent-1" || id.Team != "infra" || id.Labels["env"] != "prod" || id.Labels["ns"] != "ci" {
+		t.Fatalf("RS256: %+v %v", id, err)
+	}
+	if _, err := v.Verify(ctx, sign(t, "ES256", "ec1", ecKey, claims(nil)), now); err != nil {
+		t.Errorf("ES256: %v", err)
+	}
+	if _, err := v.Verify(ctx, sign(t, "EdDSA", "ed1", edKey, claims(nil)), now); err != nil {
+		t.Errorf("EdDSA: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"not a jwt", "dk_ci_secret", ErrMalformed},
+		{"expired", sign(t, "RS256", "rsa1", rsaKey, claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), ErrExpired},
+		{"not yet valid", sign(t, "RS256", "rsa1", rsaKey, claims(map[string]interface{}{"nbf": now.Add(5 * time.Minute).Unix()})), ErrExpired},
+		{"wrong issuer", sign(t, "RS256", "rsa1", rsaKey, claims(map[string]interface{}{"iss": "https://evil"})), ErrInvalid},
+		{"wrong audience", sign(t, "RS256", "rsa1", rsaKey, claims(map[string]interface{}{"aud": "other"})), ErrInvalid},
+		{"missing exp", sign(t, "RS256", "rsa1", rsaKey, claims(map[string]interface{}{"exp": nil})), ErrInvalid},
+		{"alg mismatch with key", sign(t, "ES256", "rsa1", ecKey, claims(nil)), ErrInvalid},
+		{"signed by other key", sign(t, "ES256", "ec1", mustEC(), claims(nil)), ErrInvalid},
+		{"alg none", sign(t, "none", "", nil, claims(nil)), ErrInvalid},
+		{"unknown kid", sign(t, "RS256", "rsa2", rsaKey, claims(nil)), ErrInvalid},
+	}
+	for _, c := range cases {
+		if _, err := v.Verify(ctx, c.token, now); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+	good := sign(t, "RS256", "rsa1", rsaKey, claims(nil))
+	parts := strings.Split(good, ".")
+	forged := parts[0] + "." + b64.EncodeToString([]byte(`{"sub":"admin","exp":9999999999,"iss":"https://idp.example.com","aud":"diting"}`)) + "." + parts[2]
+	if _, err := v.Verify(ctx, forged, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("tampered payload: got %v", err)
+	}
+}
+
+func mustEC() *ecdsa.PrivateKey {
+	k, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return k
+}
+
+func TestKeySet_RotationFromURL(t *testing.T) {
+	k1, k2 := mustEC(), mustEC()
+	var mu sync.Mutex
+	doc, fetches := jwks(ecJWK("k1", k1)), 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		_, _ = w.Write(doc)
+	}))
+	defer srv.Close()
+	v := NewVerifier(NewKeySet(srv.URL, time.Hour), Options{})
+	ctx := context.Background()
+	now := time.Now()
+	claims := map[string]interface{}{"sub": "a", "exp": now.Add(time.Hour).Unix()}
+	if _, err := v.Verify(ctx, sign(t, "ES256", "k1", k1, claims), now); err != nil {
+		t.Fatalf("k1: %v", err)
+	}
+	mu.Lock()
+	doc = jwks(ecJWK("k1", k1), ecJWK("k2", k2))
+	mu.Unlock()
+	// 刚加载过：未知 kid 不立即重新拉取
+	if _, err := v.Verify(ctx, sign(t, "ES256", "k2", k2, claims), now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("k2 within refetch interval: %v", err)
+	}
+	later := now.Add(minRefetchInterval)
+	if _, err := v.Verify(ctx, sign(t, "ES256", "k2", k2, claims), later); err != nil {
+		t.Errorf("k2 after rotation: %v", err)
+	}
+	if _, err := v.Verify(ctx, sign(t, "ES256", "k1", k1, claims), later); err != nil {
+		t.Errorf("k1 cached: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches != 2 {
+		t.Errorf("fetches = %d, want 2", fetches)
+	}
+}
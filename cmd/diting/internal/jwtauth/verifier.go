@@ -0,0 +1,282 @@
+// Package jwtauth 提供 L0 的 JWT Bearer Token 校验（工作负载身份 / OIDC ID Token）：按 JWKS（本地文件或 URL，带缓存与轮换）
+// 验签，校验 iss、aud、exp、nbf，并按配置的 claim 映射出主体、团队与标签。仅接受非对称签名算法（RS*、PS*、ES*、EdDSA）。
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // 注册 SHA-256
+	_ "crypto/sha512" // 注册 SHA-384/512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMalformed 表示 token 不是 JWT（可交给后续认证器）。
+	ErrMalformed = errors.New("jwtauth: not a jwt")
+	// ErrInvalid 表示签名、签发方、受众或 claim 校验失败。
+	ErrInvalid = errors.New("jwtauth: invalid token")
+	// ErrExpired 表示 token 已过期或尚未生效。
+	ErrExpired = errors.New("jwtauth: token expired or not yet valid")
+)
+
+// defaultLeeway 校验 exp/nbf 时默认允许的时钟偏差。
+const defaultLeeway = 60 * time.Second
+
+// Options 校验参数。
+type Options struct {
+	// Issuer 期望的 iss；空表示不校验。
+	Issuer string
+	// Audience 可接受的 aud，token 的 aud 与其任一相同即通过；空表示不校验。
+	Audience []string
+	// Leeway 校验 exp/nbf 的时钟偏差；0 表示默认 60 秒。
+	Leeway time.Duration
+	// SubjectClaim 映射为主体的 claim；空表示 "sub"。支持以 "." 分隔的嵌套路径。
+	SubjectClaim string
+	// TeamClaim 映射为团队的 claim；空表示不映射。
+	TeamClaim string
+	// LabelClaims 标签名 -> claim 路径；claim 缺失的标签不设置。
+	LabelClaims map[string]string
+}
+
+// Identity 为校验通过的 token 所代表的身份。
+type Identity struct {
+	Subject   string
+	Team      string
+	Labels    map[string]string
+	Issuer    string
+	TokenID   string // jti，可空
+	ExpiresAt time.Time
+}
+
+// Verifier JWT 校验器。
+type Verifier struct {
+	keys *KeySet
+	opts Options
+}
+
+// NewVerifier 创建校验器。
+func NewVerifier(keys *KeySet, opts Options) *Verifier {
+	if opts.Leeway <= 0 {
+		opts.Leeway = defaultLeeway
+	}
+	if opts.SubjectClaim == "" {
+		opts.SubjectClaim = "sub"
+	}
+	return &Verifier{keys: keys, opts: opts}
+}
+
+// Verify 校验 token 并返回身份。token 不是 JWT 返回 ErrMalformed；过期或未生效返回 ErrExpired；其余失败返回包装 ErrInvalid 的错误。
+func (v *Verifier) Verify(ctx context.Context, token string, now time.Time) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Alg == "" {
+		return nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature encoding", ErrInvalid)
+	}
+	pub, keyAlg, err := v.keys.key(ctx, header.Kid, now)
+	if err != nil {
+		return nil, err
+	}
+	if keyAlg != "" && keyAlg != header.Alg {
+		return nil, fmt.Errorf("%w: alg %s does not match key", ErrInvalid, header.Alg)
+	}
+	if err := verifySignature(header.Alg, pub, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad payload encoding", ErrInvalid)
+	}
+	dec := json.NewDecoder(strings.NewReader(string(payload)))
+	dec.UseNumber()
+	var claims map[string]interface{}
+	if err := dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: bad claims", ErrInvalid)
+	}
+	return v.identity(claims, now)
+}
+
+// identity 校验标准 claim 并按映射构建身份。
+func (v *Verifier) identity(claims map[string]interface{}, now time.Time) (*Identity, error) {
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalid)
+	}
+	if !now.Before(exp.Add(v.opts.Leeway)) {
+		return nil, ErrExpired
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.opts.Leeway).Before(nbf) {
+		return nil, ErrExpired
+	}
+	iss, _ := claims["iss"].(string)
+	if v.opts.Issuer != "" && iss != v.opts.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalid, iss)
+	}
+	if len(v.opts.Audience) > 0 && !audienceMatches(claims["aud"], v.opts.Audience) {
+		return nil, fmt.Errorf("%w: audience not accepted", ErrInvalid)
+	}
+	id := &Identity{Subject: claimString(claims, v.opts.SubjectClaim), Issuer: iss, ExpiresAt: exp}
+	if id.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject claim %q", ErrInvalid, v.opts.SubjectClaim)
+	}
+	id.TokenID, _ = claims["jti"].(string)
+	if v.opts.TeamClaim != "" {
+		id.Team = claimString(claims, v.opts.TeamClaim)
+	}
+	for label, path := range v.opts.LabelClaims {
+		if val := claimString(claims, path); val != "" {
+			if id.Labels == nil {
+				id.Labels = make(map[string]string)
+			}
+			id.Labels[label] = val
+		}
+	}
+	return id, nil
+}
+
+// verifySignature 按 alg 验签；拒绝 none 与对称算法。
+func verifySignature(alg string, pub crypto.PublicKey, signed, sig []byte) error {
+	var h crypto.Hash
+	if len(alg) != 5 && alg != "EdDSA" {
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalid, alg)
+	}
+	switch alg[len(alg)-3:] {
+	case "256":
+		h = crypto.SHA256
+	case "384":
+		h = crypto.SHA384
+	case "512":
+		h = crypto.SHA512
+	}
+	bad := fmt.Errorf("%w: signature verification failed", ErrInvalid)
+	if alg == "EdDSA" {
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, signed, sig) {
+			return bad
+		}
+		return nil
+	}
+	if h == 0 {
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalid, alg)
+	}
+	hasher := h.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+	switch alg[:2] {
+	case "RS":
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(k, h, digest, sig) != nil {
+			return bad
+		}
+	case "PS":
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPSS(k, h, digest, sig, nil) != nil {
+			return bad
+		}
+	case "ES":
+		k, ok := pub.(*ecdsa.PublicKey)
+		if !ok || k.Curve.Params().BitSize != map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}[alg] {
+			return bad
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return bad
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return bad
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalid, alg)
+	}
+	return nil
+}
+
+// numericDate 解析 NumericDate（秒）。
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func audienceMatches(aud interface{}, accepted []string) bool {
+	var got []string
+	switch a := aud.(type) {
+	case string:
+		got = []string{a}
+	case []interface{}:
+		for _, x := range a {
+			if s, ok := x.(string); ok {
+				got = append(got, s)
+			}
+		}
+	}
+	for _, g := range got {
+		for _, want := range accepted {
+			if g == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimString 取 claim 的字符串值；path 可为以 "." 分隔的嵌套路径，claim 名本身含 "."（如 kubernetes.io）时同样可解析。
+// 数字与布尔转为字符串。
+func claimString(claims map[string]interface{}, path string) string {
+	switch x := lookupClaim(claims, path).(type) {
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return fmt.Sprint(x)
+	}
+	return ""
+}
+
+// lookupClaim 先按完整名称查找，再依次以每个 "." 之前的部分为名称进入嵌套对象查找剩余路径。
+func lookupClaim(m map[string]interface{}, path string) interface{} {
+	if v, ok := m[path]; ok {
+		return v
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if sub, ok := m[path[:i]].(map[string]interface{}); ok {
+			if v := lookupClaim(sub, path[i+1:]); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
@@ -12,7 +12,13 @@ type Evidence struct {
 	DecisionReason  string    `json:"decision_reason,omitempty"`
 	Decision        string    `json:"decision"` // allow / deny / review / approved / rejected / expired
 	CHEQStatus      string    `json:"cheq_status,omitempty"`
+	CHEQID          string    `json:"cheq_id,omitempty"` // 关联的 CHEQ（如 approved_by_grant 引用产生授权的原 CHEQ）
 	Confirmer       string    `json:"confirmer,omitempty"`
+	ReasonCode      string    `json:"reason_code,omitempty"`      // 审批人给出的原因码
+	Comment         string    `json:"comment,omitempty"`          // 审批人备注
+	Amendment       string    `json:"amendment,omitempty"`        // 改后批准时原请求与实际执行请求的差异
+	CertFingerprint string    `json:"cert_fingerprint,omitempty"` // mTLS 客户端证书 SHA-256 指纹
+	ProfileID       string    `json:"profile_id,omitempty"`       // exec 请求 resource 命中的 Sandbox Profile
 	Timestamp       time.Time `json:"timestamp"`
 	Resource        string    `json:"resource,omitempty"`
 	Action          string    `json:"action,omitempty"`
@@ -11,6 +11,7 @@ const (
 	ConfirmationStatusApproved ConfirmationStatus = "approved"
 	ConfirmationStatusRejected ConfirmationStatus = "rejected"
 	ConfirmationStatusExpired  ConfirmationStatus = "expired"
+	ConfirmationStatusCancelled ConfirmationStatus = "cancelled" // 被撤销（如 Agent 隔离、发起方中止），不再接受 Submit
 )
 
 // ConfirmationObject 表示一次待确认对象（CHEQ 统一确认协议）。
@@ -27,12 +28,129 @@ type ConfirmationObject struct {
 	ConfirmerIDs   []string // 确认人标识列表（如飞书 user_id）。
 	Type           string   // 如 agent_onboarding / service_access / operation_approval。
 	ApprovedBy     []string // I-008「全部通过」时已批准的 confirmer_id 列表；nil 表示未使用
-	ApprovalPolicy string   // I-009：本对象的审批策略（any/all）；空则用引擎默认
+	ApprovalPolicy string   // I-009：本对象的审批策略（any/all/quorum:N/roles:role=N,...）；空则用引擎默认
+	RejectMode     string   // veto（任一拒绝即拒绝，默认）或 quorum（剩余票数已无法满足策略时才拒绝）
+	Votes          []Vote   // 每位确认人的投票记录，按时间追加
+	Requester      string   // 发起请求的主体；职责分离：不可审批本请求
+	RequesterOwner string   // 发起主体的 owner；同样不可审批本请求
+	SessionID      string   // 发起请求的会话（AuthStream 连接或 X-Session-ID）
+	Fingerprint    string   // 请求指纹（主体 + 操作 + 资源 + 规范化请求体）；相同指纹的待确认请求合并到本对象
+	Waiters        []string // 合并到本对象的重复请求 trace_id，一次审批同时放行
+	DecisionComment    string // 促成终态那一票的审批人备注，反馈给 Agent
+	DecisionReasonCode string // 促成终态那一票的原因码（见 Reason*）
+	Amendment          *Amendment // 审批人「改后批准」的改写；nil 表示按原请求执行
+	CancelledBy        string     // 撤销方（管理员或发起方）；撤销原因记在 DecisionComment
+	ClosedAt           time.Time  // 进入终态（批准/拒绝/过期/撤销）的时间；未终态为零值
+
+	EscalationPlan  []EscalationStage // 升级计划，按 At 升序；到点仍无决定则向该阶段审批人重新投递
+	EscalationLevel int               // 已触发的升级阶段数；0 表示仍在初始审批人
+	OnTimeout       string            // 最终超时动作：deny（默认）/allow_with_flag/escalate_oncall
+	TimeoutAction   string            // 实际执行过的超时动作；空表示未触发
+	Transitions     []StageTransition // 阶段流转记录（升级、转值班、超时动作、流程阶段推进），按时间追加
+
+	Stages     []WorkflowStage // 按 Type 配置的多阶段审批流程；空表示单阶段
+	StageIndex int             // 当前阶段在 Stages 中的下标；ConfirmerIDs、ApprovalPolicy、ExpiresAt 均为当前阶段的值
+
+	Deliveries []DeliveryRef // 已投递的 IM 消息，随对象持久化；重启后进入终态仍可更新卡片
+}
+
+// Vote 单张投票：确认人、决定（approve/reject）、时间；Role 为投票时确认人所属角色（roles 策略使用）。
+type Vote struct {
+	ConfirmerID string
+	Decision    string
+	Role        string
+	At          time.Time
+
+	GrantScope   string // 批准时选择的授权范围：once / minutes / session；空等同 once
+	GrantMinutes int    // 授权分钟数
+	Comment      string // 审批人备注（可选）
+	ReasonCode   string // 原因码（可选，见 Reason*）
+	Stage        string // 投票时所处的流程阶段；单阶段对象为空
+}
+
+// 审批原因码（Vote.ReasonCode）：结构化说明拒绝（或附条件批准）的原因，Agent 可据此修正后重试。
+const (
+	ReasonInsufficientContext = "insufficient_context" // 信息不足，需补充用途或上下文
+	ReasonTooBroad            = "too_broad"            // 范围过大，需缩小资源或操作
+	ReasonWrongTarget         = "wrong_target"         // 目标资源或环境不对
+	ReasonPolicyViolation     = "policy_violation"     // 违反安全策略或规范
+	ReasonNotNow              = "not_now"              // 时机不合适（如变更冻结），稍后再试
+	ReasonOther               = "other"                // 其他，见备注
+)
+
+// ValidReasonCode 返回 code 是否为已定义的原因码；空视为有效（未填写）。
+func ValidReasonCode(code string) bool {
+	switch code {
+	case "", ReasonInsufficientContext, ReasonTooBroad, ReasonWrongTarget, ReasonPolicyViolation, ReasonNotNow, ReasonOther:
+		return true
+	}
+	return false
+}
+
+// 投票决定（Vote.Decision）。
+const (
+	VoteApprove = "approve"
+	VoteReject  = "reject"
+)
+
+// 最终超时动作（ConfirmationObject.OnTimeout）。
+const (
+	OnTimeoutDeny           = "deny"            // 置为 expired，请求被拒绝
+	OnTimeoutAllowWithFlag  = "allow_with_flag" // 置为 approved 放行，审计中标记为超时放行
+	OnTimeoutEscalateOncall = "escalate_oncall" // 转安全值班再等一轮，仍无决定则按 deny 处理
+)
+
+// EscalationStage 单个升级阶段：At 时刻仍无决定则投递给 ConfirmerIDs（并入对象确认人）。
+type EscalationStage struct {
+	At           time.Time
+	ConfirmerIDs []string
+}
+
+// Amendment 审批人「改后批准」：exec 请求替换命令行，HTTP 请求对 JSON 请求体应用 merge patch（RFC 7396）。
+// 同一对象只接受一份改写，其余批准人批准的是改写后的请求。
+type Amendment struct {
+	CommandLine string // 改写后的完整命令行（exec）
+	BodyPatch   string // 对请求体的 JSON merge patch（HTTP）
+	By          string // 提交改写的确认人
+	At          time.Time
+}
+
+// WorkflowStage 多阶段审批流程中的一个阶段：本阶段审批人、审批策略与时限（自进入本阶段起计）。
+// 当前阶段达成策略后进入下一阶段，最后一个阶段达成才置为 approved；任一阶段拒绝即 rejected。
+type WorkflowStage struct {
+	Name           string
+	ConfirmerIDs   []string
+	ApprovalPolicy string
+	Timeout        time.Duration
+}
+
+// StageTransition 一次阶段流转。Stage 如 escalation:1、oncall、timeout:allow_with_flag、stage:security。
+type StageTransition struct {
+	Stage        string
+	At           time.Time
+	ConfirmerIDs []string // 本阶段新增投递的确认人；超时动作为空
+	Reason       string
+}
+
+// DeliveryRef 一条已投递的 IM 消息。Card 为 true 表示交互卡片（终态时原地更新），否则为文本消息（终态时回复）。
+type DeliveryRef struct {
+	Channel   string // 如 feishu
+	MessageID string
+	Card      bool
+}
+
+// CurrentStage 返回当前流程阶段名；单阶段对象返回空。
+func (c *ConfirmationObject) CurrentStage() string {
+	if c.StageIndex < 0 || c.StageIndex >= len(c.Stages) {
+		return ""
+	}
+	return c.Stages[c.StageIndex].Name
 }
 
 // IsTerminal 返回是否已终态（不再接受 Submit）。
 func (c *ConfirmationObject) IsTerminal() bool {
 	return c.Status == ConfirmationStatusApproved ||
 		c.Status == ConfirmationStatusRejected ||
-		c.Status == ConfirmationStatusExpired
+		c.Status == ConfirmationStatusExpired ||
+		c.Status == ConfirmationStatusCancelled
 }
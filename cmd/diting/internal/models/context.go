@@ -1,13 +1,33 @@
 // Package models 提供 proxy、policy、cheq、audit 等组件共用的请求上下文与数据类型。
 package models
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 // RequestContext 表示单次请求的上下文，供 L0/L1/L2 使用。
 // 含 Agent 身份、目标 URL/方法、资源标识、操作、请求头等。
 type RequestContext struct {
 	// AgentIdentity L0 身份标识（如 API Key、user_id）；空表示未识别。
 	AgentIdentity string
+	// AgentID 由注册表解析出的 Agent ID；非空时策略按其匹配 subject。
+	AgentID string
+	// KeyID 通过 L0 校验的 API Key 的 key id；空表示未经 API Key 认证。
+	KeyID string
+	// CertIdentity 已验证的 mTLS 客户端证书映射出的 Agent 身份；空表示未出示证书或证书无可映射的身份。
+	CertIdentity string
+	// CertFingerprint 已验证的客户端证书 SHA-256 指纹（sha256:<hex>），写入审计。
+	CertFingerprint string
+	// PeerIdentity 经 Unix 套接字到达时由对端内核凭证得出的身份（container:<id> 或 uid:<uid>）；空表示非套接字请求。
+	PeerIdentity string
+	// SignatureDID 通过 HTTP 消息签名校验的签名方 DID；空表示请求未签名。
+	SignatureDID string
+	// SignatureError 请求带签名但校验失败时的原因；非 nil 时 L0 拒绝。
+	SignatureError error
+	// AgentTeam、AgentLabels 为注册表中该 Agent 的团队与标签，供策略规则匹配。
+	AgentTeam   string
+	AgentLabels map[string]string
 	// Method HTTP 方法（GET、POST、CONNECT 等）。
 	Method string
 	// TargetURL 目标 URL（代理转发时的上游地址或路径）。
@@ -18,6 +38,35 @@ type RequestContext struct {
 	Action string
 	// Headers 请求头副本，可含 traceparent、X-Agent-Token 等。
 	Headers http.Header
+	// ProfileID exec 请求的 resource 命中的 Sandbox Profile，写入审计；空表示非 exec 请求。
+	ProfileID string
+	// SessionID 会话标识（AuthStream 连接或 X-Session-ID 头），供会话级授权匹配；空表示无会话。
+	SessionID string
 	// Context 扩展上下文（可选），用于 exec 请求的 command_line、working_dir、env 等。
 	Context map[string]string
 }
+
+// ResolvedSubject 返回经 L0 认证解析出的主体：AgentID，其次 "key:<KeyID>"；均无时为空。
+// 与 Subject 不同，不回退到自报的 AgentIdentity（可能就是凭证本身），CHEQ 发起方与限时授权只以此为键。
+func (r *RequestContext) ResolvedSubject() string {
+	switch {
+	case r.AgentID != "":
+		return r.AgentID
+	case r.KeyID != "":
+		return "key:" + r.KeyID
+	}
+	return ""
+}
+
+// Subject 返回已解析的请求主体，供策略匹配、CHEQ 发起方与审计使用：优先 AgentID，其次 "key:<KeyID>"（未映射 Agent 的 API Key），
+// 均无时为去掉 "Bearer " 前缀的 AgentIdentity（未启用 L0 校验时的自报身份）。
+func (r *RequestContext) Subject() string {
+	if s := r.ResolvedSubject(); s != "" {
+		return s
+	}
+	s := strings.TrimSpace(r.AgentIdentity)
+	if strings.HasPrefix(s, "Bearer ") {
+		s = strings.TrimSpace(strings.TrimPrefix(s, "Bearer "))
+	}
+	return s
+}
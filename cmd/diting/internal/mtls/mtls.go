@@ -0,0 +1,282 @@
+// Package mtls 提供 TLS / mTLS 监听配置：服务端证书、客户端 CA、可选 CRL，文件变更后热加载（按修改时间轮询）；
+// 并将已验证的客户端证书（SPIFFE ID、SAN、CN）映射为 Agent 身份，证书指纹供审计记录。
+package mtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultReloadInterval 检查证书文件变更的默认间隔。
+const defaultReloadInterval = 30 * time.Second
+
+// ErrRevoked 表示客户端证书已被 CRL 吊销。
+var ErrRevoked = errors.New("mtls: client certificate revoked")
+
+// Options 监听参数。
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile 客户端证书 CA（PEM，可多张）；空表示不校验客户端证书（仅 TLS）。
+	ClientCAFile string
+	// CRLFile 客户端证书吊销列表（PEM 或 DER），须由 ClientCAFile 中的 CA 签发；空表示不检查吊销。
+	CRLFile string
+	// RequireClientCert 为 true 时未出示客户端证书的连接在握手时拒绝；否则客户端证书可选（出示则须有效）。
+	RequireClientCert bool
+	// ReloadInterval 检查文件变更的间隔；0 表示默认 30 秒。
+	ReloadInterval time.Duration
+}
+
+// Reloader 持有当前生效的 TLS 配置，文件变更时整体替换；新连接使用新配置，已建立的连接不受影响。
+type Reloader struct {
+	opts Options
+
+	mu     sync.RWMutex
+	cfg    *tls.Config
+	mtimes map[string]time.Time
+}
+
+// New 加载证书并返回 Reloader；任一文件无法加载时返回错误。
+func New(opts Options) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("mtls: cert_file and key_file required")
+	}
+	if opts.CRLFile != "" && opts.ClientCAFile == "" {
+		return nil, errors.New("mtls: crl_file requires client_ca_file")
+	}
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = defaultReloadInterval
+	}
+	r := &Reloader{opts: opts}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig 返回供 http.Server 使用的配置：每次握手取当前生效的配置。
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cfg, nil
+		},
+	}
+}
+
+// Reload 在任一文件的修改时间变化时重新加载；加载失败时保留原配置并返回错误。返回是否已替换配置。
+func (r *Reloader) Reload() (bool, error) {
+	r.mu.RLock()
+	changed := false
+	for _, f := range r.files() {
+		if st, err := os.Stat(f); err != nil || !st.ModTime().Equal(r.mtimes[f]) {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	if err := r.load(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Run 按 ReloadInterval 检查文件变更直到 ctx 结束；onErr 非 nil 时接收加载失败的错误。
+func (r *Reloader) Run(ctx context.Context, onErr func(error)) {
+	t := time.NewTicker(r.opts.ReloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := r.Reload(); err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	out := []string{r.opts.CertFile, r.opts.KeyFile}
+	for _, f := range []string{r.opts.ClientCAFile, r.opts.CRLFile} {
+		if f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// load 读取全部文件并构建新配置；成功时整体替换。
+func (r *Reloader) load() error {
+	mtimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		// 先记修改时间再读内容：读取期间的写入会在下次检查时再次加载
+		st, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("mtls: %w", err)
+		}
+		mtimes[f] = st.ModTime()
+	}
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("mtls: load key pair: %w", err)
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.opts.ClientCAFile != "" {
+		cas, err := readCertificates(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		for _, c := range cas {
+			pool.AddCert(c)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.opts.RequireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		if r.opts.CRLFile != "" {
+			revoked, err := readCRL(r.opts.CRLFile, cas)
+			if err != nil {
+				return err
+			}
+			cfg.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+				for _, chain := range chains {
+					for _, c := range chain {
+						if revoked[string(c.RawIssuer)+c.SerialNumber.String()] {
+							return ErrRevoked
+						}
+					}
+				}
+				return nil
+			}
+		}
+	}
+	r.mu.Lock()
+	r.cfg, r.mtimes = cfg, mtimes
+	r.mu.Unlock()
+	return nil
+}
+
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("mtls: %w", err)
+	}
+	var out []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("mtls: parse %s: %w", path, err)
+		}
+		out = append(out, c)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("mtls: no certificates in %s", path)
+	}
+	return out, nil
+}
+
+// readCRL 解析 CRL 并以签发它的 CA 验证签名，返回吊销的 issuer+序列号集合。
+func readCRL(path string, cas []*x509.Certificate) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("mtls: %w", err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("mtls: parse crl: %w", err)
+	}
+	var issuer *x509.Certificate
+	for _, ca := range cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			issuer = ca
+			break
+		}
+	}
+	if issuer == nil {
+		return nil, errors.New("mtls: crl is not signed by a configured client CA")
+	}
+	revoked := make(map[string]bool, len(crl.RevokedCertificateEntries))
+	for _, e := range crl.RevokedCertificateEntries {
+		revoked[string(issuer.RawSubject)+e.SerialNumber.String()] = true
+	}
+	return revoked, nil
+}
+
+// DefaultIdentitySources 为客户端证书映射 Agent 身份的默认顺序。
+var DefaultIdentitySources = []string{"spiffe", "san_uri", "san_dns", "cn"}
+
+// Identity 按 sources 顺序从证书取 Agent 身份：spiffe（spiffe:// URI SAN；trustDomain 非空时须属于该信任域）、
+// san_uri（其他 URI SAN）、san_dns、san_email、cn。均无时返回空。
+func Identity(cert *x509.Certificate, sources []string, trustDomain string) string {
+	if len(sources) == 0 {
+		sources = DefaultIdentitySources
+	}
+	for _, src := range sources {
+		switch src {
+		case "spiffe":
+			for _, u := range cert.URIs {
+				if u.Scheme == "spiffe" && (trustDomain == "" || u.Host == trustDomain) {
+					return u.String()
+				}
+			}
+		case "san_uri":
+			for _, u := range cert.URIs {
+				if u.Scheme != "spiffe" {
+					return u.String()
+				}
+			}
+		case "san_dns":
+			if len(cert.DNSNames) > 0 {
+				return cert.DNSNames[0]
+			}
+		case "san_email":
+			if len(cert.EmailAddresses) > 0 {
+				return cert.EmailAddresses[0]
+			}
+		case "cn":
+			if cn := strings.TrimSpace(cert.Subject.CommonName); cn != "" {
+				return cn
+			}
+		}
+	}
+	return ""
+}
+
+// Fingerprint 返回证书 DER 的 SHA-256 指纹，形如 sha256:<hex>。
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
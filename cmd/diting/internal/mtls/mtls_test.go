@@ -0,0 +1,208 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue 签发证书并返回 PEM 证书与私钥。
+func (ca *testCA) issue(t *testing.T, serial int64, cn string, uris []string, server bool) (certPEM, keyPEM []byte, cert *x509.Certificate) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+	for _, u := range uris {
+		parsed, _ := url.Parse(u)
+		tmpl.URIs = append(tmpl.URIs, parsed)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), cert
+}
+
+func (ca *testCA) crl(t *testing.T, number int64, revoked ...*big.Int) []byte {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, s := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: s, RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("crl: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+// write 写文件并推进修改时间，保证 Reload 能观察到变更。
+func write(t *testing.T, path string, data []byte, gen int) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(time.Duration(gen) * time.Second)
+	_ = os.Chtimes(path, mt, mt)
+}
+
+func TestReloader_MTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	srvCert, srvKey, _ := ca.issue(t, 10, "server", nil, true)
+	cliCert, cliKey, cli := ca.issue(t, 20, "agent-cn", []string{"spiffe://example.org/agent/a1"}, false)
+	paths := map[string]string{}
+	for name, data := range map[string][]byte{"server.pem": srvCert, "server.key": srvKey, "ca.pem": caPEM, "crl.pem": ca.crl(t, 1)} {
+		paths[name] = filepath.Join(dir, name)
+		write(t, paths[name], data, 0)
+	}
+	r, err := New(Options{CertFile: paths["server.pem"], KeyFile: paths["server.key"], ClientCAFile: paths["ca.pem"], CRLFile: paths["crl.pem"], RequireClientCert: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{ErrorLog: log.New(io.Discard, "", 0), Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := req.TLS.VerifiedChains[0][0]
+		_, _ = io.WriteString(w, Identity(c, nil, "example.org")+" "+Fingerprint(c))
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	pair, _ := tls.X509KeyPair(cliCert, cliKey)
+	get := func(withCert bool) (string, *x509.Certificate, error) {
+		cfg := &tls.Config{RootCAs: roots}
+		if withCert {
+			cfg.Certificates = []tls.Certificate{pair}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+		resp, err := client.Get("https://" + ln.Addr().String() + "/")
+		if err != nil {
+			return "", nil, err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.TLS.PeerCertificates[0], nil
+	}
+
+	body, server, err := get(true)
+	if err != nil || body != "spiffe://example.org/agent/a1 "+Fingerprint(cli) || server.SerialNumber.Int64() != 10 {
+		t.Fatalf("mTLS request: %q %v", body, err)
+	}
+	if _, _, err := get(false); err == nil {
+		t.Errorf("request without client certificate should fail the handshake")
+	}
+
+	// 轮换服务端证书：新连接使用新证书
+	srvCert2, srvKey2, _ := ca.issue(t, 11, "server", nil, true)
+	write(t, paths["server.pem"], srvCert2, 1)
+	write(t, paths["server.key"], srvKey2, 1)
+	if changed, err := r.Reload(); !changed || err != nil {
+		t.Fatalf("reload rotated cert: %v %v", changed, err)
+	}
+	if _, server, err := get(true); err != nil || server.SerialNumber.Int64() != 11 {
+		t.Errorf("after rotation: %v %v", server, err)
+	}
+	if changed, _ := r.Reload(); changed {
+		t.Errorf("reload without file changes should be a no-op")
+	}
+
+	// 损坏的文件：保留原配置
+	write(t, paths["server.pem"], []byte("garbage"), 2)
+	if _, err := r.Reload(); err == nil {
+		t.Errorf("reload of broken cert should fail")
+	}
+	if _, server, err := get(true); err != nil || server.SerialNumber.Int64() != 11 {
+		t.Errorf("previous config should stay in effect: %v %v", server, err)
+	}
+	write(t, paths["server.pem"], srvCert2, 3)
+
+	// 吊销客户端证书
+	write(t, paths["crl.pem"], ca.crl(t, 2, big.NewInt(20)), 3)
+	if _, err := r.Reload(); err != nil {
+		t.Fatalf("reload crl: %v", err)
+	}
+	if _, _, err := get(true); err == nil {
+		t.Errorf("revoked client certificate accepted")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	ca := newCA(t)
+	_, _, c := ca.issue(t, 1, "agent-cn", []string{"spiffe://other.org/a1", "https://agents.example.com/a2"}, false)
+	cases := []struct {
+		sources     []string
+		trustDomain string
+		want        string
+	}{
+		{nil, "", "spiffe://other.org/a1"},
+		{nil, "example.org", "https://agents.example.com/a2"},
+		{[]string{"spiffe", "cn"}, "example.org", "agent-cn"},
+		{[]string{"san_dns"}, "", ""},
+	}
+	for _, tc := range cases {
+		if got := Identity(c, tc.sources, tc.trustDomain); got != tc.want {
+			t.Errorf("Identity(%v, %q) = %q, want %q", tc.sources, tc.trustDomain, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package ownership
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 审批策略种类。
+const (
+	PolicyAny    = "any"    // 任一确认人批准即通过
+	PolicyAll    = "all"    // 全部确认人批准才通过
+	PolicyQuorum = "quorum" // quorum:N，N 个确认人批准即通过
+	PolicyRoles  = "roles"  // roles:security=1,owner=1，各角色分别达到所需批准数才通过
+)
+
+// 拒绝处理方式。
+const (
+	RejectVeto   = "veto"   // 任一拒绝即拒绝（默认）
+	RejectQuorum = "quorum" // 仅当剩余未投票者已不可能满足策略时才拒绝
+)
+
+// ApprovalPolicy 解析后的审批策略。
+type ApprovalPolicy struct {
+	Kind   string         // any / all / quorum / roles
+	Quorum int            // Kind 为 quorum 时所需批准数
+	Roles  map[string]int // Kind 为 roles 时各角色所需批准数
+}
+
+// ParseApprovalPolicy 解析审批策略字符串：any、all、quorum:N、roles:role=N[,role=N...]；空串视为 any。
+func ParseApprovalPolicy(s string) (ApprovalPolicy, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || s == PolicyAny:
+		return ApprovalPolicy{Kind: PolicyAny}, nil
+	case s == PolicyAll:
+		return ApprovalPolicy{Kind: PolicyAll}, nil
+	case strings.HasPrefix(s, PolicyQuorum+":"):
+		n, err := strconv.Atoi(strings.TrimPrefix(s, PolicyQuorum+":"))
+		if err != nil || n <= 0 {
+			return ApprovalPolicy{}, fmt.Errorf("ownership: invalid quorum in approval policy %q", s)
+		}
+		return ApprovalPolicy{Kind: PolicyQuorum, Quorum: n}, nil
+	case strings.HasPrefix(s, PolicyRoles+":"):
+		roles := make(map[string]int)
+		for _, part := range strings.Split(strings.TrimPrefix(s, PolicyRoles+":"), ",") {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return ApprovalPolicy{}, fmt.Errorf("ownership: invalid role requirement %q in approval policy", part)
+			}
+			n, err := strconv.Atoi(kv[1])
+			if err != nil || n <= 0 {
+				return ApprovalPolicy{}, fmt.Errorf("ownership: invalid role count %q in approval policy", part)
+			}
+			roles[kv[0]] = n
+		}
+		return ApprovalPolicy{Kind: PolicyRoles, Roles: roles}, nil
+	}
+	return ApprovalPolicy{}, fmt.Errorf("ownership: unknown approval policy %q", s)
+}
+
+// String 返回策略的规范字符串形式（roles 按角色名排序）。
+func (p ApprovalPolicy) String() string {
+	switch p.Kind {
+	case PolicyQuorum:
+		return PolicyQuorum + ":" + strconv.Itoa(p.Quorum)
+	case PolicyRoles:
+		names := make([]string, 0, len(p.Roles))
+		for r := range p.Roles {
+			names = append(names, r)
+		}
+		sort.Strings(names)
+		parts := make([]string, 0, len(names))
+		for _, r := range names {
+			parts = append(parts, r+"="+strconv.Itoa(p.Roles[r]))
+		}
+		return PolicyRoles + ":" + strings.Join(parts, ",")
+	case "":
+		return PolicyAny
+	}
+	return p.Kind
+}
+
+// MultiVote 返回是否需要统计多张批准票（all/quorum/roles）；此时每票须带确认人标识。
+func (p ApprovalPolicy) MultiVote() bool {
+	return p.Kind != PolicyAny && p.Kind != ""
+}
+
+// Satisfied 判断 approvers（已批准的确认人）在 confirmers（全部确认人）与 roleOf（确认人 -> 角色）下是否满足策略。
+// quorum 的 N 超过确认人数时按全部确认人计，避免永远无法通过。
+func (p ApprovalPolicy) Satisfied(approvers, confirmers []string, roleOf func(string) string) bool {
+	switch p.Kind {
+	case PolicyAll:
+		if len(confirmers) == 0 {
+			return len(approvers) > 0
+		}
+		set := make(map[string]bool, len(approvers))
+		for _, a := range approvers {
+			set[a] = true
+		}
+		for _, c := range confirmers {
+			if !set[c] {
+				return false
+			}
+		}
+		return true
+	case PolicyQuorum:
+		need := p.Quorum
+		if len(confirmers) > 0 && need > len(confirmers) {
+			need = len(confirmers)
+		}
+		return len(approvers) >= need
+	case PolicyRoles:
+		got := make(map[string]int)
+		for _, a := range approvers {
+			if roleOf != nil {
+				got[roleOf(a)]++
+			}
+		}
+		for r, n := range p.Roles {
+			if got[r] < n {
+				return false
+			}
+		}
+		return true
+	}
+	return len(approvers) > 0
+}
+
+// NormalizeApprovalPolicy 返回策略的规范形式；无法解析时返回 fallback。
+func NormalizeApprovalPolicy(s, fallback string) string {
+	p, err := ParseApprovalPolicy(s)
+	if err != nil {
+		return fallback
+	}
+	return p.String()
+}
+
+// NormalizeRejectMode 返回 veto 或 quorum；其他值按 veto。
+func NormalizeRejectMode(s string) string {
+	if s == RejectQuorum {
+		return RejectQuorum
+	}
+	return RejectVeto
+}
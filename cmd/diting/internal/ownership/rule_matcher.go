@@ -5,11 +5,20 @@ import (
 	"strings"
 )
 
-// ApprovalRuleMatch 单条规则匹配结果：超时秒数、审批人 ID 列表、审批策略（any/all）。
+// ApprovalRuleMatch 单条规则匹配结果：超时秒数、审批人 ID 列表、审批策略、升级阶段与超时动作。
 type ApprovalRuleMatch struct {
 	TimeoutSeconds  int
 	ApprovalUserIDs []string
-	ApprovalPolicy  string // "any" 或 "all"
+	ApprovalPolicy  string // any / all / quorum:N / roles:role=N,...
+	RejectMode      string // veto（默认）或 quorum
+	Escalation      []EscalationStage
+	OnTimeout       string // deny / allow_with_flag / escalate_oncall；空表示 deny
+}
+
+// EscalationStage 升级阶段：创建后 AfterSeconds 秒仍无决定则投递给 ApprovalUserIDs。
+type EscalationStage struct {
+	AfterSeconds    int
+	ApprovalUserIDs []string
 }
 
 // RuleMatcher I-009：按 path 前缀与 risk_level 匹配审批规则，返回超时与审批人；无匹配时返回默认值。
@@ -24,34 +33,39 @@ type ruleEntry struct {
 	timeoutSec int
 	userIDs    []string
 	policy     string
+	rejectMode string
+	escalation []EscalationStage
+	onTimeout  string
 }
 
-// NewRuleMatcher 从规则列表与默认值构建匹配器。rules 为 (path_prefix, risk_level, timeout_seconds, approval_user_ids, approval_policy)。
-// defaultMatch 为无匹配时使用的超时、审批人、策略。
+// NewRuleMatcher 从规则列表与默认值构建匹配器。rules 为 (path_prefix, risk_level, timeout_seconds, approval_user_ids, approval_policy, reject_mode, escalation, on_timeout)。
+// defaultMatch 为无匹配时使用的超时、审批人、策略。无法解析的审批策略按 all 处理（从严），调用方应在加载配置时用 ParseApprovalPolicy 校验。
 func NewRuleMatcher(rules []struct {
 	PathPrefix      string
 	RiskLevel       string
 	TimeoutSeconds  int
 	ApprovalUserIDs []string
 	ApprovalPolicy  string
+	RejectMode      string
+	Escalation      []EscalationStage
+	OnTimeout       string
 }, defaultMatch ApprovalRuleMatch) *RuleMatcher {
 	entries := make([]ruleEntry, 0, len(rules))
 	for _, r := range rules {
-		policy := r.ApprovalPolicy
-		if policy != "all" {
-			policy = "any"
-		}
+		policy := NormalizeApprovalPolicy(r.ApprovalPolicy, PolicyAll)
 		entries = append(entries, ruleEntry{
 			pathPrefix: r.PathPrefix,
 			riskLevel:  r.RiskLevel,
 			timeoutSec: r.TimeoutSeconds,
 			userIDs:    append([]string(nil), r.ApprovalUserIDs...),
 			policy:     policy,
+			rejectMode: NormalizeRejectMode(r.RejectMode),
+			escalation: append([]EscalationStage(nil), r.Escalation...),
+			onTimeout:  r.OnTimeout,
 		})
 	}
-	if defaultMatch.ApprovalPolicy != "all" {
-		defaultMatch.ApprovalPolicy = "any"
-	}
+	defaultMatch.ApprovalPolicy = NormalizeApprovalPolicy(defaultMatch.ApprovalPolicy, PolicyAll)
+	defaultMatch.RejectMode = NormalizeRejectMode(defaultMatch.RejectMode)
 	return &RuleMatcher{rules: entries, def: defaultMatch}
 }
 
@@ -69,6 +83,9 @@ func (m *RuleMatcher) Match(path, riskLevel string) ApprovalRuleMatch {
 			TimeoutSeconds:  e.timeoutSec,
 			ApprovalUserIDs: append([]string(nil), e.userIDs...),
 			ApprovalPolicy:  e.policy,
+			RejectMode:      e.rejectMode,
+			Escalation:      append([]EscalationStage(nil), e.escalation...),
+			OnTimeout:       e.onTimeout,
 		}
 		if out.TimeoutSeconds <= 0 {
 			out.TimeoutSeconds = m.def.TimeoutSeconds
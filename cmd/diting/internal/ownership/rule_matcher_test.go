@@ -16,9 +16,13 @@ func TestRuleMatcher_Match(t *testing.T) {
 		TimeoutSeconds  int
 		ApprovalUserIDs []string
 		ApprovalPolicy  string
+		RejectMode      string
+		Escalation      []EscalationStage
+		OnTimeout       string
 	}{
 		{PathPrefix: "/admin", RiskLevel: "high", TimeoutSeconds: 600, ApprovalUserIDs: []string{"a1", "a2"}, ApprovalPolicy: "all"},
 		{PathPrefix: "/api", TimeoutSeconds: 60},
+		{PathPrefix: "/db", TimeoutSeconds: 900, OnTimeout: "escalate_oncall", Escalation: []EscalationStage{{AfterSeconds: 300, ApprovalUserIDs: []string{"lead"}}}},
 	}
 	m := NewRuleMatcher(rules, def)
 
@@ -45,4 +49,48 @@ func TestRuleMatcher_Match(t *testing.T) {
 	if got.TimeoutSeconds != 60 || len(got.ApprovalUserIDs) != 1 || got.ApprovalUserIDs[0] != "default" {
 		t.Errorf("Match(/api/read): got timeout=%d ids=%v", got.TimeoutSeconds, got.ApprovalUserIDs)
 	}
+
+	// 升级阶段与超时动作随规则返回
+	got = m.Match("/db/drop", "")
+	if got.OnTimeout != "escalate_oncall" || len(got.Escalation) != 1 || got.Escalation[0].AfterSeconds != 300 || got.Escalation[0].ApprovalUserIDs[0] != "lead" {
+		t.Errorf("Match(/db/drop): got escalation=%+v on_timeout=%s", got.Escalation, got.OnTimeout)
+	}
+}
+
+func TestParseApprovalPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"", "any", true},
+		{"all", "all", true},
+		{"quorum:2", "quorum:2", true},
+		{"roles:owner=1, security=1", "roles:owner=1,security=1", true},
+		{"quorum:0", "", false},
+		{"roles:security", "", false},
+		{"majority", "", false},
+	} {
+		p, err := ParseApprovalPolicy(tc.in)
+		if (err == nil) != tc.ok {
+			t.Errorf("ParseApprovalPolicy(%q): err=%v", tc.in, err)
+			continue
+		}
+		if tc.ok && p.String() != tc.want {
+			t.Errorf("ParseApprovalPolicy(%q) = %q, want %q", tc.in, p.String(), tc.want)
+		}
+	}
+
+	roleOf := func(id string) string { return map[string]string{"s1": "security", "o1": "owner", "o2": "owner"}[id] }
+	roles, _ := ParseApprovalPolicy("roles:security=1,owner=1")
+	if roles.Satisfied([]string{"o1", "o2"}, nil, roleOf) {
+		t.Error("roles: two owners must not satisfy security=1")
+	}
+	if !roles.Satisfied([]string{"o1", "s1"}, nil, roleOf) {
+		t.Error("roles: owner+security should satisfy")
+	}
+	q, _ := ParseApprovalPolicy("quorum:5")
+	if !q.Satisfied([]string{"a", "b"}, []string{"a", "b"}, nil) {
+		t.Error("quorum larger than confirmers should cap at all confirmers")
+	}
 }
@@ -0,0 +1,66 @@
+// Package peercred 取 Unix 域套接字对端进程的内核凭证（SO_PEERCRED：uid、gid、pid），并尽量从 /proc/<pid>/cgroup
+// 解析其所在容器 ID。凭证由内核提供，客户端无法伪造，用作同机 Agent 的身份。
+package peercred
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// ErrUnsupported 表示当前平台或连接类型无法取对端凭证。
+var ErrUnsupported = errors.New("peercred: unsupported")
+
+// Cred 为对端进程凭证。
+type Cred struct {
+	UID         uint32
+	GID         uint32
+	PID         int32
+	ContainerID string // 无法解析或不在容器中时为空
+}
+
+// Identity 返回凭证对应的主体：在容器中为 "container:<id>"，否则为 "uid:<uid>"。
+func (c *Cred) Identity() string {
+	if c.ContainerID != "" {
+		return "container:" + c.ContainerID
+	}
+	return "uid:" + strconv.FormatUint(uint64(c.UID), 10)
+}
+
+// Context 返回供策略匹配与审计的上下文键值：peer_uid、peer_gid、peer_pid，容器中另有 container_id。
+func (c *Cred) Context() map[string]string {
+	m := map[string]string{
+		"peer_uid": strconv.FormatUint(uint64(c.UID), 10),
+		"peer_gid": strconv.FormatUint(uint64(c.GID), 10),
+		"peer_pid": strconv.FormatInt(int64(c.PID), 10),
+	}
+	if c.ContainerID != "" {
+		m["container_id"] = c.ContainerID
+	}
+	return m
+}
+
+// ContextKeys 为 Context 可能写入的键；客户端自报的同名键须忽略。
+var ContextKeys = []string{"peer_uid", "peer_gid", "peer_pid", "container_id"}
+
+// containerIDPattern 匹配 cgroup 路径中的 64 位十六进制容器 ID（docker、containerd、cri-o、podman 的常见命名）。
+var containerIDPattern = regexp.MustCompile(`(?m)(?:^|[/\-:])([0-9a-f]{64})(?:\.scope)?(?:/|$)`)
+
+// ContainerID 从 /proc/<pid>/cgroup 解析容器 ID；进程不在容器中或无法读取时返回空。
+func ContainerID(pid int32) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	return parseCgroup(data)
+}
+
+// parseCgroup 取 cgroup 文件中第一个容器 ID。
+func parseCgroup(data []byte) string {
+	if m := containerIDPattern.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package peercred
+
+import (
+	"net"
+	"syscall"
+)
+
+// FromConn 取 Unix 域套接字连接对端的凭证；非 Unix 连接返回 ErrUnsupported。
+func FromConn(conn net.Conn) (*Cred, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var ucred *syscall.Ucred
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, serr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+	return &Cred{UID: ucred.Uid, GID: ucred.Gid, PID: ucred.Pid, ContainerID: ContainerID(ucred.Pid)}, nil
+}
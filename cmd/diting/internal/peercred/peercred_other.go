@@ -0,0 +1,10 @@
+//go:build !linux
+
+package peercred
+
+import "net"
+
+// FromConn 在非 Linux 平台不支持。
+func FromConn(conn net.Conn) (*Cred, error) {
+	return nil, ErrUnsupported
+}
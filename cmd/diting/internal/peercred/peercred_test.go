@@ -0,0 +1,60 @@
+package peercred
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseCgroup(t *testing.T) {
+	id := "4f1e0a3b9c2d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+	cases := map[string]string{
+		"0::/system.slice/docker-" + id + ".scope\n":                                  id,
+		"12:memory:/docker/" + id + "\n11:cpu:/docker/" + id + "\n":                   id,
+		"0::/kubepods.slice/kubepods-burstable.slice/cri-containerd-" + id + ".scope": id,
+		"0::/machine.slice/libpod-" + id + ".scope/container\n":                       id,
+		"0::/user.slice/user-1000.slice/session-2.scope\n":                            "",
+	}
+	for in, want := range cases {
+		if got := parseCgroup([]byte(in)); got != want {
+			t.Errorf("parseCgroup(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFromConn(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_PEERCRED is linux only")
+	}
+	dir, _ := os.MkdirTemp("", "peercred")
+	defer os.RemoveAll(dir)
+	lis, err := net.Listen("unix", filepath.Join(dir, "s.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		c, err := net.Dial("unix", lis.Addr().String())
+		if err == nil {
+			defer c.Close()
+			_, _ = c.Read(make([]byte, 1))
+		}
+	}()
+	conn, err := lis.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cred, err := FromConn(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.UID != uint32(os.Getuid()) || cred.PID != int32(os.Getpid()) {
+		t.Errorf("cred = %+v, want uid %d pid %d", cred, os.Getuid(), os.Getpid())
+	}
+	if _, err := FromConn(&net.TCPConn{}); err != ErrUnsupported {
+		t.Errorf("tcp conn: %v", err)
+	}
+}
@@ -12,3 +12,9 @@ type Engine interface {
 	// Evaluate 根据请求上下文做 L2 策略评估，返回 Allow/Deny/Review 及 policy_rule_id、decision_reason。
 	Evaluate(ctx context.Context, req *models.RequestContext) (*models.Decision, error)
 }
+
+// AgentRuleSetter 由支持按 Agent 下发规则的引擎实现（如内置 EngineImpl）；Agent 接入批准后挂载其基线策略。
+type AgentRuleSetter interface {
+	// SetAgentRules 设置某 Agent 的专属规则，先于全局规则匹配；rules 为空表示移除。
+	SetAgentRules(agentID string, rules []Rule)
+}
@@ -9,9 +9,10 @@ import (
 
 // EngineImpl 内置策略引擎：从 YAML 规则文件加载，按顺序匹配返回 Allow/Deny/Review。
 type EngineImpl struct {
-	mu    sync.RWMutex
-	rules []Rule
-	path  string
+	mu         sync.RWMutex
+	rules      []Rule
+	path       string
+	agentRules map[string][]Rule // Agent ID -> 专属规则（接入时由模板实例化）；不随 Reload 变化
 }
 
 // NewEngineImpl 根据规则文件路径创建引擎；path 为空时无规则，默认拒绝。
@@ -39,9 +40,24 @@ func (e *EngineImpl) Reload() error {
 	return nil
 }
 
-// Evaluate 按规则顺序匹配，第一条命中即返回对应 Decision；无命中则 Deny。
+// SetAgentRules 实现 AgentRuleSetter。
+func (e *EngineImpl) SetAgentRules(agentID string, rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(rules) == 0 {
+		delete(e.agentRules, agentID)
+		return
+	}
+	if e.agentRules == nil {
+		e.agentRules = make(map[string][]Rule)
+	}
+	e.agentRules[agentID] = append([]Rule(nil), rules...)
+}
+
+// Evaluate 按规则顺序匹配（该 Agent 的专属规则在前），第一条命中即返回对应 Decision；无命中则 Deny。
+// subject 取 req.Subject()：注册表或 API Key 解析出的 AgentID、未映射 Agent 的 "key:<id>"，否则为自报身份。
 func (e *EngineImpl) Evaluate(ctx context.Context, req *models.RequestContext) (*models.Decision, error) {
-	subject := req.AgentIdentity
+	subject := req.Subject()
 	if subject == "" {
 		subject = "*"
 	}
@@ -59,11 +75,14 @@ func (e *EngineImpl) Evaluate(ctx context.Context, req *models.RequestContext) (
 
 	e.mu.RLock()
 	rules := e.rules
+	if own := e.agentRules[subject]; len(own) > 0 {
+		rules = append(append([]Rule(nil), own...), rules...)
+	}
 	e.mu.RUnlock()
 
 	for i := range rules {
 		r := &rules[i]
-		if r.Match(subject, action, resource) {
+		if r.Match(subject, action, resource) && r.MatchAgent(req.AgentTeam, req.AgentLabels) {
 			reason := r.Reason
 			if reason == "" {
 				reason = string(r.Decision) + " by rule " + r.ID
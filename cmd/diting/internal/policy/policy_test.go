@@ -134,3 +134,68 @@ rules:
 		t.Errorf("expected Deny, got %v", dec2.Kind)
 	}
 }
+
+func TestEngineImpl_AgentRulesFromTemplate(t *testing.T) {
+	eng, err := NewEngineImpl("")
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	tmpl := []Rule{{ID: "read-own", Action: "GET", Resource: "/agents/{agent_id}", Decision: RuleAllow}}
+	rules := AgentRules("a1", tmpl)
+	if rules[0].ID != "a1/read-own" || rules[0].Subject != "a1" || rules[0].Resource != "/agents/a1" {
+		t.Fatalf("AgentRules = %+v", rules)
+	}
+	eng.SetAgentRules("a1", rules)
+	ctx := context.Background()
+
+	dec, _ := eng.Evaluate(ctx, &models.RequestContext{AgentIdentity: "dak_secret", AgentID: "a1", Method: "GET", Resource: "/agents/a1"})
+	if dec.Kind != models.DecisionAllow || dec.PolicyRuleID != "a1/read-own" {
+		t.Errorf("a1 own rule: %v %s", dec.Kind, dec.PolicyRuleID)
+	}
+	dec, _ = eng.Evaluate(ctx, &models.RequestContext{AgentID: "a2", Method: "GET", Resource: "/agents/a1"})
+	if dec.Kind != models.DecisionDeny {
+		t.Errorf("other agent should fall through to default deny, got %v", dec.Kind)
+	}
+	eng.SetAgentRules("a1", nil)
+	dec, _ = eng.Evaluate(ctx, &models.RequestContext{AgentID: "a1", Method: "GET", Resource: "/agents/a1"})
+	if dec.Kind != models.DecisionDeny {
+		t.Errorf("removed rules should no longer match, got %v", dec.Kind)
+	}
+}
+
+func TestEngineImpl_MatchAgentTeamAndLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	content := []byte(`
+rules:
+  - id: prod-review
+    labels:
+      env: prod
+    decision: review
+  - id: infra-allow
+    team: infra
+    decision: allow
+`)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	eng, err := NewEngineImpl(path)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	ctx := context.Background()
+	cases := []struct {
+		team   string
+		labels map[string]string
+		want   models.DecisionKind
+	}{
+		{"infra", map[string]string{"env": "prod"}, models.DecisionReview},
+		{"infra", map[string]string{"env": "dev"}, models.DecisionAllow},
+		{"web", nil, models.DecisionDeny},
+	}
+	for _, c := range cases {
+		dec, _ := eng.Evaluate(ctx, &models.RequestContext{AgentID: "a1", AgentTeam: c.team, AgentLabels: c.labels, Method: "GET", Resource: "/x"})
+		if dec.Kind != c.want {
+			t.Errorf("team=%s labels=%v: got %v, want %v", c.team, c.labels, dec.Kind, c.want)
+		}
+	}
+}
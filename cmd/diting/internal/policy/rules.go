@@ -4,6 +4,7 @@ package policy
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -23,6 +24,8 @@ type Rule struct {
 	Subject  string       `yaml:"subject,omitempty"`  // 空或 * 表示任意
 	Action   string       `yaml:"action,omitempty"`  // 空或 * 表示任意
 	Resource string       `yaml:"resource,omitempty"` // 空或 * 表示任意
+	Team     string            `yaml:"team,omitempty"`   // 注册表中 Agent 的团队；空表示任意
+	Labels   map[string]string `yaml:"labels,omitempty"` // 注册表中 Agent 的标签，须全部匹配；空表示任意
 	Decision RuleDecision `yaml:"decision"`
 	Reason   string       `yaml:"reason,omitempty"` // 决策理由，写入审计
 }
@@ -51,6 +54,23 @@ func LoadRules(path string) ([]Rule, error) {
 	return f.Rules, nil
 }
 
+// AgentRules 由模板规则实例化某 Agent 的专属规则：subject 置为 agentID，id 加 agentID/ 前缀，
+// resource 与 reason 中的 {agent_id} 替换为 agentID。
+func AgentRules(agentID string, tmpl []Rule) []Rule {
+	out := make([]Rule, 0, len(tmpl))
+	for _, r := range tmpl {
+		r.Subject = agentID
+		if r.ID == "" {
+			r.ID = "rule_" + string(r.Decision)
+		}
+		r.ID = agentID + "/" + r.ID
+		r.Resource = strings.ReplaceAll(r.Resource, "{agent_id}", agentID)
+		r.Reason = strings.ReplaceAll(r.Reason, "{agent_id}", agentID)
+		out = append(out, r)
+	}
+	return out
+}
+
 // Match 返回 rule 是否匹配 subject/action/resource；空或 * 表示匹配任意。
 func (r *Rule) Match(subject, action, resource string) bool {
 	match := func(pat, v string) bool {
@@ -58,3 +78,17 @@ func (r *Rule) Match(subject, action, resource string) bool {
 	}
 	return match(r.Subject, subject) && match(r.Action, action) && match(r.Resource, resource)
 }
+
+// MatchAgent 返回 rule 的 team、labels 条件是否被 Agent 的团队与标签满足；规则未设置时恒为 true。
+func (r *Rule) MatchAgent(team string, labels map[string]string) bool {
+	if r.Team != "" && r.Team != "*" && r.Team != team {
+		return false
+	}
+	for k, v := range r.Labels {
+		got, ok := labels[k]
+		if !ok || (v != "*" && v != got) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+// Agent 注册表管理接口：GET/POST/PUT/DELETE /agents。
+
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"diting/internal/agent"
+	"diting/internal/models"
+	"diting/internal/policy"
+)
+
+// errAdminTokenInvalid 表示管理接口的 X-Admin-Token 缺失或不匹配。
+var errAdminTokenInvalid = errors.New("invalid admin token")
+
+// AgentView 为注册表条目的 JSON 视图；不含凭证，credential 仅在创建或轮换时返回一次。
+type AgentView struct {
+	ID               string            `json:"id"`
+	OwnerID          string            `json:"owner_id,omitempty"`
+	Team             string            `json:"team,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	State            string            `json:"state"`
+	HasCredential    bool              `json:"has_credential"`
+	Credential       string            `json:"credential,omitempty"`
+	OnboardingCHEQID string            `json:"onboarding_cheq_id,omitempty"`
+	ApprovedBy       []string          `json:"approved_by,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	ActivatedAt      *time.Time        `json:"activated_at,omitempty"`
+	LastSeenAt       *time.Time        `json:"last_seen_at,omitempty"`
+	Quarantine       *agent.Quarantine `json:"quarantine,omitempty"`
+}
+
+// NewAgentView 由注册表条目构建对外视图（不含凭证）。
+func NewAgentView(a *agent.Agent) AgentView {
+	v := AgentView{
+		ID:               a.ID,
+		OwnerID:          a.OwnerID,
+		Team:             a.Team,
+		Labels:           a.Labels,
+		State:            string(a.State),
+		HasCredential:    a.HasCredential(),
+		OnboardingCHEQID: a.OnboardingCHEQID,
+		ApprovedBy:       a.ApprovedBy,
+		CreatedAt:        a.CreatedAt,
+		Quarantine:       a.Quarantine,
+	}
+	if !a.ActivatedAt.IsZero() {
+		v.ActivatedAt = &a.ActivatedAt
+	}
+	if !a.LastSeenAt.IsZero() {
+		v.LastSeenAt = &a.LastSeenAt
+	}
+	return v
+}
+
+// agentInput 为 POST/PUT /agents 的请求体；PUT 时未给出的字段保持不变。
+type agentInput struct {
+	ID               string            `json:"id"`
+	OwnerID          *string           `json:"owner_id"`
+	Team             *string           `json:"team"`
+	Labels           map[string]string `json:"labels"`
+	State            string            `json:"state"`
+	RotateCredential bool              `json:"rotate_credential"`
+}
+
+// SetAdminTokens 设置管理接口（/agents 等）的 token：管理员 -> token，请求放在 X-Admin-Token。未配置时不校验（仅限本地调试）。
+func (s *Server) SetAdminTokens(tokens map[string]string) {
+	s.adminTokens = tokens
+}
+
+// adminOf 校验 X-Admin-Token 并返回管理员名；未配置 admin token 时返回 ("", true)。
+func (s *Server) adminOf(r *http.Request) (string, bool) {
+	if len(s.adminTokens) == 0 {
+		return "", true
+	}
+	tok := r.Header.Get("X-Admin-Token")
+	who := ""
+	for name, t := range s.adminTokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(tok)) == 1 {
+			who = name
+		}
+	}
+	return who, who != ""
+}
+
+// agentsHandler 处理 /agents：GET 列表（?state=&team=）或单条（?id=），POST 新建，PUT ?id= 修改，DELETE ?id= 删除。
+// 写操作写 agent_admin 审计；配置 admin token 时全部操作须带 X-Admin-Token。
+func (s *Server) agentsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		admin, ok := s.adminOf(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, errAdminTokenInvalid.Error())
+			return
+		}
+		ctx := r.Context()
+		id := r.URL.Query().Get("id")
+		switch r.Method {
+		case http.MethodGet:
+			if id != "" {
+				a, err := s.agents.Get(ctx, id)
+				if err != nil {
+					writeAgentError(w, err)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(NewAgentView(a))
+				return
+			}
+			list, err := s.agents.List(ctx)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "list failed")
+				return
+			}
+			state, team := r.URL.Query().Get("state"), r.URL.Query().Get("team")
+			items := make([]AgentView, 0, len(list))
+			for _, a := range list {
+				if (state == "" || string(a.State) == state) && (team == "" || a.Team == team) {
+					items = append(items, NewAgentView(a))
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		case http.MethodPost, http.MethodPut:
+			var in agentInput
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid body")
+				return
+			}
+			if r.Method == http.MethodPost {
+				id = in.ID
+			}
+			if id == "" {
+				writeJSONError(w, http.StatusBadRequest, "missing id")
+				return
+			}
+			a, credential, err := s.saveAgent(ctx, r.Method == http.MethodPost, id, &in, admin)
+			if err != nil {
+				writeAgentError(w, err)
+				return
+			}
+			v := NewAgentView(a)
+			v.Credential = credential
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+			}
+			_ = json.NewEncoder(w).Encode(v)
+		case http.MethodDelete:
+			if id == "" {
+				writeJSONError(w, http.StatusBadRequest, "missing id")
+				return
+			}
+			if err := s.agents.Delete(ctx, id); err != nil {
+				writeAgentError(w, err)
+				return
+			}
+			s.pipeline.clearAgentPolicy(id)
+			s.auditAgentAdmin(ctx, id, "agent_deleted", "agent deleted", admin)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// errAgentExists、errInvalidAgentState 为管理接口的输入错误。
+var (
+	errAgentExists       = errors.New("agent already exists")
+	errInvalidAgentState = errors.New("invalid agent state")
+)
+
+// saveAgent 新建或修改注册表条目；新建为 active 或要求轮换时签发凭证并返回（仅此一次）。
+// 已吊销的 Agent 不可再修改；转为 active 时挂载基线策略。
+func (s *Server) saveAgent(ctx context.Context, create bool, id string, in *agentInput, admin string) (*agent.Agent, string, error) {
+	p := s.pipeline
+	p.agentMu.Lock()
+	defer p.agentMu.Unlock()
+	a, err := s.agents.Get(ctx, id)
+	switch {
+	case create && err == nil:
+		return nil, "", errAgentExists
+	case create && err == agent.ErrNotFound:
+		a = &agent.Agent{ID: id, State: agent.StateActive, CreatedAt: time.Now()}
+	case err != nil:
+		return nil, "", err
+	case a.State == agent.StateRevoked:
+		return nil, "", agent.ErrRevoked
+	}
+	prevState := a.State
+	if in.OwnerID != nil {
+		a.OwnerID = *in.OwnerID
+	}
+	if in.Team != nil {
+		a.Team = *in.Team
+	}
+	if in.Labels != nil {
+		a.Labels = in.Labels
+	}
+	if in.State != "" {
+		if !agent.ValidState(agent.State(in.State)) {
+			return nil, "", errInvalidAgentState
+		}
+		a.State = agent.State(in.State)
+		if a.State != prevState {
+			// 手工改状态即结束紧急隔离
+			a.Quarantine = nil
+		}
+	}
+	var changes []string
+	credential := ""
+	if in.RotateCredential || (create && a.State == agent.StateActive) {
+		if credential, err = a.IssueCredential(); err != nil {
+			return nil, "", err
+		}
+		a.CredentialPending = false
+		a.CredentialDelivered = true
+		changes = append(changes, "credential issued")
+	}
+	if a.State == agent.StateActive && a.ActivatedAt.IsZero() {
+		a.ActivatedAt = time.Now()
+	}
+	if err := s.agents.Put(ctx, a); err != nil {
+		return nil, "", err
+	}
+	if a.State == agent.StateActive && (create || prevState != agent.StateActive) {
+		p.applyAgentPolicy(a.ID)
+	}
+	decision := "agent_updated"
+	if create {
+		decision = "agent_created"
+	}
+	if prevState != a.State {
+		changes = append(changes, "state "+string(prevState)+" -> "+string(a.State))
+	}
+	sort.Strings(changes)
+	reason := decision
+	if len(changes) > 0 {
+		reason += ": " + strings.Join(changes, ", ")
+	}
+	s.auditAgentAdmin(ctx, id, decision, reason, admin)
+	return a, credential, nil
+}
+
+// clearAgentPolicy 移除 Agent 的专属规则（删除 Agent 时）。
+func (p *pipeline) clearAgentPolicy(agentID string) {
+	if setter, ok := p.policy.(policy.AgentRuleSetter); ok {
+		setter.SetAgentRules(agentID, nil)
+	}
+}
+
+// auditAgentAdmin 记录一次注册表管理操作。
+func (s *Server) auditAgentAdmin(ctx context.Context, id, decision, reason, admin string) {
+	_ = s.audit.Append(ctx, &models.Evidence{
+		TraceID:        id,
+		AgentID:        id,
+		PolicyRuleID:   "agent_admin",
+		DecisionReason: reason,
+		Decision:       decision,
+		Confirmer:      admin,
+		Timestamp:      time.Now(),
+		Resource:       "agent://" + id,
+		Action:         "agent:admin",
+	})
+}
+
+// writeAgentError 将注册表错误映射为 HTTP 状态码。
+func writeAgentError(w http.ResponseWriter, err error) {
+	switch err {
+	case agent.ErrNotFound:
+		writeJSONError(w, http.StatusNotFound, "not found")
+	case errAgentExists, agent.ErrRevoked:
+		writeJSONError(w, http.StatusConflict, err.Error())
+	case errInvalidAgentState:
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSONError(w, http.StatusInternalServerError, "registry error")
+	}
+}
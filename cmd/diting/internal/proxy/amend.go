@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"diting/internal/models"
+)
+
+// errAmendBody 表示请求体不是 JSON 对象或过大，无法应用审批人的 body_patch。
+var errAmendBody = errors.New("request body is not a JSON object, cannot apply body_patch")
+
+// amendHTTPRequest 将审批人的 body_patch（JSON merge patch，RFC 7396）应用到 r 的请求体，并返回原请求体与改写后的差异。
+// 无改写时返回空差异。
+func amendHTTPRequest(r *http.Request, a *models.Amendment) (string, error) {
+	if a == nil || a.BodyPatch == "" {
+		return "", nil
+	}
+	var orig map[string]interface{}
+	if r.Body != nil && r.Body != http.NoBody {
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxFingerprintBody+1))
+		if err != nil || len(data) > maxFingerprintBody {
+			return "", errAmendBody
+		}
+		if len(bytes.TrimSpace(data)) > 0 && json.Unmarshal(data, &orig) != nil {
+			return "", errAmendBody
+		}
+	}
+	if orig == nil {
+		orig = map[string]interface{}{}
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal([]byte(a.BodyPatch), &patch); err != nil {
+		return "", err
+	}
+	amended := mergePatch(cloneJSON(orig), patch).(map[string]interface{})
+	body, err := json.Marshal(amended)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Header.Set("Content-Type", "application/json")
+	var lines []string
+	jsonDiff("", orig, amended, &lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+// execAmendment 返回审批人改写后的命令行及与原命令行的差异；无改写时均为空。
+func execAmendment(req *models.RequestContext, o *models.ConfirmationObject) (string, string) {
+	if o == nil || o.Amendment == nil || o.Amendment.CommandLine == "" {
+		return "", ""
+	}
+	amended := o.Amendment.CommandLine
+	return amended, "- " + req.TargetURL + "\n+ " + amended
+}
+
+// mergePatch 按 RFC 7396 将 patch 合并到 doc：null 删除键，对象递归合并，其余替换。
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergePatch(d[k], v)
+	}
+	return d
+}
+
+// cloneJSON 深拷贝 encoding/json 解码出的值，避免 mergePatch 改动原文档。
+func cloneJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			out[k] = cloneJSON(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = cloneJSON(e)
+		}
+		return out
+	}
+	return v
+}
+
+// jsonDiff 以 JSON Pointer 路径逐行记录 a 到 b 的差异："+ /path: 新值"、"- /path: 旧值"、"~ /path: 旧值 -> 新值"。
+func jsonDiff(path string, a, b interface{}, out *[]string) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok && bok {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			av, ina := am[k]
+			bv, inb := bm[k]
+			switch {
+			case !ina:
+				*out = append(*out, fmt.Sprintf("+ %s: %s", p, compactJSON(bv)))
+			case !inb:
+				*out = append(*out, fmt.Sprintf("- %s: %s", p, compactJSON(av)))
+			default:
+				jsonDiff(p, av, bv, out)
+			}
+		}
+		return
+	}
+	if ca, cb := compactJSON(a), compactJSON(b); ca != cb {
+		*out = append(*out, fmt.Sprintf("~ %s: %s -> %s", path, ca, cb))
+	}
+}
+
+func compactJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,153 @@
+// API Key 管理接口：GET/POST/DELETE /apikeys；明文 Key 仅在创建时返回一次。
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"diting/internal/apikey"
+	"diting/internal/models"
+)
+
+// APIKeyView 为 API Key 的 JSON 视图；不含盐与哈希，key 仅在创建时返回。
+type APIKeyView struct {
+	ID          string     `json:"id"`
+	AgentID     string     `json:"agent_id,omitempty"`
+	Actions     []string   `json:"actions,omitempty"`
+	Resources   []string   `json:"resources,omitempty"`
+	Description string     `json:"description,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	Expired     bool       `json:"expired,omitempty"`
+	Key         string     `json:"key,omitempty"`
+}
+
+// NewAPIKeyView 由存储记录构建对外视图（不含明文）。
+func NewAPIKeyView(k *apikey.Key, now time.Time) APIKeyView {
+	v := APIKeyView{
+		ID:          k.ID,
+		AgentID:     k.AgentID,
+		Actions:     k.Actions,
+		Resources:   k.Resources,
+		Description: k.Description,
+		CreatedAt:   k.CreatedAt,
+		Expired:     k.Expired(now),
+	}
+	if !k.ExpiresAt.IsZero() {
+		v.ExpiresAt = &k.ExpiresAt
+	}
+	if !k.LastUsedAt.IsZero() {
+		v.LastUsedAt = &k.LastUsedAt
+	}
+	return v
+}
+
+// apiKeyInput 为 POST /apikeys 的请求体；id 为空时随机生成，ttl_seconds 与 expires_at 二选一，均空表示不过期。
+type apiKeyInput struct {
+	ID          string    `json:"id"`
+	AgentID     string    `json:"agent_id"`
+	Actions     []string  `json:"actions"`
+	Resources   []string  `json:"resources"`
+	Description string    `json:"description"`
+	TTLSeconds  int       `json:"ttl_seconds"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// apiKeysHandler 处理 /apikeys：GET 列表（?agent_id=），POST 签发（201，响应含一次性明文 key），DELETE ?id= 吊销。
+// 写操作写 apikey_admin 审计；配置 admin token 时全部操作须带 X-Admin-Token。
+func (s *Server) apiKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		admin, ok := s.adminOf(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, errAdminTokenInvalid.Error())
+			return
+		}
+		ctx := r.Context()
+		now := time.Now()
+		switch r.Method {
+		case http.MethodGet:
+			list, err := s.apiKeys.List(ctx)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "list failed")
+				return
+			}
+			agentID := r.URL.Query().Get("agent_id")
+			items := make([]APIKeyView, 0, len(list))
+			for _, k := range list {
+				if agentID == "" || k.AgentID == agentID {
+					items = append(items, NewAPIKeyView(k, now))
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		case http.MethodPost:
+			var in apiKeyInput
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.TTLSeconds < 0 {
+				writeJSONError(w, http.StatusBadRequest, "invalid body")
+				return
+			}
+			if in.ID != "" {
+				if _, err := s.apiKeys.Get(ctx, in.ID); err == nil {
+					writeJSONError(w, http.StatusConflict, "api key already exists")
+					return
+				}
+			}
+			token, k, err := apikey.Generate(in.ID)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			k.AgentID, k.Actions, k.Resources, k.Description = in.AgentID, in.Actions, in.Resources, in.Description
+			k.ExpiresAt = in.ExpiresAt
+			if in.TTLSeconds > 0 {
+				k.ExpiresAt = now.Add(time.Duration(in.TTLSeconds) * time.Second)
+			}
+			if err := s.apiKeys.Put(ctx, k); err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "store error")
+				return
+			}
+			s.auditKeyAdmin(ctx, k, "apikey_created", admin)
+			v := NewAPIKeyView(k, now)
+			v.Key = token
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(v)
+		case http.MethodDelete:
+			id := r.URL.Query().Get("id")
+			k, err := s.apiKeys.Get(ctx, id)
+			if err == nil {
+				err = s.apiKeys.Delete(ctx, id)
+			}
+			if err == apikey.ErrNotFound {
+				writeJSONError(w, http.StatusNotFound, "not found")
+				return
+			}
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "store error")
+				return
+			}
+			s.auditKeyAdmin(ctx, k, "apikey_deleted", admin)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// auditKeyAdmin 记录一次 API Key 管理操作；trace_id 为 key id，审计只含 key id 与映射的 Agent。
+func (s *Server) auditKeyAdmin(ctx context.Context, k *apikey.Key, decision, admin string) {
+	_ = s.audit.Append(ctx, &models.Evidence{
+		TraceID:        "apikey:" + k.ID,
+		AgentID:        k.AgentID,
+		PolicyRuleID:   "apikey_admin",
+		DecisionReason: decision + " " + k.ID,
+		Decision:       decision,
+		Confirmer:      admin,
+		Timestamp:      time.Now(),
+		Resource:       "apikey://" + k.ID,
+		Action:         "apikey:admin",
+	})
+}
@@ -1,12 +1,18 @@
-// Package proxy 实现 AuthStream 长连接（Story 8.4）：WebSocket 握手、鉴权请求、异步 approval_push。
+// AuthStream 长连接（Story 8.4）：WebSocket 握手、鉴权请求、异步 approval_push、服务端 profile_update 推送。
+// 连接按 Init 上报的 client_id、resource 登记：握手即下发该 resource 的 profile，profile 配置变更或紧急隔离时推送 profile_update；
+// GET /streams 列出当前连接的 Node Agent。
+
 package proxy
 
 import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 
+	"diting/internal/cheq"
 	"diting/internal/models"
 
 	"github.com/google/uuid"
@@ -23,6 +29,14 @@ type AuthStreamRequest struct {
 	Init      *AuthStreamInit         `json:"init,omitempty"`
 	Auth      *ExecAuthRequest        `json:"auth,omitempty"`
 	Ping      string                  `json:"ping,omitempty"`
+	Cancel    *AuthStreamCancel       `json:"cancel,omitempty"`
+}
+
+// AuthStreamCancel 撤回本连接上发起、仍待审批的 CHEQ；成功时该 CHEQ 的 approval_push 以 deny（cancelled）结束，
+// 失败时以 immediate deny 应答本条消息。
+type AuthStreamCancel struct {
+	CheqID string `json:"cheq_id"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // AuthStreamInit 握手包。
@@ -46,18 +60,199 @@ type AuthStreamApprovalPush struct {
 	CheqID        string `json:"cheq_id"`
 	FinalDecision string `json:"final_decision"` // allow | deny
 	Reason        string `json:"reason,omitempty"`
+
+	AmendedCommandLine string `json:"amended_command_line,omitempty"` // 审批人改后批准时应执行的命令行
+}
+
+// streamConn 为一条 AuthStream 连接（WebSocket 或 gRPC 双向流）；写操作串行化（读循环、approval_push 与 profile_update 推送并发写）。
+type streamConn struct {
+	write     func(AuthStreamResponse) error
+	wmu       sync.Mutex
+	transport string // websocket | grpc
+
+	// 以下受 Server.streamsMu 保护
+	agentID        string // 已由注册表解析出的 Agent，供紧急隔离时定向推送
+	clientID       string // Init 上报的 client_id、resource、agent_version；未握手时为空
+	resource       string
+	agentVersion   string
+	connectedAt    time.Time
+	lastHeartbeat  time.Time // 最近一次收到客户端消息（ping、init 或 auth）
+	profileID      string    // 最近下发的 profile，配置变更时据 version 判断是否需要推送
+	profileVersion string
+}
+
+// StreamClientView GET /streams 返回的一条 AuthStream 连接。
+type StreamClientView struct {
+	ClientID       string    `json:"client_id,omitempty"`
+	Resource       string    `json:"resource,omitempty"`
+	AgentVersion   string    `json:"agent_version,omitempty"`
+	AgentID        string    `json:"agent_id,omitempty"`
+	Transport      string    `json:"transport"`
+	ProfileID      string    `json:"profile_id,omitempty"`
+	ProfileVersion string    `json:"profile_version,omitempty"`
+	ConnectedAt    time.Time `json:"connected_at"`
+	LastHeartbeat  time.Time `json:"last_heartbeat"`
+}
+
+func (c *streamConn) send(out AuthStreamResponse) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.write(out)
+}
+
+// trackStream 登记连接，返回注销函数。
+func (s *Server) trackStream(c *streamConn) func() {
+	s.streamsMu.Lock()
+	if s.streams == nil {
+		s.streams = make(map[*streamConn]struct{})
+	}
+	c.connectedAt = time.Now()
+	c.lastHeartbeat = c.connectedAt
+	s.streams[c] = struct{}{}
+	s.streamsMu.Unlock()
+	return func() {
+		s.streamsMu.Lock()
+		delete(s.streams, c)
+		s.streamsMu.Unlock()
+	}
+}
+
+// bindStream 记录连接上解析出的 Agent。
+func (s *Server) bindStream(c *streamConn, agentID string) {
+	s.streamsMu.Lock()
+	c.agentID = agentID
+	s.streamsMu.Unlock()
+}
+
+// touchStream 记录收到客户端消息的时间。
+func (s *Server) touchStream(c *streamConn) {
+	s.streamsMu.Lock()
+	c.lastHeartbeat = time.Now()
+	s.streamsMu.Unlock()
+}
+
+// sendProfile 向连接下发 profile_update 并记录已下发的版本。
+func (s *Server) sendProfile(c *streamConn, requestID string, profile *SandboxProfile) error {
+	if err := sendStreamResp(c, requestID, nil, nil, profile, ""); err != nil {
+		return err
+	}
+	s.streamsMu.Lock()
+	c.profileID, c.profileVersion = profile.ProfileID, profile.Version
+	s.streamsMu.Unlock()
+	return nil
+}
+
+// pushProfile 向 match 命中的连接推送同一 profile_update，返回推送成功的连接数。
+func (s *Server) pushProfile(match func(agentID string) bool, profile *SandboxProfile) int {
+	return s.pushProfiles(func(c *streamConn) *SandboxProfile {
+		if match(c.agentID) {
+			return profile
+		}
+		return nil
+	})
+}
+
+// pushProfiles 向 profileFor 返回非 nil 的连接推送其 profile_update（profileFor 在 streamsMu 下调用），返回推送成功的连接数。
+func (s *Server) pushProfiles(profileFor func(c *streamConn) *SandboxProfile) int {
+	type target struct {
+		c       *streamConn
+		profile *SandboxProfile
+	}
+	s.streamsMu.Lock()
+	var targets []target
+	for c := range s.streams {
+		if p := profileFor(c); p != nil {
+			targets = append(targets, target{c, p})
+		}
+	}
+	s.streamsMu.Unlock()
+	n := 0
+	for _, t := range targets {
+		if err := s.sendProfile(t.c, uuid.New().String(), t.profile); err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// refreshProfiles 在 profile 配置变更后，向已握手的连接推送其 resource 当前命中的 profile；
+// version 未变或处于紧急隔离（最近下发 quarantine）的连接跳过。返回推送成功的连接数。
+func (s *Server) refreshProfiles() int {
+	return s.pushProfiles(func(c *streamConn) *SandboxProfile {
+		if c.resource == "" || c.profileID == "quarantine" {
+			return nil
+		}
+		p := s.pipeline.profiles.match(c.resource)
+		if p.Version == c.profileVersion {
+			return nil
+		}
+		return &p
+	})
+}
+
+// streamClients 返回当前连接，按 client_id、建立时间排序。
+func (s *Server) streamClients() []StreamClientView {
+	s.streamsMu.Lock()
+	out := make([]StreamClientView, 0, len(s.streams))
+	for c := range s.streams {
+		out = append(out, StreamClientView{
+			ClientID:       c.clientID,
+			Resource:       c.resource,
+			AgentVersion:   c.agentVersion,
+			AgentID:        c.agentID,
+			Transport:      c.transport,
+			ProfileID:      c.profileID,
+			ProfileVersion: c.profileVersion,
+			ConnectedAt:    c.connectedAt,
+			LastHeartbeat:  c.lastHeartbeat,
+		})
+	}
+	s.streamsMu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ClientID != out[j].ClientID {
+			return out[i].ClientID < out[j].ClientID
+		}
+		return out[i].ConnectedAt.Before(out[j].ConnectedAt)
+	})
+	return out
+}
+
+// streamsHandler 处理 GET /streams：列出当前 AuthStream 连接的 Node Agent（client_id、resource、版本、最近心跳、已下发 profile）。
+// 配置 admin token 时须带 X-Admin-Token。
+func (s *Server) streamsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if _, ok := s.adminOf(r); !ok {
+			writeJSONError(w, http.StatusUnauthorized, errAdminTokenInvalid.Error())
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": s.streamClients()})
+	}
 }
 
 func (s *Server) authStreamHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn, err := upgrader.Upgrade(w, r, nil)
+		ws, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			return
 		}
-		defer conn.Close()
+		defer ws.Close()
+		conn := &streamConn{transport: "websocket", write: func(out AuthStreamResponse) error { return ws.WriteJSON(out) }}
+		// 签名在升级请求上校验一次（nonce 仅可使用一次），结果用于该连接上的每条授权请求
+		sigDID, sigErr := s.checkSignature(r)
+		st := s.openStream(conn, requestCredential(r), func(reqCtx *models.RequestContext) {
+			s.applyClientCert(r, reqCtx)
+			s.applyPeerCred(r, reqCtx)
+			reqCtx.SignatureDID, reqCtx.SignatureError = sigDID, sigErr
+		})
+		defer st.close()
 		ctx := r.Context()
 		for {
-			_, data, err := conn.ReadMessage()
+			_, data, err := ws.ReadMessage()
 			if err != nil {
 				break
 			}
@@ -66,43 +261,140 @@ func (s *Server) authStreamHandler() http.HandlerFunc {
 				_ = sendStreamResp(conn, req.RequestID, nil, nil, nil, "invalid json")
 				continue
 			}
-			if req.RequestID == "" {
-				req.RequestID = uuid.New().String()
-			}
-			if req.Init != nil {
-				_ = sendStreamResp(conn, req.RequestID, nil, nil, nil, "pong")
-				continue
-			}
-			if req.Ping != "" {
-				_ = sendStreamResp(conn, req.RequestID, nil, nil, nil, "pong")
-				continue
-			}
-			if req.Auth != nil {
-				traceID := req.Auth.TraceID
-				if traceID == "" {
-					traceID = uuid.New().String()
-				}
-				agentIdentity := req.Auth.Subject
-				reqCtx := BuildRequestContextFromExec(req.Auth, agentIdentity)
-				if reqCtx == nil {
-					_ = sendStreamResp(conn, req.RequestID, &ExecAuthResponse{Decision: "deny", Reason: "missing subject/action/resource"}, nil, nil, "")
-					continue
-				}
-				resp, auditInfo, err := s.pipeline.ExecEvaluateNonBlocking(ctx, traceID, reqCtx)
-				if err != nil {
-					_ = sendStreamResp(conn, req.RequestID, &ExecAuthResponse{Decision: "deny", Reason: "evaluate failed"}, nil, nil, "")
-					continue
-				}
-				_ = sendStreamResp(conn, req.RequestID, resp, nil, nil, "")
-				if resp != nil && resp.Decision == "review" && resp.CheqID != "" && auditInfo != nil {
-					go waitAndPushApproval(ctx, s.pipeline, conn, req.RequestID, traceID, reqCtx, resp.CheqID, resp.ApprovalTimeoutSec, auditInfo.PolicyRuleID, auditInfo.DecisionReason)
-				}
-			}
+			st.handle(ctx, &req)
+		}
+	}
+}
+
+// authStream 为一条 AuthStream 会话：WebSocket 与 gRPC 共用的消息处理。
+type authStream struct {
+	s         *Server
+	conn      *streamConn
+	sessionID string
+	// credential 为建立连接时携带的 L0 凭证：每条授权请求据此认证，握手时据此判断是否下发 quarantine profile
+	credential string
+	// identify 为每条授权请求写入连接级身份（客户端证书、签名等）
+	identify func(*models.RequestContext)
+	untrack  func()
+
+	mu      sync.Mutex
+	pending map[string]string // 本连接上待审批的 CHEQ -> 发起方，供 cancel 撤回
+}
+
+// openStream 登记连接并开启会话；每条连接为一个会话：「本会话批准」的授权绑定连接，close 时撤销。
+func (s *Server) openStream(conn *streamConn, credential string, identify func(*models.RequestContext)) *authStream {
+	return &authStream{s: s, conn: conn, sessionID: uuid.New().String(), credential: credential, identify: identify, untrack: s.trackStream(conn)}
+}
+
+func (st *authStream) close() {
+	st.untrack()
+	if st.s.pipeline.grants != nil {
+		st.s.pipeline.grants.RevokeSession(context.Background(), st.sessionID, "session_closed")
+	}
+}
+
+// handle 处理一条客户端消息：Init 登记 client_id、resource 并以 profile_update 应答；review 时在后台等待 CHEQ 终态并推送 approval_push。
+func (st *authStream) handle(ctx context.Context, req *AuthStreamRequest) {
+	s, conn := st.s, st.conn
+	if req.RequestID == "" {
+		req.RequestID = uuid.New().String()
+	}
+	s.touchStream(conn)
+	if in := req.Init; in != nil {
+		resource := in.Resource
+		if resource == "" {
+			resource = "local://default"
+		}
+		s.streamsMu.Lock()
+		conn.clientID, conn.resource, conn.agentVersion = in.ClientID, resource, in.AgentVersion
+		s.streamsMu.Unlock()
+		profileReq := profileRequest(st.credential, resource)
+		st.identify(profileReq)
+		profile := s.sandboxProfile(ctx, profileReq)
+		if profileReq.AgentID != "" {
+			s.bindStream(conn, profileReq.AgentID)
+		}
+		_ = s.sendProfile(conn, req.RequestID, &profile)
+		return
+	}
+	if req.Ping != "" {
+		_ = sendStreamResp(conn, req.RequestID, nil, nil, nil, "pong")
+		return
+	}
+	if req.Cancel != nil {
+		st.withdraw(ctx, req.RequestID, req.Cancel)
+		return
+	}
+	if req.Auth == nil {
+		return
+	}
+	traceID := req.Auth.TraceID
+	if traceID == "" {
+		traceID = uuid.New().String()
+	}
+	// 与 /auth/exec 一致：以建立连接时携带的 L0 凭证认证，未携带时以 subject 作自报身份
+	agentIdentity := st.credential
+	if agentIdentity == "" {
+		agentIdentity = req.Auth.Subject
+	}
+	reqCtx := BuildRequestContextFromExec(req.Auth, agentIdentity)
+	if reqCtx == nil {
+		_ = sendStreamResp(conn, req.RequestID, &ExecAuthResponse{Decision: "deny", Reason: "missing subject/action/resource"}, nil, nil, "")
+		return
+	}
+	reqCtx.SessionID = st.sessionID
+	if st.identify != nil {
+		st.identify(reqCtx)
+	}
+	resp, auditInfo, err := s.pipeline.ExecEvaluateNonBlocking(ctx, traceID, reqCtx)
+	if reqCtx.AgentID != "" {
+		s.bindStream(conn, reqCtx.AgentID)
+	}
+	if err != nil {
+		_ = sendStreamResp(conn, req.RequestID, &ExecAuthResponse{Decision: "deny", Reason: "evaluate failed"}, nil, nil, "")
+		return
+	}
+	_ = sendStreamResp(conn, req.RequestID, resp, nil, nil, "")
+	if resp != nil && resp.Decision == "review" && resp.CheqID != "" && auditInfo != nil {
+		requester, _ := s.pipeline.requesterOf(reqCtx)
+		st.mu.Lock()
+		if st.pending == nil {
+			st.pending = make(map[string]string)
 		}
+		st.pending[resp.CheqID] = requester
+		st.mu.Unlock()
+		go func() {
+			waitAndPushApproval(ctx, s.pipeline, conn, req.RequestID, traceID, reqCtx, resp.CheqID, auditInfo.PolicyRuleID, auditInfo.DecisionReason)
+			st.mu.Lock()
+			delete(st.pending, resp.CheqID)
+			st.mu.Unlock()
+		}()
 	}
 }
 
-func sendStreamResp(conn *websocket.Conn, requestID string, immediate *ExecAuthResponse, approvalPush *AuthStreamApprovalPush, profileUpdate *SandboxProfile, pong string) error {
+// withdraw 以发起方身份撤回本连接上待审批的 CHEQ（cheq.Engine.Withdraw；发起方未解析时为 Cancel）；其他连接或已终态的 CHEQ 以 immediate deny 应答。
+func (st *authStream) withdraw(ctx context.Context, requestID string, in *AuthStreamCancel) {
+	st.mu.Lock()
+	requester, ok := st.pending[in.CheqID]
+	st.mu.Unlock()
+	reason := in.Reason
+	if reason == "" {
+		reason = "withdrawn by requester"
+	}
+	err := cheq.ErrNotFound
+	switch {
+	case ok && requester != "":
+		err = st.s.cheq.Withdraw(ctx, in.CheqID, requester, reason)
+	case ok:
+		// 发起方未经 L0 解析、CHEQ 上没有可比对的主体：由本连接发起即视为发起方，以会话身份撤销
+		err = st.s.cheq.Cancel(ctx, in.CheqID, "session:"+st.sessionID, reason)
+	}
+	if err != nil {
+		_ = sendStreamResp(st.conn, requestID, &ExecAuthResponse{Decision: "deny", CheqID: in.CheqID, Reason: "cancel failed: " + err.Error()}, nil, nil, "")
+	}
+}
+
+func sendStreamResp(conn *streamConn, requestID string, immediate *ExecAuthResponse, approvalPush *AuthStreamApprovalPush, profileUpdate *SandboxProfile, pong string) error {
 	out := AuthStreamResponse{RequestID: requestID}
 	if immediate != nil {
 		out.Immediate = immediate
@@ -116,29 +408,15 @@ func sendStreamResp(conn *websocket.Conn, requestID string, immediate *ExecAuthR
 	if pong != "" {
 		out.Pong = pong
 	}
-	return conn.WriteJSON(out)
+	return conn.send(out)
 }
 
-func waitAndPushApproval(ctx context.Context, pl *pipeline, conn *websocket.Conn, requestID, traceID string, reqCtx *models.RequestContext, cheqID string, timeoutSec int32, policyRuleID, decisionReason string) {
-	if timeoutSec <= 0 {
-		timeoutSec = 300
-	}
-	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
-	var finalStatus string
-	var confirmerIDs []string
-	for time.Now().Before(deadline) {
-		o, _ := pl.GetCHEQByID(ctx, cheqID)
-		if o != nil && o.IsTerminal() {
-			finalStatus = string(o.Status)
-			confirmerIDs = o.ConfirmerIDs
-			break
-		}
-		time.Sleep(2 * time.Second)
-	}
+func waitAndPushApproval(ctx context.Context, pl *pipeline, conn *streamConn, requestID, traceID string, reqCtx *models.RequestContext, cheqID string, policyRuleID, decisionReason string) {
+	finalStatus, o := pl.WaitCHEQ(ctx, cheqID)
 	if finalStatus == "" {
 		finalStatus = "expired"
 	}
-	pl.RecordCHEQDecision(ctx, traceID, reqCtx, policyRuleID, decisionReason, cheqID, finalStatus, confirmerIDs)
+	amended := pl.RecordCHEQDecision(ctx, traceID, reqCtx, policyRuleID, cheqDecisionReason(decisionReason, o), finalStatus, o)
 	decision := "deny"
 	if finalStatus == string(models.ConfirmationStatusApproved) {
 		decision = "allow"
@@ -146,6 +424,8 @@ func waitAndPushApproval(ctx context.Context, pl *pipeline, conn *websocket.Conn
 	_ = sendStreamResp(conn, requestID, nil, &AuthStreamApprovalPush{
 		CheqID:        cheqID,
 		FinalDecision: decision,
-		Reason:        "confirmation " + finalStatus,
+		Reason:        confirmationReason(finalStatus, o),
+
+		AmendedCommandLine: amended,
 	}, nil, "")
 }
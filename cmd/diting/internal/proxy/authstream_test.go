@@ -1,13 +1,21 @@
 package proxy
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"diting/internal/audit"
 	"diting/internal/cheq"
 	"diting/internal/config"
 	"diting/internal/delivery"
+	"diting/internal/models"
 	"diting/internal/ownership"
 	"diting/internal/policy"
 
@@ -20,7 +28,10 @@ func TestAuthStream_InitAndAuthAllow(t *testing.T) {
 	cfg.Proxy.Upstream = "http://localhost:9999"
 	cfg.Proxy.AllowedAPIKeys = nil
 	cfg.CHEQ.TimeoutSeconds = 60
-	srv := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	srv, err := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
 	ts := httptest.NewServer(srv.Handler())
 	defer ts.Close()
 
@@ -42,8 +53,8 @@ func TestAuthStream_InitAndAuthAllow(t *testing.T) {
 	if err := conn.ReadJSON(&resp); err != nil {
 		t.Fatalf("read init resp: %v", err)
 	}
-	if resp.Pong != "pong" {
-		t.Errorf("expected pong, got %q", resp.Pong)
+	if resp.RequestID != "req-1" || resp.ProfileUpdate == nil || resp.ProfileUpdate.ProfileID != "default" {
+		t.Errorf("expected profile_update for init, got %+v", resp)
 	}
 
 	// 鉴权 auth（Stub 策略恒 allow）
@@ -68,3 +79,190 @@ func TestAuthStream_InitAndAuthAllow(t *testing.T) {
 		t.Errorf("expected decision allow, got %q", resp.Immediate.Decision)
 	}
 }
+
+func TestAuthStream_AuthUsesConnectionCredential(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Proxy.AllowedAPIKeys = []string{"stream-key"}
+	srv, err := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	auth := func(token string) *ExecAuthResponse {
+		h := http.Header{}
+		if token != "" {
+			h.Set("X-Agent-Token", token)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+ts.URL[4:]+"/auth/stream", h)
+		if err != nil {
+			t.Fatalf("websocket dial: %v", err)
+		}
+		defer conn.Close()
+		_ = conn.WriteJSON(AuthStreamRequest{RequestID: "auth", Auth: &ExecAuthRequest{Subject: "alice", Action: "exec:run", Resource: "local://host", CommandLine: "echo ok"}})
+		var resp AuthStreamResponse
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return resp.Immediate
+	}
+	// 凭证只在握手头中携带，auth.subject 为本地用户名
+	if got := auth("stream-key"); got == nil || got.Decision != "allow" {
+		t.Errorf("header key: %+v", got)
+	}
+	if got := auth("wrong-key"); got == nil || got.Decision != "deny" {
+		t.Errorf("invalid header key: %+v", got)
+	}
+	if got := auth(""); got == nil || got.Decision != "deny" {
+		t.Errorf("self-reported subject with keys configured: %+v", got)
+	}
+}
+
+func TestAuthStreamProfileReloadAndClients(t *testing.T) {
+	profiles := []config.SandboxProfileConfig{
+		{ID: "project-a", Resource: "docker://project-a/*"},
+		{ID: "host-01", Resource: "local://host-01", DegradationPolicy: "FAIL_OPEN"},
+	}
+	srv, err := NewServer(&config.Config{}, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := srv.SetSandboxProfiles(profiles); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetAdminTokens(map[string]string{"ops": "tok-ops"})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	dial := func(clientID, resource string) (*websocket.Conn, *SandboxProfile) {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+ts.URL[4:]+"/auth/stream", nil)
+		if err != nil {
+			t.Fatalf("websocket dial: %v", err)
+		}
+		_ = conn.WriteJSON(AuthStreamRequest{RequestID: "init", Init: &AuthStreamInit{ClientID: clientID, Resource: resource, AgentVersion: "1.2.0"}})
+		var resp AuthStreamResponse
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&resp); err != nil || resp.ProfileUpdate == nil {
+			t.Fatalf("init %s: %+v %v", clientID, resp, err)
+		}
+		return conn, resp.ProfileUpdate
+	}
+	connA, profA := dial("node-a", "docker://project-a/web")
+	defer connA.Close()
+	connB, profB := dial("node-b", "local://host-01")
+	defer connB.Close()
+	if profA.ProfileID != "project-a" || profB.ProfileID != "host-01" {
+		t.Fatalf("init profiles: %+v %+v", profA, profB)
+	}
+
+	// 仅 project-a 内容变化：node-a 收到新版本，node-b 不收到推送（下一条消息为 pong）
+	profiles[0].Boundary.ReadonlyRoot = true
+	if err := srv.SetSandboxProfiles(profiles); err != nil {
+		t.Fatal(err)
+	}
+	var resp AuthStreamResponse
+	_ = connA.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := connA.ReadJSON(&resp); err != nil || resp.ProfileUpdate == nil || resp.ProfileUpdate.Version == profA.Version || !resp.ProfileUpdate.Boundary.ReadonlyRoot {
+		t.Fatalf("node-a profile_update: %+v %v", resp.ProfileUpdate, err)
+	}
+	newVersion := resp.ProfileUpdate.Version
+	_ = connB.WriteJSON(AuthStreamRequest{RequestID: "ping", Ping: "hb"})
+	resp = AuthStreamResponse{}
+	_ = connB.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := connB.ReadJSON(&resp); err != nil || resp.Pong != "pong" || resp.ProfileUpdate != nil {
+		t.Fatalf("node-b: %+v %v", resp, err)
+	}
+
+	list := func(token string) (int, []StreamClientView) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/streams", nil)
+		req.Header.Set("X-Admin-Token", token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var out struct {
+			Items []StreamClientView `json:"items"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&out)
+		return res.StatusCode, out.Items
+	}
+	if code, _ := list("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("/streams without admin token: code=%d", code)
+	}
+	code, items := list("tok-ops")
+	if code != http.StatusOK || len(items) != 2 {
+		t.Fatalf("/streams: code=%d items=%+v", code, items)
+	}
+	a, b := items[0], items[1]
+	if a.ClientID != "node-a" || a.Resource != "docker://project-a/web" || a.AgentVersion != "1.2.0" || a.Transport != "websocket" ||
+		a.ProfileID != "project-a" || a.ProfileVersion != newVersion {
+		t.Errorf("node-a = %+v", a)
+	}
+	if b.ClientID != "node-b" || b.ProfileVersion != profB.Version || !b.LastHeartbeat.After(b.ConnectedAt) {
+		t.Errorf("node-b = %+v", b)
+	}
+}
+
+func TestAuthStreamCancelWithdrawsPendingCHEQ(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: review-all\n    decision: review\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	srv, err := NewServer(&config.Config{}, pe, eng, &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+ts.URL[4:]+"/auth/stream", nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	defer conn.Close()
+	read := func() AuthStreamResponse {
+		var resp AuthStreamResponse
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return resp
+	}
+	_ = conn.WriteJSON(AuthStreamRequest{RequestID: "init", Init: &AuthStreamInit{ClientID: "node", Resource: "local://host"}})
+	read()
+	_ = conn.WriteJSON(AuthStreamRequest{RequestID: "auth", Auth: &ExecAuthRequest{Subject: "alice", Action: "exec:run", Resource: "local://host", CommandLine: "rm -rf /tmp/x"}})
+	resp := read()
+	if resp.Immediate == nil || resp.Immediate.Decision != "review" || resp.Immediate.CheqID == "" {
+		t.Fatalf("auth: %+v", resp.Immediate)
+	}
+	cheqID := resp.Immediate.CheqID
+
+	// 非本连接发起的 CHEQ 不可撤回
+	_ = conn.WriteJSON(AuthStreamRequest{RequestID: "cancel-other", Cancel: &AuthStreamCancel{CheqID: "cheq-other"}})
+	if resp = read(); resp.RequestID != "cancel-other" || resp.Immediate == nil || !strings.HasPrefix(resp.Immediate.Reason, "cancel failed") {
+		t.Fatalf("cancel unknown cheq: %+v", resp)
+	}
+
+	// 撤回后以 approval_push deny 结束，CHEQ 终态为 cancelled
+	_ = conn.WriteJSON(AuthStreamRequest{RequestID: "cancel", Cancel: &AuthStreamCancel{CheqID: cheqID, Reason: "ctrl-c"}})
+	resp = read()
+	if p := resp.ApprovalPush; p == nil || p.CheqID != cheqID || p.FinalDecision != "deny" || !strings.Contains(p.Reason, "cancelled") {
+		t.Fatalf("approval_push after cancel: %+v", resp)
+	}
+	if o, _ := eng.GetByID(context.Background(), cheqID); o == nil || o.Status != models.ConfirmationStatusCancelled {
+		t.Errorf("cheq after withdraw: %+v", o)
+	}
+
+	// 已终态的 CHEQ 再次撤回失败
+	_ = conn.WriteJSON(AuthStreamRequest{RequestID: "cancel-again", Cancel: &AuthStreamCancel{CheqID: cheqID}})
+	if resp = read(); resp.Immediate == nil || !strings.HasPrefix(resp.Immediate.Reason, "cancel failed") {
+		t.Errorf("second cancel: %+v", resp)
+	}
+}
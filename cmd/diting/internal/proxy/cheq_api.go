@@ -0,0 +1,218 @@
+// CHEQ 查询接口：GET /cheq/objects 按条件列出待确认对象，供审批人与看板查看队列。
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diting/internal/cheq"
+	"diting/internal/models"
+)
+
+// CHEQObjectView 为 ConfirmationObject 的对外 JSON 视图（snake_case，与审计、exec 接口一致）。
+type CHEQObjectView struct {
+	ID             string    `json:"id"`
+	TraceID        string    `json:"trace_id"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	Resource       string    `json:"resource,omitempty"`
+	Action         string    `json:"action,omitempty"`
+	Summary        string    `json:"summary,omitempty"`
+	ConfirmerIDs   []string  `json:"confirmer_ids,omitempty"`
+	Type           string    `json:"type,omitempty"`
+	ApprovedBy     []string  `json:"approved_by,omitempty"`
+	ApprovalPolicy string    `json:"approval_policy,omitempty"`
+
+	EscalationLevel int                   `json:"escalation_level,omitempty"`
+	OnTimeout       string                `json:"on_timeout,omitempty"`
+	TimeoutAction   string                `json:"timeout_action,omitempty"`
+	Transitions     []StageTransitionView `json:"transitions,omitempty"`
+	RejectMode      string                `json:"reject_mode,omitempty"`
+	Votes           []VoteView            `json:"votes,omitempty"`
+	Waiters         []string              `json:"waiters,omitempty"`
+
+	DecisionComment    string         `json:"decision_comment,omitempty"`
+	DecisionReasonCode string         `json:"decision_reason_code,omitempty"`
+	Amendment          *AmendmentView `json:"amendment,omitempty"`
+	Requester          string         `json:"requester,omitempty"`
+	CancelledBy        string         `json:"cancelled_by,omitempty"`
+
+	Stage      string              `json:"stage,omitempty"` // 多阶段流程的当前阶段名
+	StageIndex int                 `json:"stage_index,omitempty"`
+	Stages     []WorkflowStageView `json:"stages,omitempty"`
+}
+
+// WorkflowStageView 为流程阶段的 JSON 视图。
+type WorkflowStageView struct {
+	Name           string   `json:"name"`
+	ConfirmerIDs   []string `json:"confirmer_ids,omitempty"`
+	ApprovalPolicy string   `json:"approval_policy,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+}
+
+// VoteView 为投票记录的 JSON 视图。
+type VoteView struct {
+	ConfirmerID string    `json:"confirmer_id"`
+	Decision    string    `json:"decision"`
+	Role        string    `json:"role,omitempty"`
+	At          time.Time `json:"at"`
+
+	GrantScope   string `json:"grant_scope,omitempty"`
+	GrantMinutes int    `json:"grant_minutes,omitempty"`
+	Comment      string `json:"comment,omitempty"`
+	ReasonCode   string `json:"reason_code,omitempty"`
+	Stage        string `json:"stage,omitempty"`
+}
+
+// AmendmentView 为改后批准的 JSON 视图；body_patch 原样输出为 JSON。
+type AmendmentView struct {
+	CommandLine string          `json:"command_line,omitempty"`
+	BodyPatch   json.RawMessage `json:"body_patch,omitempty"`
+	By          string          `json:"by,omitempty"`
+	At          time.Time       `json:"at"`
+}
+
+// StageTransitionView 为阶段流转记录的 JSON 视图。
+type StageTransitionView struct {
+	Stage        string    `json:"stage"`
+	At           time.Time `json:"at"`
+	ConfirmerIDs []string  `json:"confirmer_ids,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// CHEQListResponse 为 GET /cheq/objects 的响应；next_cursor 为空表示没有更多。
+type CHEQListResponse struct {
+	Items      []CHEQObjectView `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// NewCHEQObjectView 由 ConfirmationObject 构建对外视图。
+func NewCHEQObjectView(o *models.ConfirmationObject) CHEQObjectView {
+	v := CHEQObjectView{
+		ID:             o.ID,
+		TraceID:        o.TraceID,
+		Status:         string(o.Status),
+		CreatedAt:      o.CreatedAt,
+		ExpiresAt:      o.ExpiresAt,
+		Resource:       o.Resource,
+		Action:         o.Action,
+		Summary:        o.Summary,
+		ConfirmerIDs:   o.ConfirmerIDs,
+		Type:           o.Type,
+		ApprovedBy:     o.ApprovedBy,
+		ApprovalPolicy: o.ApprovalPolicy,
+
+		EscalationLevel: o.EscalationLevel,
+		OnTimeout:       o.OnTimeout,
+		TimeoutAction:   o.TimeoutAction,
+		RejectMode:      o.RejectMode,
+		Waiters:         o.Waiters,
+
+		DecisionComment:    o.DecisionComment,
+		DecisionReasonCode: o.DecisionReasonCode,
+		Requester:          o.Requester,
+		CancelledBy:        o.CancelledBy,
+
+		Stage:      o.CurrentStage(),
+		StageIndex: o.StageIndex,
+	}
+	for _, vt := range o.Votes {
+		v.Votes = append(v.Votes, VoteView{ConfirmerID: vt.ConfirmerID, Decision: vt.Decision, Role: vt.Role, At: vt.At, GrantScope: vt.GrantScope, GrantMinutes: vt.GrantMinutes, Comment: vt.Comment, ReasonCode: vt.ReasonCode, Stage: vt.Stage})
+	}
+	for _, st := range o.Stages {
+		v.Stages = append(v.Stages, WorkflowStageView{Name: st.Name, ConfirmerIDs: st.ConfirmerIDs, ApprovalPolicy: st.ApprovalPolicy, TimeoutSeconds: int(st.Timeout / time.Second)})
+	}
+	if a := o.Amendment; a != nil {
+		v.Amendment = &AmendmentView{CommandLine: a.CommandLine, By: a.By, At: a.At}
+		if a.BodyPatch != "" {
+			v.Amendment.BodyPatch = json.RawMessage(a.BodyPatch)
+		}
+	}
+	for _, t := range o.Transitions {
+		v.Transitions = append(v.Transitions, StageTransitionView{Stage: t.Stage, At: t.At, ConfirmerIDs: t.ConfirmerIDs, Reason: t.Reason})
+	}
+	return v
+}
+
+// cheqListHandler 处理 GET /cheq/objects?status=&confirmer=&requester=&resource_prefix=&type=&created_after=&created_before=&cursor=&limit=。
+// 时间参数支持 RFC3339 或 Unix 秒。须带 X-Admin-Token 或 X-Approver-Token（见 approvalViewer）；以 approver token 访问时
+// 仅返回本人为确认人的对象。
+func (s *Server) cheqListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		who, approver, ok := s.approvalViewer(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, errApproverAuthRequired.Error())
+			return
+		}
+		q := r.URL.Query()
+		filter := &cheq.ListFilter{
+			Status:         models.ConfirmationStatus(q.Get("status")),
+			ConfirmerID:    q.Get("confirmer"),
+			Requester:      q.Get("requester"),
+			ResourcePrefix: q.Get("resource_prefix"),
+			Type:           q.Get("type"),
+			Cursor:         q.Get("cursor"),
+		}
+		if approver {
+			filter.ConfirmerID = who
+		}
+		var err error
+		if filter.CreatedAfter, err = parseTimeParam(q.Get("created_after")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid created_after")
+			return
+		}
+		if filter.CreatedBefore, err = parseTimeParam(q.Get("created_before")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid created_before")
+			return
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSONError(w, http.StatusBadRequest, "invalid limit")
+				return
+			}
+			filter.Limit = n
+		}
+		res, err := s.cheq.List(r.Context(), filter)
+		if err != nil {
+			if err == cheq.ErrInvalidCursor {
+				writeJSONError(w, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			writeJSONError(w, http.StatusInternalServerError, "list failed")
+			return
+		}
+		out := CHEQListResponse{Items: make([]CHEQObjectView, 0, len(res.Items)), NextCursor: res.NextCursor}
+		for _, o := range res.Items {
+			out.Items = append(out.Items, NewCHEQObjectView(o))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	}
+}
+
+// parseTimeParam 解析 RFC3339 或 Unix 秒；空串返回零值。
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// writeJSONError 写 {"error":"..."} 响应。
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diting/internal/audit"
+	"diting/internal/cheq"
+	"diting/internal/config"
+	"diting/internal/delivery"
+	"diting/internal/grant"
+	"diting/internal/ownership"
+	"diting/internal/policy"
+)
+
+func TestCHEQListHandler(t *testing.T) {
+	eng := cheq.NewStubEngine()
+	ctx := context.Background()
+	exp := time.Now().Add(time.Minute)
+	_, _ = eng.Create(ctx, &cheq.CreateInput{TraceID: "t1", Resource: "/admin/x", ConfirmerIDs: []string{"u1"}, Type: "operation_approval", ExpiresAt: exp})
+	_, _ = eng.Create(ctx, &cheq.CreateInput{TraceID: "t2", Resource: "/api/y", ConfirmerIDs: []string{"u2"}, Type: "operation_approval", ExpiresAt: exp})
+
+	srv, err := NewServer(&config.Config{}, &policy.StubEngine{}, eng, &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+
+	if err != nil {
+
+		t.Fatalf("NewServer: %v", err)
+
+	}
+	h := srv.Handler()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/cheq/objects?status=pending&resource_prefix=/admin", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /cheq/objects: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var out CHEQListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(out.Items) != 1 || out.Items[0].TraceID != "t1" || out.Items[0].Status != "pending" {
+		t.Errorf("unexpected items: %+v", out.Items)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/cheq/objects?created_after=notatime", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("bad created_after: code=%d", rr.Code)
+	}
+}
+
+func TestCHEQApproveHandler_ApproverAuth(t *testing.T) {
+	eng := cheq.NewStubEngine()
+	ctx := context.Background()
+	obj, _ := eng.Create(ctx, &cheq.CreateInput{TraceID: "t-auth", Resource: "/admin/x", ConfirmerIDs: []string{"u1"}, Type: "operation_approval", ExpiresAt: time.Now().Add(time.Minute)})
+	auditStore := audit.NewStubStore()
+	srv, err := NewServer(&config.Config{}, &policy.StubEngine{}, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	signer := cheq.NewApprovalSigner("secret", 0)
+	srv.SetApproverAuth(signer, map[string]string{"u1": "tok-u1"})
+	h := srv.Handler()
+
+	// 未签名、伪造 by 均被拒绝并审计
+	for _, target := range []string{
+		"/cheq/approve?id=" + obj.ID + "&approved=true&by=u1",
+		"/cheq/approve?" + strings.Replace(signer.LinkQuery(obj, "u1", true), "by=u1", "by=u2", 1),
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: code=%d body=%s", target, rr.Code, rr.Body.String())
+		}
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-auth")
+	if len(evs) != 2 || evs[0].Decision != "approval_rejected" {
+		t.Errorf("rejected attempts evidence = %+v", evs)
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/cheq/approve?id="+obj.ID+"&approved=true&by=u2", nil)
+	req.Header.Set("X-Approver-Token", "tok-u1")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("token with mismatched by: code=%d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/cheq/approve?"+signer.LinkQuery(obj, "u1", true), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("signed link: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	got, _ := eng.GetByID(ctx, obj.ID)
+	if got.Status != "approved" {
+		t.Errorf("status = %v", got.Status)
+	}
+}
+
+func TestFeishuCardHandler_VerifiesCallback(t *testing.T) {
+	eng := cheq.NewStubEngine()
+	ctx := context.Background()
+	obj, _ := eng.Create(ctx, &cheq.CreateInput{TraceID: "t-card", Resource: "/admin/x", ConfirmerIDs: []string{"ou_1"}, Type: "operation_approval", ExpiresAt: time.Now().Add(time.Minute)})
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{}
+	srv, err := NewServer(cfg, &policy.StubEngine{}, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	srv.SetApproverAuth(cheq.NewApprovalSigner("secret", 0), nil)
+	h := srv.Handler()
+	post := func(token string) int {
+		body := `{"token":"` + token + `","operator":{"open_id":"ou_1"},"action":{"value":{"request_id":"` + obj.ID + `","action":"approve"}}}`
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/feishu/card", strings.NewReader(body)))
+		return rr.Code
+	}
+
+	// 启用审批人认证而未配置飞书校验：拒绝并审计
+	if code := post(""); code != http.StatusUnauthorized {
+		t.Errorf("unverifiable callback: code=%d", code)
+	}
+	cfg.Delivery.Feishu.VerificationToken = "vt"
+	if code := post("forged"); code != http.StatusUnauthorized {
+		t.Errorf("wrong verification token: code=%d", code)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "t-card")
+	if len(evs) != 2 || evs[0].Decision != "approval_rejected" || evs[0].Confirmer != "ou_1" {
+		t.Errorf("rejected callbacks evidence = %+v", evs)
+	}
+	if got, _ := eng.GetByID(ctx, obj.ID); got.Status != "pending" {
+		t.Fatalf("status after rejected callbacks = %v", got.Status)
+	}
+
+	if code := post("vt"); code != http.StatusOK {
+		t.Fatalf("verified callback: code=%d", code)
+	}
+	if got, _ := eng.GetByID(ctx, obj.ID); got.Status != "approved" {
+		t.Errorf("status = %v", got.Status)
+	}
+}
+
+func TestCHEQListHandler_RequiresAuth(t *testing.T) {
+	eng := cheq.NewStubEngine()
+	ctx := context.Background()
+	exp := time.Now().Add(time.Minute)
+	_, _ = eng.Create(ctx, &cheq.CreateInput{TraceID: "t1", Resource: "/admin/x", ConfirmerIDs: []string{"u1"}, Type: "operation_approval", ExpiresAt: exp})
+	_, _ = eng.Create(ctx, &cheq.CreateInput{TraceID: "t2", Resource: "/api/y", ConfirmerIDs: []string{"u2"}, Type: "operation_approval", ExpiresAt: exp})
+	srv, err := NewServer(&config.Config{}, &policy.StubEngine{}, eng, &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	srv.SetApproverAuth(nil, map[string]string{"u1": "tok-u1"})
+	h := srv.Handler()
+	list := func(header, token string) (int, []CHEQObjectView) {
+		req := httptest.NewRequest(http.MethodGet, "/cheq/objects", nil)
+		if header != "" {
+			req.Header.Set(header, token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var out CHEQListResponse
+		_ = json.NewDecoder(rr.Body).Decode(&out)
+		return rr.Code, out.Items
+	}
+
+	// 启用审批人认证而未配置 admin token：无 token 或无效 token 拒绝
+	if code, _ := list("", ""); code != http.StatusUnauthorized {
+		t.Errorf("no token: code=%d", code)
+	}
+	if code, _ := list("X-Approver-Token", "forged"); code != http.StatusUnauthorized {
+		t.Errorf("forged approver token: code=%d", code)
+	}
+	// approver token 仅可见本人为确认人的对象
+	if code, items := list("X-Approver-Token", "tok-u1"); code != http.StatusOK || len(items) != 1 || items[0].TraceID != "t1" {
+		t.Errorf("approver u1: code=%d items=%+v", code, items)
+	}
+	srv.SetAdminTokens(map[string]string{"ops": "tok-ops"})
+	if code, items := list("X-Admin-Token", "tok-ops"); code != http.StatusOK || len(items) != 2 {
+		t.Errorf("admin: code=%d items=%+v", code, items)
+	}
+	if code, _ := list("X-Admin-Token", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong admin token: code=%d", code)
+	}
+}
+
+func TestGrantHandlers_RequireAuth(t *testing.T) {
+	ctx := context.Background()
+	grants, _ := grant.NewFileStore("")
+	now := time.Now()
+	_, _ = grants.Create(ctx, &grant.Grant{ID: "g1", Subject: "agent1", Action: "POST", Resource: "/admin", Scope: grant.ScopeMinutes, CHEQID: "c1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	srv, err := NewServer(&config.Config{}, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	srv.SetGrantStore(grants)
+	srv.SetAdminTokens(map[string]string{"ops": "tok-ops"})
+	h := srv.Handler()
+	do := func(method, target, token string) int {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := do(http.MethodGet, "/grants", ""); code != http.StatusUnauthorized {
+		t.Errorf("list without token: code=%d", code)
+	}
+	if code := do(http.MethodPost, "/grants/revoke?id=g1&by=mallory", ""); code != http.StatusUnauthorized {
+		t.Errorf("revoke without token: code=%d", code)
+	}
+	if code := do(http.MethodGet, "/grants", "tok-ops"); code != http.StatusOK {
+		t.Errorf("list as admin: code=%d", code)
+	}
+	if code := do(http.MethodPost, "/grants/revoke?id=g1&by=mallory", "tok-ops"); code != http.StatusOK {
+		t.Fatalf("revoke as admin: code=%d", code)
+	}
+	if all, _ := grants.List(ctx, false); len(all) != 1 || all[0].RevokedBy != "ops" {
+		t.Errorf("revoked grant = %+v", all)
+	}
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"diting/internal/models"
+)
+
+// maxFingerprintBody 参与请求指纹的请求体上限；超过则不去重，照常创建 CHEQ。
+const maxFingerprintBody = 1 << 20
+
+// requestFingerprint 返回主体 + 操作 + 资源 + 规范化请求体的 SHA-256（hex），用于合并重试产生的重复待确认请求。
+func requestFingerprint(subject, action, resource string, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{subject, action, resource} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(normalizeBody(body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeBody 规范化请求体：JSON 按键排序并紧凑化，其余仅去首尾空白。
+func normalizeBody(body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	var v interface{}
+	if json.Unmarshal(body, &v) == nil {
+		if out, err := json.Marshal(v); err == nil {
+			return out
+		}
+	}
+	return body
+}
+
+// httpFingerprint 计算 HTTP 请求的指纹：查询参数按键排序后并入资源，请求体读出后原样放回；请求体过大返回空（不去重）。
+func httpFingerprint(r *http.Request, subject, action, resource string) string {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		buf, err := io.ReadAll(io.LimitReader(r.Body, maxFingerprintBody+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		if err != nil || len(buf) > maxFingerprintBody {
+			return ""
+		}
+		body = buf
+	}
+	if q := r.URL.Query(); len(q) > 0 {
+		resource += "?" + q.Encode()
+	}
+	return requestFingerprint(subject, action, resource, body)
+}
+
+// execFingerprint 计算执行层请求的指纹：请求体为按空白规范化的命令行。
+func execFingerprint(req *models.RequestContext, subject, resource string) string {
+	return requestFingerprint(subject, req.Action, resource, []byte(strings.Join(strings.Fields(req.TargetURL), " ")))
+}
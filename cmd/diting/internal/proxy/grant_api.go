@@ -0,0 +1,98 @@
+// 限时授权接口：GET /grants 列出授权，POST /grants/revoke 撤销授权。
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"diting/internal/grant"
+	"diting/internal/models"
+)
+
+// grantListHandler 处理 GET /grants?active=true|false；默认仅返回有效授权。须带 X-Admin-Token 或 X-Approver-Token（见 approvalViewer）。
+func (s *Server) grantListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if _, _, ok := s.approvalViewer(r); !ok {
+			writeJSONError(w, http.StatusUnauthorized, errApproverAuthRequired.Error())
+			return
+		}
+		activeOnly := r.URL.Query().Get("active") != "false"
+		list, err := s.grants.List(r.Context(), activeOnly)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"list failed"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": list})
+	}
+}
+
+// grantRevokeHandler 处理 POST /grants/revoke?id=xxx。须带 X-Admin-Token 或 X-Approver-Token（见 approvalViewer），
+// 撤销人（确认人或管理员）记入授权与审计；仅在未配置任何认证时采信 ?by=。
+func (s *Server) grantRevokeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"missing id"}`))
+			return
+		}
+		by, _, ok := s.approvalViewer(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, errApproverAuthRequired.Error())
+			return
+		}
+		if by == "" {
+			by = r.URL.Query().Get("by")
+		}
+		if err := s.grants.Revoke(r.Context(), id, by); err != nil {
+			if err == grant.ErrNotFound {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"not found"}`))
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"revoke failed"}`))
+			return
+		}
+		s.auditGrantRevoked(r.Context(), id, by)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}
+}
+
+// auditGrantRevoked 记录授权撤销，引用产生该授权的 CHEQ。
+func (s *Server) auditGrantRevoked(ctx context.Context, id, by string) {
+	ev := &models.Evidence{
+		TraceID:        id,
+		PolicyRuleID:   "grant_revoke",
+		DecisionReason: "grant " + id + " revoked",
+		Decision:       "grant_revoked",
+		Confirmer:      by,
+		Timestamp:      time.Now(),
+	}
+	if list, err := s.grants.List(ctx, false); err == nil {
+		for _, g := range list {
+			if g.ID == id {
+				ev.CHEQID = g.CHEQID
+				ev.AgentID = g.Subject
+				ev.Resource = g.Resource
+				ev.Action = g.Action
+				break
+			}
+		}
+	}
+	_ = s.audit.Append(ctx, ev)
+}
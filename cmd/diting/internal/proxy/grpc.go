@@ -0,0 +1,229 @@
+// gRPC ExecAuthService（proto/3af_exec.proto）：与 HTTP /auth/exec、/auth/sandbox-profile、/auth/stream
+// 共用流水线与 Sandbox Profile；AuthStream 为真正的双向流，review 后异步推送 approval_push，紧急隔离时推送 profile_update。
+// L0 凭证取自 metadata x-agent-token 或 authorization，mTLS 下客户端证书身份同 HTTP。
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"diting/internal/models"
+	ditingpb "diting/proto"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcService 实现 ditingpb.ExecAuthServiceServer。
+type grpcService struct {
+	ditingpb.UnimplementedExecAuthServiceServer
+	s *Server
+}
+
+// GRPCServer 返回注册了 ExecAuthService 的 gRPC 服务；SetTLS 后以相同证书启用 TLS / mTLS。
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	if s.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tls.TLSConfig())))
+	}
+	g := grpc.NewServer(opts...)
+	ditingpb.RegisterExecAuthServiceServer(g, &grpcService{s: s})
+	return g
+}
+
+// serveGRPC 在 proxy.grpc_listen_addr 上启动 gRPC 服务直到 ctx 结束；未配置时不启动。监听失败返回错误。
+func (s *Server) serveGRPC(ctx context.Context) error {
+	addr := s.cfg.Proxy.GRPCListenAddr
+	if addr == "" {
+		return nil
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	g := s.GRPCServer()
+	go func() {
+		<-ctx.Done()
+		// AuthStream 为长连接，不等待其结束
+		g.Stop()
+	}()
+	go func() { _ = g.Serve(lis) }()
+	return nil
+}
+
+// grpcCredential 取 metadata 中的 L0 凭证：x-agent-token 优先，其次 authorization。
+func grpcCredential(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, k := range []string{"x-agent-token", "authorization"} {
+		if v := md.Get(k); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// applyGRPCPeer 将 gRPC 连接上已验证的客户端证书写入 req，并剔除客户端自报的 peer_* 上下文。
+func (s *Server) applyGRPCPeer(ctx context.Context, req *models.RequestContext) {
+	if req == nil {
+		return
+	}
+	stripPeerContext(req)
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			s.applyPeerCert(&info.State, req)
+		}
+	}
+}
+
+func (g *grpcService) GetSandboxProfile(ctx context.Context, in *ditingpb.GetSandboxProfileRequest) (*ditingpb.GetSandboxProfileResponse, error) {
+	req := profileRequest(grpcCredential(ctx), in.GetResource())
+	g.s.applyGRPCPeer(ctx, req)
+	profile := g.s.sandboxProfile(ctx, req)
+	return &ditingpb.GetSandboxProfileResponse{Profile: profileToPB(&profile)}, nil
+}
+
+func (g *grpcService) ExecAuth(ctx context.Context, in *ditingpb.ExecAuthRequest) (*ditingpb.ExecAuthResponse, error) {
+	body := execRequestFromPB(in)
+	traceID := body.TraceID
+	if traceID == "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get("traceparent"); len(v) > 0 {
+			traceID = v[0]
+		}
+	}
+	if traceID == "" {
+		traceID = uuid.New().String()
+	}
+	// 与 /auth/exec 一致：未携带凭证时以 subject 作自报身份
+	agentIdentity := grpcCredential(ctx)
+	if agentIdentity == "" {
+		agentIdentity = body.Subject
+	}
+	reqCtx := BuildRequestContextFromExec(body, agentIdentity)
+	if reqCtx == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing subject/action/resource")
+	}
+	g.s.applyGRPCPeer(ctx, reqCtx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-trace-id", traceID))
+	resp, err := g.s.pipeline.ExecEvaluate(context.WithValue(ctx, ctxKeyTraceID, traceID), traceID, reqCtx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "evaluate failed")
+	}
+	return execResponseToPB(resp), nil
+}
+
+// AuthStream 双向流：metadata 中的 L0 凭证作为连接凭证，流上每条授权请求据此认证（同 ExecAuth）。
+func (g *grpcService) AuthStream(stream ditingpb.ExecAuthService_AuthStreamServer) error {
+	ctx := stream.Context()
+	conn := &streamConn{transport: "grpc", write: func(out AuthStreamResponse) error { return stream.Send(streamResponseToPB(&out)) }}
+	st := g.s.openStream(conn, grpcCredential(ctx), func(reqCtx *models.RequestContext) {
+		g.s.applyGRPCPeer(ctx, reqCtx)
+	})
+	defer st.close()
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		req := AuthStreamRequest{RequestID: in.GetRequestId(), Ping: in.GetPing()}
+		if init := in.GetInit(); init != nil {
+			req.Init = &AuthStreamInit{ClientID: init.GetClientId(), Resource: init.GetResource(), AgentVersion: init.GetAgentVersion()}
+		}
+		if a := in.GetAuth(); a != nil {
+			req.Auth = execRequestFromPB(a)
+		}
+		if c := in.GetCancel(); c != nil {
+			req.Cancel = &AuthStreamCancel{CheqID: c.GetCheqId(), Reason: c.GetReason()}
+		}
+		st.handle(ctx, &req)
+	}
+}
+
+// execRequestFromPB 将 proto 请求转为 /auth/exec 的请求体。
+func execRequestFromPB(in *ditingpb.ExecAuthRequest) *ExecAuthRequest {
+	c := in.GetCtx()
+	return &ExecAuthRequest{
+		Subject:     c.GetSubject(),
+		Action:      c.GetAction(),
+		Resource:    c.GetResource(),
+		Context:     c.GetContext(),
+		CommandLine: in.GetCommandLine(),
+		WorkingDir:  in.GetWorkingDir(),
+		TraceID:     in.GetTraceId(),
+	}
+}
+
+var decisionToPB = map[string]ditingpb.Decision{
+	"allow":  ditingpb.Decision_ALLOW,
+	"deny":   ditingpb.Decision_DENY,
+	"review": ditingpb.Decision_REVIEW,
+}
+
+func execResponseToPB(r *ExecAuthResponse) *ditingpb.ExecAuthResponse {
+	if r == nil {
+		return nil
+	}
+	return &ditingpb.ExecAuthResponse{
+		Decision:           decisionToPB[r.Decision],
+		PolicyRuleId:       r.PolicyRuleID,
+		Reason:             r.Reason,
+		CheqId:             r.CheqID,
+		ApprovalTimeoutSec: r.ApprovalTimeoutSec,
+		AuditMetadata:      r.AuditMetadata,
+		AmendedCommandLine: r.AmendedCommandLine,
+	}
+}
+
+func streamResponseToPB(r *AuthStreamResponse) *ditingpb.AuthStreamResponse {
+	out := &ditingpb.AuthStreamResponse{RequestId: r.RequestID}
+	switch {
+	case r.Immediate != nil:
+		out.Payload = &ditingpb.AuthStreamResponse_Immediate{Immediate: execResponseToPB(r.Immediate)}
+	case r.ApprovalPush != nil:
+		out.Payload = &ditingpb.AuthStreamResponse_ApprovalPush{ApprovalPush: &ditingpb.AuthStreamApprovalPush{
+			CheqId:             r.ApprovalPush.CheqID,
+			FinalDecision:      decisionToPB[r.ApprovalPush.FinalDecision],
+			Reason:             r.ApprovalPush.Reason,
+			AmendedCommandLine: r.ApprovalPush.AmendedCommandLine,
+		}}
+	case r.ProfileUpdate != nil:
+		out.Payload = &ditingpb.AuthStreamResponse_ProfileUpdate{ProfileUpdate: profileToPB(r.ProfileUpdate)}
+	case r.Pong != "":
+		out.Payload = &ditingpb.AuthStreamResponse_Pong{Pong: r.Pong}
+	}
+	return out
+}
+
+func profileToPB(p *SandboxProfile) *ditingpb.SandboxProfile {
+	out := &ditingpb.SandboxProfile{
+		ProfileId:         p.ProfileID,
+		Version:           p.Version,
+		DegradationPolicy: ditingpb.DegradationPolicy(ditingpb.DegradationPolicy_value[p.DegradationPolicy]),
+	}
+	if b := p.Boundary; b != nil {
+		out.Boundary = &ditingpb.SandboxBoundary{
+			NetworkEnabled:  b.NetworkEnabled,
+			FsWritablePaths: b.FsWritablePaths,
+			SyscallPreset:   b.SyscallPreset,
+			MaxMemoryMb:     b.MaxMemoryMB,
+			ReadonlyRoot:    b.ReadonlyRoot,
+		}
+	}
+	for _, a := range p.HotCacheActions {
+		out.HotCacheActions = append(out.HotCacheActions, &ditingpb.HotCacheAction{Executable: a.Executable, ArgvAllowlist: a.ArgvAllowlist, RunAsSudo: a.RunAsSudo})
+	}
+	for _, e := range p.SudoHotCache {
+		out.SudoHotCache = append(out.SudoHotCache, &ditingpb.SudoHotCacheEntry{CommandPattern: e.CommandPattern, RunAsUser: e.RunAsUser, Description: e.Description})
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"diting/internal/audit"
+	"diting/internal/cheq"
+	"diting/internal/config"
+	"diting/internal/delivery"
+	"diting/internal/ownership"
+	"diting/internal/policy"
+	ditingpb "diting/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCExecAuthService(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: ls\n    action: exec:ls\n    decision: allow\n  - id: exec-review\n    action: exec:run\n    decision: review\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	s, err := NewServer(&config.Config{}, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	g := s.GRPCServer()
+	go func() { _ = g.Serve(lis) }()
+	defer g.Stop()
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := ditingpb.NewExecAuthServiceClient(cc)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prof, err := client.GetSandboxProfile(ctx, &ditingpb.GetSandboxProfileRequest{Resource: "local://host"})
+	if err != nil || prof.Profile.GetDegradationPolicy() != ditingpb.DegradationPolicy_FAIL_CLOSE || !prof.Profile.GetBoundary().GetNetworkEnabled() {
+		t.Errorf("GetSandboxProfile = %v, %v", prof, err)
+	}
+	resp, err := client.ExecAuth(ctx, &ditingpb.ExecAuthRequest{
+		Ctx:         &ditingpb.RequestContext{Subject: "a1", Action: "exec:ls", Resource: "local://host"},
+		CommandLine: "ls /tmp",
+		TraceId:     "trace-grpc",
+	})
+	if err != nil || resp.Decision != ditingpb.Decision_ALLOW || resp.PolicyRuleId != "ls" {
+		t.Fatalf("ExecAuth = %v, %v", resp, err)
+	}
+	if evs, _ := auditStore.QueryByTraceID(ctx, "trace-grpc"); len(evs) != 1 || evs[0].AgentID != "a1" {
+		t.Errorf("audit = %+v", evs)
+	}
+
+	stream, err := client.AuthStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stream.Send(&ditingpb.AuthStreamRequest{RequestId: "r0", Payload: &ditingpb.AuthStreamRequest_Init{Init: &ditingpb.AuthStreamInit{ClientId: "node-1", Resource: "local://host"}}})
+	if out, err := stream.Recv(); err != nil || out.GetProfileUpdate().GetProfileId() != "default" {
+		t.Fatalf("init: %v, %v", out, err)
+	}
+	_ = stream.Send(&ditingpb.AuthStreamRequest{RequestId: "r1", Payload: &ditingpb.AuthStreamRequest_Auth{Auth: &ditingpb.ExecAuthRequest{
+		Ctx:         &ditingpb.RequestContext{Subject: "a1", Action: "exec:run", Resource: "local://host"},
+		CommandLine: "rm -rf /tmp/x",
+	}}})
+	out, err := stream.Recv()
+	if err != nil || out.GetImmediate().GetDecision() != ditingpb.Decision_REVIEW || out.GetImmediate().GetCheqId() == "" {
+		t.Fatalf("review: %v, %v", out, err)
+	}
+	cheqID := out.GetImmediate().GetCheqId()
+	if err := eng.Submit(ctx, cheqID, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	out, err = stream.Recv()
+	if push := out.GetApprovalPush(); err != nil || out.RequestId != "r1" || push.GetCheqId() != cheqID || push.GetFinalDecision() != ditingpb.Decision_ALLOW {
+		t.Errorf("approval_push: %v, %v", out, err)
+	}
+	_ = stream.CloseSend()
+}
+
+func TestGRPCAuthStreamUsesMetadataCredential(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Proxy.AllowedAPIKeys = []string{"grpc-key"}
+	s, err := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	g := s.GRPCServer()
+	go func() { _ = g.Serve(lis) }()
+	defer g.Stop()
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := ditingpb.NewExecAuthServiceClient(cc)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	auth := func(token string) ditingpb.Decision {
+		sctx := ctx
+		if token != "" {
+			sctx = metadata.AppendToOutgoingContext(ctx, "x-agent-token", token)
+		}
+		stream, err := client.AuthStream(sctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stream.CloseSend()
+		// 凭证只在 metadata 中携带，ctx.subject 为本地用户名
+		_ = stream.Send(&ditingpb.AuthStreamRequest{RequestId: "r1", Payload: &ditingpb.AuthStreamRequest_Auth{Auth: &ditingpb.ExecAuthRequest{
+			Ctx:         &ditingpb.RequestContext{Subject: "alice", Action: "exec:ls", Resource: "local://host"},
+			CommandLine: "ls",
+		}}})
+		out, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.GetImmediate().GetDecision()
+	}
+	if d := auth("grpc-key"); d != ditingpb.Decision_ALLOW {
+		t.Errorf("metadata key: %v", d)
+	}
+	if d := auth("wrong-key"); d != ditingpb.Decision_DENY {
+		t.Errorf("invalid metadata key: %v", d)
+	}
+	if d := auth(""); d != ditingpb.Decision_DENY {
+		t.Errorf("self-reported subject with keys configured: %v", d)
+	}
+}
@@ -28,6 +28,7 @@ func buildRequestContext(r *http.Request, traceID string) *models.RequestContext
 		Resource:      r.URL.Path,
 		Action:        r.Method,
 		Headers:       r.Header.Clone(),
+		SessionID:     r.Header.Get("X-Session-ID"),
 	}
 }
 
@@ -55,6 +56,8 @@ func (s *Server) proxyHandler() http.HandlerFunc {
 			traceID = uuid.New().String()
 		}
 		reqCtx := buildRequestContext(r, traceID)
+		s.applyClientCert(r, reqCtx)
+		s.applySignature(r, reqCtx)
 		ctx := context.WithValue(r.Context(), ctxKeyTraceID, traceID)
 		s.pipeline.ServeHTTP(w, r.WithContext(ctx), reqCtx, rp)
 	}
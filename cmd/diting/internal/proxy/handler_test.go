@@ -2,16 +2,43 @@ package proxy
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"diting/internal/agent"
+	"diting/internal/apikey"
 	"diting/internal/audit"
 	"diting/internal/cheq"
 	"diting/internal/config"
 	"diting/internal/delivery"
+	"diting/internal/httpsig"
+	"diting/internal/jwtauth"
+	"diting/internal/models"
+	"diting/internal/mtls"
 	"diting/internal/ownership"
+	"diting/internal/peercred"
 	"diting/internal/policy"
+	chainpkg "diting/pkg/chain"
 )
 
 func TestProxyHandler_DirectorPreservesUpstreamAndInjectsTraceHeaders(t *testing.T) {
@@ -36,7 +63,13 @@ func TestProxyHandler_DirectorPreservesUpstreamAndInjectsTraceHeaders(t *testing
 		},
 	}
 
-	s := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	s, err := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+
+	if err != nil {
+
+		t.Fatalf("NewServer: %v", err)
+
+	}
 	h := s.proxyHandler()
 
 	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
@@ -61,3 +94,656 @@ func TestProxyHandler_DirectorPreservesUpstreamAndInjectsTraceHeaders(t *testing
 	_ = context.Background()
 }
 
+
+func TestInitPermissionOnboarding(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: health\n    action: GET\n    resource: /healthz\n    decision: allow\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{Proxy: config.ProxyConfig{Upstream: up.URL, AllowedAPIKeys: []string{"legacy-key"}}}
+	s, err := NewServer(cfg, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, []policy.Rule{{ID: "read-own", Action: "GET", Resource: "/agents/{agent_id}", Decision: policy.RuleAllow}})
+	s.SetAdminTokens(map[string]string{"tianshu": "svc-token"})
+	h := s.Handler()
+	ctx := context.Background()
+
+	register := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/init_permission", strings.NewReader(`{"agent_id":"a1","owner_id":"o1"}`))
+		req.Header.Set("X-Admin-Token", token)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := register("forged"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /init_permission without admin token: code=%d", rr.Code)
+	}
+	rr := register("svc-token")
+	var view AgentOnboardingView
+	_ = json.NewDecoder(rr.Body).Decode(&view)
+	if rr.Code != http.StatusAccepted || view.State != "pending" || view.CHEQID == "" || view.PickupToken == "" {
+		t.Fatalf("POST /init_permission: code=%d view=%+v", rr.Code, view)
+	}
+	pickup := view.PickupToken
+	status := func(token string) (int, AgentOnboardingView) {
+		req := httptest.NewRequest(http.MethodGet, "/init_permission?agent_id=a1", nil)
+		if token != "" {
+			req.Header.Set("X-Pickup-Token", token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var v AgentOnboardingView
+		_ = json.NewDecoder(rr.Body).Decode(&v)
+		return rr.Code, v
+	}
+	obj, _ := eng.GetByID(ctx, view.CHEQID)
+	if obj.Type != "agent_onboarding" || len(obj.ConfirmerIDs) != 1 || obj.ConfirmerIDs[0] != "o1" {
+		t.Fatalf("onboarding object = %+v", obj)
+	}
+
+	proxyGet := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		req.Header.Set("X-Agent-Token", token)
+		rr := httptest.NewRecorder()
+		s.proxyHandler()(rr, req)
+		return rr.Code
+	}
+	if code := proxyGet("/agents/a1", "a1"); code != http.StatusForbidden {
+		t.Errorf("pending agent: code=%d, want 403", code)
+	}
+	if code := proxyGet("/healthz", "a1"); code != http.StatusOK {
+		t.Errorf("pending agent health path: code=%d, want 200", code)
+	}
+
+	if err := eng.Submit(ctx, view.CHEQID, true, "a1"); err != cheq.ErrSelfApproval {
+		t.Fatalf("agent approving itself: got %v", err)
+	}
+	if err := eng.Submit(ctx, view.CHEQID, true, "o1"); err != nil {
+		t.Fatalf("owner approval: %v", err)
+	}
+	// 未持领取口令者不能抢先领取凭证
+	for _, tok := range []string{"", "dap_forged"} {
+		if code, v := status(tok); code != http.StatusUnauthorized || v.Credential != "" {
+			t.Fatalf("status with pickup token %q: code=%d view=%+v", tok, code, v)
+		}
+	}
+	code, view := status(pickup)
+	if code != http.StatusOK || view.State != "active" || view.Credential == "" {
+		t.Fatalf("after approval: code=%d view=%+v", code, view)
+	}
+	if code, again := status(pickup); code != http.StatusUnauthorized || again.Credential != "" {
+		t.Errorf("credential should be delivered only once: code=%d view=%+v", code, again)
+	}
+
+	if code := proxyGet("/agents/a1", view.Credential); code != http.StatusOK {
+		t.Errorf("active agent with credential: code=%d, want 200", code)
+	}
+	if code := proxyGet("/agents/a2", view.Credential); code != http.StatusForbidden {
+		t.Errorf("baseline policy should not cover other paths: code=%d", code)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, obj.TraceID)
+	if len(evs) != 2 || evs[0].Decision != "agent_registered" || evs[1].Decision != "agent_activated" {
+		t.Errorf("onboarding evidence = %+v", evs)
+	}
+}
+
+func TestAgentsAdminAPI(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: infra-read\n    team: infra\n    action: GET\n    decision: allow\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{Proxy: config.ProxyConfig{Upstream: up.URL}}
+	s, err := NewServer(cfg, pe, cheq.NewStubEngine(), &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, nil)
+	s.SetAdminTokens(map[string]string{"ops": "tok-ops"})
+	h := s.Handler()
+
+	admin := func(method, target, body string) (*httptest.ResponseRecorder, AgentView) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Admin-Token", "tok-ops")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var v AgentView
+		_ = json.Unmarshal(rr.Body.Bytes(), &v)
+		return rr, v
+	}
+	proxyGet := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/data", nil)
+		req.Header.Set("X-Agent-Token", token)
+		rr := httptest.NewRecorder()
+		s.proxyHandler()(rr, req)
+		return rr.Code
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/agents", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without admin token: code=%d", rr.Code)
+	}
+	rr, created := admin(http.MethodPost, "/agents", `{"id":"a1","owner_id":"o1","team":"infra","labels":{"env":"prod"}}`)
+	if rr.Code != http.StatusCreated || created.State != "active" || created.Credential == "" {
+		t.Fatalf("create: code=%d view=%+v", rr.Code, created)
+	}
+	if rr, _ := admin(http.MethodPost, "/agents", `{"id":"a1"}`); rr.Code != http.StatusConflict {
+		t.Errorf("duplicate create: code=%d", rr.Code)
+	}
+	if code := proxyGet(created.Credential); code != http.StatusOK {
+		t.Fatalf("team rule should allow a1: code=%d", code)
+	}
+	if code := proxyGet("a1"); code != http.StatusForbidden {
+		t.Errorf("raw agent id is not a credential: code=%d", code)
+	}
+	_, got := admin(http.MethodGet, "/agents?id=a1", "")
+	if got.Credential != "" || !got.HasCredential || got.LastSeenAt == nil {
+		t.Errorf("GET view should hide credential and record last seen: %+v", got)
+	}
+
+	if rr, _ := admin(http.MethodPut, "/agents?id=a1", `{"state":"suspended"}`); rr.Code != http.StatusOK {
+		t.Fatalf("suspend: code=%d", rr.Code)
+	}
+	if code := proxyGet(created.Credential); code != http.StatusForbidden {
+		t.Errorf("suspended agent: code=%d, want 403", code)
+	}
+	_, _ = admin(http.MethodPut, "/agents?id=a1", `{"state":"active"}`)
+	if code := proxyGet(created.Credential); code != http.StatusOK {
+		t.Errorf("reactivated agent: code=%d", code)
+	}
+	_, _ = admin(http.MethodPut, "/agents?id=a1", `{"state":"revoked"}`)
+	if rr, _ := admin(http.MethodPut, "/agents?id=a1", `{"state":"active"}`); rr.Code != http.StatusConflict {
+		t.Errorf("revoked agent cannot be reactivated: code=%d", rr.Code)
+	}
+	if rr, _ := admin(http.MethodDelete, "/agents?id=a1", ""); rr.Code != http.StatusOK {
+		t.Errorf("delete: code=%d", rr.Code)
+	}
+	evs, _ := auditStore.QueryByTraceID(context.Background(), "a1")
+	if len(evs) != 5 || evs[0].Decision != "agent_created" || evs[0].Confirmer != "ops" || evs[4].Decision != "agent_deleted" {
+		t.Errorf("admin evidence = %+v", evs)
+	}
+}
+
+func TestAPIKeyL0(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: read\n    action: GET\n    decision: allow\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{Proxy: config.ProxyConfig{Upstream: up.URL, AllowedAPIKeys: []string{"legacy-key"}}}
+	s, err := NewServer(cfg, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, nil)
+	keys, _ := apikey.NewFileStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err := s.SetAPIKeyStore(keys); err != nil {
+		t.Fatalf("SetAPIKeyStore: %v", err)
+	}
+	h := s.Handler()
+	ctx := context.Background()
+
+	do := func(method, path, body string, out interface{}) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if out != nil {
+			_ = json.NewDecoder(rr.Body).Decode(out)
+		}
+		return rr.Code
+	}
+	proxyGet := func(path, token string) (int, []*models.Evidence) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		s.proxyHandler()(rr, req)
+		evs, _ := auditStore.QueryByTraceID(ctx, rr.Header().Get("X-Trace-ID"))
+		for _, ev := range evs {
+			if b, _ := json.Marshal(ev); strings.Contains(string(b), token) {
+				t.Errorf("token leaked into audit: %s", b)
+			}
+		}
+		return rr.Code, evs
+	}
+	do(http.MethodPost, "/agents", `{"id":"bot","owner_id":"o1"}`, nil)
+	var scoped, unmapped, expired APIKeyView
+	if code := do(http.MethodPost, "/apikeys", `{"id":"ci","agent_id":"bot","resources":["/data*"]}`, &scoped); code != http.StatusCreated || scoped.Key == "" {
+		t.Fatalf("POST /apikeys: code=%d view=%+v", code, scoped)
+	}
+	do(http.MethodPost, "/apikeys", `{"id":"k2"}`, &unmapped)
+	do(http.MethodPost, "/apikeys", `{"id":"old","expires_at":"2000-01-01T00:00:00Z"}`, &expired)
+	if code := do(http.MethodPost, "/apikeys", `{"id":"ci"}`, nil); code != http.StatusConflict {
+		t.Errorf("duplicate key id: code=%d", code)
+	}
+
+	if code, evs := proxyGet("/data/1", scoped.Key); code != http.StatusOK || len(evs) == 0 || evs[len(evs)-1].AgentID != "bot" {
+		t.Errorf("scoped key: code=%d evidence=%+v", code, evs)
+	}
+	if code, evs := proxyGet("/other", scoped.Key); code != http.StatusForbidden || len(evs) != 1 || evs[0].Decision != "l0_scope" {
+		t.Errorf("out of scope: code=%d evidence=%+v", code, evs)
+	}
+	if code, evs := proxyGet("/data", unmapped.Key); code != http.StatusOK || evs[len(evs)-1].AgentID != "key:k2" {
+		t.Errorf("unmapped key: code=%d evidence=%+v", code, evs)
+	}
+	if code, evs := proxyGet("/data", expired.Key); code != http.StatusUnauthorized || len(evs) != 1 || evs[0].Decision != "l0_expired" {
+		t.Errorf("expired key: code=%d evidence=%+v", code, evs)
+	}
+	if code, _ := proxyGet("/data", "legacy-key"); code != http.StatusOK {
+		t.Errorf("legacy key: code=%d", code)
+	}
+	if code, _ := proxyGet("/data", "dk_ci_forged"); code != http.StatusUnauthorized {
+		t.Errorf("forged key: code=%d", code)
+	}
+
+	do(http.MethodPost, "/agents/quarantine", `{"agent_id":"bot","reason":"leak"}`, nil)
+	if code, _ := proxyGet("/data", scoped.Key); code != http.StatusForbidden {
+		t.Errorf("key of quarantined agent: code=%d", code)
+	}
+
+	var list struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	do(http.MethodGet, "/apikeys", "", &list)
+	if len(list.Items) != 4 {
+		t.Errorf("GET /apikeys: %d items", len(list.Items))
+	}
+	for _, raw := range list.Items {
+		if strings.Contains(string(raw), `"hash"`) || strings.Contains(string(raw), `"salt"`) || strings.Contains(string(raw), `"key"`) {
+			t.Errorf("list exposes secret material: %s", raw)
+		}
+	}
+	if code := do(http.MethodDelete, "/apikeys?id=k2", "", nil); code != http.StatusOK {
+		t.Errorf("DELETE /apikeys: code=%d", code)
+	}
+	if code, _ := proxyGet("/data", unmapped.Key); code != http.StatusUnauthorized {
+		t.Errorf("deleted key: code=%d", code)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "apikey:k2")
+	if len(evs) != 2 || evs[0].Decision != "apikey_created" || evs[1].Decision != "apikey_deleted" {
+		t.Errorf("apikey admin audit = %+v", evs)
+	}
+}
+
+func TestSetAPIKeyStoreReconcilesConfigKeys(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	start := func(legacy ...string) (*Server, apikey.Store) {
+		cfg := &config.Config{Proxy: config.ProxyConfig{AllowedAPIKeys: legacy}}
+		s, err := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+		if err != nil {
+			t.Fatalf("NewServer: %v", err)
+		}
+		st, err := apikey.NewFileStore(path)
+		if err != nil {
+			t.Fatalf("NewFileStore: %v", err)
+		}
+		if err := s.SetAPIKeyStore(st); err != nil {
+			t.Fatalf("SetAPIKeyStore: %v", err)
+		}
+		return s, st
+	}
+	_, st := start("key-a", "key-b")
+	issued := &apikey.Key{ID: "ops", CreatedAt: time.Now()}
+	_ = issued.SetSecret("ops-secret")
+	_ = st.Put(ctx, issued)
+	b, err := st.Verify(ctx, "key-b", time.Now())
+	if err != nil {
+		t.Fatalf("Verify key-b: %v", err)
+	}
+
+	// 重启时从配置移除 key-a 并调整顺序：key-a 失效，key-b 的 id 不变，接口签发的 Key 不受影响
+	_, st = start("key-c", "key-b")
+	if _, err := st.Verify(ctx, "key-a", time.Now()); err == nil {
+		t.Error("key removed from config still authenticates")
+	}
+	if got, err := st.Verify(ctx, "key-b", time.Now()); err != nil || got.ID != b.ID {
+		t.Errorf("key-b after reorder: %+v, %v (want id %s)", got, err, b.ID)
+	}
+	if _, err := st.Verify(ctx, "ops-secret", time.Now()); err != nil {
+		t.Errorf("key issued via API should be kept: %v", err)
+	}
+}
+
+func TestNewServerRejectsMalformedAPIKeys(t *testing.T) {
+	for name, k := range map[string]config.APIKeyConfig{
+		"missing hash":   {ID: "ci"},
+		"bad hash":       {ID: "ci", Hash: "zz:zz"},
+		"bad expires_at": {ID: "ci", Hash: "00:00", ExpiresAt: "tomorrow"},
+	} {
+		cfg := &config.Config{Proxy: config.ProxyConfig{APIKeys: []config.APIKeyConfig{k}}}
+		if _, err := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil); err == nil {
+			t.Errorf("%s: NewServer should fail", name)
+		}
+	}
+}
+
+func TestJWTL0Chain(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: infra-prod\n    action: GET\n    team: infra\n    labels:\n      env: prod\n    decision: allow\n  - id: key-read\n    subject: key:ci\n    action: GET\n    decision: allow\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{Proxy: config.ProxyConfig{Upstream: up.URL}}
+	s, err := NewServer(cfg, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, nil)
+	ctx := context.Background()
+	_ = reg.Put(ctx, &agent.Agent{ID: "wl-2", OwnerID: "o1", State: agent.StateSuspended})
+
+	keys, _ := apikey.NewFileStore("")
+	apiToken, k, _ := apikey.Generate("ci")
+	_ = keys.Put(ctx, k)
+	_ = s.SetAPIKeyStore(keys)
+	signKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwksPath := filepath.Join(dir, "jwks.json")
+	enc := base64.RawURLEncoding
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "EC", "kid": "k1", "crv": "P-256",
+		"x": enc.EncodeToString(signKey.X.FillBytes(make([]byte, 32))), "y": enc.EncodeToString(signKey.Y.FillBytes(make([]byte, 32))),
+	}}})
+	_ = os.WriteFile(jwksPath, jwks, 0644)
+	s.SetJWTVerifier(jwtauth.NewVerifier(jwtauth.NewKeySet(jwksPath, 0), jwtauth.Options{
+		Issuer: "https://idp.example.com", Audience: []string{"diting"}, TeamClaim: "team", LabelClaims: map[string]string{"env": "env"},
+	}))
+	mint := func(sub, team, iss string, exp time.Time) string {
+		h, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "k1"})
+		c, _ := json.Marshal(map[string]interface{}{"iss": iss, "aud": "diting", "sub": sub, "team": team, "env": "prod", "exp": exp.Unix()})
+		input := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+		digest := sha256.Sum256([]byte(input))
+		r, sv, _ := ecdsa.Sign(rand.Reader, signKey, digest[:])
+		return input + "." + enc.EncodeToString(append(r.FillBytes(make([]byte, 32)), sv.FillBytes(make([]byte, 32))...))
+	}
+	proxyGet := func(token string) (int, []*models.Evidence) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/data", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		s.proxyHandler()(rr, req)
+		evs, _ := auditStore.QueryByTraceID(ctx, rr.Header().Get("X-Trace-ID"))
+		for _, ev := range evs {
+			if b, _ := json.Marshal(ev); strings.Contains(string(b), token) {
+				t.Errorf("token leaked into audit: %s", b)
+			}
+		}
+		return rr.Code, evs
+	}
+	hour := time.Now().Add(time.Hour)
+	if code, evs := proxyGet(mint("wl-1", "infra", "https://idp.example.com", hour)); code != http.StatusOK || evs[len(evs)-1].AgentID != "wl-1" {
+		t.Errorf("jwt with team/labels: code=%d evidence=%+v", code, evs)
+	}
+	if code, _ := proxyGet(mint("wl-1", "sales", "https://idp.example.com", hour)); code != http.StatusForbidden {
+		t.Errorf("jwt team not matched by policy: code=%d", code)
+	}
+	if code, evs := proxyGet(mint("wl-1", "infra", "https://idp.example.com", time.Now().Add(-time.Hour))); code != http.StatusUnauthorized || evs[0].Decision != "l0_expired" {
+		t.Errorf("expired jwt: code=%d evidence=%+v", code, evs)
+	}
+	if code, evs := proxyGet(mint("wl-1", "infra", "https://evil.example.com", hour)); code != http.StatusUnauthorized || evs[0].Decision != "l0_invalid" {
+		t.Errorf("wrong issuer: code=%d evidence=%+v", code, evs)
+	}
+	if code, _ := proxyGet(mint("wl-2", "infra", "https://idp.example.com", hour)); code != http.StatusForbidden {
+		t.Errorf("jwt subject suspended in registry: code=%d", code)
+	}
+	if code, evs := proxyGet(apiToken); code != http.StatusOK || evs[len(evs)-1].AgentID != "key:ci" {
+		t.Errorf("api key in chain: code=%d evidence=%+v", code, evs)
+	}
+	if code, _ := proxyGet("not-a-credential"); code != http.StatusUnauthorized {
+		t.Errorf("unknown token: code=%d", code)
+	}
+
+	cfg.Proxy.L0Authenticators = []string{"jwt"}
+	s.SetJWTVerifier(s.jwt)
+	if code, _ := proxyGet(apiToken); code != http.StatusUnauthorized {
+		t.Errorf("api key outside configured chain: code=%d", code)
+	}
+}
+
+func TestMTLSClientIdentity(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: a1-read\n    subject: spiffe://example.org/a1\n    action: GET\n    decision: allow\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{Proxy: config.ProxyConfig{Upstream: up.URL, AllowedAPIKeys: []string{"legacy-key"}}}
+	s, err := NewServer(cfg, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, nil)
+	ctx := context.Background()
+	b1Agent := &agent.Agent{ID: "b1", OwnerID: "o1", State: agent.StateActive}
+	_ = b1Agent.SetCredential("b1-credential")
+	_ = reg.Put(ctx, b1Agent)
+
+	certFor := func(cn, uri string) *x509.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn}, NotAfter: time.Now().Add(time.Hour)}
+		if uri != "" {
+			u, _ := url.Parse(uri)
+			tmpl.URIs = []*url.URL{u}
+		}
+		der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		c, _ := x509.ParseCertificate(der)
+		return c
+	}
+	proxyGet := func(cert *x509.Certificate, token string) (int, []*models.Evidence) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/data", nil)
+		if token != "" {
+			req.Header.Set("X-Agent-Token", token)
+		}
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		rr := httptest.NewRecorder()
+		s.proxyHandler()(rr, req)
+		evs, _ := auditStore.QueryByTraceID(ctx, rr.Header().Get("X-Trace-ID"))
+		return rr.Code, evs
+	}
+	a1 := certFor("ignored-cn", "spiffe://example.org/a1")
+	code, evs := proxyGet(a1, "")
+	if code != http.StatusOK || len(evs) == 0 {
+		t.Fatalf("client certificate as L0 identity: code=%d", code)
+	}
+	if ev := evs[len(evs)-1]; ev.AgentID != "spiffe://example.org/a1" || ev.CertFingerprint != mtls.Fingerprint(a1) {
+		t.Errorf("evidence = %+v", ev)
+	}
+	if code, evs := proxyGet(a1, "b1-credential"); code != http.StatusForbidden || evs[0].Decision != "l0_cert_mismatch" {
+		t.Errorf("certificate and credential of different agents: code=%d evidence=%+v", code, evs)
+	}
+	b1 := certFor("b1", "")
+	if code, _ := proxyGet(b1, "b1-credential"); code != http.StatusForbidden {
+		t.Errorf("b1 has no policy allow: code=%d", code)
+	}
+	b1Agent.State = agent.StateSuspended
+	_ = reg.Put(ctx, b1Agent)
+	if code, evs := proxyGet(b1, ""); code != http.StatusForbidden || evs[0].Decision != "agent_suspended" {
+		t.Errorf("certificate of suspended agent: code=%d evidence=%+v", code, evs)
+	}
+	if code, _ := proxyGet(nil, ""); code != http.StatusUnauthorized {
+		t.Errorf("no certificate and no api key: code=%d", code)
+	}
+}
+
+func TestDIDSignatureL0(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: did-read\n    subject: did:ziwei:local:a1\n    action: GET\n    decision: allow\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{Proxy: config.ProxyConfig{Upstream: up.URL, AllowedAPIKeys: []string{"legacy-key"}}}
+	s, err := NewServer(cfg, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ctx := context.Background()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	jwk := `{"kty":"OKP","crv":"Ed25519","x":"` + base64.RawURLEncoding.EncodeToString(pub) + `"}`
+	ledger := chainpkg.NewLocalStore()
+	_ = ledger.PutDID(ctx, &chainpkg.DIDDocument{ID: "did:ziwei:local:a1", PublicKey: jwk, Status: chainpkg.DIDStatusActive})
+	_ = ledger.PutDID(ctx, &chainpkg.DIDDocument{ID: "did:ziwei:local:gone", PublicKey: jwk, Status: chainpkg.DIDStatusRevoked})
+	s.SetSignatureVerifier(httpsig.NewVerifier(httpsig.NewDIDResolver(ledger), httpsig.Options{}))
+
+	sign := func(req *http.Request, did, nonce string) {
+		params := fmt.Sprintf(`("@method" "@target-uri");created=%d;keyid="%s";nonce="%s"`, time.Now().Unix(), did, nonce)
+		base := fmt.Sprintf("\"@method\": %s\n\"@target-uri\": %s\n\"@signature-params\": %s", req.Method, httpsig.TargetURI(req), params)
+		req.Header.Set("Signature-Input", "sig1="+params)
+		req.Header.Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(base)))+":")
+	}
+	proxyGet := func(req *http.Request) (int, []*models.Evidence) {
+		rr := httptest.NewRecorder()
+		s.proxyHandler()(rr, req)
+		evs, _ := auditStore.QueryByTraceID(ctx, rr.Header().Get("X-Trace-ID"))
+		return rr.Code, evs
+	}
+	signed := httptest.NewRequest(http.MethodGet, "http://example.com/data", nil)
+	sign(signed, "did:ziwei:local:a1", "n1")
+	code, evs := proxyGet(signed)
+	if code != http.StatusOK || len(evs) == 0 || evs[len(evs)-1].AgentID != "did:ziwei:local:a1" {
+		t.Fatalf("signed request: code=%d evidence=%+v", code, evs)
+	}
+	replay := httptest.NewRequest(http.MethodGet, "http://example.com/data", nil)
+	replay.Header = signed.Header.Clone()
+	if code, evs := proxyGet(replay); code != http.StatusUnauthorized || evs[0].Decision != "l0_signature" {
+		t.Errorf("replayed signature: code=%d evidence=%+v", code, evs)
+	}
+	revoked := httptest.NewRequest(http.MethodGet, "http://example.com/data", nil)
+	sign(revoked, "did:ziwei:local:gone", "n2")
+	if code, evs := proxyGet(revoked); code != http.StatusForbidden || evs[0].Decision != "l0_did_revoked" {
+		t.Errorf("revoked did: code=%d evidence=%+v", code, evs)
+	}
+	// 未签名的请求仍走 API Key 校验
+	unsigned := httptest.NewRequest(http.MethodGet, "http://example.com/data", nil)
+	if code, _ := proxyGet(unsigned); code != http.StatusUnauthorized {
+		t.Errorf("unsigned request without api key: code=%d", code)
+	}
+
+	for did, want := range map[string]int{"did:ziwei:local:a1": http.StatusOK, "did:ziwei:local:gone": http.StatusForbidden} {
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/challenge", strings.NewReader(`{"keyid":"`+did+`"}`)))
+		if rr.Code != want || (want == http.StatusOK && !strings.Contains(rr.Body.String(), `"nonce"`)) {
+			t.Errorf("challenge %s: code=%d body=%s", did, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestUnixSocketPeerCred(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_PEERCRED is linux only")
+	}
+	self := &peercred.Cred{UID: uint32(os.Getuid()), ContainerID: peercred.ContainerID(int32(os.Getpid()))}
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: peer-run\n    subject: "+self.Identity()+"\n    action: exec:run\n    decision: allow\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	defer eng.Stop()
+	auditStore := audit.NewStubStore()
+	dir, _ := os.MkdirTemp("", "diting")
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "3af.sock")
+	cfg := &config.Config{Proxy: config.ProxyConfig{UnixSocketPath: sock}}
+	s, err := NewServer(cfg, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.serveUnix(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Stat(sock); err != nil || st.Mode().Perm() != 0660 {
+		t.Fatalf("socket mode: %v %v", st, err)
+	}
+	client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+	}}}
+	// 自报的 subject 与 peer_uid 均不采信
+	body := `{"subject":"root-agent","action":"exec:run","resource":"local://host","command_line":"id","trace_id":"trace-uds","context":{"peer_uid":"0"}}`
+	resp, err := client.Post("http://unix/auth/exec", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("exec over unix socket: code=%d", resp.StatusCode)
+	}
+	evs, _ := auditStore.QueryByTraceID(ctx, "trace-uds")
+	if len(evs) != 1 || evs[0].AgentID != self.Identity() {
+		t.Errorf("audit = %+v, want agent %s", evs, self.Identity())
+	}
+	if resp, err := client.Get("http://unix/debug/audit?trace_id=trace-uds"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("non-exec route exposed on socket: %v %v", resp, err)
+	}
+}
+
+func TestUnixHandlerRequiresPeerCred(t *testing.T) {
+	s, err := NewServer(&config.Config{}, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	h := s.unixHandler()
+	// 取不到内核凭证的套接字连接：拒绝，不退回自报 subject
+	body := `{"subject":"root-agent","action":"exec:run","resource":"local://host","command_line":"id","context":{"peer_uid":"0"}}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/exec", strings.NewReader(body)))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("exec without peer cred: code=%d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("healthz: code=%d", rr.Code)
+	}
+
+	// 非套接字请求同样剔除自报的 peer_* 上下文
+	req := &models.RequestContext{Context: map[string]string{"peer_uid": "0", "container_id": "abc", "env": "prod"}}
+	s.applyPeerCred(httptest.NewRequest(http.MethodPost, "/auth/exec", nil), req)
+	if _, ok := req.Context["peer_uid"]; ok || req.Context["container_id"] != "" || req.Context["env"] != "prod" {
+		t.Errorf("context = %v", req.Context)
+	}
+}
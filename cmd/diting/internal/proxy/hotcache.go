@@ -0,0 +1,150 @@
+// 本地执行补录（POST /auth/exec/audit）：3af-exec 按 Hot Cache 本地放行或 Diting 不可达时按 FAIL_OPEN 执行的命令，
+// 事后批量上报；服务端以当前 profile 复核并逐条写审计，不符合 profile 的记为 violation。
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"diting/internal/hotcache"
+	"diting/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxExecAuditRecords 单批补录的记录数上限。
+	maxExecAuditRecords = 1000
+	// maxExecAuditBytes 单批补录的请求体上限。
+	maxExecAuditBytes = 4 << 20
+)
+
+// 本地执行的类型。
+const (
+	LocalExecHotCache = "hot_cache" // 命中 Hot Cache 本地放行
+	LocalExecFailOpen = "fail_open" // Diting 不可达，按 FAIL_OPEN 放行
+)
+
+// ExecAuditBatch POST /auth/exec/audit 的请求体。
+type ExecAuditBatch struct {
+	Subject  string            `json:"subject"`
+	Resource string            `json:"resource"`
+	Records  []ExecAuditRecord `json:"records"`
+}
+
+// ExecAuditRecord 一条本地执行记录。
+type ExecAuditRecord struct {
+	Kind           string    `json:"kind"` // hot_cache | fail_open
+	TraceID        string    `json:"trace_id,omitempty"`
+	Action         string    `json:"action"`
+	Argv           []string  `json:"argv"`
+	CommandLine    string    `json:"command_line,omitempty"`
+	ProfileID      string    `json:"profile_id,omitempty"`
+	ProfileVersion string    `json:"profile_version,omitempty"`
+	ExecutedAt     time.Time `json:"executed_at"`
+	ExitCode       int       `json:"exit_code"`
+}
+
+// ExecAuditResult POST /auth/exec/audit 的响应：写入条数与不符合 profile 的条数。
+type ExecAuditResult struct {
+	Recorded   int `json:"recorded"`
+	Violations int `json:"violations"`
+}
+
+// recordLocalExec 复核一条本地执行记录并写审计：hot_cache 须命中 profile 的 Hot Cache，fail_open 须 profile 为 FAIL_OPEN，
+// 否则 decision 记为 *_violation。返回是否符合 profile。
+func (p *pipeline) recordLocalExec(ctx context.Context, req *models.RequestContext, rec *ExecAuditRecord) bool {
+	profile := p.profiles.match(req.Resource)
+	var ok bool
+	switch rec.Kind {
+	case LocalExecHotCache:
+		ok = hotcache.Allows(profile.HotCacheActions, rec.Argv)
+	case LocalExecFailOpen:
+		ok = profile.DegradationPolicy == "FAIL_OPEN"
+	}
+	decision := rec.Kind + "_allow"
+	if !ok {
+		decision = rec.Kind + "_violation"
+	}
+	cmd := rec.CommandLine
+	if cmd == "" {
+		cmd = strings.Join(rec.Argv, " ")
+	}
+	reason := fmt.Sprintf("%q executed locally with profile %s@%s, exit code %d", cmd, rec.ProfileID, rec.ProfileVersion, rec.ExitCode)
+	if rec.ProfileID != profile.ProfileID || rec.ProfileVersion != profile.Version {
+		reason += fmt.Sprintf("; current profile %s@%s", profile.ProfileID, profile.Version)
+	}
+	traceID := rec.TraceID
+	if traceID == "" {
+		traceID = uuid.New().String()
+	}
+	ev := cheqEvidence(traceID, req, decision, "sandbox_profile", reason, "", "", nil)
+	ev.ProfileID = rec.ProfileID
+	if !rec.ExecutedAt.IsZero() {
+		ev.Timestamp = rec.ExecutedAt
+	}
+	_ = p.audit.Append(ctx, ev)
+	return ok
+}
+
+// execAuditHandler 处理 POST /auth/exec/audit：L0 认证同 /auth/exec（凭证、客户端证书、Unix 套接字对端身份），
+// 通过后逐条复核并写审计。
+func (s *Server) execAuditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var batch ExecAuditBatch
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxExecAuditBytes)).Decode(&batch); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if batch.Subject == "" || batch.Resource == "" || len(batch.Records) > maxExecAuditRecords {
+			writeJSONError(w, http.StatusBadRequest, "missing subject/resource or too many records")
+			return
+		}
+		for i := range batch.Records {
+			rec := &batch.Records[i]
+			if (rec.Kind != LocalExecHotCache && rec.Kind != LocalExecFailOpen) || len(rec.Argv) == 0 || rec.Action == "" {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("records[%d]: invalid kind, action or argv", i))
+				return
+			}
+		}
+		agentIdentity := requestCredential(r)
+		if agentIdentity == "" {
+			agentIdentity = batch.Subject
+		}
+		// 凭证的作用域按记录的 action 校验：每个 action 认证一次，任一不通过则整批拒绝
+		ctx := r.Context()
+		reqs := make(map[string]*models.RequestContext)
+		for _, rec := range batch.Records {
+			if reqs[rec.Action] != nil {
+				continue
+			}
+			req := BuildRequestContextFromExec(&ExecAuthRequest{Subject: batch.Subject, Action: rec.Action, Resource: batch.Resource}, agentIdentity)
+			s.applyClientCert(r, req)
+			s.applyPeerCred(r, req)
+			if d := s.pipeline.authenticate(ctx, uuid.New().String(), req); d != nil {
+				writeJSONError(w, d.Status, d.Reason)
+				return
+			}
+			reqs[rec.Action] = req
+		}
+		res := ExecAuditResult{}
+		for i := range batch.Records {
+			rec := &batch.Records[i]
+			if !s.pipeline.recordLocalExec(ctx, reqs[rec.Action], rec) {
+				res.Violations++
+			}
+			res.Recorded++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
@@ -0,0 +1,66 @@
+// HTTP 消息签名 L0：Agent 以链上 DID 的公钥对请求签名（RFC 9421 风格），校验通过后以 DID 作为 Agent 身份；
+// POST /auth/challenge 签发一次性 nonce，供要求 challenge 时签名使用。
+
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"diting/internal/httpsig"
+	"diting/internal/models"
+)
+
+// SetSignatureVerifier 启用 HTTP 消息签名校验：带 Signature-Input 的请求须验签通过，签名 DID 作为 Agent 身份。
+func (s *Server) SetSignatureVerifier(v *httpsig.Verifier) {
+	s.httpsig = v
+}
+
+// checkSignature 校验 r 的消息签名，返回签名 DID；未启用或请求未签名时返回空。
+func (s *Server) checkSignature(r *http.Request) (string, error) {
+	if s.httpsig == nil || !httpsig.Signed(r) {
+		return "", nil
+	}
+	res, err := s.httpsig.Verify(r.Context(), r, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return res.KeyID, nil
+}
+
+// applySignature 将 r 的签名校验结果写入 req。
+func (s *Server) applySignature(r *http.Request, req *models.RequestContext) {
+	if req != nil {
+		req.SignatureDID, req.SignatureError = s.checkSignature(r)
+	}
+}
+
+// challengeHandler 处理 POST /auth/challenge：请求体 {"keyid":"<did>"}，返回 {"nonce","expires_at"}；DID 不可用时 403。
+func (s *Server) challengeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var body struct {
+			KeyID string `json:"keyid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.KeyID == "" {
+			writeJSONError(w, http.StatusBadRequest, "keyid required")
+			return
+		}
+		nonce, exp, err := s.httpsig.Challenge(r.Context(), body.KeyID, time.Now())
+		switch {
+		case errors.Is(err, httpsig.ErrRevoked), errors.Is(err, httpsig.ErrInvalid):
+			writeJSONError(w, http.StatusForbidden, "unknown or inactive did")
+			return
+		case err != nil:
+			writeJSONError(w, http.StatusInternalServerError, "challenge failed")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"nonce": nonce, "expires_at": exp.UTC()})
+	}
+}
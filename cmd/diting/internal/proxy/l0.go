@@ -0,0 +1,321 @@
+// L0 身份校验：注册表凭证、mTLS 客户端证书与 DID 消息签名，其次为按序尝试的认证链（API Key、JWT）。API Key 只以加盐哈希保存，按 key id
+// 定位后常量时间比对；JWT 按 JWKS 验签并映射 claim。通过后以解析出的 Agent（或 "key:<id>"）作为策略与审计主体，
+// 审计中不出现所携带的 token。
+
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"diting/internal/apikey"
+	"diting/internal/config"
+	"diting/internal/httpsig"
+	"diting/internal/jwtauth"
+	"diting/internal/models"
+)
+
+// l0Denial 为 L0 校验的拒绝结果。
+type l0Denial struct {
+	RuleID string
+	Reason string
+	Status int // HTTP 状态码
+}
+
+var (
+	// errL0Skip 表示 token 不属于该认证器（格式不符或不是其签发），交给链中下一个认证器。
+	errL0Skip = errors.New("l0: not handled")
+	// errL0Expired 表示凭证已过期或尚未生效。
+	errL0Expired = errors.New("l0: credential expired")
+)
+
+// l0Identity 为 L0 认证器解析出的身份。
+type l0Identity struct {
+	KeyID   string // API Key 的 key id；其他方式为空
+	AgentID string
+	Team    string
+	Labels  map[string]string
+	// Allows 凭证自身的作用域；nil 表示不限
+	Allows func(action, resource string) bool
+}
+
+// l0Authenticator 为 L0 认证链中的一环。token 不归其处理时返回 errL0Skip，过期返回包装 errL0Expired 的错误，
+// 其他错误表示 token 归其处理但校验失败（不再尝试后续认证器）。
+type l0Authenticator interface {
+	authenticate(ctx context.Context, token string, now time.Time) (*l0Identity, error)
+}
+
+// apiKeyAuthenticator 以 API Key 存储校验 token。
+type apiKeyAuthenticator struct{ store apikey.Store }
+
+func (a apiKeyAuthenticator) authenticate(ctx context.Context, token string, now time.Time) (*l0Identity, error) {
+	k, err := a.store.Verify(ctx, token, now)
+	switch {
+	case err == apikey.ErrInvalid:
+		return nil, errL0Skip
+	case err == apikey.ErrExpired:
+		return nil, fmt.Errorf("%w: api key expired", errL0Expired)
+	case err != nil:
+		return nil, err
+	}
+	return &l0Identity{KeyID: k.ID, AgentID: k.AgentID, Allows: k.Allows}, nil
+}
+
+// jwtAuthenticator 校验 JWT Bearer Token，按 claim 映射得到 Agent、团队与标签。
+type jwtAuthenticator struct{ verifier *jwtauth.Verifier }
+
+func (a jwtAuthenticator) authenticate(ctx context.Context, token string, now time.Time) (*l0Identity, error) {
+	id, err := a.verifier.Verify(ctx, token, now)
+	switch {
+	case err == jwtauth.ErrMalformed:
+		return nil, errL0Skip
+	case err == jwtauth.ErrExpired:
+		return nil, fmt.Errorf("%w: jwt expired or not yet valid", errL0Expired)
+	case err != nil:
+		return nil, err
+	}
+	return &l0Identity{AgentID: id.Subject, Team: id.Team, Labels: id.Labels}, nil
+}
+
+// authenticate 执行 L0 校验：先按注册表凭证解析 Agent；请求带签名但验签失败时拒绝；有验签通过的 DID 或已验证的 mTLS
+// 客户端证书时以其身份为准（见 admitVerified）；经 Unix 套接字且未携带凭证时以对端凭证为身份；否则启用了 L0 认证链时
+// 按序尝试各认证器，由第一个认领 token 的认证器决定结果。通过后写入 req 的 KeyID 与 Agent 身份，该 Agent 在注册表中时同样受其状态约束（团队与标签以注册表为准），
+// 最后检查凭证自身的作用域（action/resource）。放行返回 nil。
+func (p *pipeline) authenticate(ctx context.Context, traceID string, req *models.RequestContext) *l0Denial {
+	if reason := p.agentGate(ctx, traceID, req); reason != "" {
+		return &l0Denial{RuleID: "agent_registry", Reason: reason, Status: http.StatusForbidden}
+	}
+	if err := req.SignatureError; err != nil {
+		if errors.Is(err, httpsig.ErrRevoked) {
+			p.appendEvidence(ctx, traceID, req, "l0_did_revoked", "l0", err.Error())
+			return &l0Denial{RuleID: "l0", Reason: "signing did is revoked or inactive", Status: http.StatusForbidden}
+		}
+		p.appendEvidence(ctx, traceID, req, "l0_signature", "l0", err.Error())
+		return &l0Denial{RuleID: "l0", Reason: "invalid http message signature", Status: http.StatusUnauthorized}
+	}
+	if req.SignatureDID != "" {
+		if req.CertIdentity != "" && req.CertIdentity != req.SignatureDID {
+			reason := fmt.Sprintf("signing did %s does not match client certificate identity %s", req.SignatureDID, req.CertIdentity)
+			p.appendEvidence(ctx, traceID, req, "l0_did_mismatch", "l0", reason)
+			return &l0Denial{RuleID: "l0", Reason: "signing did does not match client certificate", Status: http.StatusForbidden}
+		}
+		return p.admitVerified(ctx, traceID, req, req.SignatureDID, "signing did", "l0_did_mismatch")
+	}
+	if req.CertIdentity != "" {
+		return p.admitVerified(ctx, traceID, req, req.CertIdentity, "client certificate", "l0_cert_mismatch")
+	}
+	if req.PeerIdentity != "" && req.AgentID == "" && normalizeL0Token(req.AgentIdentity) == "" {
+		// 经 Unix 套接字且未携带凭证：以内核提供的对端凭证作为身份
+		return p.admitVerified(ctx, traceID, req, req.PeerIdentity, "peer credential", "l0_peer_mismatch")
+	}
+	if len(p.l0) == 0 || req.AgentID != "" {
+		return nil
+	}
+	// 校验失败的审计不记录所携带的身份（可能是密钥）
+	anon := *req
+	anon.AgentIdentity = ""
+	token := normalizeL0Token(req.AgentIdentity)
+	if token == "" {
+		p.appendEvidence(ctx, traceID, &anon, "l0_missing", "l0", "missing or empty agent identity")
+		return &l0Denial{RuleID: "l0", Reason: "missing or invalid agent identity", Status: http.StatusUnauthorized}
+	}
+	now := time.Now()
+	var id *l0Identity
+	err := errL0Skip
+	for _, a := range p.l0 {
+		if id, err = a.authenticate(ctx, token, now); err != errL0Skip {
+			break
+		}
+	}
+	switch {
+	case err == errL0Skip:
+		p.appendEvidence(ctx, traceID, &anon, "l0_invalid", "l0", "agent identity is not a valid credential")
+		return &l0Denial{RuleID: "l0", Reason: "invalid agent identity", Status: http.StatusUnauthorized}
+	case errors.Is(err, errL0Expired):
+		p.appendEvidence(ctx, traceID, &anon, "l0_expired", "l0", strings.TrimPrefix(err.Error(), errL0Expired.Error()+": "))
+		return &l0Denial{RuleID: "l0", Reason: "credential expired", Status: http.StatusUnauthorized}
+	case err != nil:
+		p.appendEvidence(ctx, traceID, &anon, "l0_invalid", "l0", err.Error())
+		return &l0Denial{RuleID: "l0", Reason: "invalid agent identity", Status: http.StatusUnauthorized}
+	}
+	req.KeyID, req.AgentID, req.AgentTeam, req.AgentLabels = id.KeyID, id.AgentID, id.Team, id.Labels
+	if id.AgentID != "" && p.agents != nil {
+		if a, err := p.agents.Get(ctx, id.AgentID); err == nil {
+			if reason := p.admitAgent(ctx, traceID, req, a); reason != "" {
+				return &l0Denial{RuleID: "agent_registry", Reason: reason, Status: http.StatusForbidden}
+			}
+		}
+	}
+	action, resource := req.Action, req.Resource
+	if action == "" {
+		action = req.Method
+	}
+	if resource == "" {
+		resource = req.TargetURL
+	}
+	if id.Allows != nil && !id.Allows(action, resource) {
+		p.appendEvidence(ctx, traceID, req, "l0_scope", "l0", fmt.Sprintf("api key %s not permitted for %s %s", id.KeyID, action, resource))
+		return &l0Denial{RuleID: "l0", Reason: "api key not permitted for this action or resource", Status: http.StatusForbidden}
+	}
+	return nil
+}
+
+// admitVerified 以已验证的身份 identity（mTLS 客户端证书或签名 DID，source 为其描述）作为 Agent：已按注册表凭证解析出
+// 其他 Agent 时拒绝并以 decision 记审计（身份与凭证不一致），该 Agent 在注册表中时受其状态约束。已验证的身份无须再经认证链。
+func (p *pipeline) admitVerified(ctx context.Context, traceID string, req *models.RequestContext, identity, source, decision string) *l0Denial {
+	if req.AgentID != "" && req.AgentID != identity {
+		reason := fmt.Sprintf("%s identity %s does not match agent %s", source, identity, req.AgentID)
+		p.appendEvidence(ctx, traceID, req, decision, "l0", reason)
+		return &l0Denial{RuleID: "l0", Reason: source + " does not match agent credential", Status: http.StatusForbidden}
+	}
+	if req.AgentID != "" {
+		return nil
+	}
+	req.AgentID = identity
+	if p.agents != nil {
+		if a, err := p.agents.Get(ctx, req.AgentID); err == nil {
+			if reason := p.admitAgent(ctx, traceID, req, a); reason != "" {
+				return &l0Denial{RuleID: "agent_registry", Reason: reason, Status: http.StatusForbidden}
+			}
+		}
+	}
+	return nil
+}
+
+// SetJWTVerifier 启用 JWT Bearer Token 形式的 L0 校验，在认证链中的位置由 proxy.l0_authenticators 决定。
+func (s *Server) SetJWTVerifier(v *jwtauth.Verifier) {
+	s.jwt = v
+	s.pipeline.l0 = l0Chain(s.cfg.Proxy.L0Authenticators, s.apiKeys, s.jwt)
+}
+
+// l0Chain 按 order（空表示 api_key、jwt）组装已启用的认证器；未启用或未知的名称忽略。
+func l0Chain(order []string, keys apikey.Store, jwt *jwtauth.Verifier) []l0Authenticator {
+	if len(order) == 0 {
+		order = []string{"api_key", "jwt"}
+	}
+	var chain []l0Authenticator
+	for _, name := range order {
+		switch {
+		case name == "api_key" && keys != nil:
+			chain = append(chain, apiKeyAuthenticator{keys})
+		case name == "jwt" && jwt != nil:
+			chain = append(chain, jwtAuthenticator{jwt})
+		}
+	}
+	return chain
+}
+
+// SetAPIKeyStore 启用 API Key 形式的 L0 校验，并将配置中的 Key 写入 st：proxy.api_keys（哈希）与已弃用的
+// proxy.allowed_api_keys（明文，启动时转为哈希，key id 由明文的哈希派生）。已有记录保留创建与最近使用时间；
+// st 中来自配置、但已不在配置中的 Key 被删除。配置的哈希或过期时间格式错误时返回错误。
+func (s *Server) SetAPIKeyStore(st apikey.Store) error {
+	ctx := context.Background()
+	keys, err := configuredAPIKeys(s.cfg.Proxy)
+	if err != nil {
+		return err
+	}
+	configured := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		configured[k.ID] = true
+		if prev, err := st.Get(ctx, k.ID); err == nil {
+			k.CreatedAt, k.LastUsedAt = prev.CreatedAt, prev.LastUsedAt
+		}
+		if err := st.Put(ctx, k); err != nil {
+			return err
+		}
+	}
+	existing, err := st.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range existing {
+		if fromConfig(k) && !configured[k.ID] {
+			if err := st.Delete(ctx, k.ID); err != nil && err != apikey.ErrNotFound {
+				return err
+			}
+		}
+	}
+	s.apiKeys = st
+	s.pipeline.l0 = l0Chain(s.cfg.Proxy.L0Authenticators, s.apiKeys, s.jwt)
+	return nil
+}
+
+// fromConfig 返回 Key 是否由配置写入；早期版本未记录 Source，按写入时的 Description 识别。
+func fromConfig(k *apikey.Key) bool {
+	return k.Source == apikey.SourceConfig || k.Description == "proxy.api_keys" || k.Description == "proxy.allowed_api_keys"
+}
+
+// configuredAPIKeys 将配置中的 Key 转为存储记录。allowed_api_keys 的 key id 为 legacy-<明文 sha256 前 12 位>，
+// 不随列表顺序变化。
+func configuredAPIKeys(cfg config.ProxyConfig) ([]*apikey.Key, error) {
+	now := time.Now()
+	var out []*apikey.Key
+	for _, tok := range cfg.AllowedAPIKeys {
+		tok = strings.TrimSpace(tok)
+		sum := sha256.Sum256([]byte(tok))
+		k := &apikey.Key{ID: "legacy-" + hex.EncodeToString(sum[:6]), Description: "proxy.allowed_api_keys", Source: apikey.SourceConfig, CreatedAt: now}
+		if err := k.SetSecret(tok); err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	for i, c := range cfg.APIKeys {
+		salt, hash, ok := strings.Cut(c.Hash, ":")
+		if c.ID == "" || !ok || !isHex(salt) || !isHex(hash) {
+			return nil, fmt.Errorf("proxy.api_keys[%d]: id and hash (<salt>:<sha256>) required", i)
+		}
+		k := &apikey.Key{
+			ID:          c.ID,
+			AgentID:     c.AgentID,
+			Salt:        salt,
+			Hash:        hash,
+			Actions:     c.Actions,
+			Resources:   c.Resources,
+			Description: "proxy.api_keys",
+			Source:      apikey.SourceConfig,
+			CreatedAt:   now,
+		}
+		if c.ExpiresAt != "" {
+			t, err := time.Parse(time.RFC3339, c.ExpiresAt)
+			if err != nil {
+				return nil, fmt.Errorf("proxy.api_keys[%d].expires_at: %v", i, err)
+			}
+			k.ExpiresAt = t
+		}
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return s != "" && err == nil
+}
+
+// newConfiguredAPIKeyStore 在配置了 Key 时返回已写入这些 Key 的内存存储，供 NewServer 默认启用 L0；未配置返回 nil, nil。
+// 任一条目无法解析时返回错误。
+func newConfiguredAPIKeyStore(cfg config.ProxyConfig) (apikey.Store, error) {
+	if len(cfg.AllowedAPIKeys) == 0 && len(cfg.APIKeys) == 0 {
+		return nil, nil
+	}
+	keys, err := configuredAPIKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	st, err := apikey.NewFileStore("")
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if err := st.Put(context.Background(), k); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
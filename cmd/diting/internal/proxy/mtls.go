@@ -0,0 +1,32 @@
+// TLS / mTLS 监听：已验证的客户端证书按 proxy.tls.client_identity 映射为 L0 的 Agent 身份，证书指纹写入审计。
+
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"diting/internal/models"
+	"diting/internal/mtls"
+)
+
+// SetTLS 以 r 提供的证书启用 HTTPS 监听（Serve 时生效）；r 配置了客户端 CA 时即为 mTLS。
+func (s *Server) SetTLS(r *mtls.Reloader) {
+	s.tls = r
+}
+
+// applyClientCert 从已验证的客户端证书取 Agent 身份与指纹写入 req；未出示证书或未经 CA 校验时不写入。
+func (s *Server) applyClientCert(r *http.Request, req *models.RequestContext) {
+	s.applyPeerCert(r.TLS, req)
+}
+
+// applyPeerCert 同 applyClientCert，取自连接的 TLS 状态（HTTP 与 gRPC 共用）。
+func (s *Server) applyPeerCert(state *tls.ConnectionState, req *models.RequestContext) {
+	if req == nil || state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return
+	}
+	cert := state.VerifiedChains[0][0]
+	c := s.cfg.Proxy.TLS
+	req.CertIdentity = mtls.Identity(cert, c.ClientIdentity, c.SPIFFETrustDomain)
+	req.CertFingerprint = mtls.Fingerprint(cert)
+}
@@ -0,0 +1,313 @@
+// Agent 接入：POST /init_permission 登记 Agent 并向 owner 发起 agent_onboarding 审批，
+// 批准后签发 L0 凭证并挂载基线策略；待审批的 Agent 在流水线中除健康检查路径外一律拒绝。
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"diting/internal/agent"
+	"diting/internal/cheq"
+	"diting/internal/models"
+	"diting/internal/policy"
+)
+
+// defaultOnboardingTimeout 接入审批默认时限。
+const defaultOnboardingTimeout = 24 * time.Hour
+
+// errPickupTokenInvalid 查询接入状态时领取口令缺失或不匹配。
+var errPickupTokenInvalid = errors.New("invalid pickup token")
+
+// defaultAgentHealthPath 待审批 Agent 默认可访问的健康检查路径。
+const defaultAgentHealthPath = "/healthz"
+
+// AgentOnboardingView 为 /init_permission 的响应；pickup_token 仅在登记请求发起审批时返回，
+// credential 仅在批准后凭 pickup_token 首次查询时返回。
+type AgentOnboardingView struct {
+	AgentID     string `json:"agent_id"`
+	OwnerID     string `json:"owner_id,omitempty"`
+	State       string `json:"state"`
+	CHEQID      string `json:"cheq_id,omitempty"`
+	PickupToken string `json:"pickup_token,omitempty"`
+	Credential  string `json:"credential,omitempty"`
+}
+
+// SetAgentRegistry 启用 Agent 接入流程：/init_permission 写入注册表并发起审批，流水线拦截待审批 Agent。
+// template 为基线策略模板，批准后按 Agent 实例化；已激活的 Agent 在此重新挂载（进程重启后）。
+func (s *Server) SetAgentRegistry(reg agent.Registry, template []policy.Rule) {
+	s.agents = reg
+	p := s.pipeline
+	p.agents = reg
+	p.agentTemplate = template
+	p.agentHealthPath = s.cfg.Agents.HealthPath
+	if p.agentHealthPath == "" {
+		p.agentHealthPath = defaultAgentHealthPath
+	}
+	list, err := reg.List(context.Background())
+	if err != nil {
+		return
+	}
+	for _, a := range list {
+		if a.State == agent.StateActive {
+			p.applyAgentPolicy(a.ID)
+		}
+	}
+}
+
+// initPermissionHandler 处理天枢「Agent 注册完成」通知：POST /init_permission，体为 agent_id、owner_id，
+// 配置 admin token 时须带 X-Admin-Token；发起审批时响应中返回一次性的 pickup_token。
+// GET /init_permission?agent_id= 查询接入状态，批准后凭 X-Pickup-Token 首次查询返回 L0 凭证。
+// 未启用 agents 时保持占位行为：POST 返回 200 OK，默认策略由全局 policy 规则文件生效。
+func (s *Server) initPermissionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && s.agents != nil:
+			s.onboardingStatus(w, r)
+			return
+		case r.Method != http.MethodPost:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// owner_id 即接入审批人，登记须由天枢等上游服务以管理 token 发起
+		if _, ok := s.adminOf(r); !ok {
+			writeJSONError(w, http.StatusUnauthorized, errAdminTokenInvalid.Error())
+			return
+		}
+		var body struct {
+			AgentID string `json:"agent_id"`
+			OwnerID string `json:"owner_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if s.agents == nil {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		if body.AgentID == "" || body.OwnerID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"agent_id and owner_id required"}`))
+			return
+		}
+		a, pickup, err := s.onboard(r.Context(), body.AgentID, body.OwnerID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"onboarding failed"}`))
+			return
+		}
+		if a.State == agent.StateActive {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		v := onboardingView(a, "")
+		v.PickupToken = pickup
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+// onboardingStatus 处理 GET /init_permission?agent_id=：须出示登记时返回的 X-Pickup-Token，或为管理员（仅查看状态）。
+// 凭证在批准后凭领取口令首次查询时签发并只下发一次，随后口令作废；注册表只保存二者的哈希。
+func (s *Server) onboardingStatus(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("agent_id")
+	pickup := r.Header.Get("X-Pickup-Token")
+	w.Header().Set("Content-Type", "application/json")
+	_, isAdmin := s.adminOf(r)
+	a, err := s.pipeline.reconcileAgent(r.Context(), id)
+	if err == nil && !isAdmin && !a.PickupMatches(pickup) {
+		err = errPickupTokenInvalid
+	}
+	if err != nil {
+		if err == errPickupTokenInvalid || (err == agent.ErrNotFound && !isAdmin) {
+			writeJSONError(w, http.StatusUnauthorized, errPickupTokenInvalid.Error())
+			return
+		}
+		if err == agent.ErrNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"lookup failed"}`))
+		return
+	}
+	credential := ""
+	if a.State == agent.StateActive && a.CredentialPending && a.PickupMatches(pickup) {
+		issued, err := a.IssueCredential()
+		if err == nil {
+			a.CredentialPending, a.CredentialDelivered = false, true
+			a.PickupSalt, a.PickupHash = "", ""
+			err = s.agents.Put(r.Context(), a)
+		}
+		if err == nil {
+			credential = issued
+		}
+	}
+	_ = json.NewEncoder(w).Encode(onboardingView(a, credential))
+}
+
+func onboardingView(a *agent.Agent, credential string) AgentOnboardingView {
+	return AgentOnboardingView{AgentID: a.ID, OwnerID: a.OwnerID, State: string(a.State), CHEQID: a.OnboardingCHEQID, Credential: credential}
+}
+
+// onboard 登记 Agent 并发起接入审批，返回领取凭证的一次性口令；非 pending（已激活、暂停或吊销）或已有进行中审批时
+// 直接返回当前记录，不再签发口令。
+func (s *Server) onboard(ctx context.Context, agentID, ownerID string) (*agent.Agent, string, error) {
+	p := s.pipeline
+	a, err := p.reconcileAgent(ctx, agentID)
+	switch {
+	case err == agent.ErrNotFound:
+		a = &agent.Agent{ID: agentID, State: agent.StatePending, CreatedAt: time.Now()}
+	case err != nil:
+		return nil, "", err
+	case a.State != agent.StatePending || a.OnboardingCHEQID != "":
+		return a, "", nil
+	}
+	timeout := time.Duration(s.cfg.Agents.OnboardingTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultOnboardingTimeout
+	}
+	traceID := uuid.New().String()
+	obj, err := p.cheq.Create(ctx, &cheq.CreateInput{
+		TraceID:        traceID,
+		Resource:       "agent://" + agentID,
+		Action:         "agent:onboard",
+		Summary:        fmt.Sprintf("Agent 接入申请：%s（owner %s）", agentID, ownerID),
+		ExpiresAt:      time.Now().Add(timeout),
+		ConfirmerIDs:   []string{ownerID},
+		KeepConfirmers: true,
+		Type:           "agent_onboarding",
+		Requester:      agentID,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	pickup, err := a.IssuePickupToken()
+	if err != nil {
+		return nil, "", err
+	}
+	a.OwnerID = ownerID
+	a.OnboardingCHEQID = obj.ID
+	if err := s.agents.Put(ctx, a); err != nil {
+		return nil, "", err
+	}
+	p.appendAgentEvidence(ctx, traceID, a, "agent_registered", "agent onboarding requested, pending owner approval", obj.ID, string(obj.Status), obj.ConfirmerIDs)
+	go p.awaitOnboarding(agentID, obj)
+	return a, pickup, nil
+}
+
+// awaitOnboarding 订阅接入审批至终态后更新注册表；进程重启丢失订阅时由 reconcileAgent 在下次访问时补做。
+func (p *pipeline) awaitOnboarding(agentID string, obj *models.ConfirmationObject) {
+	ctx := context.Background()
+	if status, _ := p.waitCHEQ(ctx, obj, false); status == "" {
+		return
+	}
+	if _, err := p.reconcileAgent(ctx, agentID); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "[diting] Agent 接入状态更新失败 agent=%s: %v\n", agentID, err)
+	}
+}
+
+// reconcileAgent 读取 Agent 记录；若处于 pending 且接入审批已终态，批准则激活（标记待签发凭证、挂载基线策略），
+// 拒绝或过期则清空审批以便重新申请。返回更新后的记录。
+func (p *pipeline) reconcileAgent(ctx context.Context, id string) (*agent.Agent, error) {
+	p.agentMu.Lock()
+	defer p.agentMu.Unlock()
+	a, err := p.agents.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.State != agent.StatePending || a.OnboardingCHEQID == "" {
+		return a, nil
+	}
+	obj, err := p.cheq.GetByID(ctx, a.OnboardingCHEQID)
+	if err != nil || obj == nil || !obj.IsTerminal() {
+		return a, nil
+	}
+	if obj.Status != models.ConfirmationStatusApproved {
+		a.OnboardingCHEQID = ""
+		if err := p.agents.Put(ctx, a); err != nil {
+			return nil, err
+		}
+		p.appendAgentEvidence(ctx, obj.TraceID, a, "agent_onboarding_"+string(obj.Status), confirmationReason(string(obj.Status), obj), obj.ID, string(obj.Status), obj.ApprovedBy)
+		return a, nil
+	}
+	a.State = agent.StateActive
+	a.CredentialPending = true
+	a.ApprovedBy = obj.ApprovedBy
+	a.ActivatedAt = time.Now()
+	if err := p.agents.Put(ctx, a); err != nil {
+		return nil, err
+	}
+	p.applyAgentPolicy(a.ID)
+	p.appendAgentEvidence(ctx, obj.TraceID, a, "agent_activated", "agent onboarding approved, credential issued on first status query", obj.ID, string(obj.Status), obj.ApprovedBy)
+	return a, nil
+}
+
+// applyAgentPolicy 按模板为 Agent 挂载基线策略；策略引擎不支持按 Agent 下发或无模板时忽略。
+func (p *pipeline) applyAgentPolicy(agentID string) {
+	setter, ok := p.policy.(policy.AgentRuleSetter)
+	if !ok || len(p.agentTemplate) == 0 {
+		return
+	}
+	setter.SetAgentRules(agentID, policy.AgentRules(agentID, p.agentTemplate))
+}
+
+// agentGate 按注册表解析请求身份：已签发的凭证解析为 Agent 并经 admitAgent 放行或拒绝，且免于 API Key 校验；
+// 以 agent_id 作身份时仅解析非 active 的 Agent（以便拦截）。未启用注册表或身份不在注册表中时不拦截。
+func (p *pipeline) agentGate(ctx context.Context, traceID string, req *models.RequestContext) string {
+	if p.agents == nil {
+		return ""
+	}
+	token := normalizeL0Token(req.AgentIdentity)
+	if token == "" {
+		return ""
+	}
+	a, err := p.agents.ByCredential(ctx, token)
+	if err != nil {
+		a, err = p.reconcileAgent(ctx, token)
+		if err != nil || a.State == agent.StateActive {
+			return ""
+		}
+	}
+	return p.admitAgent(ctx, traceID, req, a)
+}
+
+// admitAgent 将 Agent 的 ID、团队与标签写入 req 供策略匹配，并按状态放行：pending 除健康检查路径外、suspended 与 revoked
+// 一律返回拒绝原因并写审计；放行返回空。
+func (p *pipeline) admitAgent(ctx context.Context, traceID string, req *models.RequestContext, a *agent.Agent) string {
+	req.AgentID, req.AgentTeam, req.AgentLabels = a.ID, a.Team, a.Labels
+	p.agents.Touch(ctx, a.ID, time.Now())
+	var reason, ruleID string
+	switch a.State {
+	case agent.StateActive:
+		return ""
+	case agent.StatePending:
+		if req.Method != "EXEC" && req.Resource == p.agentHealthPath {
+			return ""
+		}
+		reason, ruleID = "agent pending onboarding approval", "agent_onboarding"
+	default:
+		reason, ruleID = "agent "+string(a.State), "agent_registry"
+		if a.Quarantine != nil {
+			reason = "agent quarantined: " + a.Quarantine.Reason
+		}
+	}
+	p.appendEvidence(ctx, traceID, req, "agent_"+string(a.State), ruleID, reason)
+	return reason
+}
+
+// appendAgentEvidence 写一条 Agent 接入审计，引用接入审批的 CHEQ。
+func (p *pipeline) appendAgentEvidence(ctx context.Context, traceID string, a *agent.Agent, decision, reason, cheqID, cheqStatus string, confirmerIDs []string) {
+	p.appendEvidenceWithCHEQ(ctx, traceID, &models.RequestContext{AgentID: a.ID, Resource: "agent://" + a.ID, Action: "agent:onboard"}, decision, "agent_onboarding", reason, cheqID, cheqStatus, confirmerIDs)
+}
@@ -7,14 +7,19 @@ import (
 	"net/http/httputil"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"diting/internal/agent"
 	"diting/internal/audit"
 	"diting/internal/cheq"
 	"diting/internal/delivery"
+	"diting/internal/grant"
 	"diting/internal/models"
 	"diting/internal/ownership"
 	"diting/internal/policy"
+
+	"github.com/google/uuid"
 )
 
 // responseWriterWithTraceID 在首次 WriteHeader 时注入 X-Trace-ID，便于验收时按 trace_id 查审计。
@@ -41,8 +46,18 @@ type pipeline struct {
 	cheqTimeoutSec               int
 	reminderSecondsBeforeTimeout int // 超时前多少秒发飞书提醒；0 用默认 60
 	reviewRequiresApproval       bool
-	allowedAPIKeys               []string // 非空时启用 L0 校验：身份须在此列表中
+	l0                           []l0Authenticator      // L0 认证链；非空时未经注册表解析的请求须通过其中一个认证器
 	approvalMatcher              *ownership.RuleMatcher // I-009：按 path/risk 匹配超时与审批人；nil 则用全局配置
+	requesterOwners              map[string]string      // Agent 身份 -> owner，职责分离：owner 不可审批该 Agent 的请求
+	grants                       grant.Store            // 限时授权；nil 表示不启用
+	grantMaxMinutes              int                    // 限时授权时长上限（分钟）；0 用默认 480
+	profiles                     *sandboxProfiles       // 按 resource 下发的 Sandbox Profile；exec 审计记录命中的 profile_id
+
+	agents          agent.Registry // Agent 注册表；nil 表示不解析 Agent、不拦截待审批 Agent
+	agentTemplate   []policy.Rule  // Agent 接入批准后挂载的基线策略模板
+	agentHealthPath string         // 待审批 Agent 仍可访问的路径
+	agentMu         sync.Mutex     // 串行化注册表状态变更（接入审批落库、紧急隔离），避免重复签发凭证
+	inflight        inflightSet    // 已解析 Agent 的在途请求，紧急隔离时中止
 }
 
 func (p *pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request, reqCtx *models.RequestContext, rp *httputil.ReverseProxy) {
@@ -54,22 +69,15 @@ func (p *pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request, reqCtx *mod
 
 	wrap := &responseWriterWithTraceID{ResponseWriter: w, traceID: traceID}
 
-	// 3.2.1 L0 校验：若配置了 allowed_api_keys，未携带或无效身份则拒绝并写审计。
-	if len(p.allowedAPIKeys) > 0 {
-		token := normalizeL0Token(reqCtx.AgentIdentity)
-		if token == "" {
-			p.appendEvidence(ctx, traceID, reqCtx, "l0_missing", "l0", "missing or empty agent identity")
-			wrap.WriteHeader(http.StatusUnauthorized)
-			_, _ = wrap.Write([]byte("missing or invalid agent identity"))
-			return
-		}
-		if !containsString(p.allowedAPIKeys, token) {
-			p.appendEvidence(ctx, traceID, reqCtx, "l0_invalid", "l0", "agent identity not in allowed list")
-			wrap.WriteHeader(http.StatusUnauthorized)
-			_, _ = wrap.Write([]byte("invalid agent identity"))
-			return
-		}
+	// 3.2.1 L0 校验：注册表凭证或 API Key；未携带、无效、过期、超出作用域或 Agent 非 active 时拒绝并写审计
+	ctx, done, d := p.admit(ctx, traceID, reqCtx)
+	defer done()
+	if d != nil {
+		wrap.WriteHeader(d.Status)
+		_, _ = wrap.Write([]byte(d.Reason))
+		return
 	}
+	r = r.WithContext(ctx)
 
 	// 3.2.2 调用 PolicyEngine.Evaluate
 	decision, err := p.policy.Evaluate(ctx, reqCtx)
@@ -91,39 +99,12 @@ func (p *pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request, reqCtx *mod
 		_, _ = wrap.Write([]byte(decision.DecisionReason))
 	case decision.Review():
 		// 3.2.5 review：创建 CHEQ；若需人工确认则轮询直到终态或超时，否则立即放行（占位）
-		timeoutSec := p.cheqTimeoutSec
-		if timeoutSec <= 0 {
-			timeoutSec = 300
-		}
-		resource := reqCtx.Resource
-		if resource == "" {
-			resource = reqCtx.TargetURL
-		}
-		riskLevel := ""
-		if reqCtx.Context != nil {
-			riskLevel = reqCtx.Context["risk_level"]
-		}
-		var confirmerIDs []string
-		approvalPolicy := ""
-		if p.approvalMatcher != nil {
-			m := p.approvalMatcher.Match(resource, riskLevel)
-			if m.TimeoutSeconds > 0 {
-				timeoutSec = m.TimeoutSeconds
-			}
-			confirmerIDs = m.ApprovalUserIDs
-			approvalPolicy = m.ApprovalPolicy
-		}
-		expiresAt := time.Now().Add(time.Duration(timeoutSec) * time.Second)
-		in := &cheq.CreateInput{
-			TraceID:        traceID,
-			Resource:      resource,
-			Action:        reqCtx.Action,
-			Summary:       reqCtx.TargetURL,
-			ExpiresAt:     expiresAt,
-			ConfirmerIDs:  confirmerIDs,
-			Type:          "operation_approval",
-			ApprovalPolicy: approvalPolicy,
+		in, _ := p.reviewInput(traceID, reqCtx, reqCtx.TargetURL)
+		if g := p.matchGrant(ctx, traceID, reqCtx, in.Resource, decision.PolicyRuleID); g != nil {
+			rp.ServeHTTP(wrap, r)
+			break
 		}
+		in.Fingerprint = httpFingerprint(r, in.Requester, in.Action, in.Resource)
 		obj, err := p.cheq.Create(ctx, in)
 		if err != nil {
 			p.appendEvidence(ctx, traceID, reqCtx, "review_error", "cheq_create", err.Error())
@@ -131,50 +112,35 @@ func (p *pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request, reqCtx *mod
 			return
 		}
 		if !p.reviewRequiresApproval {
-			_ = p.cheq.Submit(ctx, obj.ID, true, "")
+			if err := p.autoApprove(ctx, traceID, reqCtx, obj); err != nil {
+				wrap.WriteHeader(http.StatusInternalServerError)
+				return
+			}
 			rp.ServeHTTP(wrap, r)
-			p.appendEvidenceWithCHEQ(ctx, traceID, reqCtx, "approved", decision.PolicyRuleID, decision.DecisionReason, string(models.ConfirmationStatusApproved), obj.ConfirmerIDs)
+			p.appendEvidenceWithCHEQ(ctx, traceID, reqCtx, "approved", decision.PolicyRuleID, decision.DecisionReason, obj.ID, string(models.ConfirmationStatusApproved), obj.ConfirmerIDs)
 			break
 		}
-		_, _ = fmt.Fprintf(os.Stderr, "[diting] CHEQ 待确认 id=%s 批准: http://localhost:8080/cheq/approve?id=%s&approved=true 拒绝: http://localhost:8080/cheq/approve?id=%s&approved=false\n", obj.ID, obj.ID, obj.ID)
-		deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
-		var finalStatus string
-		var reminded bool
-		var o *models.ConfirmationObject
-		for time.Now().Before(deadline) {
-			o, _ = p.cheq.GetByID(ctx, obj.ID)
-			if o == nil {
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			if o.IsTerminal() {
-				finalStatus = string(o.Status)
+		// 审批链接仅经投递渠道发出（按确认人签名），日志只记 id
+		_, _ = fmt.Fprintf(os.Stderr, "[diting] CHEQ 待确认 id=%s\n", obj.ID)
+		finalStatus, o := p.waitCHEQ(ctx, obj, true)
+		if finalStatus == string(models.ConfirmationStatusApproved) {
+			diff, err := amendHTTPRequest(r, o.Amendment)
+			if err != nil {
+				p.appendEvidenceWithCHEQ(ctx, traceID, reqCtx, "amendment_failed", decision.PolicyRuleID, err.Error(), o.ID, finalStatus, o.ConfirmerIDs)
+				wrap.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = wrap.Write([]byte("confirmation approved with amendment: " + err.Error()))
 				break
 			}
-			remindSec := p.reminderSecondsBeforeTimeout
-			if remindSec <= 0 {
-				remindSec = 60
-			}
-			if !reminded && p.delivery != nil && time.Until(o.ExpiresAt) <= time.Duration(remindSec)*time.Second {
-				reminded = true
-				_ = p.delivery.Deliver(ctx, &delivery.DeliverInput{Object: o, Options: &delivery.DeliverOptions{Summary: "【提醒】该请求即将超时，请尽快处理"}})
-			}
-			time.Sleep(2 * time.Second)
-		}
-		var evidenceConfirmerIDs []string
-		if o != nil {
-			evidenceConfirmerIDs = o.ConfirmerIDs
-		}
-		if finalStatus == string(models.ConfirmationStatusApproved) {
+			p.recordGrant(ctx, o)
 			rp.ServeHTTP(wrap, r)
-			p.appendEvidenceWithCHEQ(ctx, traceID, reqCtx, "approved", decision.PolicyRuleID, decision.DecisionReason, finalStatus, evidenceConfirmerIDs)
+			p.appendCHEQOutcome(ctx, traceID, reqCtx, decision.PolicyRuleID, cheqDecisionReason(decision.DecisionReason, o), finalStatus, o, diff)
 		} else {
 			wrap.WriteHeader(http.StatusForbidden)
 			if finalStatus == "" {
 				finalStatus = "expired"
 			}
-			p.appendEvidenceWithCHEQ(ctx, traceID, reqCtx, finalStatus, decision.PolicyRuleID, decision.DecisionReason, finalStatus, evidenceConfirmerIDs)
-			_, _ = wrap.Write([]byte("confirmation " + finalStatus))
+			p.appendCHEQOutcome(ctx, traceID, reqCtx, decision.PolicyRuleID, decision.DecisionReason, finalStatus, o, "")
+			_, _ = wrap.Write([]byte(confirmationReason(finalStatus, o)))
 		}
 	default:
 		p.appendEvidence(ctx, traceID, reqCtx, "unknown", decision.PolicyRuleID, decision.DecisionReason)
@@ -182,6 +148,264 @@ func (p *pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request, reqCtx *mod
 	}
 }
 
+// autoApprove 在无需人工确认时（reviewRequiresApproval 为 false）以空确认人批准 obj。审批策略要求计票时提交失败：
+// 撤销该 CHEQ（不留下待审批对象）并写 review_error 审计，调用方按失败处理、不放行。
+func (p *pipeline) autoApprove(ctx context.Context, traceID string, req *models.RequestContext, obj *models.ConfirmationObject) error {
+	err := p.cheq.Submit(ctx, obj.ID, true, "")
+	if err == nil {
+		return nil
+	}
+	_ = p.cheq.Cancel(ctx, obj.ID, "auto-approval", err.Error())
+	p.appendEvidenceWithCHEQ(ctx, traceID, req, "review_error", "cheq_submit", err.Error(), obj.ID, string(models.ConfirmationStatusCancelled), obj.ConfirmerIDs)
+	return err
+}
+
+// admit 执行 L0 校验（authenticate），通过且解析出 Agent 时登记在途请求（trackAgent）：HTTP 代理、/auth/exec 与 AuthStream
+// 共用，隔离时可中止其在途请求。返回派生的 ctx 与须在请求结束时调用的 done；拒绝时返回 l0Denial（已写审计）。
+func (p *pipeline) admit(ctx context.Context, traceID string, req *models.RequestContext) (context.Context, func(), *l0Denial) {
+	if d := p.authenticate(ctx, traceID, req); d != nil {
+		return ctx, func() {}, d
+	}
+	if req.AgentID == "" {
+		return ctx, func() {}, nil
+	}
+	ctx, done, ok := p.trackAgent(ctx, req.AgentID)
+	if !ok {
+		p.appendEvidence(ctx, traceID, req, "agent_suspended", "agent_registry", "agent suspended")
+		return ctx, done, &l0Denial{RuleID: "agent_registry", Reason: "agent suspended", Status: http.StatusForbidden}
+	}
+	return ctx, done, nil
+}
+
+// reviewInput 按审批规则（resource、risk_level）构造 review 的 CHEQ 创建入参，返回入参与审批时限（秒）。
+// Fingerprint 因入口而异（HTTP 请求或命令行），由调用方填写。
+func (p *pipeline) reviewInput(traceID string, req *models.RequestContext, summary string) (*cheq.CreateInput, int) {
+	timeoutSec := p.cheqTimeoutSec
+	if timeoutSec <= 0 {
+		timeoutSec = 300
+	}
+	resource := req.Resource
+	if resource == "" {
+		resource = req.TargetURL
+	}
+	riskLevel := ""
+	if req.Context != nil {
+		riskLevel = req.Context["risk_level"]
+	}
+	in := &cheq.CreateInput{
+		TraceID:   traceID,
+		Resource:  resource,
+		Action:    req.Action,
+		Summary:   summary,
+		Type:      "operation_approval",
+		SessionID: req.SessionID,
+	}
+	if p.approvalMatcher != nil {
+		m := p.approvalMatcher.Match(resource, riskLevel)
+		if m.TimeoutSeconds > 0 {
+			timeoutSec = m.TimeoutSeconds
+		}
+		in.ConfirmerIDs = m.ApprovalUserIDs
+		in.ApprovalPolicy = m.ApprovalPolicy
+		in.Escalation = escalationInput(m.Escalation)
+		in.OnTimeout = m.OnTimeout
+	}
+	in.ExpiresAt = time.Now().Add(time.Duration(timeoutSec) * time.Second)
+	in.Requester, in.RequesterOwner = p.requesterOf(req)
+	return in, timeoutSec
+}
+
+// watchGrace 为等待 CHEQ 终态的兜底余量：引擎应在 ExpiresAt 由定时器置为 expired，超出余量仍未终态则按过期处理。
+const watchGrace = 5 * time.Second
+
+// waitCHEQ 订阅 obj 的状态变化直至终态、ctx 取消或兜底超时，返回终态（未达终态为空）与最后一次看到的对象。
+// remind 为 true 时在超时前 reminderSecondsBeforeTimeout 秒投递一次飞书提醒。
+func (p *pipeline) waitCHEQ(ctx context.Context, obj *models.ConfirmationObject, remind bool) (string, *models.ConfirmationObject) {
+	ch, err := p.cheq.Watch(ctx, obj.ID)
+	if err != nil {
+		return "", obj
+	}
+	last := obj
+	var remindC <-chan time.Time
+	if remind && p.delivery != nil {
+		remindSec := p.reminderSecondsBeforeTimeout
+		if remindSec <= 0 {
+			remindSec = 60
+		}
+		t := time.NewTimer(time.Until(obj.ExpiresAt) - time.Duration(remindSec)*time.Second)
+		defer t.Stop()
+		remindC = t.C
+	}
+	deadline := time.NewTimer(time.Until(obj.ExpiresAt) + watchGrace)
+	defer deadline.Stop()
+	for {
+		select {
+		case o, ok := <-ch:
+			if !ok {
+				if last.IsTerminal() {
+					return string(last.Status), last
+				}
+				return "", last
+			}
+			last = o
+			if o.IsTerminal() {
+				return string(o.Status), o
+			}
+			// ExpiresAt 可能被延长，兜底超时随之顺延
+			if !deadline.Stop() {
+				select {
+				case <-deadline.C:
+				default:
+				}
+			}
+			deadline.Reset(time.Until(o.ExpiresAt) + watchGrace)
+		case <-remindC:
+			remindC = nil
+			_ = p.delivery.Deliver(ctx, &delivery.DeliverInput{Object: last, Options: &delivery.DeliverOptions{Summary: "【提醒】该请求即将超时，请尽快处理"}})
+		case <-deadline.C:
+			return "", last
+		case <-ctx.Done():
+			return "", last
+		}
+	}
+}
+
+// defaultGrantMinutes 审批人选择「N 分钟内」但未给出分钟数时的默认时长；defaultGrantMaxMinutes 为未配置上限时的默认上限。
+const (
+	defaultGrantMinutes    = 60
+	defaultGrantMaxMinutes = 480
+)
+
+// matchGrant 在创建 CHEQ 前查限时授权：命中时写 approved_by_grant 审计（引用产生授权的 CHEQ）并返回授权；未启用或未命中返回 nil。
+func (p *pipeline) matchGrant(ctx context.Context, traceID string, req *models.RequestContext, resource, policyRuleID string) *grant.Grant {
+	if p.grants == nil {
+		return nil
+	}
+	subject := req.ResolvedSubject()
+	if subject == "" {
+		return nil
+	}
+	g, err := p.grants.Match(ctx, subject, req.Action, resource, req.SessionID, time.Now())
+	if err != nil || g == nil {
+		return nil
+	}
+	_ = p.audit.Append(ctx, &models.Evidence{
+		TraceID:         traceID,
+		AgentID:         req.Subject(),
+		PolicyRuleID:    policyRuleID,
+		DecisionReason:  fmt.Sprintf("grant %s (scope=%s, expires_at=%s)", g.ID, g.Scope, g.ExpiresAt.Format(time.RFC3339)),
+		Decision:        "approved_by_grant",
+		CHEQStatus:      string(models.ConfirmationStatusApproved),
+		CHEQID:          g.CHEQID,
+		Confirmer:       strings.Join(g.GrantedBy, ","),
+		Timestamp:       time.Now(),
+		Resource:        req.Resource,
+		Action:          req.Action,
+		CertFingerprint: req.CertFingerprint,
+		ProfileID:       req.ProfileID,
+	})
+	return g
+}
+
+// recordGrant 在 CHEQ 批准后按审批人的选择创建限时授权；时长不超过上限，同一 CHEQ 只产生一条。
+// 发起方未经 L0 解析（Requester 为空）时不产生授权。
+func (p *pipeline) recordGrant(ctx context.Context, o *models.ConfirmationObject) {
+	// 改后批准只针对改写后的这一次请求，不产生授权
+	if p.grants == nil || o == nil || o.Requester == "" || o.Amendment != nil {
+		return
+	}
+	scope, minutes, ok := grant.FromApproval(o)
+	if !ok || (scope == grant.ScopeSession && o.SessionID == "") {
+		return
+	}
+	maxMinutes := p.grantMaxMinutes
+	if maxMinutes <= 0 {
+		maxMinutes = defaultGrantMaxMinutes
+	}
+	if minutes <= 0 {
+		// 会话授权未指定时长时以上限兜底，避免长连接上的授权永久有效
+		minutes = defaultGrantMinutes
+		if scope == grant.ScopeSession {
+			minutes = maxMinutes
+		}
+	}
+	if minutes > maxMinutes {
+		minutes = maxMinutes
+	}
+	g := &grant.Grant{
+		ID:        uuid.New().String(),
+		Subject:   o.Requester,
+		Action:    o.Action,
+		Resource:  o.Resource,
+		Scope:     scope,
+		CHEQID:    o.ID,
+		CreatedAt: time.Now(),
+	}
+	g.ExpiresAt = g.CreatedAt.Add(time.Duration(minutes) * time.Minute)
+	if scope == grant.ScopeSession {
+		g.SessionID = o.SessionID
+	}
+	for _, v := range o.Votes {
+		if v.Decision == models.VoteApprove && v.ConfirmerID != "" {
+			g.GrantedBy = append(g.GrantedBy, v.ConfirmerID)
+		}
+	}
+	if _, err := p.grants.Create(ctx, g); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "[diting] 创建限时授权失败 cheq=%s: %v\n", o.ID, err)
+	}
+}
+
+// requesterOf 返回请求发起主体及其 owner，写入 CHEQ 用于职责分离校验与限时授权。只取 L0 解析出的主体
+// （AgentID 或 key:<id>）；未解析时返回空，不以自报身份（可能是凭证明文）作发起方。
+func (p *pipeline) requesterOf(req *models.RequestContext) (string, string) {
+	if req.AgentID != "" && p.agents != nil {
+		if a, err := p.agents.Get(context.Background(), req.AgentID); err == nil {
+			return a.ID, a.OwnerID
+		}
+	}
+	who := req.ResolvedSubject()
+	if who == "" {
+		return "", ""
+	}
+	return who, p.requesterOwners[who]
+}
+
+// escalationInput 将审批规则的升级阶段转换为 CHEQ 创建入参。
+func escalationInput(stages []ownership.EscalationStage) []cheq.EscalationStage {
+	if len(stages) == 0 {
+		return nil
+	}
+	out := make([]cheq.EscalationStage, 0, len(stages))
+	for _, st := range stages {
+		out = append(out, cheq.EscalationStage{After: time.Duration(st.AfterSeconds) * time.Second, ConfirmerIDs: st.ApprovalUserIDs})
+	}
+	return out
+}
+
+// confirmationReason 返回反馈给 Agent 的确认结果说明："confirmation <status>"，审批人给出原因码或备注时附在其后，
+// 如 "confirmation rejected [too_broad]: 请限定到 /tmp"，Agent 可据此修正后重试。
+func confirmationReason(finalStatus string, o *models.ConfirmationObject) string {
+	s := "confirmation " + finalStatus
+	if o == nil || string(o.Status) != finalStatus {
+		return s
+	}
+	if o.DecisionReasonCode != "" {
+		s += " [" + o.DecisionReasonCode + "]"
+	}
+	if o.DecisionComment != "" {
+		s += ": " + o.DecisionComment
+	}
+	return s
+}
+
+// cheqDecisionReason 在 CHEQ 因超时动作结束（如 allow_with_flag）时于审计理由中标注，便于事后复核。
+func cheqDecisionReason(reason string, o *models.ConfirmationObject) string {
+	if o == nil || o.TimeoutAction == "" || o.TimeoutAction == models.OnTimeoutDeny {
+		return reason
+	}
+	return reason + " [timeout_action=" + o.TimeoutAction + "]"
+}
+
 // normalizeL0Token 去掉 Authorization 的 "Bearer " 前缀，便于与配置的 key 比对。
 func normalizeL0Token(identity string) string {
 	s := strings.TrimSpace(identity)
@@ -191,34 +415,45 @@ func normalizeL0Token(identity string) string {
 	return s
 }
 
-func containsString(slice []string, s string) bool {
-	for _, v := range slice {
-		if v == s {
-			return true
-		}
-	}
-	return false
+func (p *pipeline) appendEvidence(ctx context.Context, traceID string, req *models.RequestContext, decision, policyRuleID, reason string) {
+	p.appendEvidenceWithCHEQ(ctx, traceID, req, decision, policyRuleID, reason, "", "", nil)
 }
 
-func (p *pipeline) appendEvidence(ctx context.Context, traceID string, req *models.RequestContext, decision, policyRuleID, reason string) {
-	p.appendEvidenceWithCHEQ(ctx, traceID, req, decision, policyRuleID, reason, "", nil)
+func (p *pipeline) appendEvidenceWithCHEQ(ctx context.Context, traceID string, req *models.RequestContext, decision, policyRuleID, reason, cheqID, cheqStatus string, confirmerIDs []string) {
+	_ = p.audit.Append(ctx, cheqEvidence(traceID, req, decision, policyRuleID, reason, cheqID, cheqStatus, confirmerIDs))
 }
 
-func (p *pipeline) appendEvidenceWithCHEQ(ctx context.Context, traceID string, req *models.RequestContext, decision, policyRuleID, reason, cheqStatus string, confirmerIDs []string) {
+// appendCHEQOutcome 写 CHEQ 终态（或等待超时）的审计：decision 与 cheq_status 均为 finalStatus，带审批人的原因码与备注；
+// diff 为改后批准时原请求与实际执行请求的差异。合并到同一对象的每个等待方各写一条。
+func (p *pipeline) appendCHEQOutcome(ctx context.Context, traceID string, req *models.RequestContext, policyRuleID, reason, finalStatus string, o *models.ConfirmationObject, diff string) {
+	if o == nil {
+		p.appendEvidenceWithCHEQ(ctx, traceID, req, finalStatus, policyRuleID, reason, "", finalStatus, nil)
+		return
+	}
+	ev := cheqEvidence(traceID, req, finalStatus, policyRuleID, reason, o.ID, finalStatus, o.ConfirmerIDs)
+	ev.ReasonCode, ev.Comment = o.DecisionReasonCode, o.DecisionComment
+	ev.Amendment = diff
+	_ = p.audit.Append(ctx, ev)
+}
+
+func cheqEvidence(traceID string, req *models.RequestContext, decision, policyRuleID, reason, cheqID, cheqStatus string, confirmerIDs []string) *models.Evidence {
 	confirmer := ""
 	if len(confirmerIDs) > 0 {
 		confirmer = strings.Join(confirmerIDs, ",")
 	}
-	_ = p.audit.Append(ctx, &models.Evidence{
-		TraceID:        traceID,
-		AgentID:        req.AgentIdentity,
-		PolicyRuleID:   policyRuleID,
-		DecisionReason: reason,
-		Decision:       decision,
-		CHEQStatus:     cheqStatus,
-		Confirmer:      confirmer,
-		Timestamp:      time.Now(),
-		Resource:       req.Resource,
-		Action:         req.Action,
-	})
+	return &models.Evidence{
+		TraceID:         traceID,
+		AgentID:         req.Subject(),
+		PolicyRuleID:    policyRuleID,
+		DecisionReason:  reason,
+		Decision:        decision,
+		CHEQStatus:      cheqStatus,
+		CHEQID:          cheqID,
+		Confirmer:       confirmer,
+		Timestamp:       time.Now(),
+		Resource:        req.Resource,
+		Action:          req.Action,
+		CertFingerprint: req.CertFingerprint,
+		ProfileID:       req.ProfileID,
+	}
 }
@@ -2,14 +2,23 @@ package proxy
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"diting/internal/audit"
 	"diting/internal/cheq"
+	"diting/internal/delivery"
+	"diting/internal/grant"
 	"diting/internal/models"
 	"diting/internal/policy"
 )
@@ -52,3 +61,365 @@ func TestPipelineAllowWritesAudit(t *testing.T) {
 		t.Error("policy_rule_id should be set")
 	}
 }
+
+func TestPipelineReviewWaitsForApproval(t *testing.T) {
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstreamServer.Close()
+
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: review-all\n    decision: review\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	approver := &approveOnDeliver{}
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, approver, "any")
+	approver.eng = eng
+	store := audit.NewStubStore()
+	pl := &pipeline{policy: pe, cheq: eng, audit: store, cheqTimeoutSec: 30, reviewRequiresApproval: true}
+	upstreamURL, _ := url.Parse(upstreamServer.URL)
+	rp := httputil.NewSingleHostReverseProxy(upstreamURL)
+
+	req, _ := http.NewRequest("POST", "http://example.com/admin", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyTraceID, "trace-review"))
+	reqCtx := &models.RequestContext{AgentIdentity: "agent1", Method: "POST", Resource: "/admin", Action: "POST"}
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	pl.ServeHTTP(rec, req, reqCtx, rp)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 after approval, got %d", rec.Code)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("approval wait took %v, expected event-driven release", time.Since(start))
+	}
+	evs, _ := store.QueryByTraceID(context.Background(), "trace-review")
+	if len(evs) != 1 || evs[0].Decision != "approved" {
+		t.Fatalf("expected one approved audit record, got %+v", evs)
+	}
+}
+
+func TestPipelineAutoApproveFailsClosedUnderMultiVote(t *testing.T) {
+	hit := false
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hit = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstreamServer.Close()
+
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: review-all\n    decision: review\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	// all 策略须逐个确认人计票，占位模式的空确认人提交被拒绝
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "all")
+	defer eng.Stop()
+	store := audit.NewStubStore()
+	pl := &pipeline{policy: pe, cheq: eng, audit: store, cheqTimeoutSec: 30}
+	upstreamURL, _ := url.Parse(upstreamServer.URL)
+	rp := httputil.NewSingleHostReverseProxy(upstreamURL)
+	ctx := context.Background()
+
+	req, _ := http.NewRequest("POST", "http://example.com/admin", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyTraceID, "trace-auto"))
+	reqCtx := &models.RequestContext{AgentIdentity: "agent1", Method: "POST", Resource: "/admin", Action: "POST"}
+	rec := httptest.NewRecorder()
+	pl.ServeHTTP(rec, req, reqCtx, rp)
+	if rec.Code != http.StatusInternalServerError || hit {
+		t.Fatalf("code=%d upstream hit=%v, want 500 without forwarding", rec.Code, hit)
+	}
+	var cheqID string
+	evs, _ := store.QueryByTraceID(ctx, "trace-auto")
+	for _, e := range evs {
+		if e.Decision == "approved" {
+			t.Errorf("unexpected approved audit: %+v", e)
+		}
+		if e.Decision == "review_error" {
+			cheqID = e.CHEQID
+		}
+	}
+	if cheqID == "" {
+		t.Fatalf("no review_error audit: %+v", evs)
+	}
+	if o, _ := eng.GetByID(ctx, cheqID); o == nil || o.Status != models.ConfirmationStatusCancelled {
+		t.Errorf("cheq left as %+v, want cancelled", o)
+	}
+
+	execReq := &models.RequestContext{Method: "EXEC", Action: "exec:run", Resource: "local://host"}
+	if resp, err := pl.ExecEvaluate(ctx, "trace-auto-exec", execReq); err == nil {
+		t.Errorf("ExecEvaluate = %+v, want error", resp)
+	}
+}
+
+func TestPipelineReviewGrantSkipsCHEQ(t *testing.T) {
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstreamServer.Close()
+
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: review-all\n    decision: review\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	approver := &approveOnDeliver{opts: &cheq.SubmitOptions{GrantScope: grant.ScopeMinutes, GrantMinutes: 30}}
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, approver, "any")
+	approver.eng = eng
+	grants, _ := grant.NewFileStore("")
+	store := audit.NewStubStore()
+	pl := &pipeline{policy: pe, cheq: eng, audit: store, cheqTimeoutSec: 30, reviewRequiresApproval: true, grants: grants}
+	upstreamURL, _ := url.Parse(upstreamServer.URL)
+	rp := httputil.NewSingleHostReverseProxy(upstreamURL)
+
+	serveAs := func(reqCtx *models.RequestContext, traceID, resource string) int {
+		req, _ := http.NewRequest("POST", "http://example.com"+resource, nil)
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyTraceID, traceID))
+		reqCtx.Method, reqCtx.Resource, reqCtx.Action = "POST", resource, "POST"
+		rec := httptest.NewRecorder()
+		pl.ServeHTTP(rec, req, reqCtx, rp)
+		return rec.Code
+	}
+	serve := func(traceID, resource string) int {
+		return serveAs(&models.RequestContext{AgentIdentity: "agent1-token", KeyID: "agent1"}, traceID, resource)
+	}
+	// 未经 L0 解析的自报身份（可能是凭证明文）不产生授权
+	if code := serveAs(&models.RequestContext{AgentIdentity: "raw-token"}, "trace-0", "/admin"); code != http.StatusOK {
+		t.Fatalf("unresolved request: expected 200, got %d", code)
+	}
+	if list, _ := grants.List(context.Background(), true); len(list) != 0 {
+		t.Fatalf("grant created for unresolved subject: %+v", list)
+	}
+	if code := serve("trace-1", "/admin"); code != http.StatusOK {
+		t.Fatalf("first request: expected 200, got %d", code)
+	}
+	list, _ := grants.List(context.Background(), true)
+	if len(list) != 1 || list[0].Subject != "key:agent1" || list[0].Resource != "/admin" {
+		t.Fatalf("expected one grant for agent1 /admin, got %+v", list)
+	}
+	if d := list[0].ExpiresAt.Sub(list[0].CreatedAt); d != 30*time.Minute {
+		t.Errorf("grant duration = %v, want 30m", d)
+	}
+
+	if code := serve("trace-2", "/admin"); code != http.StatusOK {
+		t.Fatalf("granted request: expected 200, got %d", code)
+	}
+	if n := approver.count(); n != 2 {
+		t.Errorf("granted request should not create a CHEQ, deliveries = %d", n)
+	}
+	evs, _ := store.QueryByTraceID(context.Background(), "trace-2")
+	if len(evs) != 1 || evs[0].Decision != "approved_by_grant" || evs[0].CHEQID != list[0].CHEQID {
+		t.Fatalf("expected approved_by_grant audit referencing %s, got %+v", list[0].CHEQID, evs)
+	}
+
+	// 撤销后重新走审批
+	_ = grants.Revoke(context.Background(), list[0].ID, "sec_1")
+	if code := serve("trace-3", "/admin"); code != http.StatusOK {
+		t.Fatalf("after revoke: expected 200, got %d", code)
+	}
+	if n := approver.count(); n != 3 {
+		t.Errorf("revoked grant should require approval again, deliveries = %d", n)
+	}
+}
+
+func TestPipelineReviewDedupReleasesAllWaiters(t *testing.T) {
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstreamServer.Close()
+
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: review-all\n    decision: review\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	store := audit.NewStubStore()
+	pl := &pipeline{policy: pe, cheq: eng, audit: store, cheqTimeoutSec: 30, reviewRequiresApproval: true}
+	upstreamURL, _ := url.Parse(upstreamServer.URL)
+	rp := httputil.NewSingleHostReverseProxy(upstreamURL)
+
+	bodies := []string{`{"a":1,"b":2}`, ` {"b":2, "a":1}`}
+	codes := make([]int, len(bodies))
+	var wg sync.WaitGroup
+	for i, body := range bodies {
+		wg.Add(1)
+		go func(i int, body string) {
+			defer wg.Done()
+			req, _ := http.NewRequest("POST", "http://example.com/admin", strings.NewReader(body))
+			req = req.WithContext(context.WithValue(req.Context(), ctxKeyTraceID, "trace-dup-"+strconv.Itoa(i)))
+			reqCtx := &models.RequestContext{AgentIdentity: "agent1", Method: "POST", Resource: "/admin", Action: "POST"}
+			rec := httptest.NewRecorder()
+			pl.ServeHTTP(rec, req, reqCtx, rp)
+			codes[i] = rec.Code
+		}(i, body)
+		// 等第一个请求创建对象后再发重试
+		if i == 0 {
+			waitPending(t, eng, 1)
+		}
+	}
+	res := waitPending(t, eng, 1)
+	obj := res.Items[0]
+	for len(obj.Waiters) == 0 {
+		time.Sleep(10 * time.Millisecond)
+		obj, _ = eng.GetByID(context.Background(), obj.ID)
+	}
+	if err := eng.Submit(context.Background(), obj.ID, true, "u1"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("waiter %d: expected 200, got %d", i, code)
+		}
+		evs, _ := store.QueryByTraceID(context.Background(), "trace-dup-"+strconv.Itoa(i))
+		if len(evs) != 1 || evs[0].Decision != "approved" || evs[0].CHEQID != obj.ID {
+			t.Errorf("waiter %d: expected own approved audit for %s, got %+v", i, obj.ID, evs)
+		}
+	}
+}
+
+// waitPending 等待引擎中出现 n 个待确认对象。
+func waitPending(t *testing.T, eng cheq.Engine, n int) *cheq.ListResult {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		res, _ := eng.List(context.Background(), &cheq.ListFilter{Status: models.ConfirmationStatusPending})
+		if res != nil && len(res.Items) == n {
+			return res
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d pending objects", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExecEvaluateRejectionCarriesReason(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: review-all\n    decision: review\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	store := audit.NewStubStore()
+	pl := &pipeline{policy: pe, cheq: eng, audit: store, cheqTimeoutSec: 30, reviewRequiresApproval: true}
+
+	// 审批人：待确认对象出现后带原因码与备注拒绝
+	go func() {
+		for {
+			res, _ := eng.List(context.Background(), &cheq.ListFilter{Status: models.ConfirmationStatusPending})
+			if res != nil && len(res.Items) > 0 {
+				_ = eng.SubmitWithOptions(context.Background(), res.Items[0].ID, false, "u1", &cheq.SubmitOptions{ReasonCode: models.ReasonTooBroad, Comment: "limit to /tmp"})
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	req := &models.RequestContext{AgentIdentity: "agent1", Method: "EXEC", TargetURL: "rm -rf /", Resource: "/", Action: "exec:rm"}
+	resp, err := pl.ExecEvaluate(context.Background(), "trace-reject", req)
+	if err != nil {
+		t.Fatalf("ExecEvaluate: %v", err)
+	}
+	if resp.Decision != "deny" || resp.Reason != "confirmation rejected [too_broad]: limit to /tmp" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	evs, _ := store.QueryByTraceID(context.Background(), "trace-reject")
+	if len(evs) != 1 || evs[0].ReasonCode != models.ReasonTooBroad || evs[0].Comment != "limit to /tmp" {
+		t.Errorf("expected audit with reason code and comment, got %+v", evs)
+	}
+}
+
+func TestPipelineReviewAppliesAmendment(t *testing.T) {
+	var gotBody string
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstreamServer.Close()
+
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: review-all\n    decision: review\n"), 0644)
+	pe, err := policy.NewEngineImpl(rulesPath)
+	if err != nil {
+		t.Fatalf("NewEngineImpl: %v", err)
+	}
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	approver := &approveOnDeliver{opts: &cheq.SubmitOptions{BodyPatch: `{"dry_run":true,"force":null}`}}
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, approver, "any")
+	approver.eng = eng
+	store := audit.NewStubStore()
+	pl := &pipeline{policy: pe, cheq: eng, audit: store, cheqTimeoutSec: 30, reviewRequiresApproval: true}
+	upstreamURL, _ := url.Parse(upstreamServer.URL)
+	rp := httputil.NewSingleHostReverseProxy(upstreamURL)
+
+	req, _ := http.NewRequest("POST", "http://example.com/deploy", strings.NewReader(`{"app":"web","force":true}`))
+	req = req.WithContext(context.WithValue(req.Context(), ctxKeyTraceID, "trace-amend"))
+	reqCtx := &models.RequestContext{AgentIdentity: "agent1", Method: "POST", Resource: "/deploy", Action: "POST"}
+	rec := httptest.NewRecorder()
+	pl.ServeHTTP(rec, req, reqCtx, rp)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if gotBody != `{"app":"web","dry_run":true}` {
+		t.Errorf("upstream body = %s, want amended body", gotBody)
+	}
+	evs, _ := store.QueryByTraceID(context.Background(), "trace-amend")
+	want := "+ /dry_run: true\n- /force: true"
+	if len(evs) != 1 || evs[0].Amendment != want {
+		t.Fatalf("expected audit amendment %q, got %+v", want, evs)
+	}
+}
+
+func TestRequestFingerprintNormalizesBody(t *testing.T) {
+	a := requestFingerprint("agent1", "POST", "/admin", []byte(`{"a":1,"b":[1,2]}`))
+	b := requestFingerprint("agent1", "POST", "/admin", []byte("\n{ \"b\": [1, 2], \"a\": 1 }\n"))
+	if a != b {
+		t.Error("equivalent JSON bodies should share a fingerprint")
+	}
+	if a == requestFingerprint("agent2", "POST", "/admin", []byte(`{"a":1,"b":[1,2]}`)) {
+		t.Error("different subjects should not share a fingerprint")
+	}
+	if a == requestFingerprint("agent1", "POST", "/admin", []byte(`{"a":2,"b":[1,2]}`)) {
+		t.Error("different bodies should not share a fingerprint")
+	}
+}
+
+// approveOnDeliver 模拟审批人：收到投递后异步批准；opts 非 nil 时附带授权选择。
+type approveOnDeliver struct {
+	eng  cheq.Engine
+	opts *cheq.SubmitOptions
+	mu   sync.Mutex
+	n    int
+}
+
+func (a *approveOnDeliver) Deliver(ctx context.Context, in *delivery.DeliverInput) error {
+	id := in.Object.ID
+	a.mu.Lock()
+	a.n++
+	a.mu.Unlock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = a.eng.SubmitWithOptions(context.Background(), id, true, "", a.opts)
+	}()
+	return nil
+}
+
+func (a *approveOnDeliver) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.n
+}
@@ -0,0 +1,263 @@
+// 紧急隔离（kill switch）：POST /agents/quarantine 按 ID、标签或全部暂停 Agent，
+// 立即中止其在途请求、撤销待审批的 CHEQ，并向 AuthStream 连接推送禁网的 profile_update；POST /agents/release 解除。
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"diting/internal/agent"
+	"diting/internal/cheq"
+	"diting/internal/models"
+)
+
+// inflightReq 为一个已解析 Agent 的在途请求，隔离时取消其 ctx。
+type inflightReq struct {
+	cancel context.CancelFunc
+}
+
+// inflightSet 按 Agent 记录在途请求。
+type inflightSet struct {
+	mu   sync.Mutex
+	reqs map[string]map[*inflightReq]struct{}
+}
+
+// trackAgent 为 Agent 请求派生可被隔离中止的 ctx，返回的 done 须在请求结束时调用。
+// 启用注册表时登记后复查：若 Agent 已在通过 agentGate 之后被暂停或吊销，返回 ok=false，调用方应拒绝。
+func (p *pipeline) trackAgent(ctx context.Context, agentID string) (context.Context, func(), bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	req := &inflightReq{cancel: cancel}
+	s := &p.inflight
+	s.mu.Lock()
+	if s.reqs == nil {
+		s.reqs = make(map[string]map[*inflightReq]struct{})
+	}
+	if s.reqs[agentID] == nil {
+		s.reqs[agentID] = make(map[*inflightReq]struct{})
+	}
+	s.reqs[agentID][req] = struct{}{}
+	s.mu.Unlock()
+	done := func() {
+		cancel()
+		s.mu.Lock()
+		delete(s.reqs[agentID], req)
+		if len(s.reqs[agentID]) == 0 {
+			delete(s.reqs, agentID)
+		}
+		s.mu.Unlock()
+	}
+	if p.agents == nil {
+		return ctx, done, true
+	}
+	if a, err := p.agents.Get(ctx, agentID); err == nil && (a.State == agent.StateSuspended || a.State == agent.StateRevoked) {
+		done()
+		return ctx, func() {}, false
+	}
+	return ctx, done, true
+}
+
+// cancelInflight 取消 agentIDs 的全部在途请求，返回取消的数量。
+func (p *pipeline) cancelInflight(agentIDs map[string]bool) int {
+	s := &p.inflight
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, reqs := range s.reqs {
+		if !agentIDs[id] {
+			continue
+		}
+		for req := range reqs {
+			req.cancel()
+			n++
+		}
+	}
+	return n
+}
+
+// quarantineInput 为 /agents/quarantine 与 /agents/release 的请求体；agent_id、selector（或 labels）、all 三者取其一。
+type quarantineInput struct {
+	AgentID  string            `json:"agent_id"`
+	Selector string            `json:"selector"` // 标签选择器，如 "env=prod,team=infra"
+	Labels   map[string]string `json:"labels"`
+	All      bool              `json:"all"`
+	Reason   string            `json:"reason"`
+}
+
+// QuarantineResult 为隔离/解除的响应：受影响的 Agent 及中止的在途请求、撤销的 CHEQ、推送的连接数。
+type QuarantineResult struct {
+	Action          string   `json:"action"` // quarantine | release
+	Reason          string   `json:"reason,omitempty"`
+	Agents          []string `json:"agents"`
+	CancelledCHEQs  []string `json:"cancelled_cheqs,omitempty"`
+	AbortedRequests int      `json:"aborted_requests,omitempty"`
+	NotifiedStreams int      `json:"notified_streams"`
+}
+
+// quarantineHandler 处理 POST /agents/quarantine（release 为 false）与 POST /agents/release（release 为 true）。
+func (s *Server) quarantineHandler(release bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		admin, ok := s.adminOf(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, errAdminTokenInvalid.Error())
+			return
+		}
+		var in quarantineInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		sel := agent.Selector{ID: in.AgentID, Labels: in.Labels, All: in.All}
+		if in.Selector != "" {
+			labels, err := agent.ParseLabelSelector(in.Selector)
+			if err != nil || len(in.Labels) > 0 {
+				writeJSONError(w, http.StatusBadRequest, agent.ErrInvalidSelector.Error())
+				return
+			}
+			sel.Labels = labels
+		}
+		if err := sel.Validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var res *QuarantineResult
+		var err error
+		if release {
+			res, err = s.releaseAgents(r.Context(), sel, in.Reason, admin)
+		} else {
+			if in.Reason == "" {
+				writeJSONError(w, http.StatusBadRequest, "missing reason")
+				return
+			}
+			res, err = s.quarantineAgents(r.Context(), sel, in.Reason, admin)
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "registry error")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+// quarantineAgents 将选中的 pending/active Agent 置为 suspended 并记录隔离原因（已隔离、已吊销的跳过），
+// 随后中止其在途请求、撤销其发起的待审批 CHEQ（含接入审批），并推送禁网 profile。每个 Agent 写一条 agent_quarantined 审计。
+func (s *Server) quarantineAgents(ctx context.Context, sel agent.Selector, reason, admin string) (*QuarantineResult, error) {
+	p := s.pipeline
+	res := &QuarantineResult{Action: "quarantine", Reason: reason, Agents: []string{}}
+	affected, err := s.updateSelected(ctx, sel, func(a *agent.Agent) bool {
+		if a.Quarantine != nil || (a.State != agent.StateActive && a.State != agent.StatePending) {
+			return false
+		}
+		a.Quarantine = &agent.Quarantine{Reason: reason, By: admin, At: time.Now(), PrevState: a.State}
+		a.State = agent.StateSuspended
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(affected))
+	for _, a := range affected {
+		ids[a.ID] = true
+		res.Agents = append(res.Agents, a.ID)
+		s.auditAgentAdmin(ctx, a.ID, "agent_quarantined", "agent quarantined: "+reason, admin)
+	}
+	// 先撤销 CHEQ，使等待审批的请求以 cancelled 结束；再中止其余在途请求（如正在转发上游）
+	for _, id := range res.Agents {
+		res.CancelledCHEQs = append(res.CancelledCHEQs, s.cancelPendingCHEQs(ctx, id, admin, "agent quarantined: "+reason)...)
+	}
+	res.AbortedRequests = p.cancelInflight(ids)
+	profile := quarantineProfile()
+	res.NotifiedStreams = s.pushProfile(func(agentID string) bool { return sel.All || ids[agentID] }, &profile)
+	return res, nil
+}
+
+// releaseAgents 解除选中 Agent 的隔离：恢复为隔离前的状态并推送连接 resource 对应的 profile；非隔离中的 Agent 跳过。
+// 每个 Agent 写一条 agent_released 审计。
+func (s *Server) releaseAgents(ctx context.Context, sel agent.Selector, reason, admin string) (*QuarantineResult, error) {
+	res := &QuarantineResult{Action: "release", Reason: reason, Agents: []string{}}
+	affected, err := s.updateSelected(ctx, sel, func(a *agent.Agent) bool {
+		if a.Quarantine == nil || a.State != agent.StateSuspended {
+			return false
+		}
+		a.State = a.Quarantine.PrevState
+		a.Quarantine = nil
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(affected))
+	for _, a := range affected {
+		ids[a.ID] = true
+		res.Agents = append(res.Agents, a.ID)
+		msg := "agent released"
+		if reason != "" {
+			msg += ": " + reason
+		}
+		s.auditAgentAdmin(ctx, a.ID, "agent_released", msg, admin)
+	}
+	res.NotifiedStreams = s.pushProfiles(func(c *streamConn) *SandboxProfile {
+		if !sel.All && !ids[c.agentID] {
+			return nil
+		}
+		p := s.pipeline.profiles.match(c.resource)
+		return &p
+	})
+	return res, nil
+}
+
+// updateSelected 在 agentMu 下对选中的 Agent 执行 apply，apply 返回 true 的记录写回注册表并按 ID 排序返回。
+func (s *Server) updateSelected(ctx context.Context, sel agent.Selector, apply func(a *agent.Agent) bool) ([]*agent.Agent, error) {
+	p := s.pipeline
+	p.agentMu.Lock()
+	defer p.agentMu.Unlock()
+	list, err := s.agents.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []*agent.Agent
+	for _, a := range list {
+		if !sel.Match(a) || !apply(a) {
+			continue
+		}
+		if err := s.agents.Put(ctx, a); err != nil {
+			return out, err
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// cancelPendingCHEQs 撤销 agentID 发起的全部待审批 CHEQ，返回撤销的 id。
+func (s *Server) cancelPendingCHEQs(ctx context.Context, agentID, by, reason string) []string {
+	var ids []string
+	filter := &cheq.ListFilter{Status: models.ConfirmationStatusPending, Requester: agentID, Limit: 500}
+	for {
+		page, err := s.cheq.List(ctx, filter)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "[diting] 隔离时查询待审批 CHEQ 失败 agent=%s: %v\n", agentID, err)
+			return ids
+		}
+		for _, o := range page.Items {
+			if err := s.cheq.Cancel(ctx, o.ID, by, reason); err == nil {
+				ids = append(ids, o.ID)
+			}
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
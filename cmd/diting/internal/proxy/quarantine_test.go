@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"diting/internal/agent"
+	"diting/internal/audit"
+	"diting/internal/cheq"
+	"diting/internal/config"
+	"diting/internal/delivery"
+	"diting/internal/models"
+	"diting/internal/ownership"
+	"diting/internal/peercred"
+	"diting/internal/policy"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAgentQuarantineAndRelease(t *testing.T) {
+	upstreamHit := make(chan struct{}, 1)
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			upstreamHit <- struct{}{}
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	_ = os.WriteFile(rulesPath, []byte("rules:\n  - id: read\n    action: GET\n    decision: allow\n  - id: exec-review\n    action: exec:run\n    decision: review\n"), 0644)
+	pe, _ := policy.NewEngineImpl(rulesPath)
+	cheqStore, _ := cheq.NewJSONStore(t.TempDir())
+	eng := cheq.NewEngineImpl(cheqStore, 30, nil, nil, "any")
+	auditStore := audit.NewStubStore()
+	cfg := &config.Config{Proxy: config.ProxyConfig{Upstream: up.URL}}
+	s, err := NewServer(cfg, pe, eng, &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, true, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, nil)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	post := func(path, body string, out interface{}) int {
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		_ = json.NewDecoder(resp.Body).Decode(out)
+		return resp.StatusCode
+	}
+	proxyGet := func(path, token string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		req.Header.Set("X-Agent-Token", token)
+		rr := httptest.NewRecorder()
+		s.proxyHandler()(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+	var prod, dev AgentView
+	post("/agents", `{"id":"a1","owner_id":"o1","labels":{"env":"prod"}}`, &prod)
+	post("/agents", `{"id":"b1","owner_id":"o1","labels":{"env":"dev"}}`, &dev)
+
+	// a1 经 AuthStream 发起待审批的 exec，并有一个正在转发上游的 HTTP 请求
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+ts.URL[4:]+"/auth/stream", nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.WriteJSON(AuthStreamRequest{RequestID: "r1", Auth: &ExecAuthRequest{Subject: prod.Credential, Action: "exec:run", Resource: "local://host", CommandLine: "curl example.com"}})
+	var resp AuthStreamResponse
+	if err := conn.ReadJSON(&resp); err != nil || resp.Immediate == nil || resp.Immediate.Decision != "review" {
+		t.Fatalf("auth resp = %+v, %v", resp.Immediate, err)
+	}
+	cheqID := resp.Immediate.CheqID
+	slowDone := make(chan int, 1)
+	go func() {
+		code, _ := proxyGet("/slow", prod.Credential)
+		slowDone <- code
+	}()
+	<-upstreamHit
+
+	var res QuarantineResult
+	if code := post("/agents/quarantine", `{"selector":"env=prod"}`, &res); code != http.StatusBadRequest {
+		t.Errorf("quarantine without reason: code=%d", code)
+	}
+	if code := post("/agents/quarantine", `{"selector":"env=prod","reason":"credential leak"}`, &res); code != http.StatusOK {
+		t.Fatalf("quarantine: code=%d", code)
+	}
+	if len(res.Agents) != 1 || res.Agents[0] != "a1" || len(res.CancelledCHEQs) != 1 || res.CancelledCHEQs[0] != cheqID || res.AbortedRequests != 1 || res.NotifiedStreams != 1 {
+		t.Errorf("quarantine result = %+v", res)
+	}
+	select {
+	case code := <-slowDone:
+		if code != http.StatusBadGateway {
+			t.Errorf("aborted in-flight request: code=%d", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("in-flight request was not aborted")
+	}
+	var profile *SandboxProfile
+	var push *AuthStreamApprovalPush
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for profile == nil || push == nil {
+		var m AuthStreamResponse
+		if err := conn.ReadJSON(&m); err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		if m.ProfileUpdate != nil {
+			profile = m.ProfileUpdate
+		}
+		if m.ApprovalPush != nil {
+			push = m.ApprovalPush
+		}
+	}
+	if profile.Boundary == nil || profile.Boundary.NetworkEnabled || profile.ProfileID != "quarantine" {
+		t.Errorf("profile_update = %+v", profile)
+	}
+	if push.CheqID != cheqID || push.FinalDecision != "deny" || push.Reason != "confirmation cancelled: agent quarantined: credential leak" {
+		t.Errorf("approval_push = %+v", push)
+	}
+	if code, body := proxyGet("/data", prod.Credential); code != http.StatusForbidden || body != "agent quarantined: credential leak" {
+		t.Errorf("quarantined agent: code=%d body=%q", code, body)
+	}
+	if code, _ := proxyGet("/data", dev.Credential); code != http.StatusOK {
+		t.Errorf("agent outside selector: code=%d", code)
+	}
+	if post("/agents/quarantine", `{"agent_id":"a1","reason":"again"}`, &res); len(res.Agents) != 0 {
+		t.Errorf("already quarantined agent should be skipped: %+v", res)
+	}
+
+	if code := post("/agents/release", `{"agent_id":"a1","reason":"rotated"}`, &res); code != http.StatusOK || len(res.Agents) != 1 || res.NotifiedStreams != 1 {
+		t.Fatalf("release: code=%d result=%+v", code, res)
+	}
+	var m AuthStreamResponse
+	if err := conn.ReadJSON(&m); err != nil || m.ProfileUpdate == nil || !m.ProfileUpdate.Boundary.NetworkEnabled {
+		t.Errorf("release profile_update = %+v, %v", m.ProfileUpdate, err)
+	}
+	if code, _ := proxyGet("/data", prod.Credential); code != http.StatusOK {
+		t.Errorf("released agent: code=%d", code)
+	}
+	evs, _ := auditStore.QueryByTraceID(context.Background(), "a1")
+	var decisions []string
+	for _, ev := range evs {
+		decisions = append(decisions, ev.Decision)
+	}
+	if got := strings.Join(decisions, ","); got != "agent_created,agent_quarantined,agent_released" {
+		t.Errorf("admin audit = %s", got)
+	}
+}
+
+func TestSandboxProfileQuarantineByPeerCred(t *testing.T) {
+	s, err := NewServer(&config.Config{}, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, audit.NewStubStore(), &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, nil)
+	h := s.Handler()
+	do := func(method, target, body string, cred *peercred.Cred) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cred != nil {
+			req = req.WithContext(context.WithValue(req.Context(), ctxKeyPeerCred, cred))
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	profileOf := func(cred *peercred.Cred) string {
+		var p SandboxProfile
+		_ = json.NewDecoder(do(http.MethodGet, "/auth/sandbox-profile?resource=local://host", "", cred).Body).Decode(&p)
+		return p.ProfileID
+	}
+	do(http.MethodPost, "/agents", `{"id":"uid:1000","owner_id":"o1"}`, nil)
+	if rr := do(http.MethodPost, "/agents/quarantine", `{"agent_id":"uid:1000","reason":"leak"}`, nil); rr.Code != http.StatusOK {
+		t.Fatalf("quarantine: code=%d body=%s", rr.Code, rr.Body)
+	}
+
+	// 未携带凭证、仅由 SO_PEERCRED 识别的 Agent 同样取得 quarantine profile
+	if id := profileOf(&peercred.Cred{UID: 1000}); id != "quarantine" {
+		t.Errorf("quarantined peer: profile = %s", id)
+	}
+	if id := profileOf(&peercred.Cred{UID: 1001}); id != "default" {
+		t.Errorf("other peer: profile = %s", id)
+	}
+}
+
+// countingAudit 记录 Append 次数。
+type countingAudit struct {
+	*audit.StubStore
+	n int
+}
+
+func (c *countingAudit) Append(ctx context.Context, e *models.Evidence) error {
+	c.n++
+	return c.StubStore.Append(ctx, e)
+}
+
+func TestSandboxProfileLookupHasNoSideEffects(t *testing.T) {
+	auditStore := &countingAudit{StubStore: audit.NewStubStore()}
+	cfg := &config.Config{Proxy: config.ProxyConfig{AllowedAPIKeys: []string{"valid-key"}}}
+	s, err := NewServer(cfg, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	reg, _ := agent.NewFileRegistry("")
+	s.SetAgentRegistry(reg, nil)
+	ctx := context.Background()
+	a := &agent.Agent{ID: "q1", OwnerID: "o1", State: agent.StateSuspended, Quarantine: &agent.Quarantine{Reason: "leak", At: time.Now()}}
+	if err := a.SetCredential("q1-secret"); err != nil {
+		t.Fatal(err)
+	}
+	_ = reg.Put(ctx, a)
+	profileOf := func(token string) string {
+		req := httptest.NewRequest(http.MethodGet, "/auth/sandbox-profile?resource=local://host", nil)
+		if token != "" {
+			req.Header.Set("X-Agent-Token", token)
+		}
+		rr := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rr, req)
+		var p SandboxProfile
+		_ = json.NewDecoder(rr.Body).Decode(&p)
+		return p.ProfileID
+	}
+	if id := profileOf("q1-secret"); id != "quarantine" {
+		t.Errorf("quarantined credential: profile = %s", id)
+	}
+	for _, token := range []string{"", "bogus", "valid-key"} {
+		if id := profileOf(token); id != "default" {
+			t.Errorf("token %q: profile = %s", token, id)
+		}
+	}
+	// 拉取 profile 不写 L0/Agent 审计，也不更新最近访问时间
+	if auditStore.n != 0 {
+		t.Errorf("profile fetch wrote %d audit rows", auditStore.n)
+	}
+	if got, _ := reg.Get(ctx, "q1"); !got.LastSeenAt.IsZero() {
+		t.Errorf("LastSeenAt = %v, want untouched", got.LastSeenAt)
+	}
+}
@@ -1,9 +1,21 @@
-// Package proxy 提供 GetSandboxProfile 接口（Story 8.1）；按 resource 返回边界与 Hot Cache。
+// GetSandboxProfile 接口（Story 8.1）；按 resource 匹配 sandbox_profiles 配置返回边界与 Hot Cache。
+
 package proxy
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"diting/internal/config"
+	"diting/internal/hotcache"
+	"diting/internal/models"
 )
 
 // SandboxProfile 与 proto SandboxProfile 对齐的 JSON 结构（MVP 最小实现）。
@@ -25,12 +37,8 @@ type SandboxBoundary struct {
 	ReadonlyRoot     bool     `json:"readonly_root,omitempty"`
 }
 
-// HotCacheAction 本地快速放行规则。
-type HotCacheAction struct {
-	Executable      string   `json:"executable,omitempty"`
-	ArgvAllowlist   []string `json:"argv_allowlist,omitempty"`
-	RunAsSudo       bool     `json:"run_as_sudo,omitempty"`
-}
+// HotCacheAction 本地快速放行规则，匹配逻辑见 hotcache.Allows。
+type HotCacheAction = hotcache.Action
 
 // SudoHotCacheEntry Sudo 预授权条目。
 type SudoHotCacheEntry struct {
@@ -39,24 +47,229 @@ type SudoHotCacheEntry struct {
 	Description    string `json:"description,omitempty"`
 }
 
-// sandboxProfileHandler 处理 GET /auth/sandbox-profile?resource=xxx，返回最小 SandboxProfile（Story 8.1）。
+// defaultSandboxProfile 返回未配置 sandbox_profiles 或均未命中时的默认 SandboxProfile：放行网络、无 Hot Cache。
+func defaultSandboxProfile() SandboxProfile {
+	return versioned(SandboxProfile{
+		ProfileID:         "default",
+		Boundary:          &SandboxBoundary{NetworkEnabled: true, SyscallPreset: "default"},
+		DegradationPolicy: "FAIL_CLOSE",
+	})
+}
+
+// quarantineProfile 返回紧急隔离时下发的 SandboxProfile：禁网、清空 Hot Cache，断连时 FAIL_CLOSE。
+func quarantineProfile() SandboxProfile {
+	return versioned(SandboxProfile{
+		ProfileID:         "quarantine",
+		Boundary:          &SandboxBoundary{NetworkEnabled: false, SyscallPreset: "default", ReadonlyRoot: true},
+		DegradationPolicy: "FAIL_CLOSE",
+	})
+}
+
+// versioned 以 profile 内容（不含 version）的 SHA-256 前 16 位十六进制作为 version：内容不变则 version 不变，
+// 3af-exec 据此判断本地缓存是否过期。
+func versioned(p SandboxProfile) SandboxProfile {
+	p.Version = ""
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	p.Version = hex.EncodeToString(sum[:8])
+	return p
+}
+
+// sandboxProfiles 按 resource 匹配的 Sandbox Profile 集（config sandbox_profiles）；先匹配先生效，均未命中用默认。
+// 可整体替换，配置重载后新请求即取新 profile。
+type sandboxProfiles struct {
+	mu    sync.RWMutex
+	rules []sandboxProfileRule
+}
+
+type sandboxProfileRule struct {
+	resource string // "*"、结尾 "*" 的前缀或精确值
+	profile  SandboxProfile
+}
+
+// newSandboxProfiles 校验并转换配置；id、resource 缺失，id 重复，degradation_policy 或 hot cache 条目非法时返回错误。
+func newSandboxProfiles(cfgs []config.SandboxProfileConfig) ([]sandboxProfileRule, error) {
+	rules := make([]sandboxProfileRule, 0, len(cfgs))
+	seen := make(map[string]bool, len(cfgs))
+	for i, c := range cfgs {
+		if c.ID == "" || c.Resource == "" {
+			return nil, fmt.Errorf("proxy: sandbox_profiles[%d]: id and resource are required", i)
+		}
+		if seen[c.ID] || c.ID == "default" || c.ID == "quarantine" {
+			return nil, fmt.Errorf("proxy: sandbox_profiles[%d]: duplicate or reserved id %q", i, c.ID)
+		}
+		seen[c.ID] = true
+		policy := strings.ToUpper(c.DegradationPolicy)
+		switch policy {
+		case "":
+			policy = "FAIL_CLOSE"
+		case "FAIL_OPEN", "FAIL_CLOSE":
+		default:
+			return nil, fmt.Errorf("proxy: sandbox_profiles[%d]: unknown degradation_policy %q", i, c.DegradationPolicy)
+		}
+		b := c.Boundary
+		if b.SyscallPreset == "" {
+			b.SyscallPreset = "default"
+		}
+		p := SandboxProfile{
+			ProfileID: c.ID,
+			Boundary: &SandboxBoundary{
+				NetworkEnabled:  b.NetworkEnabled,
+				FsWritablePaths: b.FsWritablePaths,
+				SyscallPreset:   b.SyscallPreset,
+				MaxMemoryMB:     b.MaxMemoryMB,
+				ReadonlyRoot:    b.ReadonlyRoot,
+			},
+			DegradationPolicy: policy,
+		}
+		for j, a := range c.HotCacheActions {
+			if a.Executable == "" {
+				return nil, fmt.Errorf("proxy: sandbox_profiles[%d].hot_cache_actions[%d]: executable is required", i, j)
+			}
+			p.HotCacheActions = append(p.HotCacheActions, HotCacheAction{Executable: a.Executable, ArgvAllowlist: a.ArgvAllowlist, RunAsSudo: a.RunAsSudo})
+		}
+		for j, e := range c.SudoHotCache {
+			if e.CommandPattern == "" {
+				return nil, fmt.Errorf("proxy: sandbox_profiles[%d].sudo_hot_cache[%d]: command_pattern is required", i, j)
+			}
+			p.SudoHotCache = append(p.SudoHotCache, SudoHotCacheEntry{CommandPattern: e.CommandPattern, RunAsUser: e.RunAsUser, Description: e.Description})
+		}
+		rules = append(rules, sandboxProfileRule{resource: c.Resource, profile: versioned(p)})
+	}
+	return rules, nil
+}
+
+// set 整体替换规则。
+func (ps *sandboxProfiles) set(rules []sandboxProfileRule) {
+	ps.mu.Lock()
+	ps.rules = rules
+	ps.mu.Unlock()
+}
+
+// match 返回 resource 命中的第一条 profile；均未命中（或未配置）时返回默认 profile。
+func (ps *sandboxProfiles) match(resource string) SandboxProfile {
+	if ps != nil {
+		ps.mu.RLock()
+		defer ps.mu.RUnlock()
+		for _, r := range ps.rules {
+			if matchResource(r.resource, resource) {
+				return r.profile
+			}
+		}
+	}
+	return defaultSandboxProfile()
+}
+
+// matchResource "*" 匹配任意，结尾 "*" 为前缀匹配，否则精确匹配。
+func matchResource(pattern, resource string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(resource, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == resource
+}
+
+// SetSandboxProfiles 设置按 resource 下发的 Sandbox Profile（config sandbox_profiles），可在运行中重复调用以重载：
+// 已握手的 AuthStream 连接若 profile 版本变化则收到 profile_update。配置非法时返回错误并保留原配置。
+func (s *Server) SetSandboxProfiles(cfgs []config.SandboxProfileConfig) error {
+	rules, err := newSandboxProfiles(cfgs)
+	if err != nil {
+		return err
+	}
+	s.pipeline.profiles.set(rules)
+	s.refreshProfiles()
+	return nil
+}
+
+// sandboxProfile 返回 req.Resource 对应的 profile；req 经 L0 校验解析出的 Agent 处于紧急隔离时返回 quarantine profile。
+func (s *Server) sandboxProfile(ctx context.Context, req *models.RequestContext) SandboxProfile {
+	if s.quarantinedRequest(ctx, req) {
+		return quarantineProfile()
+	}
+	return s.pipeline.profiles.match(req.Resource)
+}
+
+// profileRequest 构造拉取 profile 的请求上下文；调用方再写入传输层身份（对端凭证、客户端证书、签名）。
+func profileRequest(credential, resource string) *models.RequestContext {
+	if resource == "" {
+		resource = "local://default"
+	}
+	return &models.RequestContext{AgentIdentity: credential, Method: "EXEC", Action: "exec:sandbox_profile", Resource: resource}
+}
+
+// requestCredential 返回请求头中的 L0 凭证：X-Agent-Token 优先，其次 Authorization。
+func requestCredential(r *http.Request) string {
+	if token := r.Header.Get("X-Agent-Token"); token != "" {
+		return token
+	}
+	return r.Header.Get("Authorization")
+}
+
+// quarantinedRequest 以 lookupAgent 解析 req 的 Agent 并写入 req.AgentID，返回其是否处于紧急隔离中。
+// 拉取 profile 不是授权请求：不执行完整 L0 校验，不写审计，也不更新 Agent 最近访问时间。
+func (s *Server) quarantinedRequest(ctx context.Context, req *models.RequestContext) bool {
+	if s.agents == nil {
+		return false
+	}
+	req.AgentID = s.lookupAgent(ctx, req)
+	if req.AgentID == "" {
+		return false
+	}
+	a, err := s.agents.Get(ctx, req.AgentID)
+	return err == nil && a.Quarantine != nil
+}
+
+// lookupAgent 按与 authenticate 相同的优先级解析 req 对应的 Agent ID：注册表凭证、签名 DID、mTLS 客户端证书、
+// 对端凭证（未携带凭证时），最后为认证链。无副作用；解析不出或签名无效时返回空。
+func (s *Server) lookupAgent(ctx context.Context, req *models.RequestContext) string {
+	token := normalizeL0Token(req.AgentIdentity)
+	if token != "" {
+		if a, err := s.agents.ByCredential(ctx, token); err == nil {
+			return a.ID
+		}
+	}
+	switch {
+	case req.SignatureError != nil:
+		return ""
+	case req.SignatureDID != "":
+		return req.SignatureDID
+	case req.CertIdentity != "":
+		return req.CertIdentity
+	case token == "":
+		return req.PeerIdentity
+	}
+	now := time.Now()
+	for _, a := range s.pipeline.l0 {
+		id, err := a.authenticate(ctx, token, now)
+		if err == errL0Skip {
+			continue
+		}
+		if err != nil {
+			return ""
+		}
+		return id.AgentID
+	}
+	return ""
+}
+
+// sandboxProfileHandler 处理 GET /auth/sandbox-profile?resource=xxx，返回 resource 命中的 SandboxProfile（Story 8.1）；
+// 紧急隔离中的 Agent（按 L0 身份）返回禁网的 quarantine profile。If-None-Match 与当前 version 相同时返回 304。
 func (s *Server) sandboxProfileHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet && r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		resource := r.URL.Query().Get("resource")
-		if resource == "" {
-			resource = "local://default"
-		}
-		profile := SandboxProfile{
-			ProfileID:         "default",
-			Version:           "1",
-			Boundary:          &SandboxBoundary{NetworkEnabled: true, SyscallPreset: "default"},
-			HotCacheActions:   nil,
-			SudoHotCache:      nil,
-			DegradationPolicy: "FAIL_CLOSE",
+		req := profileRequest(requestCredential(r), r.URL.Query().Get("resource"))
+		s.applyClientCert(r, req)
+		s.applyPeerCred(r, req)
+		s.applySignature(r, req)
+		profile := s.sandboxProfile(r.Context(), req)
+		// version 即 ETag：客户端以 If-None-Match 携带本地缓存的 version，未变化时返回 304
+		etag := `"` + profile.Version + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(profile)
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"diting/internal/audit"
+	"diting/internal/cheq"
+	"diting/internal/config"
+	"diting/internal/delivery"
+	"diting/internal/ownership"
+	"diting/internal/policy"
+)
+
+func TestSandboxProfilesByResource(t *testing.T) {
+	profiles := []config.SandboxProfileConfig{
+		{
+			ID:       "project-a",
+			Resource: "docker://project-a/*",
+			Boundary: config.SandboxBoundaryConfig{FsWritablePaths: []string{"/workspace"}, ReadonlyRoot: true},
+			HotCacheActions: []config.HotCacheActionConfig{
+				{Executable: "git", ArgvAllowlist: []string{"status", "diff"}},
+			},
+			SudoHotCache: []config.SudoHotCacheConfig{{CommandPattern: "systemctl status *", RunAsUser: "root"}},
+		},
+		{ID: "host-01", Resource: "local://host-01", Boundary: config.SandboxBoundaryConfig{NetworkEnabled: true}, DegradationPolicy: "fail_open"},
+	}
+	auditStore := audit.NewStubStore()
+	s, err := NewServer(&config.Config{}, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.SetSandboxProfiles(profiles); err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+	get := func(resource string) SandboxProfile {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth/sandbox-profile?resource="+resource, nil))
+		var p SandboxProfile
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatalf("decode profile for %s: %v", resource, err)
+		}
+		return p
+	}
+
+	a := get("docker://project-a/web")
+	if a.ProfileID != "project-a" || a.Boundary.NetworkEnabled || !a.Boundary.ReadonlyRoot || a.DegradationPolicy != "FAIL_CLOSE" ||
+		len(a.HotCacheActions) != 1 || a.HotCacheActions[0].Executable != "git" || len(a.SudoHotCache) != 1 {
+		t.Errorf("project-a profile = %+v", a)
+	}
+	if host := get("local://host-01"); host.ProfileID != "host-01" || host.DegradationPolicy != "FAIL_OPEN" || !host.Boundary.NetworkEnabled {
+		t.Errorf("host-01 profile = %+v", host)
+	}
+	if d := get("local://host-02"); d.ProfileID != "default" {
+		t.Errorf("unmatched resource: profile = %+v", d)
+	}
+
+	// version 为内容哈希：重载相同内容不变，内容变化则变化
+	if a.Version == "" || get("docker://project-a/api").Version != a.Version {
+		t.Errorf("version = %q", a.Version)
+	}
+	profiles[0].Boundary.NetworkEnabled = true
+	if err := s.SetSandboxProfiles(profiles); err != nil {
+		t.Fatal(err)
+	}
+	if v := get("docker://project-a/web").Version; v == a.Version {
+		t.Errorf("version unchanged after content change: %s", v)
+	}
+
+	// 非法配置被拒绝且保留原配置
+	bad := []config.SandboxProfileConfig{{ID: "x", Resource: "*", DegradationPolicy: "FAIL_SOMETIMES"}}
+	if err := s.SetSandboxProfiles(bad); err == nil {
+		t.Error("invalid degradation_policy accepted")
+	}
+	if p := get("local://host-01"); p.ProfileID != "host-01" {
+		t.Errorf("profiles replaced by invalid config: %+v", p)
+	}
+
+	// exec 审计记录命中的 profile_id
+	body := `{"subject":"agent-1","action":"exec:run","resource":"docker://project-a/web","command_line":"ls","trace_id":"trace-profile"}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/exec", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("exec: code=%d body=%s", rr.Code, rr.Body)
+	}
+	evs, _ := auditStore.QueryByTraceID(context.Background(), "trace-profile")
+	if len(evs) != 1 || evs[0].ProfileID != "project-a" {
+		t.Errorf("audit = %+v", evs)
+	}
+}
+
+func TestExecAuditReplaysLocalExecutions(t *testing.T) {
+	auditStore := audit.NewStubStore()
+	s, err := NewServer(&config.Config{}, &policy.StubEngine{}, cheq.NewStubEngine(), &delivery.StubProvider{}, auditStore, &ownership.StubResolver{}, false, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	err = s.SetSandboxProfiles([]config.SandboxProfileConfig{{
+		ID:                "project-a",
+		Resource:          "docker://project-a/*",
+		HotCacheActions:   []config.HotCacheActionConfig{{Executable: "git", ArgvAllowlist: []string{"status", "diff"}}},
+		DegradationPolicy: "FAIL_OPEN",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+
+	// If-None-Match 与当前 version 相同时 304
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth/sandbox-profile?resource=docker://project-a/web", nil))
+	etag := rr.Header().Get("ETag")
+	req := httptest.NewRequest(http.MethodGet, "/auth/sandbox-profile?resource=docker://project-a/web", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if etag == "" || rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("conditional get: etag=%q code=%d", etag, rr.Code)
+	}
+
+	batch := ExecAuditBatch{Subject: "agent-1", Resource: "docker://project-a/web", Records: []ExecAuditRecord{
+		{Kind: LocalExecHotCache, TraceID: "t-hit", Action: "exec:run", Argv: []string{"git", "status"}, ProfileID: "project-a"},
+		{Kind: LocalExecHotCache, TraceID: "t-miss", Action: "exec:run", Argv: []string{"git", "push"}, ProfileID: "project-a"},
+		{Kind: LocalExecFailOpen, TraceID: "t-open", Action: "exec:run", Argv: []string{"make"}, ProfileID: "project-a", ExitCode: 2},
+	}}
+	body, _ := json.Marshal(batch)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/exec/audit", strings.NewReader(string(body))))
+	var res ExecAuditResult
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+	if rr.Code != http.StatusOK || res.Recorded != 3 || res.Violations != 1 {
+		t.Fatalf("audit upload: code=%d result=%+v", rr.Code, res)
+	}
+	for traceID, want := range map[string]string{"t-hit": "hot_cache_allow", "t-miss": "hot_cache_violation", "t-open": "fail_open_allow"} {
+		evs, _ := auditStore.QueryByTraceID(context.Background(), traceID)
+		if len(evs) != 1 || evs[0].Decision != want || evs[0].AgentID != "agent-1" || evs[0].ProfileID != "project-a" {
+			t.Errorf("%s: audit = %+v, want %s", traceID, evs, want)
+		}
+	}
+}
@@ -3,15 +3,29 @@ package proxy
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
+	"diting/internal/agent"
+	"diting/internal/apikey"
 	"diting/internal/audit"
 	"diting/internal/cheq"
 	"diting/internal/config"
 	"diting/internal/delivery"
+	"diting/internal/delivery/feishu"
+	"diting/internal/grant"
+	"diting/internal/httpsig"
+	"diting/internal/jwtauth"
+	"diting/internal/models"
+	"diting/internal/mtls"
 	"diting/internal/ownership"
 	"diting/internal/policy"
 )
@@ -27,10 +41,24 @@ type Server struct {
 	ownership    ownership.Resolver
 	pipeline     *pipeline
 	chainHandler http.Handler
+
+	approvalSigner *cheq.ApprovalSigner // 审批链接验签；nil 表示不接受签名链接
+	approverTokens map[string]string    // 确认人 -> token，直接调用 /cheq/approve 时认证
+	grants         grant.Store          // 限时授权；nil 表示不启用
+	agents         agent.Registry       // Agent 注册表；nil 表示 /init_permission 仅占位
+	adminTokens    map[string]string    // 管理员 -> token，管理接口认证；空表示不校验
+	apiKeys        apikey.Store         // L0 API Key；nil 表示不强制 L0 校验
+	jwt            *jwtauth.Verifier    // L0 JWT 校验；nil 表示未启用
+	tls            *mtls.Reloader       // 非 nil 时以 HTTPS / mTLS 监听
+	httpsig        *httpsig.Verifier    // L0 HTTP 消息签名校验；nil 表示未启用
+
+	streamsMu sync.Mutex
+	streams   map[*streamConn]struct{} // 当前 AuthStream 连接，供 profile_update 推送
 }
 
-// NewServer 构造 Server；各组件由调用方注入。reviewRequiresApproval 为 true 时 review 路径轮询等待确认，否则立即放行（占位行为）。
+// NewServer 构造 Server；各组件由调用方注入。reviewRequiresApproval 为 true 时 review 路径轮询等待确认，否则立即放行（占位行为；审批策略要求计票时无法自动批准，按失败处理）。
 // approvalMatcher 为 I-009 按 path/risk 匹配超时与审批人；nil 则使用全局 CHEQ/Feishu 配置。
+// proxy.api_keys 中有无法解析的条目时返回错误，避免带着残缺的 Key 配置启动。
 func NewServer(
 	cfg *config.Config,
 	policy policy.Engine,
@@ -40,9 +68,14 @@ func NewServer(
 	ownership ownership.Resolver,
 	reviewRequiresApproval bool,
 	approvalMatcher *ownership.RuleMatcher,
-) *Server {
+) (*Server, error) {
+	apiKeys, err := newConfiguredAPIKeyStore(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
 	return &Server{
 		cfg:       cfg,
+		apiKeys:   apiKeys,
 		policy:    policy,
 		cheq:      cheq,
 		delivery:  delivery,
@@ -56,10 +89,12 @@ func NewServer(
 			cheqTimeoutSec:               cfg.CHEQ.TimeoutSeconds,
 			reminderSecondsBeforeTimeout: cfg.CHEQ.ReminderSecondsBeforeTimeout,
 			reviewRequiresApproval:       reviewRequiresApproval,
-			allowedAPIKeys:               cfg.Proxy.AllowedAPIKeys,
+			l0:                           l0Chain(cfg.Proxy.L0Authenticators, apiKeys, nil),
 			approvalMatcher:              approvalMatcher,
+			requesterOwners:              cfg.CHEQ.RequesterOwners,
+			profiles:                     &sandboxProfiles{},
 		},
-	}
+	}, nil
 }
 
 // SetChainHandler 设置 /chain/* 子模块 Handler（I-017）。调用方传入已处理 /chain 前缀后路径的 Handler。
@@ -67,6 +102,19 @@ func (s *Server) SetChainHandler(h http.Handler) {
 	s.chainHandler = h
 }
 
+// SetApproverAuth 设置 /cheq/approve 的审批人认证：签名链接与确认人 token。两者均未配置时保持无认证（仅限本地调试）。
+func (s *Server) SetApproverAuth(signer *cheq.ApprovalSigner, tokens map[string]string) {
+	s.approvalSigner = signer
+	s.approverTokens = tokens
+}
+
+// SetGrantStore 启用限时授权：流水线在创建 CHEQ 前查授权，审批人选择限时批准后写入授权，并挂载 /grants 接口。
+func (s *Server) SetGrantStore(g grant.Store) {
+	s.grants = g
+	s.pipeline.grants = g
+	s.pipeline.grantMaxMinutes = s.cfg.Grants.MaxMinutes
+}
+
 // Handler 返回用于注册路由的 HTTP Handler，供测试或外部嵌入使用。
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
@@ -80,11 +128,29 @@ func (s *Server) Handler() http.Handler {
 	})
 	mux.HandleFunc("/debug/audit", s.debugAuditHandler())
 	mux.HandleFunc("/cheq/approve", s.cheqApproveHandler())
+	mux.HandleFunc("/cheq/objects", s.cheqListHandler())
 	mux.HandleFunc("/feishu/card", s.feishuCardHandler())
+	if s.grants != nil {
+		mux.HandleFunc("/grants", s.grantListHandler())
+		mux.HandleFunc("/grants/revoke", s.grantRevokeHandler())
+	}
+	if s.apiKeys != nil {
+		mux.HandleFunc("/apikeys", s.apiKeysHandler())
+	}
 	mux.HandleFunc("/auth/exec", s.execAuthHandler())
+	mux.HandleFunc("/auth/exec/audit", s.execAuditHandler())
 	mux.HandleFunc("/auth/sandbox-profile", s.sandboxProfileHandler())
 	mux.HandleFunc("/auth/stream", s.authStreamHandler())
+	mux.HandleFunc("/streams", s.streamsHandler())
+	if s.httpsig != nil {
+		mux.HandleFunc("/auth/challenge", s.challengeHandler())
+	}
 	mux.HandleFunc("/init_permission", s.initPermissionHandler())
+	if s.agents != nil {
+		mux.HandleFunc("/agents", s.agentsHandler())
+		mux.HandleFunc("/agents/quarantine", s.quarantineHandler(false))
+		mux.HandleFunc("/agents/release", s.quarantineHandler(true))
+	}
 	if s.chainHandler != nil {
 		mux.Handle("/chain/", http.StripPrefix("/chain", s.chainHandler))
 	}
@@ -92,17 +158,28 @@ func (s *Server) Handler() http.Handler {
 	return mux
 }
 
-// Serve 启动 HTTP 服务：/healthz、/readyz 与代理监听（Phase 2 代理先返回 503）。
+// Serve 启动 HTTP 服务：/healthz、/readyz 与代理监听（Phase 2 代理先返回 503）；SetTLS 后以 HTTPS / mTLS 监听。
+// 配置了 proxy.grpc_listen_addr 时同时启动 gRPC ExecAuthService，配置了 proxy.unix_socket_path 时同时监听 Unix 套接字。
 func (s *Server) Serve(ctx context.Context) error {
 	addr := s.cfg.Proxy.ListenAddr
 	if addr == "" {
 		addr = ":8080"
 	}
+	if err := s.serveGRPC(ctx); err != nil {
+		return err
+	}
+	if err := s.serveUnix(ctx); err != nil {
+		return err
+	}
 	server := &http.Server{Addr: addr, Handler: s.Handler()}
 	go func() {
 		<-ctx.Done()
 		_ = server.Shutdown(context.Background())
 	}()
+	if s.tls != nil {
+		server.TLSConfig = s.tls.TLSConfig()
+		return server.ListenAndServeTLS("", "")
+	}
 	return server.ListenAndServe()
 }
 
@@ -131,6 +208,11 @@ func (s *Server) debugAuditHandler() http.HandlerFunc {
 }
 
 // cheqApproveHandler 处理 GET/POST /cheq/approve?id=xxx&approved=true|false，用于人工确认后提交。
+// 批准时可带 grant=minutes&grant_minutes=N 或 grant=session，对同一主体 + 操作 + 资源产生限时授权；
+// 可带 comment（备注）与 reason_code（原因码，见 models.Reason*），随结果反馈给 Agent。
+// 改后批准：exec 请求带 amend_command（改写后的命令行），HTTP 请求带 body_patch（JSON merge patch）；
+// 参数也可放在 POST 的 JSON 请求体中（body_patch 可直接为 JSON 对象）。
+// 启用审批人认证时须满足其一：签名链接（by、exp、sig）或请求头 X-Approver-Token；认证失败写 approval_rejected 审计。
 func (s *Server) cheqApproveHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.URL.Query().Get("id")
@@ -141,14 +223,61 @@ func (s *Server) cheqApproveHandler() http.HandlerFunc {
 			return
 		}
 		approved := approvedStr == "true" || approvedStr == "1" || approvedStr == "yes"
-		by := r.URL.Query().Get("by") // I-008 全部通过时标识谁批准
-		err := s.cheq.Submit(r.Context(), id, approved, by)
+		by, err := s.authenticateApprover(r, id)
+		if err != nil {
+			s.auditApprovalRejected(r.Context(), id, by, err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"` + err.Error() + `"}`))
+			return
+		}
+		opts, err := submitOptionsOf(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid json body"}`))
+			return
+		}
+		err = s.cheq.SubmitWithOptions(r.Context(), id, approved, by, opts)
 		if err != nil {
 			if err == cheq.ErrNotFound {
 				w.WriteHeader(http.StatusNotFound)
 				_, _ = w.Write([]byte(`{"error":"not found"}`))
 				return
 			}
+			if err == cheq.ErrInvalidAmendment {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid amendment: amend_command is for exec requests, body_patch (JSON object) for HTTP requests, approve only"}`))
+				return
+			}
+			if err == cheq.ErrAmendmentConflict {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"request already amended differently"}`))
+				return
+			}
+			if err == cheq.ErrInvalidReasonCode {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid reason_code"}`))
+				return
+			}
+			if err == cheq.ErrConfirmerRequired {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"missing by: approval policy requires confirmer id"}`))
+				return
+			}
+			if err == cheq.ErrSelfApproval {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"error":"requester cannot approve its own request"}`))
+				return
+			}
+			if err == cheq.ErrNotConfirmer {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"error":"not a confirmer of this request"}`))
+				return
+			}
+			if err == cheq.ErrAlreadyVoted {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"already voted"}`))
+				return
+			}
 			if err == cheq.ErrAlreadyProcessed || err == cheq.ErrExpired {
 				w.WriteHeader(http.StatusConflict)
 				_, _ = w.Write([]byte(`{"error":"already processed or expired"}`))
@@ -167,6 +296,107 @@ func (s *Server) cheqApproveHandler() http.HandlerFunc {
 	}
 }
 
+// maxApproveBody /cheq/approve JSON 请求体上限。
+const maxApproveBody = 64 << 10
+
+// submitOptionsOf 解析审批附加选择：查询参数，POST 且为 JSON 请求体时请求体中的同名字段优先。
+func submitOptionsOf(r *http.Request) (*cheq.SubmitOptions, error) {
+	q := r.URL.Query()
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return cheq.ParseSubmitOptions(q.Get), nil
+	}
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxApproveBody)).Decode(&body); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return cheq.ParseSubmitOptions(func(k string) string {
+		raw, ok := body[k]
+		if !ok {
+			return q.Get(k)
+		}
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+		return string(raw)
+	}), nil
+}
+
+// errApproverAuthRequired 表示已启用审批人认证但请求既无有效 token 也无签名。
+var errApproverAuthRequired = errors.New("approver authentication required")
+
+// errApproverTokenInvalid 表示 X-Approver-Token 无效或与 by 不一致。
+var errApproverTokenInvalid = errors.New("invalid approver token")
+
+// authenticateApprover 返回经认证的确认人标识。优先 X-Approver-Token（by 可省略，给出时须与 token 所属确认人一致），
+// 其次签名链接；两者均未配置时直接信任 by（I-008 兼容）。
+func (s *Server) authenticateApprover(r *http.Request, id string) (string, error) {
+	q := r.URL.Query()
+	by := q.Get("by")
+	if s.approvalSigner == nil && len(s.approverTokens) == 0 {
+		return by, nil
+	}
+	if tok := r.Header.Get("X-Approver-Token"); tok != "" {
+		who := s.approverByToken(tok)
+		if who == "" || (by != "" && by != who) {
+			return by, errApproverTokenInvalid
+		}
+		return who, nil
+	}
+	if q.Get("sig") != "" && s.approvalSigner != nil {
+		if err := s.approvalSigner.Verify(id, by, q.Get("exp"), q.Get("sig"), time.Now()); err != nil {
+			return by, err
+		}
+		return by, nil
+	}
+	return by, errApproverAuthRequired
+}
+
+// approverByToken 以常量时间比对全部 token，返回所属确认人；无匹配返回空。
+func (s *Server) approverByToken(tok string) string {
+	who := ""
+	for confirmer, t := range s.approverTokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(tok)) == 1 {
+			who = confirmer
+		}
+	}
+	return who
+}
+
+// approvalViewer 认证 /cheq/objects、/grants 等审批数据接口的调用方：X-Approver-Token 有效时返回其确认人（approver=true），
+// 否则按 adminOf 校验 X-Admin-Token。携带无效的 approver token、或启用了审批人认证而未配置 admin token 时一律拒绝，
+// 仅在审批人认证与 admin token 均未配置（本地调试）时放行。
+func (s *Server) approvalViewer(r *http.Request) (who string, approver bool, ok bool) {
+	if tok := r.Header.Get("X-Approver-Token"); tok != "" {
+		who = s.approverByToken(tok)
+		return who, true, who != ""
+	}
+	if len(s.adminTokens) == 0 && (s.approvalSigner != nil || len(s.approverTokens) > 0) {
+		return "", false, false
+	}
+	who, ok = s.adminOf(r)
+	return who, false, ok
+}
+
+// auditApprovalRejected 记录被拒绝的审批尝试（认证失败）；对象存在时带上其 trace 与资源。
+func (s *Server) auditApprovalRejected(ctx context.Context, id, by, reason string) {
+	ev := &models.Evidence{
+		TraceID:        id,
+		PolicyRuleID:   "cheq_approver_auth",
+		DecisionReason: reason,
+		Decision:       "approval_rejected",
+		Confirmer:      by,
+		Timestamp:      time.Now(),
+	}
+	if obj, err := s.cheq.GetByID(ctx, id); err == nil && obj != nil {
+		ev.TraceID = obj.TraceID
+		ev.CHEQStatus = string(obj.Status)
+		ev.Resource = obj.Resource
+		ev.Action = obj.Action
+	}
+	_ = s.audit.Append(ctx, ev)
+}
+
 // execAuthHandler 处理 POST /auth/exec 执行能力鉴权；与 HTTP 代理共用 Policy、CHEQ、Audit（Story 7.1、7.2）。
 func (s *Server) execAuthHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -174,6 +404,8 @@ func (s *Server) execAuthHandler() http.HandlerFunc {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
+		// 先验签：校验 content-digest 时读取并还原请求体
+		sigDID, sigErr := s.checkSignature(r)
 		var body ExecAuthRequest
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -190,11 +422,12 @@ func (s *Server) execAuthHandler() http.HandlerFunc {
 		if traceID == "" {
 			traceID = uuid.New().String()
 		}
+		// L0 身份优先取请求头中的凭证；未携带时以请求体 subject 作自报身份
 		agentIdentity := r.Header.Get("X-Agent-Token")
 		if agentIdentity == "" {
 			agentIdentity = r.Header.Get("Authorization")
 		}
-		if body.Subject != "" {
+		if agentIdentity == "" {
 			agentIdentity = body.Subject
 		}
 		reqCtx := BuildRequestContextFromExec(&body, agentIdentity)
@@ -203,6 +436,9 @@ func (s *Server) execAuthHandler() http.HandlerFunc {
 			_, _ = w.Write([]byte(`{"error":"missing subject/action/resource"}`))
 			return
 		}
+		s.applyClientCert(r, reqCtx)
+		s.applyPeerCred(r, reqCtx)
+		reqCtx.SignatureDID, reqCtx.SignatureError = sigDID, sigErr
 		ctx := context.WithValue(r.Context(), ctxKeyTraceID, traceID)
 		resp, err := s.pipeline.ExecEvaluate(ctx, traceID, reqCtx)
 		if err != nil {
@@ -221,41 +457,20 @@ func (s *Server) execAuthHandler() http.HandlerFunc {
 	}
 }
 
-// initPermissionHandler 处理天枢「Agent 注册完成」通知：POST /init_permission，体为 agent_id、owner_id。
-// 占位实现：返回 200 OK，便于天枢配置 DITING_INIT_PERMISSION_URL；默认策略由全局 policy 规则文件生效。
-func (s *Server) initPermissionHandler() http.HandlerFunc {
+// feishuCardHandler 处理飞书卡片回调 POST /feishu/card（HTTP 回调方式）。长连接方式下卡片点击事件在 feishu.RunLongConnection 中处理。
+func (s *Server) feishuCardHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		var body struct {
-			AgentID string `json:"agent_id"`
-			OwnerID string `json:"owner_id"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		raw, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		if body.AgentID != "" {
-			// 可选：记录或下发默认策略；当前仅占位
-			_ = body.OwnerID
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"ok":true}`))
-	}
-}
-
-// feishuCardHandler 处理飞书卡片回调 POST /feishu/card（HTTP 回调方式）。长连接方式下卡片点击事件在 feishu.RunLongConnection 中处理。
-func (s *Server) feishuCardHandler() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
 		var callback map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&callback); err != nil {
+		if err := json.Unmarshal(raw, &callback); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -282,13 +497,29 @@ func (s *Server) feishuCardHandler() http.HandlerFunc {
 			return
 		}
 		approved := actionType == "approve"
-		err := s.cheq.Submit(r.Context(), requestID, approved, "")
+		operator := cardOperatorID(callback, s.cfg.Delivery.Feishu.ReceiveIDType)
+		// operator 仅在回调经飞书签名或 verification token 校验后可信；启用审批人认证时未配置校验即拒绝
+		if err := s.verifyFeishuCallback(r, raw); err != nil {
+			s.auditApprovalRejected(r.Context(), requestID, operator, "feishu card callback: "+err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		opts := cheq.ParseSubmitOptions(func(k string) string {
+			v, _ := value[k].(string)
+			return v
+		})
+		err = s.cheq.SubmitWithOptions(r.Context(), requestID, approved, operator, opts)
 		if err != nil {
 			if err == cheq.ErrNotFound || err == cheq.ErrExpired || err == cheq.ErrAlreadyProcessed {
 				w.Header().Set("Content-Type", "application/json")
 				_, _ = w.Write([]byte(`{"toast":{"type":"warning","content":"该请求已失效或已处理"}}`))
 				return
 			}
+			if err == cheq.ErrNotConfirmer || err == cheq.ErrConfirmerRequired || err == cheq.ErrAlreadyVoted {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"toast":{"type":"warning","content":"您不是该请求的审批人或已投过票"}}`))
+				return
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -300,3 +531,28 @@ func (s *Server) feishuCardHandler() http.HandlerFunc {
 		_, _ = w.Write([]byte(`{"toast":{"type":"success","content":"` + msg + `"}}`))
 	}
 }
+
+// verifyFeishuCallback 校验 /feishu/card 回调来源（feishu.VerifyCardCallback）。未配置 encrypt_key / verification_token 时，
+// 仅在未启用审批人认证（签名链接、approver token）时放行，与 /cheq/approve 的兼容行为一致。
+func (s *Server) verifyFeishuCallback(r *http.Request, body []byte) error {
+	err := feishu.VerifyCardCallback(s.cfg.Delivery.Feishu, r.Header, body, time.Now())
+	if errors.Is(err, feishu.ErrCallbackUnverifiable) && s.approvalSigner == nil && len(s.approverTokens) == 0 {
+		return nil
+	}
+	return err
+}
+
+// cardOperatorID 从飞书卡片回调中取点击人标识：receive_id_type 为 user_id 时取 user_id，否则取 open_id，与审批人配置一致。
+func cardOperatorID(callback map[string]interface{}, receiveIDType string) string {
+	op, _ := callback["operator"].(map[string]interface{})
+	if op == nil {
+		return ""
+	}
+	if receiveIDType == "user_id" {
+		if id, _ := op["user_id"].(string); id != "" {
+			return id
+		}
+	}
+	id, _ := op["open_id"].(string)
+	return id
+}
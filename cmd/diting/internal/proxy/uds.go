@@ -0,0 +1,135 @@
+// Unix 域套接字监听：同机 Agent 经套接字调用 /auth/exec、/auth/sandbox-profile 与 /auth/stream，
+// 身份取自内核提供的对端凭证（SO_PEERCRED），客户端自报的 subject 与 peer_* 上下文不作信任依据。
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"diting/internal/models"
+	"diting/internal/peercred"
+)
+
+// ctxKeyPeerCred 用于在连接 context 中存放对端凭证。
+const ctxKeyPeerCred ctxKey = "peer_cred"
+
+// errPeerCredMissing 经 Unix 套接字到达但取不到内核对端凭证时的拒绝原因。
+const errPeerCredMissing = "peer credential unavailable"
+
+// defaultUnixSocketMode 套接字文件的默认权限。
+const defaultUnixSocketMode = 0660
+
+// unixHandler 返回套接字上挂载的路由：仅执行层接口。
+func (s *Server) unixHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/auth/exec", s.execAuthHandler())
+	mux.HandleFunc("/auth/exec/audit", s.execAuditHandler())
+	mux.HandleFunc("/auth/sandbox-profile", s.sandboxProfileHandler())
+	mux.HandleFunc("/auth/stream", s.authStreamHandler())
+	return requirePeerCred(mux)
+}
+
+// requirePeerCred 拒绝取不到内核对端凭证的套接字请求（如非 Linux 平台或 SO_PEERCRED 失败），
+// 避免退回信任客户端自报的 subject 与 peer_* 上下文；/healthz 不受限。
+func requirePeerCred(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			if _, ok := r.Context().Value(ctxKeyPeerCred).(*peercred.Cred); !ok {
+				writeJSONError(w, http.StatusForbidden, errPeerCredMissing)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveUnix 在 proxy.unix_socket_path 上监听直到 ctx 结束；未配置时不启动。已存在的套接字文件先删除。
+func (s *Server) serveUnix(ctx context.Context) error {
+	path := s.cfg.Proxy.UnixSocketPath
+	if path == "" {
+		return nil
+	}
+	mode := os.FileMode(defaultUnixSocketMode)
+	if m := s.cfg.Proxy.UnixSocketMode; m != "" {
+		v, err := strconv.ParseUint(m, 8, 32)
+		if err != nil {
+			return fmt.Errorf("proxy.unix_socket_mode: %v", err)
+		}
+		mode = os.FileMode(v)
+	}
+	if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = lis.Close()
+		return err
+	}
+	server := &http.Server{
+		Handler: s.unixHandler(),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			cred, err := peercred.FromConn(c)
+			if err != nil {
+				return ctx
+			}
+			return context.WithValue(ctx, ctxKeyPeerCred, cred)
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+	go func() {
+		if err := server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			_, _ = fmt.Fprintf(os.Stderr, "[diting] unix socket: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// applyPeerCred 先剔除客户端自报的 peer_uid/peer_gid/peer_pid/container_id 上下文（任何传输上都不采信）；
+// 对经 Unix 套接字到达的请求再以对端凭证作为身份：L0 凭证只取请求头（不再以自报 subject 代替），
+// 凭证写入 req.PeerIdentity，上述键以内核提供的值写回。
+func (s *Server) applyPeerCred(r *http.Request, req *models.RequestContext) {
+	if req == nil {
+		return
+	}
+	stripPeerContext(req)
+	cred, ok := r.Context().Value(ctxKeyPeerCred).(*peercred.Cred)
+	if !ok {
+		return
+	}
+	req.AgentIdentity = r.Header.Get("X-Agent-Token")
+	if req.AgentIdentity == "" {
+		req.AgentIdentity = r.Header.Get("Authorization")
+	}
+	req.PeerIdentity = cred.Identity()
+	for k, v := range cred.Context() {
+		req.Context[k] = v
+	}
+}
+
+// stripPeerContext 以副本替换 req.Context 并删除 peercred.ContextKeys，这些键只能由内核凭证写入。
+func stripPeerContext(req *models.RequestContext) {
+	ctx := make(map[string]string, len(req.Context)+len(peercred.ContextKeys))
+	for k, v := range req.Context {
+		ctx[k] = v
+	}
+	for _, k := range peercred.ContextKeys {
+		delete(ctx, k)
+	}
+	req.Context = ctx
+}
@@ -0,0 +1,1974 @@
+// 3AF Exec API - AI Agent Audit & Firewall
+// 基础设施层契约：同机 UDS (SO_PEERCRED) + 跨机 gRPC (mTLS)
+// 方案定位：Docker/混合环境安全内核
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: 3af_exec.proto
+
+package ditingpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DegradationPolicy int32
+
+const (
+	DegradationPolicy_DEGRADATION_UNSPECIFIED DegradationPolicy = 0
+	DegradationPolicy_FAIL_OPEN               DegradationPolicy = 1 // 3AF 挂了 -> 放行 (业务优先)
+	DegradationPolicy_FAIL_CLOSE              DegradationPolicy = 2 // 3AF 挂了 -> 拒绝 (安全优先)
+)
+
+// Enum value maps for DegradationPolicy.
+var (
+	DegradationPolicy_name = map[int32]string{
+		0: "DEGRADATION_UNSPECIFIED",
+		1: "FAIL_OPEN",
+		2: "FAIL_CLOSE",
+	}
+	DegradationPolicy_value = map[string]int32{
+		"DEGRADATION_UNSPECIFIED": 0,
+		"FAIL_OPEN":               1,
+		"FAIL_CLOSE":              2,
+	}
+)
+
+func (x DegradationPolicy) Enum() *DegradationPolicy {
+	p := new(DegradationPolicy)
+	*p = x
+	return p
+}
+
+func (x DegradationPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DegradationPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file__3af_exec_proto_enumTypes[0].Descriptor()
+}
+
+func (DegradationPolicy) Type() protoreflect.EnumType {
+	return &file__3af_exec_proto_enumTypes[0]
+}
+
+func (x DegradationPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DegradationPolicy.Descriptor instead.
+func (DegradationPolicy) EnumDescriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{0}
+}
+
+type Decision int32
+
+const (
+	Decision_DECISION_UNSPECIFIED Decision = 0
+	Decision_ALLOW                Decision = 1
+	Decision_DENY                 Decision = 2
+	Decision_REVIEW               Decision = 3 // 进入 pending 状态
+)
+
+// Enum value maps for Decision.
+var (
+	Decision_name = map[int32]string{
+		0: "DECISION_UNSPECIFIED",
+		1: "ALLOW",
+		2: "DENY",
+		3: "REVIEW",
+	}
+	Decision_value = map[string]int32{
+		"DECISION_UNSPECIFIED": 0,
+		"ALLOW":                1,
+		"DENY":                 2,
+		"REVIEW":               3,
+	}
+)
+
+func (x Decision) Enum() *Decision {
+	p := new(Decision)
+	*p = x
+	return p
+}
+
+func (x Decision) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Decision) Descriptor() protoreflect.EnumDescriptor {
+	return file__3af_exec_proto_enumTypes[1].Descriptor()
+}
+
+func (Decision) Type() protoreflect.EnumType {
+	return &file__3af_exec_proto_enumTypes[1]
+}
+
+func (x Decision) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Decision.Descriptor instead.
+func (Decision) EnumDescriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{1}
+}
+
+// RequestContext 3.0：策略引擎的统一输入
+type RequestContext struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Subject  string            `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`                                                                                         // Agent ID / Sandbox ID
+	Action   string            `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`                                                                                           // 核心动作: exec:run, exec:fs.write, exec:net.connect, exec:sudo
+	Resource string            `protobuf:"bytes,3,opt,name=resource,proto3" json:"resource,omitempty"`                                                                                       // 资源标识: docker://<container_id>, local://<host>
+	Context  map[string]string `protobuf:"bytes,4,rep,name=context,proto3" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // 上下文: command, args, cwd, env_vars, parent_pid
+}
+
+func (x *RequestContext) Reset() {
+	*x = RequestContext{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RequestContext) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestContext) ProtoMessage() {}
+
+func (x *RequestContext) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestContext.ProtoReflect.Descriptor instead.
+func (*RequestContext) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RequestContext) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *RequestContext) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *RequestContext) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *RequestContext) GetContext() map[string]string {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+// 物理身份凭证 (服务端通过 UDS syscall 提取，或 mTLS 证书映射)
+// 实现约束：使用 UDS 时，服务端必须忽略 Request 中客户端填充的 PeerCred，
+// 强制通过 getsockopt(SO_PEERCRED) 从连接提取；Request 中本字段仅用于跨机调试或存根
+type PeerCred struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Uid         uint32 `protobuf:"varint,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid         uint32 `protobuf:"varint,2,opt,name=gid,proto3" json:"gid,omitempty"`
+	Pid         int64  `protobuf:"varint,3,opt,name=pid,proto3" json:"pid,omitempty"`
+	ContainerId string `protobuf:"bytes,4,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"` // 若能从 cgroup 提取则由服务端填充
+}
+
+func (x *PeerCred) Reset() {
+	*x = PeerCred{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerCred) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerCred) ProtoMessage() {}
+
+func (x *PeerCred) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerCred.ProtoReflect.Descriptor instead.
+func (*PeerCred) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PeerCred) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *PeerCred) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+func (x *PeerCred) GetPid() int64 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *PeerCred) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+// 跨机/长连接会话令牌
+type SessionToken struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Token            string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	ExpiresAtUnixSec int64  `protobuf:"varint,2,opt,name=expires_at_unix_sec,json=expiresAtUnixSec,proto3" json:"expires_at_unix_sec,omitempty"`
+	SandboxId        string `protobuf:"bytes,3,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+}
+
+func (x *SessionToken) Reset() {
+	*x = SessionToken{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SessionToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionToken) ProtoMessage() {}
+
+func (x *SessionToken) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionToken.ProtoReflect.Descriptor instead.
+func (*SessionToken) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SessionToken) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *SessionToken) GetExpiresAtUnixSec() int64 {
+	if x != nil {
+		return x.ExpiresAtUnixSec
+	}
+	return 0
+}
+
+func (x *SessionToken) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+type SandboxBoundary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	NetworkEnabled  bool     `protobuf:"varint,1,opt,name=network_enabled,json=networkEnabled,proto3" json:"network_enabled,omitempty"`
+	FsWritablePaths []string `protobuf:"bytes,2,rep,name=fs_writable_paths,json=fsWritablePaths,proto3" json:"fs_writable_paths,omitempty"`
+	SyscallPreset   string   `protobuf:"bytes,3,opt,name=syscall_preset,json=syscallPreset,proto3" json:"syscall_preset,omitempty"` // 如 "default", "restricted"
+	MaxMemoryMb     int64    `protobuf:"varint,4,opt,name=max_memory_mb,json=maxMemoryMb,proto3" json:"max_memory_mb,omitempty"`
+	ReadonlyRoot    bool     `protobuf:"varint,5,opt,name=readonly_root,json=readonlyRoot,proto3" json:"readonly_root,omitempty"`
+}
+
+func (x *SandboxBoundary) Reset() {
+	*x = SandboxBoundary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SandboxBoundary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxBoundary) ProtoMessage() {}
+
+func (x *SandboxBoundary) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxBoundary.ProtoReflect.Descriptor instead.
+func (*SandboxBoundary) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SandboxBoundary) GetNetworkEnabled() bool {
+	if x != nil {
+		return x.NetworkEnabled
+	}
+	return false
+}
+
+func (x *SandboxBoundary) GetFsWritablePaths() []string {
+	if x != nil {
+		return x.FsWritablePaths
+	}
+	return nil
+}
+
+func (x *SandboxBoundary) GetSyscallPreset() string {
+	if x != nil {
+		return x.SyscallPreset
+	}
+	return ""
+}
+
+func (x *SandboxBoundary) GetMaxMemoryMb() int64 {
+	if x != nil {
+		return x.MaxMemoryMb
+	}
+	return 0
+}
+
+func (x *SandboxBoundary) GetReadonlyRoot() bool {
+	if x != nil {
+		return x.ReadonlyRoot
+	}
+	return false
+}
+
+// Hot Cache: 本地快速放行规则
+type HotCacheAction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Executable    string   `protobuf:"bytes,1,opt,name=executable,proto3" json:"executable,omitempty"`                            // 精确匹配，如 "/bin/ls"
+	ArgvAllowlist []string `protobuf:"bytes,2,rep,name=argv_allowlist,json=argvAllowlist,proto3" json:"argv_allowlist,omitempty"` // 参数白名单，空则不限
+	RunAsSudo     bool     `protobuf:"varint,3,opt,name=run_as_sudo,json=runAsSudo,proto3" json:"run_as_sudo,omitempty"`          // 命中后，Agent 是否自动提权执行
+}
+
+func (x *HotCacheAction) Reset() {
+	*x = HotCacheAction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HotCacheAction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HotCacheAction) ProtoMessage() {}
+
+func (x *HotCacheAction) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HotCacheAction.ProtoReflect.Descriptor instead.
+func (*HotCacheAction) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HotCacheAction) GetExecutable() string {
+	if x != nil {
+		return x.Executable
+	}
+	return ""
+}
+
+func (x *HotCacheAction) GetArgvAllowlist() []string {
+	if x != nil {
+		return x.ArgvAllowlist
+	}
+	return nil
+}
+
+func (x *HotCacheAction) GetRunAsSudo() bool {
+	if x != nil {
+		return x.RunAsSudo
+	}
+	return false
+}
+
+// Sudo 专用预授权 (针对运维类复杂命令)
+// 实现约束：Node Agent 应对 command_line 做参数解析 (Tokenization) 后再与 command_pattern 匹配，
+// 或约定 command_pattern 使用 Glob 规范，避免多空格等绕过 (如 apt-get  install)
+type SudoHotCacheEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CommandPattern string `protobuf:"bytes,1,opt,name=command_pattern,json=commandPattern,proto3" json:"command_pattern,omitempty"` // 支持 Glob，如 "systemctl restart *"
+	RunAsUser      string `protobuf:"bytes,2,opt,name=run_as_user,json=runAsUser,proto3" json:"run_as_user,omitempty"`              // 默认 "root"，也可为 "www-data" 等
+	Description    string `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+}
+
+func (x *SudoHotCacheEntry) Reset() {
+	*x = SudoHotCacheEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SudoHotCacheEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SudoHotCacheEntry) ProtoMessage() {}
+
+func (x *SudoHotCacheEntry) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SudoHotCacheEntry.ProtoReflect.Descriptor instead.
+func (*SudoHotCacheEntry) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SudoHotCacheEntry) GetCommandPattern() string {
+	if x != nil {
+		return x.CommandPattern
+	}
+	return ""
+}
+
+func (x *SudoHotCacheEntry) GetRunAsUser() string {
+	if x != nil {
+		return x.RunAsUser
+	}
+	return ""
+}
+
+func (x *SudoHotCacheEntry) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+// 策略下沉：加密的基础策略副本（可选，用于离线/逃生）
+type PolicySnapshot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	EncryptedPayload  []byte `protobuf:"bytes,1,opt,name=encrypted_payload,json=encryptedPayload,proto3" json:"encrypted_payload,omitempty"`
+	Version           string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	ValidUntilUnixSec int64  `protobuf:"varint,3,opt,name=valid_until_unix_sec,json=validUntilUnixSec,proto3" json:"valid_until_unix_sec,omitempty"`
+}
+
+func (x *PolicySnapshot) Reset() {
+	*x = PolicySnapshot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PolicySnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolicySnapshot) ProtoMessage() {}
+
+func (x *PolicySnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolicySnapshot.ProtoReflect.Descriptor instead.
+func (*PolicySnapshot) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PolicySnapshot) GetEncryptedPayload() []byte {
+	if x != nil {
+		return x.EncryptedPayload
+	}
+	return nil
+}
+
+func (x *PolicySnapshot) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *PolicySnapshot) GetValidUntilUnixSec() int64 {
+	if x != nil {
+		return x.ValidUntilUnixSec
+	}
+	return 0
+}
+
+type SandboxProfile struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ProfileId         string               `protobuf:"bytes,1,opt,name=profile_id,json=profileId,proto3" json:"profile_id,omitempty"`
+	Version           string               `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"` // 版本/哈希，用于检测配置漂移与 Hot Cache 失效
+	Boundary          *SandboxBoundary     `protobuf:"bytes,3,opt,name=boundary,proto3" json:"boundary,omitempty"`
+	HotCacheActions   []*HotCacheAction    `protobuf:"bytes,4,rep,name=hot_cache_actions,json=hotCacheActions,proto3" json:"hot_cache_actions,omitempty"`
+	SudoHotCache      []*SudoHotCacheEntry `protobuf:"bytes,5,rep,name=sudo_hot_cache,json=sudoHotCache,proto3" json:"sudo_hot_cache,omitempty"`
+	DegradationPolicy DegradationPolicy    `protobuf:"varint,6,opt,name=degradation_policy,json=degradationPolicy,proto3,enum=diting.v1.DegradationPolicy" json:"degradation_policy,omitempty"`
+	SessionToken      *SessionToken        `protobuf:"bytes,7,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	PolicySnapshot    *PolicySnapshot      `protobuf:"bytes,8,opt,name=policy_snapshot,json=policySnapshot,proto3" json:"policy_snapshot,omitempty"` // 可选，故障逃生时使用
+}
+
+func (x *SandboxProfile) Reset() {
+	*x = SandboxProfile{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SandboxProfile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxProfile) ProtoMessage() {}
+
+func (x *SandboxProfile) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxProfile.ProtoReflect.Descriptor instead.
+func (*SandboxProfile) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SandboxProfile) GetProfileId() string {
+	if x != nil {
+		return x.ProfileId
+	}
+	return ""
+}
+
+func (x *SandboxProfile) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *SandboxProfile) GetBoundary() *SandboxBoundary {
+	if x != nil {
+		return x.Boundary
+	}
+	return nil
+}
+
+func (x *SandboxProfile) GetHotCacheActions() []*HotCacheAction {
+	if x != nil {
+		return x.HotCacheActions
+	}
+	return nil
+}
+
+func (x *SandboxProfile) GetSudoHotCache() []*SudoHotCacheEntry {
+	if x != nil {
+		return x.SudoHotCache
+	}
+	return nil
+}
+
+func (x *SandboxProfile) GetDegradationPolicy() DegradationPolicy {
+	if x != nil {
+		return x.DegradationPolicy
+	}
+	return DegradationPolicy_DEGRADATION_UNSPECIFIED
+}
+
+func (x *SandboxProfile) GetSessionToken() *SessionToken {
+	if x != nil {
+		return x.SessionToken
+	}
+	return nil
+}
+
+func (x *SandboxProfile) GetPolicySnapshot() *PolicySnapshot {
+	if x != nil {
+		return x.PolicySnapshot
+	}
+	return nil
+}
+
+type GetSandboxProfileRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resource     string `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`                             // 如 docker://project-a/dev 或 local://host-01
+	AgentVersion string `protobuf:"bytes,2,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"` // Agent 版本，便于服务端做兼容性判断
+}
+
+func (x *GetSandboxProfileRequest) Reset() {
+	*x = GetSandboxProfileRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSandboxProfileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSandboxProfileRequest) ProtoMessage() {}
+
+func (x *GetSandboxProfileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSandboxProfileRequest.ProtoReflect.Descriptor instead.
+func (*GetSandboxProfileRequest) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetSandboxProfileRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *GetSandboxProfileRequest) GetAgentVersion() string {
+	if x != nil {
+		return x.AgentVersion
+	}
+	return ""
+}
+
+type GetSandboxProfileResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Profile *SandboxProfile `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
+}
+
+func (x *GetSandboxProfileResponse) Reset() {
+	*x = GetSandboxProfileResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSandboxProfileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSandboxProfileResponse) ProtoMessage() {}
+
+func (x *GetSandboxProfileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSandboxProfileResponse.ProtoReflect.Descriptor instead.
+func (*GetSandboxProfileResponse) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetSandboxProfileResponse) GetProfile() *SandboxProfile {
+	if x != nil {
+		return x.Profile
+	}
+	return nil
+}
+
+type ExecAuthRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ctx           *RequestContext   `protobuf:"bytes,1,opt,name=ctx,proto3" json:"ctx,omitempty"`
+	CommandLine   string            `protobuf:"bytes,2,opt,name=command_line,json=commandLine,proto3" json:"command_line,omitempty"` // 完整命令行 (用于审计)
+	WorkingDir    string            `protobuf:"bytes,3,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	ParentProcess string            `protobuf:"bytes,4,opt,name=parent_process,json=parentProcess,proto3" json:"parent_process,omitempty"`
+	TraceId       string            `protobuf:"bytes,5,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`                                                                  // 分布式追踪 ID
+	Env           map[string]string `protobuf:"bytes,6,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // 环境变量快照 (可选，用于敏感信息检查)
+}
+
+func (x *ExecAuthRequest) Reset() {
+	*x = ExecAuthRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExecAuthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecAuthRequest) ProtoMessage() {}
+
+func (x *ExecAuthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecAuthRequest.ProtoReflect.Descriptor instead.
+func (*ExecAuthRequest) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ExecAuthRequest) GetCtx() *RequestContext {
+	if x != nil {
+		return x.Ctx
+	}
+	return nil
+}
+
+func (x *ExecAuthRequest) GetCommandLine() string {
+	if x != nil {
+		return x.CommandLine
+	}
+	return ""
+}
+
+func (x *ExecAuthRequest) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *ExecAuthRequest) GetParentProcess() string {
+	if x != nil {
+		return x.ParentProcess
+	}
+	return ""
+}
+
+func (x *ExecAuthRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *ExecAuthRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+type ExecAuthResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Decision           Decision          `protobuf:"varint,1,opt,name=decision,proto3,enum=diting.v1.Decision" json:"decision,omitempty"`
+	PolicyRuleId       string            `protobuf:"bytes,2,opt,name=policy_rule_id,json=policyRuleId,proto3" json:"policy_rule_id,omitempty"`
+	Reason             string            `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	CheqId             string            `protobuf:"bytes,4,opt,name=cheq_id,json=cheqId,proto3" json:"cheq_id,omitempty"`
+	ApprovalTimeoutSec int32             `protobuf:"varint,5,opt,name=approval_timeout_sec,json=approvalTimeoutSec,proto3" json:"approval_timeout_sec,omitempty"`
+	AuditMetadata      map[string]string `protobuf:"bytes,6,rep,name=audit_metadata,json=auditMetadata,proto3" json:"audit_metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // 审计元数据，如 "record_stdout": "true"
+	AmendedCommandLine string            `protobuf:"bytes,7,opt,name=amended_command_line,json=amendedCommandLine,proto3" json:"amended_command_line,omitempty"`                                                                        // 审批人改后批准时应执行的命令行（非空则替代原命令）
+}
+
+func (x *ExecAuthResponse) Reset() {
+	*x = ExecAuthResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExecAuthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecAuthResponse) ProtoMessage() {}
+
+func (x *ExecAuthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecAuthResponse.ProtoReflect.Descriptor instead.
+func (*ExecAuthResponse) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ExecAuthResponse) GetDecision() Decision {
+	if x != nil {
+		return x.Decision
+	}
+	return Decision_DECISION_UNSPECIFIED
+}
+
+func (x *ExecAuthResponse) GetPolicyRuleId() string {
+	if x != nil {
+		return x.PolicyRuleId
+	}
+	return ""
+}
+
+func (x *ExecAuthResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ExecAuthResponse) GetCheqId() string {
+	if x != nil {
+		return x.CheqId
+	}
+	return ""
+}
+
+func (x *ExecAuthResponse) GetApprovalTimeoutSec() int32 {
+	if x != nil {
+		return x.ApprovalTimeoutSec
+	}
+	return 0
+}
+
+func (x *ExecAuthResponse) GetAuditMetadata() map[string]string {
+	if x != nil {
+		return x.AuditMetadata
+	}
+	return nil
+}
+
+func (x *ExecAuthResponse) GetAmendedCommandLine() string {
+	if x != nil {
+		return x.AmendedCommandLine
+	}
+	return ""
+}
+
+type AuthStreamRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // 客户端生成，用于关联 Response
+	// Types that are assignable to Payload:
+	//	*AuthStreamRequest_Init
+	//	*AuthStreamRequest_Auth
+	//	*AuthStreamRequest_Ping
+	//	*AuthStreamRequest_Cancel
+	Payload isAuthStreamRequest_Payload `protobuf_oneof:"payload"`
+}
+
+func (x *AuthStreamRequest) Reset() {
+	*x = AuthStreamRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthStreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthStreamRequest) ProtoMessage() {}
+
+func (x *AuthStreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthStreamRequest.ProtoReflect.Descriptor instead.
+func (*AuthStreamRequest) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AuthStreamRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (m *AuthStreamRequest) GetPayload() isAuthStreamRequest_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (x *AuthStreamRequest) GetInit() *AuthStreamInit {
+	if x, ok := x.GetPayload().(*AuthStreamRequest_Init); ok {
+		return x.Init
+	}
+	return nil
+}
+
+func (x *AuthStreamRequest) GetAuth() *ExecAuthRequest {
+	if x, ok := x.GetPayload().(*AuthStreamRequest_Auth); ok {
+		return x.Auth
+	}
+	return nil
+}
+
+func (x *AuthStreamRequest) GetPing() string {
+	if x, ok := x.GetPayload().(*AuthStreamRequest_Ping); ok {
+		return x.Ping
+	}
+	return ""
+}
+
+func (x *AuthStreamRequest) GetCancel() *AuthStreamCancel {
+	if x, ok := x.GetPayload().(*AuthStreamRequest_Cancel); ok {
+		return x.Cancel
+	}
+	return nil
+}
+
+type isAuthStreamRequest_Payload interface {
+	isAuthStreamRequest_Payload()
+}
+
+type AuthStreamRequest_Init struct {
+	Init *AuthStreamInit `protobuf:"bytes,2,opt,name=init,proto3,oneof"` // 握手包 (连接建立首包)，传 client_id/resource/跨机 Token
+}
+
+type AuthStreamRequest_Auth struct {
+	Auth *ExecAuthRequest `protobuf:"bytes,3,opt,name=auth,proto3,oneof"` // 鉴权请求
+}
+
+type AuthStreamRequest_Ping struct {
+	Ping string `protobuf:"bytes,4,opt,name=ping,proto3,oneof"` // 心跳
+}
+
+type AuthStreamRequest_Cancel struct {
+	Cancel *AuthStreamCancel `protobuf:"bytes,5,opt,name=cancel,proto3,oneof"` // 撤回本连接上发起、仍待审批的 CHEQ（如用户 Ctrl-C）
+}
+
+func (*AuthStreamRequest_Init) isAuthStreamRequest_Payload() {}
+
+func (*AuthStreamRequest_Auth) isAuthStreamRequest_Payload() {}
+
+func (*AuthStreamRequest_Ping) isAuthStreamRequest_Payload() {}
+
+func (*AuthStreamRequest_Cancel) isAuthStreamRequest_Payload() {}
+
+type AuthStreamCancel struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CheqId string `protobuf:"bytes,1,opt,name=cheq_id,json=cheqId,proto3" json:"cheq_id,omitempty"`
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (x *AuthStreamCancel) Reset() {
+	*x = AuthStreamCancel{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthStreamCancel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthStreamCancel) ProtoMessage() {}
+
+func (x *AuthStreamCancel) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthStreamCancel.ProtoReflect.Descriptor instead.
+func (*AuthStreamCancel) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AuthStreamCancel) GetCheqId() string {
+	if x != nil {
+		return x.CheqId
+	}
+	return ""
+}
+
+func (x *AuthStreamCancel) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type AuthStreamInit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientId     string `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Resource     string `protobuf:"bytes,2,opt,name=resource,proto3" json:"resource,omitempty"`
+	AgentVersion string `protobuf:"bytes,3,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"` // 跨机时 Token 放在此处或 gRPC Metadata Header 中
+}
+
+func (x *AuthStreamInit) Reset() {
+	*x = AuthStreamInit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthStreamInit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthStreamInit) ProtoMessage() {}
+
+func (x *AuthStreamInit) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthStreamInit.ProtoReflect.Descriptor instead.
+func (*AuthStreamInit) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *AuthStreamInit) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *AuthStreamInit) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *AuthStreamInit) GetAgentVersion() string {
+	if x != nil {
+		return x.AgentVersion
+	}
+	return ""
+}
+
+type AuthStreamResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // 对应 Request 中的 ID
+	// Types that are assignable to Payload:
+	//	*AuthStreamResponse_Immediate
+	//	*AuthStreamResponse_ApprovalPush
+	//	*AuthStreamResponse_ProfileUpdate
+	//	*AuthStreamResponse_Pong
+	Payload isAuthStreamResponse_Payload `protobuf_oneof:"payload"`
+}
+
+func (x *AuthStreamResponse) Reset() {
+	*x = AuthStreamResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthStreamResponse) ProtoMessage() {}
+
+func (x *AuthStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthStreamResponse.ProtoReflect.Descriptor instead.
+func (*AuthStreamResponse) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AuthStreamResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (m *AuthStreamResponse) GetPayload() isAuthStreamResponse_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (x *AuthStreamResponse) GetImmediate() *ExecAuthResponse {
+	if x, ok := x.GetPayload().(*AuthStreamResponse_Immediate); ok {
+		return x.Immediate
+	}
+	return nil
+}
+
+func (x *AuthStreamResponse) GetApprovalPush() *AuthStreamApprovalPush {
+	if x, ok := x.GetPayload().(*AuthStreamResponse_ApprovalPush); ok {
+		return x.ApprovalPush
+	}
+	return nil
+}
+
+func (x *AuthStreamResponse) GetProfileUpdate() *SandboxProfile {
+	if x, ok := x.GetPayload().(*AuthStreamResponse_ProfileUpdate); ok {
+		return x.ProfileUpdate
+	}
+	return nil
+}
+
+func (x *AuthStreamResponse) GetPong() string {
+	if x, ok := x.GetPayload().(*AuthStreamResponse_Pong); ok {
+		return x.Pong
+	}
+	return ""
+}
+
+type isAuthStreamResponse_Payload interface {
+	isAuthStreamResponse_Payload()
+}
+
+type AuthStreamResponse_Immediate struct {
+	Immediate *ExecAuthResponse `protobuf:"bytes,2,opt,name=immediate,proto3,oneof"` // 立即结果 (Allow/Deny/Review_Wait)
+}
+
+type AuthStreamResponse_ApprovalPush struct {
+	ApprovalPush *AuthStreamApprovalPush `protobuf:"bytes,3,opt,name=approval_push,json=approvalPush,proto3,oneof"` // 异步推送：Review 完成后的最终结果
+}
+
+type AuthStreamResponse_ProfileUpdate struct {
+	ProfileUpdate *SandboxProfile `protobuf:"bytes,4,opt,name=profile_update,json=profileUpdate,proto3,oneof"` // 服务端推送配置更新 (如紧急封禁)，Hot Cache 应失效或更新
+}
+
+type AuthStreamResponse_Pong struct {
+	Pong string `protobuf:"bytes,5,opt,name=pong,proto3,oneof"` // 心跳响应
+}
+
+func (*AuthStreamResponse_Immediate) isAuthStreamResponse_Payload() {}
+
+func (*AuthStreamResponse_ApprovalPush) isAuthStreamResponse_Payload() {}
+
+func (*AuthStreamResponse_ProfileUpdate) isAuthStreamResponse_Payload() {}
+
+func (*AuthStreamResponse_Pong) isAuthStreamResponse_Payload() {}
+
+type AuthStreamApprovalPush struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CheqId             string   `protobuf:"bytes,1,opt,name=cheq_id,json=cheqId,proto3" json:"cheq_id,omitempty"`
+	FinalDecision      Decision `protobuf:"varint,2,opt,name=final_decision,json=finalDecision,proto3,enum=diting.v1.Decision" json:"final_decision,omitempty"`
+	Reason             string   `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	AmendedCommandLine string   `protobuf:"bytes,4,opt,name=amended_command_line,json=amendedCommandLine,proto3" json:"amended_command_line,omitempty"` // 审批人改后批准时应执行的命令行
+}
+
+func (x *AuthStreamApprovalPush) Reset() {
+	*x = AuthStreamApprovalPush{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file__3af_exec_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthStreamApprovalPush) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthStreamApprovalPush) ProtoMessage() {}
+
+func (x *AuthStreamApprovalPush) ProtoReflect() protoreflect.Message {
+	mi := &file__3af_exec_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthStreamApprovalPush.ProtoReflect.Descriptor instead.
+func (*AuthStreamApprovalPush) Descriptor() ([]byte, []int) {
+	return file__3af_exec_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *AuthStreamApprovalPush) GetCheqId() string {
+	if x != nil {
+		return x.CheqId
+	}
+	return ""
+}
+
+func (x *AuthStreamApprovalPush) GetFinalDecision() Decision {
+	if x != nil {
+		return x.FinalDecision
+	}
+	return Decision_DECISION_UNSPECIFIED
+}
+
+func (x *AuthStreamApprovalPush) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *AuthStreamApprovalPush) GetAmendedCommandLine() string {
+	if x != nil {
+		return x.AmendedCommandLine
+	}
+	return ""
+}
+
+var File__3af_exec_proto protoreflect.FileDescriptor
+
+var file__3af_exec_proto_rawDesc = []byte{
+	0x0a, 0x0e, 0x33, 0x61, 0x66, 0x5f, 0x65, 0x78, 0x65, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x09, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x22, 0xdc, 0x01, 0x0a, 0x0e,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x18,
+	0x0a, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x40, 0x0a, 0x07,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x26, 0x2e,
+	0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x1a, 0x3a,
+	0x0a, 0x0c, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x63, 0x0a, 0x08, 0x50, 0x65,
+	0x65, 0x72, 0x43, 0x72, 0x65, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x03, 0x75, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x67, 0x69, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x67, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x69,
+	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x70, 0x69, 0x64, 0x12, 0x21, 0x0a, 0x0c,
+	0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x72, 0x49, 0x64, 0x22,
+	0x72, 0x0a, 0x0c, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12,
+	0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x2d, 0x0a, 0x13, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73,
+	0x5f, 0x61, 0x74, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x73, 0x65, 0x63, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x10, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x55, 0x6e, 0x69,
+	0x78, 0x53, 0x65, 0x63, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x5f,
+	0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x61, 0x6e, 0x64, 0x62, 0x6f,
+	0x78, 0x49, 0x64, 0x22, 0xd6, 0x01, 0x0a, 0x0f, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x42,
+	0x6f, 0x75, 0x6e, 0x64, 0x61, 0x72, 0x79, 0x12, 0x27, 0x0a, 0x0f, 0x6e, 0x65, 0x74, 0x77, 0x6f,
+	0x72, 0x6b, 0x5f, 0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0e, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x45, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64,
+	0x12, 0x2a, 0x0a, 0x11, 0x66, 0x73, 0x5f, 0x77, 0x72, 0x69, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f,
+	0x70, 0x61, 0x74, 0x68, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x66, 0x73, 0x57,
+	0x72, 0x69, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x50, 0x61, 0x74, 0x68, 0x73, 0x12, 0x25, 0x0a, 0x0e,
+	0x73, 0x79, 0x73, 0x63, 0x61, 0x6c, 0x6c, 0x5f, 0x70, 0x72, 0x65, 0x73, 0x65, 0x74, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x73, 0x79, 0x73, 0x63, 0x61, 0x6c, 0x6c, 0x50, 0x72, 0x65,
+	0x73, 0x65, 0x74, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x61, 0x78, 0x5f, 0x6d, 0x65, 0x6d, 0x6f, 0x72,
+	0x79, 0x5f, 0x6d, 0x62, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6d, 0x61, 0x78, 0x4d,
+	0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4d, 0x62, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x61, 0x64, 0x6f,
+	0x6e, 0x6c, 0x79, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c,
+	0x72, 0x65, 0x61, 0x64, 0x6f, 0x6e, 0x6c, 0x79, 0x52, 0x6f, 0x6f, 0x74, 0x22, 0x77, 0x0a, 0x0e,
+	0x48, 0x6f, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1e,
+	0x0a, 0x0a, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x25,
+	0x0a, 0x0e, 0x61, 0x72, 0x67, 0x76, 0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x6c, 0x69, 0x73, 0x74,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0d, 0x61, 0x72, 0x67, 0x76, 0x41, 0x6c, 0x6c, 0x6f,
+	0x77, 0x6c, 0x69, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x0b, 0x72, 0x75, 0x6e, 0x5f, 0x61, 0x73, 0x5f,
+	0x73, 0x75, 0x64, 0x6f, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x72, 0x75, 0x6e, 0x41,
+	0x73, 0x53, 0x75, 0x64, 0x6f, 0x22, 0x7e, 0x0a, 0x11, 0x53, 0x75, 0x64, 0x6f, 0x48, 0x6f, 0x74,
+	0x43, 0x61, 0x63, 0x68, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f,
+	0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x70, 0x61, 0x74, 0x74, 0x65, 0x72, 0x6e, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x50, 0x61, 0x74, 0x74,
+	0x65, 0x72, 0x6e, 0x12, 0x1e, 0x0a, 0x0b, 0x72, 0x75, 0x6e, 0x5f, 0x61, 0x73, 0x5f, 0x75, 0x73,
+	0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x75, 0x6e, 0x41, 0x73, 0x55,
+	0x73, 0x65, 0x72, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x88, 0x01, 0x0a, 0x0e, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79,
+	0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x2b, 0x0a, 0x11, 0x65, 0x6e, 0x63, 0x72,
+	0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x10, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x50, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x2f, 0x0a, 0x14, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x5f, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x5f, 0x75,
+	0x6e, 0x69, 0x78, 0x5f, 0x73, 0x65, 0x63, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x11, 0x76,
+	0x61, 0x6c, 0x69, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x55, 0x6e, 0x69, 0x78, 0x53, 0x65, 0x63,
+	0x22, 0xdb, 0x03, 0x0a, 0x0e, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f, 0x66,
+	0x69, 0x6c, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65,
+	0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x36, 0x0a, 0x08,
+	0x62, 0x6f, 0x75, 0x6e, 0x64, 0x61, 0x72, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x61, 0x6e, 0x64, 0x62,
+	0x6f, 0x78, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x61, 0x72, 0x79, 0x52, 0x08, 0x62, 0x6f, 0x75, 0x6e,
+	0x64, 0x61, 0x72, 0x79, 0x12, 0x45, 0x0a, 0x11, 0x68, 0x6f, 0x74, 0x5f, 0x63, 0x61, 0x63, 0x68,
+	0x65, 0x5f, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x19, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x6f, 0x74, 0x43,
+	0x61, 0x63, 0x68, 0x65, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0f, 0x68, 0x6f, 0x74, 0x43,
+	0x61, 0x63, 0x68, 0x65, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x42, 0x0a, 0x0e, 0x73,
+	0x75, 0x64, 0x6f, 0x5f, 0x68, 0x6f, 0x74, 0x5f, 0x63, 0x61, 0x63, 0x68, 0x65, 0x18, 0x05, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x75, 0x64, 0x6f, 0x48, 0x6f, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x0c, 0x73, 0x75, 0x64, 0x6f, 0x48, 0x6f, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x12,
+	0x4b, 0x0a, 0x12, 0x64, 0x65, 0x67, 0x72, 0x61, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x70,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x64, 0x69,
+	0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x67, 0x72, 0x61, 0x64, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x11, 0x64, 0x65, 0x67, 0x72, 0x61,
+	0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x3c, 0x0a, 0x0d,
+	0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x0c, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x42, 0x0a, 0x0f, 0x70, 0x6f,
+	0x6c, 0x69, 0x63, 0x79, 0x5f, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x52, 0x0e,
+	0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x22, 0x5b,
+	0x0a, 0x18, 0x47, 0x65, 0x74, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f, 0x66,
+	0x69, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x72, 0x65,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x61,
+	0x67, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x50, 0x0a, 0x19, 0x47,
+	0x65, 0x74, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x33, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x66,
+	0x69, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x64, 0x69, 0x74, 0x69,
+	0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f,
+	0x66, 0x69, 0x6c, 0x65, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x22, 0xb3, 0x02,
+	0x0a, 0x0f, 0x45, 0x78, 0x65, 0x63, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x2b, 0x0a, 0x03, 0x63, 0x74, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19,
+	0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x52, 0x03, 0x63, 0x74, 0x78, 0x12, 0x21,
+	0x0a, 0x0c, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x4c, 0x69, 0x6e,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x5f, 0x64, 0x69, 0x72,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x44,
+	0x69, 0x72, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x72, 0x6f,
+	0x63, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x70, 0x61, 0x72, 0x65,
+	0x6e, 0x74, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x74, 0x72, 0x61,
+	0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61,
+	0x63, 0x65, 0x49, 0x64, 0x12, 0x35, 0x0a, 0x03, 0x65, 0x6e, 0x76, 0x18, 0x06, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x23, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x78,
+	0x65, 0x63, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x45, 0x6e,
+	0x76, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x03, 0x65, 0x6e, 0x76, 0x1a, 0x36, 0x0a, 0x08, 0x45,
+	0x6e, 0x76, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x22, 0x97, 0x03, 0x0a, 0x10, 0x45, 0x78, 0x65, 0x63, 0x41, 0x75, 0x74, 0x68,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2f, 0x0a, 0x08, 0x64, 0x65, 0x63, 0x69,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x64, 0x69, 0x74,
+	0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x63, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x52,
+	0x08, 0x64, 0x65, 0x63, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x24, 0x0a, 0x0e, 0x70, 0x6f, 0x6c,
+	0x69, 0x63, 0x79, 0x5f, 0x72, 0x75, 0x6c, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0c, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x75, 0x6c, 0x65, 0x49, 0x64, 0x12,
+	0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x17, 0x0a, 0x07, 0x63, 0x68, 0x65, 0x71, 0x5f,
+	0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x68, 0x65, 0x71, 0x49, 0x64,
+	0x12, 0x30, 0x0a, 0x14, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61, 0x6c, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x6f, 0x75, 0x74, 0x5f, 0x73, 0x65, 0x63, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x12,
+	0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61, 0x6c, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x53,
+	0x65, 0x63, 0x12, 0x55, 0x0a, 0x0e, 0x61, 0x75, 0x64, 0x69, 0x74, 0x5f, 0x6d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2e, 0x2e, 0x64, 0x69, 0x74,
+	0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x78, 0x65, 0x63, 0x41, 0x75, 0x74, 0x68, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e, 0x41, 0x75, 0x64, 0x69, 0x74, 0x4d, 0x65, 0x74,
+	0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0d, 0x61, 0x75, 0x64, 0x69,
+	0x74, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x30, 0x0a, 0x14, 0x61, 0x6d, 0x65,
+	0x6e, 0x64, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x6c, 0x69, 0x6e,
+	0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x61, 0x6d, 0x65, 0x6e, 0x64, 0x65, 0x64,
+	0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x4c, 0x69, 0x6e, 0x65, 0x1a, 0x40, 0x0a, 0x12, 0x41,
+	0x75, 0x64, 0x69, 0x74, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xed, 0x01,
+	0x0a, 0x11, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x49, 0x64, 0x12, 0x2f, 0x0a, 0x04, 0x69, 0x6e, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x19, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75, 0x74,
+	0x68, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x6e, 0x69, 0x74, 0x48, 0x00, 0x52, 0x04, 0x69,
+	0x6e, 0x69, 0x74, 0x12, 0x30, 0x0a, 0x04, 0x61, 0x75, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x78,
+	0x65, 0x63, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52,
+	0x04, 0x61, 0x75, 0x74, 0x68, 0x12, 0x14, 0x0a, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x35, 0x0a, 0x06, 0x63,
+	0x61, 0x6e, 0x63, 0x65, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x64, 0x69,
+	0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x43, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x48, 0x00, 0x52, 0x06, 0x63, 0x61, 0x6e, 0x63,
+	0x65, 0x6c, 0x42, 0x09, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x43, 0x0a,
+	0x10, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x43, 0x61, 0x6e, 0x63, 0x65,
+	0x6c, 0x12, 0x17, 0x0a, 0x07, 0x63, 0x68, 0x65, 0x71, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x63, 0x68, 0x65, 0x71, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73,
+	0x6f, 0x6e, 0x22, 0x6e, 0x0a, 0x0e, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x49, 0x6e, 0x69, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49,
+	0x64, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x23, 0x0a,
+	0x0d, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x22, 0x9f, 0x02, 0x0a, 0x12, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12, 0x3b, 0x0a, 0x09, 0x69, 0x6d, 0x6d, 0x65,
+	0x64, 0x69, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x64, 0x69,
+	0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x78, 0x65, 0x63, 0x41, 0x75, 0x74, 0x68,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x09, 0x69, 0x6d, 0x6d, 0x65,
+	0x64, 0x69, 0x61, 0x74, 0x65, 0x12, 0x48, 0x0a, 0x0d, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61,
+	0x6c, 0x5f, 0x70, 0x75, 0x73, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x64,
+	0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x41, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61, 0x6c, 0x50, 0x75, 0x73, 0x68, 0x48,
+	0x00, 0x52, 0x0c, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61, 0x6c, 0x50, 0x75, 0x73, 0x68, 0x12,
+	0x42, 0x0a, 0x0e, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x75, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f, 0x66, 0x69,
+	0x6c, 0x65, 0x48, 0x00, 0x52, 0x0d, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x04, 0x70, 0x6f, 0x6e, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x48, 0x00, 0x52, 0x04, 0x70, 0x6f, 0x6e, 0x67, 0x42, 0x09, 0x0a, 0x07, 0x70, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x22, 0xb7, 0x01, 0x0a, 0x16, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x41, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61, 0x6c, 0x50, 0x75, 0x73, 0x68, 0x12,
+	0x17, 0x0a, 0x07, 0x63, 0x68, 0x65, 0x71, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x63, 0x68, 0x65, 0x71, 0x49, 0x64, 0x12, 0x3a, 0x0a, 0x0e, 0x66, 0x69, 0x6e, 0x61,
+	0x6c, 0x5f, 0x64, 0x65, 0x63, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x13, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x63,
+	0x69, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x66, 0x69, 0x6e, 0x61, 0x6c, 0x44, 0x65, 0x63, 0x69,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x30, 0x0a, 0x14,
+	0x61, 0x6d, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f,
+	0x6c, 0x69, 0x6e, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x61, 0x6d, 0x65, 0x6e,
+	0x64, 0x65, 0x64, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x4c, 0x69, 0x6e, 0x65, 0x2a, 0x4f,
+	0x0a, 0x11, 0x44, 0x65, 0x67, 0x72, 0x61, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x6c,
+	0x69, 0x63, 0x79, 0x12, 0x1b, 0x0a, 0x17, 0x44, 0x45, 0x47, 0x52, 0x41, 0x44, 0x41, 0x54, 0x49,
+	0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00,
+	0x12, 0x0d, 0x0a, 0x09, 0x46, 0x41, 0x49, 0x4c, 0x5f, 0x4f, 0x50, 0x45, 0x4e, 0x10, 0x01, 0x12,
+	0x0e, 0x0a, 0x0a, 0x46, 0x41, 0x49, 0x4c, 0x5f, 0x43, 0x4c, 0x4f, 0x53, 0x45, 0x10, 0x02, 0x2a,
+	0x45, 0x0a, 0x08, 0x44, 0x65, 0x63, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x14, 0x44,
+	0x45, 0x43, 0x49, 0x53, 0x49, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46,
+	0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x41, 0x4c, 0x4c, 0x4f, 0x57, 0x10, 0x01,
+	0x12, 0x08, 0x0a, 0x04, 0x44, 0x45, 0x4e, 0x59, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x52, 0x45,
+	0x56, 0x49, 0x45, 0x57, 0x10, 0x03, 0x32, 0x85, 0x02, 0x0a, 0x0f, 0x45, 0x78, 0x65, 0x63, 0x41,
+	0x75, 0x74, 0x68, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x5e, 0x0a, 0x11, 0x47, 0x65,
+	0x74, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12,
+	0x23, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x53,
+	0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31,
+	0x2e, 0x47, 0x65, 0x74, 0x53, 0x61, 0x6e, 0x64, 0x62, 0x6f, 0x78, 0x50, 0x72, 0x6f, 0x66, 0x69,
+	0x6c, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x43, 0x0a, 0x08, 0x45, 0x78,
+	0x65, 0x63, 0x41, 0x75, 0x74, 0x68, 0x12, 0x1a, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e,
+	0x76, 0x31, 0x2e, 0x45, 0x78, 0x65, 0x63, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x45,
+	0x78, 0x65, 0x63, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x4d, 0x0a, 0x0a, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x1c, 0x2e,
+	0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x64, 0x69,
+	0x74, 0x69, 0x6e, 0x67, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42, 0x17,
+	0x5a, 0x15, 0x64, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3b, 0x64,
+	0x69, 0x74, 0x69, 0x6e, 0x67, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file__3af_exec_proto_rawDescOnce sync.Once
+	file__3af_exec_proto_rawDescData = file__3af_exec_proto_rawDesc
+)
+
+func file__3af_exec_proto_rawDescGZIP() []byte {
+	file__3af_exec_proto_rawDescOnce.Do(func() {
+		file__3af_exec_proto_rawDescData = protoimpl.X.CompressGZIP(file__3af_exec_proto_rawDescData)
+	})
+	return file__3af_exec_proto_rawDescData
+}
+
+var file__3af_exec_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file__3af_exec_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file__3af_exec_proto_goTypes = []any{
+	(DegradationPolicy)(0),            // 0: diting.v1.DegradationPolicy
+	(Decision)(0),                     // 1: diting.v1.Decision
+	(*RequestContext)(nil),            // 2: diting.v1.RequestContext
+	(*PeerCred)(nil),                  // 3: diting.v1.PeerCred
+	(*SessionToken)(nil),              // 4: diting.v1.SessionToken
+	(*SandboxBoundary)(nil),           // 5: diting.v1.SandboxBoundary
+	(*HotCacheAction)(nil),            // 6: diting.v1.HotCacheAction
+	(*SudoHotCacheEntry)(nil),         // 7: diting.v1.SudoHotCacheEntry
+	(*PolicySnapshot)(nil),            // 8: diting.v1.PolicySnapshot
+	(*SandboxProfile)(nil),            // 9: diting.v1.SandboxProfile
+	(*GetSandboxProfileRequest)(nil),  // 10: diting.v1.GetSandboxProfileRequest
+	(*GetSandboxProfileResponse)(nil), // 11: diting.v1.GetSandboxProfileResponse
+	(*ExecAuthRequest)(nil),           // 12: diting.v1.ExecAuthRequest
+	(*ExecAuthResponse)(nil),          // 13: diting.v1.ExecAuthResponse
+	(*AuthStreamRequest)(nil),         // 14: diting.v1.AuthStreamRequest
+	(*AuthStreamCancel)(nil),          // 15: diting.v1.AuthStreamCancel
+	(*AuthStreamInit)(nil),            // 16: diting.v1.AuthStreamInit
+	(*AuthStreamResponse)(nil),        // 17: diting.v1.AuthStreamResponse
+	(*AuthStreamApprovalPush)(nil),    // 18: diting.v1.AuthStreamApprovalPush
+	nil,                               // 19: diting.v1.RequestContext.ContextEntry
+	nil,                               // 20: diting.v1.ExecAuthRequest.EnvEntry
+	nil,                               // 21: diting.v1.ExecAuthResponse.AuditMetadataEntry
+}
+var file__3af_exec_proto_depIdxs = []int32{
+	19, // 0: diting.v1.RequestContext.context:type_name -> diting.v1.RequestContext.ContextEntry
+	5,  // 1: diting.v1.SandboxProfile.boundary:type_name -> diting.v1.SandboxBoundary
+	6,  // 2: diting.v1.SandboxProfile.hot_cache_actions:type_name -> diting.v1.HotCacheAction
+	7,  // 3: diting.v1.SandboxProfile.sudo_hot_cache:type_name -> diting.v1.SudoHotCacheEntry
+	0,  // 4: diting.v1.SandboxProfile.degradation_policy:type_name -> diting.v1.DegradationPolicy
+	4,  // 5: diting.v1.SandboxProfile.session_token:type_name -> diting.v1.SessionToken
+	8,  // 6: diting.v1.SandboxProfile.policy_snapshot:type_name -> diting.v1.PolicySnapshot
+	9,  // 7: diting.v1.GetSandboxProfileResponse.profile:type_name -> diting.v1.SandboxProfile
+	2,  // 8: diting.v1.ExecAuthRequest.ctx:type_name -> diting.v1.RequestContext
+	20, // 9: diting.v1.ExecAuthRequest.env:type_name -> diting.v1.ExecAuthRequest.EnvEntry
+	1,  // 10: diting.v1.ExecAuthResponse.decision:type_name -> diting.v1.Decision
+	21, // 11: diting.v1.ExecAuthResponse.audit_metadata:type_name -> diting.v1.ExecAuthResponse.AuditMetadataEntry
+	16, // 12: diting.v1.AuthStreamRequest.init:type_name -> diting.v1.AuthStreamInit
+	12, // 13: diting.v1.AuthStreamRequest.auth:type_name -> diting.v1.ExecAuthRequest
+	15, // 14: diting.v1.AuthStreamRequest.cancel:type_name -> diting.v1.AuthStreamCancel
+	13, // 15: diting.v1.AuthStreamResponse.immediate:type_name -> diting.v1.ExecAuthResponse
+	18, // 16: diting.v1.AuthStreamResponse.approval_push:type_name -> diting.v1.AuthStreamApprovalPush
+	9,  // 17: diting.v1.AuthStreamResponse.profile_update:type_name -> diting.v1.SandboxProfile
+	1,  // 18: diting.v1.AuthStreamApprovalPush.final_decision:type_name -> diting.v1.Decision
+	10, // 19: diting.v1.ExecAuthService.GetSandboxProfile:input_type -> diting.v1.GetSandboxProfileRequest
+	12, // 20: diting.v1.ExecAuthService.ExecAuth:input_type -> diting.v1.ExecAuthRequest
+	14, // 21: diting.v1.ExecAuthService.AuthStream:input_type -> diting.v1.AuthStreamRequest
+	11, // 22: diting.v1.ExecAuthService.GetSandboxProfile:output_type -> diting.v1.GetSandboxProfileResponse
+	13, // 23: diting.v1.ExecAuthService.ExecAuth:output_type -> diting.v1.ExecAuthResponse
+	17, // 24: diting.v1.ExecAuthService.AuthStream:output_type -> diting.v1.AuthStreamResponse
+	22, // [22:25] is the sub-list for method output_type
+	19, // [19:22] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file__3af_exec_proto_init() }
+func file__3af_exec_proto_init() {
+	if File__3af_exec_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file__3af_exec_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*RequestContext); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*PeerCred); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*SessionToken); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*SandboxBoundary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*HotCacheAction); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*SudoHotCacheEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*PolicySnapshot); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*SandboxProfile); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*GetSandboxProfileRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*GetSandboxProfileResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*ExecAuthRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*ExecAuthResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*AuthStreamRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[13].Exporter = func(v any, i int) any {
+			switch v := v.(*AuthStreamCancel); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[14].Exporter = func(v any, i int) any {
+			switch v := v.(*AuthStreamInit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[15].Exporter = func(v any, i int) any {
+			switch v := v.(*AuthStreamResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file__3af_exec_proto_msgTypes[16].Exporter = func(v any, i int) any {
+			switch v := v.(*AuthStreamApprovalPush); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file__3af_exec_proto_msgTypes[12].OneofWrappers = []any{
+		(*AuthStreamRequest_Init)(nil),
+		(*AuthStreamRequest_Auth)(nil),
+		(*AuthStreamRequest_Ping)(nil),
+		(*AuthStreamRequest_Cancel)(nil),
+	}
+	file__3af_exec_proto_msgTypes[15].OneofWrappers = []any{
+		(*AuthStreamResponse_Immediate)(nil),
+		(*AuthStreamResponse_ApprovalPush)(nil),
+		(*AuthStreamResponse_ProfileUpdate)(nil),
+		(*AuthStreamResponse_Pong)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file__3af_exec_proto_rawDesc,
+			NumEnums:      2,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file__3af_exec_proto_goTypes,
+		DependencyIndexes: file__3af_exec_proto_depIdxs,
+		EnumInfos:         file__3af_exec_proto_enumTypes,
+		MessageInfos:      file__3af_exec_proto_msgTypes,
+	}.Build()
+	File__3af_exec_proto = out.File
+	file__3af_exec_proto_rawDesc = nil
+	file__3af_exec_proto_goTypes = nil
+	file__3af_exec_proto_depIdxs = nil
+}
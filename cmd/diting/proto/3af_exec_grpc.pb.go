@@ -0,0 +1,202 @@
+// 3AF Exec API - AI Agent Audit & Firewall
+// 基础设施层契约：同机 UDS (SO_PEERCRED) + 跨机 gRPC (mTLS)
+// 方案定位：Docker/混合环境安全内核
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: 3af_exec.proto
+
+package ditingpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ExecAuthService_GetSandboxProfile_FullMethodName = "/diting.v1.ExecAuthService/GetSandboxProfile"
+	ExecAuthService_ExecAuth_FullMethodName          = "/diting.v1.ExecAuthService/ExecAuth"
+	ExecAuthService_AuthStream_FullMethodName        = "/diting.v1.ExecAuthService/AuthStream"
+)
+
+// ExecAuthServiceClient is the client API for ExecAuthService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ExecAuthServiceClient interface {
+	// 冷启动：拉取全量配置
+	GetSandboxProfile(ctx context.Context, in *GetSandboxProfileRequest, opts ...grpc.CallOption) (*GetSandboxProfileResponse, error)
+	// 简单调用：适用于 Wrapper 或短连接场景
+	ExecAuth(ctx context.Context, in *ExecAuthRequest, opts ...grpc.CallOption) (*ExecAuthResponse, error)
+	// 核心模式：长连接双向流 (支持异步审批、配置热推)
+	AuthStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AuthStreamRequest, AuthStreamResponse], error)
+}
+
+type execAuthServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExecAuthServiceClient(cc grpc.ClientConnInterface) ExecAuthServiceClient {
+	return &execAuthServiceClient{cc}
+}
+
+func (c *execAuthServiceClient) GetSandboxProfile(ctx context.Context, in *GetSandboxProfileRequest, opts ...grpc.CallOption) (*GetSandboxProfileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSandboxProfileResponse)
+	err := c.cc.Invoke(ctx, ExecAuthService_GetSandboxProfile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *execAuthServiceClient) ExecAuth(ctx context.Context, in *ExecAuthRequest, opts ...grpc.CallOption) (*ExecAuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExecAuthResponse)
+	err := c.cc.Invoke(ctx, ExecAuthService_ExecAuth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *execAuthServiceClient) AuthStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AuthStreamRequest, AuthStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ExecAuthService_ServiceDesc.Streams[0], ExecAuthService_AuthStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AuthStreamRequest, AuthStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ExecAuthService_AuthStreamClient = grpc.BidiStreamingClient[AuthStreamRequest, AuthStreamResponse]
+
+// ExecAuthServiceServer is the server API for ExecAuthService service.
+// All implementations must embed UnimplementedExecAuthServiceServer
+// for forward compatibility.
+type ExecAuthServiceServer interface {
+	// 冷启动：拉取全量配置
+	GetSandboxProfile(context.Context, *GetSandboxProfileRequest) (*GetSandboxProfileResponse, error)
+	// 简单调用：适用于 Wrapper 或短连接场景
+	ExecAuth(context.Context, *ExecAuthRequest) (*ExecAuthResponse, error)
+	// 核心模式：长连接双向流 (支持异步审批、配置热推)
+	AuthStream(grpc.BidiStreamingServer[AuthStreamRequest, AuthStreamResponse]) error
+	mustEmbedUnimplementedExecAuthServiceServer()
+}
+
+// UnimplementedExecAuthServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedExecAuthServiceServer struct{}
+
+func (UnimplementedExecAuthServiceServer) GetSandboxProfile(context.Context, *GetSandboxProfileRequest) (*GetSandboxProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSandboxProfile not implemented")
+}
+func (UnimplementedExecAuthServiceServer) ExecAuth(context.Context, *ExecAuthRequest) (*ExecAuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExecAuth not implemented")
+}
+func (UnimplementedExecAuthServiceServer) AuthStream(grpc.BidiStreamingServer[AuthStreamRequest, AuthStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method AuthStream not implemented")
+}
+func (UnimplementedExecAuthServiceServer) mustEmbedUnimplementedExecAuthServiceServer() {}
+func (UnimplementedExecAuthServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeExecAuthServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ExecAuthServiceServer will
+// result in compilation errors.
+type UnsafeExecAuthServiceServer interface {
+	mustEmbedUnimplementedExecAuthServiceServer()
+}
+
+func RegisterExecAuthServiceServer(s grpc.ServiceRegistrar, srv ExecAuthServiceServer) {
+	// If the following call pancis, it indicates UnimplementedExecAuthServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ExecAuthService_ServiceDesc, srv)
+}
+
+func _ExecAuthService_GetSandboxProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSandboxProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecAuthServiceServer).GetSandboxProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExecAuthService_GetSandboxProfile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecAuthServiceServer).GetSandboxProfile(ctx, req.(*GetSandboxProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExecAuthService_ExecAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecAuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecAuthServiceServer).ExecAuth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExecAuthService_ExecAuth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecAuthServiceServer).ExecAuth(ctx, req.(*ExecAuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExecAuthService_AuthStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ExecAuthServiceServer).AuthStream(&grpc.GenericServerStream[AuthStreamRequest, AuthStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ExecAuthService_AuthStreamServer = grpc.BidiStreamingServer[AuthStreamRequest, AuthStreamResponse]
+
+// ExecAuthService_ServiceDesc is the grpc.ServiceDesc for ExecAuthService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ExecAuthService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "diting.v1.ExecAuthService",
+	HandlerType: (*ExecAuthServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSandboxProfile",
+			Handler:    _ExecAuthService_GetSandboxProfile_Handler,
+		},
+		{
+			MethodName: "ExecAuth",
+			Handler:    _ExecAuthService_ExecAuth_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AuthStream",
+			Handler:       _ExecAuthService_AuthStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "3af_exec.proto",
+}